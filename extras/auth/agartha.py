@@ -19,6 +19,7 @@ Enable Agartha authentication.
 """
 
 from __future__ import absolute_import
+import hashlib
 import logging
 import itertools
 import sys
@@ -46,6 +47,9 @@ except ImportError:
 
 __virtualname__ = "agartha"
 
+# Personal API tokens carry this prefix; see server/model/agartha/apiToken.go.
+API_TOKEN_PREFIX = "agartha_pat_"
+
 
 def __virtual__():
     if HAS_MYSQL or HAS_POSTGRES:
@@ -765,13 +769,30 @@ def auth(username, password):
     log.info("Authenticating %s", username)
     if str(password):
         with _get_serv() as cur:
-            sql = """
-                SELECT a.is_active, a.is_superuser 
-                FROM user_settings c 
-                INNER JOIN auth_user a ON c.user_id = a.id 
-                WHERE a.username = %s AND a.is_active 
-                AND c.token = crypt(%s, c.token);
-            """
+            token = str(password)
+            if token.startswith("Bearer "):
+                token = token[len("Bearer ") :]
+            if token.startswith(API_TOKEN_PREFIX):
+                # Personal API tokens are stored as a SHA-256 digest. Read-only
+                # tokens never receive a Salt token.
+                sql = """
+                    SELECT a.is_active, a.is_superuser
+                    FROM api_token t
+                    INNER JOIN auth_user a ON t.user_id = a.id
+                    WHERE a.username = %s AND a.is_active
+                    AND t.token_hash = %s AND NOT t.read_only
+                    AND t.revoked_at IS NULL
+                    AND (t.expires_at IS NULL OR t.expires_at > now());
+                """
+                password = hashlib.sha256(token.encode("utf-8")).hexdigest()
+            else:
+                sql = """
+                    SELECT a.is_active, a.is_superuser 
+                    FROM user_settings c 
+                    INNER JOIN auth_user a ON c.user_id = a.id 
+                    WHERE a.username = %s AND a.is_active 
+                    AND c.token = crypt(%s, c.token);
+                """
             cur.execute(sql, (username, password))
 
             result = cur.fetchone()
@@ -60,13 +60,15 @@ func BuildJSONPathSelect(jsonPaths []string, column string) string {
 	return fmt.Sprintf("jsonb_build_object(%s) AS %s", strings.Join(queryParts, ", "), column)
 }
 
-func extractJSONPathDetails(path string) (string, string) {
-	// Regular expression to match keys, including those with special characters and array indices
-	re := regexp.MustCompile(`(?:^|\.)("([^"]*)"|([^.\[\]]+)|(\[\d+\]))`)
-	matches := re.FindAllStringSubmatch(path, -1)
-	var keys []string
+// jsonPathSegment matches one key of a path, including quoted keys with
+// special characters and array indices.
+var jsonPathSegment = regexp.MustCompile(`(?:^|\.)("([^"]*)"|([^.\[\]]+)|(\[\d+\]))`)
 
-	for _, match := range matches {
+// jsonPathSegments splits a path into its keys, unquoting quoted keys and
+// keeping array indices in brackets.
+func jsonPathSegments(path string) []string {
+	var keys []string
+	for _, match := range jsonPathSegment.FindAllStringSubmatch(path, -1) {
 		if match[2] != "" {
 			keys = append(keys, match[2])
 		} else if match[3] != "" {
@@ -75,6 +77,26 @@ func extractJSONPathDetails(path string) (string, string) {
 			keys = append(keys, match[4])
 		}
 	}
+	return keys
+}
+
+// SelectKeys returns the key naming a path given to BuildJSONPathSelect in
+// the object it builds, and the keys of the path with array indices as plain
+// numbers.
+func SelectKeys(path string) (string, []string) {
+	keys := jsonPathSegments(path)
+	if len(keys) == 0 {
+		return "", nil
+	}
+	name := keys[len(keys)-1]
+	for i, key := range keys {
+		keys[i] = strings.TrimSuffix(strings.TrimPrefix(key, "["), "]")
+	}
+	return name, keys
+}
+
+func extractJSONPathDetails(path string) (string, string) {
+	keys := jsonPathSegments(path)
 
 	key := keys[len(keys)-1]
 	// Quote keys that need to be quoted in JSON path
@@ -1,6 +1,7 @@
 package jsonPathFilter
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -51,3 +52,10 @@ func TestBuildJSONPathSelect(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectKeys(t *testing.T) {
+	key, keys := SelectKeys(`mysql."root-user".hosts.[0]`)
+	if key != "[0]" || !reflect.DeepEqual(keys, []string{"mysql", "root-user", "hosts", "0"}) {
+		t.Errorf("SelectKeys() = %v, %v", key, keys)
+	}
+}
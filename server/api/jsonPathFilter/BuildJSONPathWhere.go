@@ -82,6 +82,17 @@ func BuildJSONPathWhere(jsonPathFilters []string, column string) (string, error)
 	return strings.Join(expressions, " AND "), nil
 }
 
+// FilterKeys returns the keys of the path a filter given to
+// BuildJSONPathWhere compares, and whether it compares with a LIKE pattern.
+func FilterKeys(filter string) (keys []string, pattern bool, err error) {
+	keyPart, _, _, operator, err := parseFilterParts(filter)
+	if err != nil {
+		return nil, false, err
+	}
+	keys, err = splitKeys(keyPart)
+	return keys, operator == "like" || operator == "not_like", err
+}
+
 func parseFilterParts(filter string) (keyPart string, value string, typ string, operator string, err error) {
 	parts := strings.Split(filter, "::")
 	if len(parts) < 2 {
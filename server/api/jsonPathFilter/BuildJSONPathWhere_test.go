@@ -1,6 +1,7 @@
 package jsonPathFilter
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -132,3 +133,14 @@ func TestBuildJSONPathWhere(t *testing.T) {
 		})
 	}
 }
+
+func TestFilterKeys(t *testing.T) {
+	keys, pattern, err := FilterKeys("mysql.root.password:hunter%::string::like")
+	if err != nil || !pattern || !reflect.DeepEqual(keys, []string{"mysql", "root", "password"}) {
+		t.Errorf("FilterKeys() = %v, %v, %v", keys, pattern, err)
+	}
+	keys, pattern, err = FilterKeys("os:Debian::string")
+	if err != nil || pattern || !reflect.DeepEqual(keys, []string{"os"}) {
+		t.Errorf("FilterKeys() = %v, %v, %v", keys, pattern, err)
+	}
+}
@@ -0,0 +1,33 @@
+package jsonPathFilter
+
+import (
+	"github.com/PaulChristophel/agartha/server/redact"
+	"github.com/gin-gonic/gin"
+)
+
+// RedactionSelections describes the values BuildJSONPathSelect copies out of
+// a column, so that redact.SubsetResponse can match path rules against where
+// each value came from.
+func RedactionSelections(jsonPaths []string) []redact.Selection {
+	selections := make([]redact.Selection, 0, len(jsonPaths))
+	for _, path := range jsonPaths {
+		key, keys := SelectKeys(path)
+		if key != "" {
+			selections = append(selections, redact.Selection{Key: key, Path: keys})
+		}
+	}
+	return selections
+}
+
+// HidesRedactedData reports whether any of the filters given to
+// BuildJSONPathWhere for column compares data that is redacted for the
+// caller. Filters that fail to parse are left to BuildJSONPathWhere.
+func HidesRedactedData(c *gin.Context, jsonPathFilters []string, column string) bool {
+	for _, filter := range jsonPathFilters {
+		keys, pattern, err := FilterKeys(filter)
+		if err == nil && redact.HidesFilter(c, column, keys, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -34,7 +35,7 @@ func GetConformity(c *gin.Context) {
 	id := c.Param("id")
 
 	// find all jids in the database
-	if err := db.Where("id = ?", id).Find(&conformity).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, "id")).Where("id = ?", id).Find(&conformity).Error; err != nil {
 		log.Error("Failed to fetch conformity data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch conformity data.")
 		return
@@ -13,6 +13,7 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	matModel "github.com/PaulChristophel/agartha/server/model/salt/materializedView"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -70,7 +71,7 @@ func GetConformities(c *gin.Context) {
 	}
 
 	// Initialize base query to use throughout
-	filterQuery := db.Model(&matModel.Conformity{})
+	filterQuery := db.Model(&matModel.Conformity{}).Scopes(middleware.VisibleMinions(c, "id"))
 
 	if id != "" {
 		if strings.Contains(id, "*") {
@@ -3,21 +3,30 @@ package conformity
 import (
 	get "github.com/PaulChristophel/agartha/server/api/v1/conformity/get"
 	post "github.com/PaulChristophel/agartha/server/api/v1/conformity/post"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 var (
 	isRefreshing bool = false
 )
 
-func AddRoutes(rg *gin.RouterGroup) {
+// AddRoutes registers the conformity routes. Reads are open to holders of
+// view-returns and refreshes to holders of refresh-views; everyone else needs
+// Salt permissions.
+func AddRoutes(rg *gin.RouterGroup, database *gorm.DB) {
 	grp := rg.Group("/conformity")
+	salt := middleware.SaltPermissionForMethodRequired(database)
+	view := middleware.CapabilityRequired(database, model.CapabilityViewReturns, salt)
+	refresh := middleware.CapabilityRequired(database, model.CapabilityRefreshViews, salt)
 
 	post.SetOptions(&isRefreshing)
 	get.SetOptions(&isRefreshing)
 
-	grp.GET("/", get.GetConformities)
-	grp.POST("/refresh", post.Refresh)
-	grp.GET("/refresh", get.Refresh)
-	grp.GET("/:id", get.GetConformity)
+	grp.GET("/", view, get.GetConformities)
+	grp.POST("/refresh", refresh, post.Refresh)
+	grp.GET("/refresh", refresh, get.Refresh)
+	grp.GET("/:id", view, get.GetConformity)
 }
@@ -6,7 +6,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -35,7 +37,7 @@ func GetHighState(c *gin.Context) {
 	log.Debug("Received request to get high state", zap.String("id", id))
 
 	// find all HighStates in the database with the specified id
-	if err := db.Where("id = ?", id).Find(&highStates).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, "id")).Where("id = ?", id).Find(&highStates).Error; err != nil {
 		log.Error("Failed to fetch high state data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch high state data.")
 		return
@@ -49,6 +51,7 @@ func GetHighState(c *gin.Context) {
 	}
 
 	log.Debug("Successfully retrieved high state", zap.String("id", id), zap.Int("record_count", len(highStates)))
+	redact.Response(c, &highStates[0])
 	// Else return HighStates
 	c.JSON(http.StatusOK, highStates[0])
 }
@@ -13,7 +13,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -86,7 +88,7 @@ func GetHighStates(c *gin.Context) {
 	}
 
 	// Construct the base query with filters
-	filterQuery := db.Select(selection).Model(&model.HighState{})
+	filterQuery := db.Select(selection).Model(&model.HighState{}).Scopes(middleware.VisibleMinions(c, "id"))
 
 	if id != "" {
 		if strings.Contains(id, "*") {
@@ -197,6 +199,7 @@ func GetHighStates(c *gin.Context) {
 		zap.Int("result_count", len(highStates)),
 		zap.Int64("total_count", totalCount))
 
+	redact.Response(c, &response)
 	// Return the paginated high states
 	c.JSON(http.StatusOK, response)
 }
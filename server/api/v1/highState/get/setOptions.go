@@ -0,0 +1,9 @@
+package highState
+
+import "github.com/PaulChristophel/agartha/server/config"
+
+var returnsTable string
+
+func SetOptions(saltTables config.SaltDBTables) {
+	returnsTable = saltTables.SaltReturns
+}
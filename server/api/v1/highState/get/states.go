@@ -0,0 +1,242 @@
+package highState
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/api/validate"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/dto"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// stateFuns are the functions whose returns are keyed by state.
+var stateFuns = []string{"state.highstate", "state.apply", "state.sls"}
+
+// likePattern turns the * and ? wildcards of the text filters into a LIKE
+// pattern, escaping the characters LIKE would otherwise treat as wildcards.
+var likePattern = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_")
+
+// GetHighStateStates retrieves paginated states from highstate returns based on the provided query parameters.
+//
+//	@Summary		Get states from highstate returns (paginated).
+//	@Description	Get the states of the most recent highstate of each minion as one row per state, so failing states can be found across the fleet without downloading whole returns. When jid is set, the states of that job's returns are listed instead, whether or not it is the most recent run. Duration is in milliseconds.
+//	@Tags			HighState
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dto.HighStateStatePageResponse
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@Router			/api/v1/high_state/states [get]
+//	@Param			id			query	string	false	"Filter by minion id (Supports wildcards * and ? for single char matches.)"
+//	@Param			jid			query	string	false	"List the states of this job instead of the most recent highstate of each minion"
+//	@Param			state_id	query	string	false	"Filter by state id (Supports wildcards * and ?)"
+//	@Param			fun			query	string	false	"Filter by state module and function, e.g. service.running (Supports wildcards * and ?)"
+//	@Param			name		query	string	false	"Filter by state name (Supports wildcards * and ?)"
+//	@Param			sls			query	string	false	"Filter by SLS file (Supports wildcards * and ?)"
+//	@Param			result		query	string	false	"Filter by result: true, false or null (a test run that would make changes)"
+//	@Param			has_changes	query	boolean	false	"Filter by whether the state made changes"
+//	@Param			since		query	string	false	"Filter items from this date (RFC3339 format)."
+//	@Param			until		query	string	false	"Filter items up to this date (RFC3339 format)."
+//	@Param			per_page	query	int		false	"Number of items per page"
+//	@Param			page		query	int		false	"Page number of results to retrieve"
+//	@Param			order_by	query	string	false	"Order by column(s). Comma separated list of columns to order by (e.g. duration desc,id). Defaults to id,run_num"
+//	@Security		Bearer
+func GetHighStateStates(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+	var states []model.HighStateState
+
+	jid := c.Query("jid")
+	since := c.Query("since")
+	until := c.Query("until")
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("per_page", "50"))
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	// A job's returns are flattened directly, since the view only holds the
+	// most recent highstate of each minion.
+	filterQuery := db.Model(&model.HighStateState{})
+	if jid != "" {
+		returns := db.Table(returnsTable+" AS r").
+			Select("r.id", "r.jid", "r.alter_time", "states.*").
+			Joins("CROSS JOIN LATERAL salt_highstate_states(r.return::jsonb) AS states").
+			Where("r.jid = ?", jid).
+			Where("r.fun IN ?", stateFuns).
+			Where(`POSITION($nul$\u0000$nul$ IN r.return::text) = 0`)
+		filterQuery = db.Table("(?) AS vw_salt_highstate_states", returns)
+	}
+	filterQuery = filterQuery.Scopes(middleware.VisibleMinions(c, "id"))
+
+	for _, column := range []string{"id", "state_id", "fun", "name", "sls"} {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "*?") {
+			filterQuery = filterQuery.Where(column+` LIKE ? ESCAPE '\'`, likePattern.Replace(value))
+		} else {
+			filterQuery = filterQuery.Where(column+" = ?", value)
+		}
+	}
+
+	switch result := c.Query("result"); result {
+	case "":
+	case "null":
+		filterQuery = filterQuery.Where("result IS NULL")
+	default:
+		boolResult, err := strconv.ParseBool(result)
+		if err != nil {
+			log.Error("Invalid 'result' value", zap.String("result", result), zap.Error(err))
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'result' value, must be true, false or null")
+			return
+		}
+		filterQuery = filterQuery.Where("result = ?", boolResult)
+	}
+
+	if hasChanges := c.Query("has_changes"); hasChanges != "" {
+		boolHasChanges, err := strconv.ParseBool(hasChanges)
+		if err != nil {
+			log.Error("Invalid 'has_changes' value", zap.String("has_changes", hasChanges), zap.Error(err))
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'has_changes' value")
+			return
+		}
+		filterQuery = filterQuery.Where("has_changes = ?", boolHasChanges)
+	}
+
+	if since != "" {
+		fromTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			log.Error("Invalid 'since' date format", zap.String("since", since), zap.Error(err))
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'since' date format")
+			return
+		}
+		filterQuery = filterQuery.Where("alter_time >= ?", fromTime)
+	}
+	if until != "" {
+		toTime, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			log.Error("Invalid 'until' date format", zap.String("until", until), zap.Error(err))
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'until' date format")
+			return
+		}
+		filterQuery = filterQuery.Where("alter_time <= ?", toTime)
+	}
+
+	// List of valid columns that can be sorted
+	var validColumns = []string{
+		"id",
+		"jid",
+		"alter_time",
+		"state_id",
+		"fun",
+		"name",
+		"sls",
+		"run_num",
+		"result",
+		"duration",
+		"start_time",
+		"has_changes",
+	}
+	orderBy := c.DefaultQuery("order_by", "id,run_num")
+	validatedOrderBy, err := validate.OrderBy(orderBy, validColumns, "", []string{})
+	if err != nil {
+		log.Error("Invalid order_by parameter", zap.String("order_by", orderBy), zap.Error(err))
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// First, get the total count for pagination metadata
+	var totalCount int64
+	if err := filterQuery.Count(&totalCount).Error; err != nil {
+		log.Error("Failed to count high state states", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch high state states.")
+		return
+	}
+
+	if validatedOrderBy != "" {
+		filterQuery = filterQuery.Order(validatedOrderBy)
+	}
+
+	// Apply pagination to the query for fetching results
+	if err := filterQuery.Offset((page - 1) * limit).Limit(limit).Find(&states).Error; err != nil {
+		log.Error("Failed to fetch high state states", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch high state states.")
+		return
+	}
+
+	// Construct pagination URLs
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, c.Request.URL.Path)
+
+	// The links keep the active filters and ordering.
+	pageURL := func(page int) string {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(limit))
+		return baseURL + "?" + query.Encode()
+	}
+
+	var nextPage, previousPage string
+	if page > 1 {
+		previousPage = pageURL(page - 1)
+	}
+	if int64((page-1)*limit+len(states)) < totalCount {
+		nextPage = pageURL(page + 1)
+	}
+
+	response := dto.HighStateStatePageResponse{
+		Results: states,
+		Paging: dto.PageResponse{
+			PerPage:  int64(limit),
+			NumPages: int64(math.Ceil(float64(totalCount) / float64(limit))),
+			Count:    totalCount,
+			Next:     nextPage,
+			Previous: previousPage,
+		},
+	}
+
+	// If no results are present, return an error
+	if len(states) == 0 {
+		log.Debug("No high state states present", zap.String("jid", jid), zap.String("since", since), zap.String("until", until))
+		httputil.NewError(c, http.StatusNotFound, "No high state states present.")
+		return
+	}
+
+	log.Debug("Successfully retrieved high state states",
+		zap.String("jid", jid),
+		zap.Int("page", page),
+		zap.Int("per_page", limit),
+		zap.Int("result_count", len(states)),
+		zap.Int64("total_count", totalCount))
+
+	// Names and comments are copied out of the returns, so they are masked
+	// as they would be in the return they came from.
+	fields := make([]redact.Field, 0, 2*len(states))
+	for i := range states {
+		state := &response.Results[i]
+		fields = append(fields,
+			redact.Field{Value: &state.Name, Path: []string{"return", state.StateKey, "name"}},
+			redact.Field{Value: state.Comment, Path: []string{"return", state.StateKey, "comment"}})
+	}
+	redact.Fields(c, fields)
+	c.JSON(http.StatusOK, response)
+}
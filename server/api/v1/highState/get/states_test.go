@@ -0,0 +1,169 @@
+package highState
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/redact"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+var stateColumns = []string{"id", "jid", "alter_time", "state_key", "state_id", "fun", "name", "sls", "run_num", "result", "comment", "duration", "start_time", "has_changes"}
+
+func TestGetHighStateStatesAcrossFleet(t *testing.T) {
+	mock := installMockDatabase(t)
+	ran := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const where = `WHERE state_id = $1 AND result = $2`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vw_salt_highstate_states" `+where)).
+		WithArgs("nginx_service", false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vw_salt_highstate_states" `+where+` ORDER BY duration desc, id asc LIMIT $3`)).
+		WithArgs("nginx_service", false, 50).
+		WillReturnRows(sqlmock.NewRows(stateColumns).
+			AddRow("web01", "20240501120000000000", ran, "service_|-nginx_service_|-nginx_|-running", "nginx_service", "service.running", "nginx", "web.nginx", 1, false, "Service nginx failed to start", 15.5, "12:00:01.000000", false))
+
+	response := serveStates("/high_state/states?state_id=nginx_service&result=false&order_by=duration+desc,id")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `{
+		"paging": {"per_page": 50, "num_pages": 1, "count": 1, "next": "", "previous": ""},
+		"results": [{
+			"id": "web01",
+			"jid": "20240501120000000000",
+			"alter_time": "2024-05-01T12:00:00Z",
+			"state_key": "service_|-nginx_service_|-nginx_|-running",
+			"state_id": "nginx_service",
+			"fun": "service.running",
+			"name": "nginx",
+			"sls": "web.nginx",
+			"run_num": 1,
+			"result": false,
+			"comment": "Service nginx failed to start",
+			"duration": 15.5,
+			"start_time": "12:00:01.000000",
+			"has_changes": false
+		}]
+	}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetHighStateStatesForJob(t *testing.T) {
+	mock := installMockDatabase(t)
+	returnsTable = "salt_returns"
+	const from = `FROM (SELECT r.id,r.jid,r.alter_time,states.* FROM salt_returns AS r CROSS JOIN LATERAL salt_highstate_states(r.return::jsonb) AS states WHERE r.jid = $1 AND r.fun IN ($2,$3,$4) AND POSITION($nul$\u0000$nul$ IN r.return::text) = 0) AS vw_salt_highstate_states WHERE has_changes = $5`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) `+from)).
+		WithArgs("20240501120000000000", "state.highstate", "state.apply", "state.sls", true).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * `+from+` ORDER BY id asc, run_num asc LIMIT $6`)).
+		WithArgs("20240501120000000000", "state.highstate", "state.apply", "state.sls", true, 50).
+		WillReturnRows(sqlmock.NewRows(stateColumns))
+
+	response := serveStates("/high_state/states?jid=20240501120000000000&has_changes=true")
+
+	require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetHighStateStatesMatchesWildcardCharactersLiterally(t *testing.T) {
+	mock := installMockDatabase(t)
+	ran := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	const where = `WHERE name LIKE $1 ESCAPE '\' AND result = $2`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vw_salt_highstate_states" `+where)).
+		WithArgs(`/srv/100\%\_%`, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vw_salt_highstate_states" `+where+` ORDER BY id asc, run_num asc LIMIT $3 OFFSET $4`)).
+		WithArgs(`/srv/100\%\_%`, false, 1, 1).
+		WillReturnRows(sqlmock.NewRows(stateColumns).
+			AddRow("web01", "20240501120000000000", ran, "file_|-cfg_|-/srv/100%_a_|-managed", "cfg", "file.managed", "/srv/100%_a", nil, 2, false, nil, nil, nil, false))
+
+	response := serveStates("/high_state/states?name=/srv/100%25_*&result=false&page=2&per_page=1")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.Contains(t, response.Body.String(), `"next":"http://example.com/high_state/states?name=%2Fsrv%2F100%25_%2A\u0026page=3\u0026per_page=1\u0026result=false"`)
+	require.Contains(t, response.Body.String(), `"previous":"http://example.com/high_state/states?name=%2Fsrv%2F100%25_%2A\u0026page=1\u0026per_page=1\u0026result=false"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetHighStateStatesRedactsNamesAndComments(t *testing.T) {
+	mock := installMockDatabase(t)
+	require.NoError(t, redact.Configure(config.RedactionOptions{Paths: []string{"return.user_*.name"}, Values: []string{`password=\S+`}, Mask: "***"}))
+	t.Cleanup(func() {
+		require.NoError(t, redact.Configure(config.RedactionOptions{}))
+	})
+	ran := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "vw_salt_highstate_states"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "vw_salt_highstate_states" ORDER BY id asc, run_num asc LIMIT $1`)).
+		WithArgs(50).
+		WillReturnRows(sqlmock.NewRows(stateColumns).
+			AddRow("web01", "20240501120000000000", ran, "user_|-deploy_|-deploy_|-present", "deploy", "user.present", "deploy", nil, 1, true, "User deploy is present", nil, nil, false).
+			AddRow("web01", "20240501120000000000", ran, "cmd_|-seed_|-seed_|-run", "seed", "cmd.run", "seed", nil, 2, false, "Command failed: password=hunter2", nil, nil, false))
+
+	response := serveStates("/high_state/states")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.Equal(t, "true", response.Header().Get(redact.Header))
+	body := response.Body.String()
+	require.Contains(t, body, `"name":"***"`)
+	require.Contains(t, body, `"comment":"User deploy is present"`)
+	require.Contains(t, body, `"name":"seed"`)
+	require.Contains(t, body, `"comment":"***"`)
+	require.NotContains(t, body, "hunter2")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetHighStateStatesRejectsInvalidFilters(t *testing.T) {
+	for _, query := range []string{"result=maybe", "has_changes=sometimes", "order_by=comment"} {
+		t.Run(query, func(t *testing.T) {
+			installMockDatabase(t)
+			response := serveStates("/high_state/states?" + query)
+			require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+		})
+	}
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveStates(target string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/high_state/states", GetHighStateStates)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, target, nil))
+	return response
+}
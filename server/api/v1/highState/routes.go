@@ -2,6 +2,7 @@ package highState
 
 import (
 	get "github.com/PaulChristophel/agartha/server/api/v1/highState/get"
+	"github.com/PaulChristophel/agartha/server/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -9,5 +10,10 @@ func AddRoutes(rg *gin.RouterGroup) {
 	grp := rg.Group("/high_state")
 
 	grp.GET("", get.GetHighStates)
+	grp.GET("/states", get.GetHighStateStates)
 	grp.GET("/:id", get.GetHighState)
 }
+
+func SetOptions(saltTables config.SaltDBTables) {
+	get.SetOptions(saltTables)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -36,7 +37,7 @@ func GetJID(c *gin.Context) {
 	log.Debug("Received request to get jid", zap.String("jid", id))
 
 	// find all HighStates in the database with the specified id
-	if err := db.Where("jid = ?", id).Find(&jids).Error; err != nil {
+	if err := db.Where("jid = ?", id).Scopes(visibleJobs(c)).Find(&jids).Error; err != nil {
 		log.Error("Failed to fetch jid data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch jid data.")
 		return
@@ -50,6 +51,8 @@ func GetJID(c *gin.Context) {
 	}
 
 	log.Debug("Successfully retrieved jid", zap.String("jid", id))
+	hideLoadMinions(c, &jids)
+	redact.Response(c, &jids)
 	// Return the jid with the Id
 	c.JSON(http.StatusOK, jids)
 }
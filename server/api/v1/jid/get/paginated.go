@@ -14,11 +14,17 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	agartha "github.com/PaulChristophel/agartha/server/model/agartha"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+// likePattern turns the * and ? wildcards of the fun, user and tgt filters
+// into a LIKE pattern, escaping the characters LIKE itself treats specially.
+var likePattern = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_")
+
 // GetJIDs retrieves paginated jids based on the provided limit and page query parameters.
 //
 //	@Summary		Get Job data about all jobs (paginated).
@@ -34,6 +40,10 @@ import (
 //	@router			/api/v1/jid [get]
 //	@Param			jid			query	string	false	"Filter based on JIDs starting with input string"
 //	@Param			load_load	query	bool	false	"Load the Load field. This defaults to false for performance reasons"
+//	@Param			fun			query	string	false	"Filter by function. * and ? match any characters and one character"
+//	@Param			user		query	string	false	"Filter by the user who ran the job. * and ? match any characters and one character"
+//	@Param			tgt			query	string	false	"Filter by target. List targets are joined with commas. * and ? match any characters and one character"
+//	@Param			state		query	string	false	"Filter by job state. Comma separated list of pending, running, complete, partial and timed-out"
 //	@Param			since		query	string	false	"Filter items from this date (RFC3339 format)."
 //	@Param			until		query	string	false	"Filter items up to this date (RFC3339 format)."
 //	@Param			page		query	int		false	"Page number of results to retrieve"
@@ -66,7 +76,7 @@ func GetJIDs(c *gin.Context) {
 	}
 
 	// Initialize base query to use throughout
-	baseQuery := db.Select(selection).Model(&model.JID{})
+	baseQuery := db.Select(selection).Model(&model.JID{}).Scopes(visibleJobs(c))
 
 	if filter != "" {
 		if strings.Contains(filter, "*") {
@@ -78,6 +88,41 @@ func GetJIDs(c *gin.Context) {
 		}
 	}
 
+	// fun, user and tgt are matched against the metadata extracted from each
+	// load into jid_metadata, so the loads themselves are never read.
+	var conditions []string
+	var values []any
+	for _, column := range []string{"fun", "user", "tgt"} {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		if strings.ContainsAny(value, "*?") {
+			conditions = append(conditions, fmt.Sprintf(`%q LIKE ? ESCAPE '\'`, column))
+			values = append(values, likePattern.Replace(value))
+		} else {
+			conditions = append(conditions, fmt.Sprintf(`%q = ?`, column))
+			values = append(values, value)
+		}
+	}
+	if len(conditions) > 0 {
+		baseQuery = baseQuery.Where("jid IN (SELECT jid FROM jid_metadata WHERE "+strings.Join(conditions, " AND ")+")", values...)
+	}
+
+	if state := c.Query("state"); state != "" {
+		var states []agartha.JobStatus
+		for _, value := range strings.Split(state, ",") {
+			status := agartha.JobStatus(strings.TrimSpace(value))
+			if !status.Valid() {
+				log.Debug("Invalid state filter", zap.String("state", state))
+				httputil.NewError(c, http.StatusBadRequest, fmt.Sprintf("invalid state %q", status))
+				return
+			}
+			states = append(states, status)
+		}
+		baseQuery = baseQuery.Where("jid IN (SELECT jid FROM job_state WHERE state IN ?)", states)
+	}
+
 	// Read and validate 'since' and 'until' query parameters
 	since := c.Query("since")
 	until := c.Query("until")
@@ -188,6 +233,10 @@ func GetJIDs(c *gin.Context) {
 		zap.Int("result_count", len(jids)),
 		zap.Int64("total_count", totalCount))
 
+	for i := range response.Results {
+		hideLoadMinions(c, &response.Results[i])
+	}
+	redact.Response(c, &response)
 	// Return the paginated jids
 	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,145 @@
+package jid
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	agartha "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJIDsFiltersByMetadataAndState(t *testing.T) {
+	mock := installMockDatabase(t)
+	table = "jids"
+	since := time.Date(2024, 4, 24, 0, 0, 0, 0, time.UTC)
+	const where = `WHERE (jid IN (SELECT jid FROM jid_metadata WHERE "fun" LIKE $1 ESCAPE '\' AND "user" = $2)) AND jid IN (SELECT jid FROM job_state WHERE state IN ($3,$4)) AND alter_time >= $5`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "jids" `+where)).
+		WithArgs("state.%", "alice", "partial", "timed-out", since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "jid","alter_time" FROM "jids" `+where+` ORDER BY alter_time desc LIMIT $6`)).
+		WithArgs("state.%", "alice", "partial", "timed-out", since, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "alter_time"}).AddRow("20240501120000000000", since))
+
+	router := gin.New()
+	router.GET("/jid", GetJIDs)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/jid?fun=state.*&user=alice&state=partial,timed-out&since=2024-04-24T00:00:00Z", nil))
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJIDsMatchesWildcardCharactersLiterally(t *testing.T) {
+	mock := installMockDatabase(t)
+	table = "jids"
+	since := time.Date(2024, 4, 24, 0, 0, 0, 0, time.UTC)
+	const where = `WHERE (jid IN (SELECT jid FROM jid_metadata WHERE "user" LIKE $1 ESCAPE '\' AND "tgt" LIKE $2 ESCAPE '\')) AND alter_time >= $3`
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "jids" `+where)).
+		WithArgs(`svc\_deploy%`, `web\%_`, since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "jid","alter_time" FROM "jids" `+where+` ORDER BY alter_time desc LIMIT $4`)).
+		WithArgs(`svc\_deploy%`, `web\%_`, since, 50).
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "alter_time"}))
+
+	router := gin.New()
+	router.GET("/jid", GetJIDs)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/jid?user=svc_deploy*&tgt=web%25%3F&since=2024-04-24T00:00:00Z", nil))
+
+	require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJIDsRejectsUnknownState(t *testing.T) {
+	installMockDatabase(t)
+	table = "jids"
+
+	router := gin.New()
+	router.GET("/jid", GetJIDs)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/jid?state=finished", nil))
+
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestJobsOnlyShowTheVisibleMinions(t *testing.T) {
+	since := time.Date(2024, 4, 24, 0, 0, 0, 0, time.UTC)
+	serve := func(path string, handler gin.HandlerFunc, url string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET(path, func(c *gin.Context) {
+			c.Set("auth_user", agartha.AuthUser{ID: 7})
+		}, middleware.SaltPermissionRequired(db.DB, middleware.ReadSaltData), handler)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, url, nil))
+		return response
+	}
+	expectPermissions := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings"`)).
+			WithArgs(uint(7), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[{"web*": ["test.*"]}]`))
+	}
+
+	t.Run("list", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		table, returnsTable = "jids", "salt_returns"
+		expectPermissions(mock)
+		where := `WHERE alter_time >= $1 AND ` + visibleJobsWhere(2)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "jids" `+where)).
+			WithArgs(since, "^web.*$", "^web.*$").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "jid","alter_time" FROM "jids" `+where+` ORDER BY alter_time desc LIMIT $4`)).
+			WithArgs(since, "^web.*$", "^web.*$", 50).
+			WillReturnRows(sqlmock.NewRows([]string{"jid", "alter_time"}).AddRow("20240501120000000000", since))
+
+		response := serve("/jid", GetJIDs, "/jid?since=2024-04-24T00:00:00Z")
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("job of another minion", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		table, returnsTable = "jids", "salt_returns"
+		expectPermissions(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jids" WHERE jid = $1 AND `+visibleJobsWhere(2))).
+			WithArgs("20240501120000000000", "^web.*$", "^web.*$").
+			WillReturnRows(sqlmock.NewRows([]string{"jid", "load", "alter_time"}))
+
+		response := serve("/jid/:jid", GetJID, "/jid/20240501120000000000")
+
+		require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("job shared with other minions", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		table, returnsTable = "jids", "salt_returns"
+		expectPermissions(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jids" WHERE jid = $1 AND `+visibleJobsWhere(2))).
+			WithArgs("20240501120000000000", "^web.*$", "^web.*$").
+			WillReturnRows(sqlmock.NewRows([]string{"jid", "load", "alter_time"}).
+				AddRow("20240501120000000000", `{"fun": "test.ping", "minions": ["db1", "web1", "vault1"]}`, since))
+
+		response := serve("/jid/:jid", GetJID, "/jid/20240501120000000000")
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		require.JSONEq(t, `{"jid": "20240501120000000000", "load": {"fun": "test.ping", "minions": ["web1"]}, "alter_time": "2024-04-24T00:00:00Z"}`, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+// visibleJobsWhere is the condition visibleJobs adds for a caller allowed to
+// read web*, with its bind parameters numbered from first.
+func visibleJobsWhere(first int) string {
+	return fmt.Sprintf(`(jid IN (SELECT jid FROM "salt_returns" WHERE (id ~ $%d)) OR EXISTS (SELECT 1 FROM %s WHERE (minion.id ~ $%d)))`, first, loadMinions, first+1)
+}
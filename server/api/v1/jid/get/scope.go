@@ -0,0 +1,52 @@
+package jid
+
+import (
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadMinions lists the minions a job load targeted as rows of minion(id).
+// Loads without a minions list yield no rows.
+const loadMinions = `jsonb_array_elements_text(CASE WHEN jsonb_typeof(load::jsonb -> 'minions') = 'array' THEN load::jsonb -> 'minions' ELSE '[]' END) AS minion(id)`
+
+// visibleJobs restricts a query of the jids table to jobs that targeted, or
+// got a return from, a minion the caller may read. Jobs are not keyed by a
+// minion, so requests not limited to some minions are left alone rather than
+// losing the jobs that reached no minion at all.
+func visibleJobs(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if !middleware.MinionsRestricted(c) {
+			return query
+		}
+		returned := db.DB.Table(returnsTable).Select("jid").Scopes(middleware.VisibleMinions(c, "id"))
+		targeted := db.DB.Table(loadMinions).Select("1").Scopes(middleware.VisibleMinions(c, "minion.id"))
+		return query.Where("jid IN (?) OR EXISTS (?)", returned, targeted)
+	}
+}
+
+// hideLoadMinions drops the minions the caller may not read from the minions
+// list of a job load, as summarize does for the expected minions. A job
+// visible through one minion must not reveal the other minions it targeted.
+func hideLoadMinions(c *gin.Context, job *model.JID) {
+	if !middleware.MinionsRestricted(c) {
+		return
+	}
+	load, ok := job.Load.Data.(map[string]any)
+	if !ok {
+		return
+	}
+	minions, ok := load["minions"].([]any)
+	if !ok {
+		return
+	}
+	visible := make([]any, 0, len(minions))
+	for _, value := range minions {
+		if minion, ok := value.(string); ok && middleware.MinionVisible(c, minion) {
+			visible = append(visible, minion)
+		}
+	}
+	load["minions"] = visible
+}
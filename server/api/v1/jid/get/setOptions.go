@@ -3,7 +3,9 @@ package jid
 import "github.com/PaulChristophel/agartha/server/config"
 
 var table string
+var returnsTable string
 
 func SetOptions(saltTables config.SaltDBTables) {
 	table = saltTables.JIDs
+	returnsTable = saltTables.SaltReturns
 }
@@ -0,0 +1,205 @@
+package jid
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	"github.com/PaulChristophel/agartha/server/model/custom"
+	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Job and minion statuses reported by GetJIDSummary.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusMissing   = "missing"
+	// StatusIncomplete is a job where no minion failed but some never answered.
+	StatusIncomplete = "incomplete"
+	// StatusNoMinions is a job that targeted no minions and got no returns.
+	StatusNoMinions = "no_minions"
+)
+
+// JobSummary describes a job and how the minions it targeted answered.
+type JobSummary struct {
+	JID        string      `json:"jid" example:"20060102150405999999"`
+	Fun        string      `json:"fun" example:"state.apply"`
+	Arg        custom.JSON `json:"arg" swaggertype:"array,object"`
+	Target     custom.JSON `json:"tgt" swaggertype:"string" example:"web*"`
+	TargetType string      `json:"tgt_type" example:"glob"`
+	User       string      `json:"user" example:"alice"`
+	StartTime  *time.Time  `json:"start_time" example:"2006-01-02T15:04:05.999999-07:00"`
+	Status     string      `json:"status" example:"failed"`
+	Expected   []string    `json:"expected" example:"web01,web02,web03"`
+	Returned   []string    `json:"returned" example:"web01,web02"`
+	Failed     []string    `json:"failed" example:"web02"`
+	Missing    []string    `json:"missing" example:"web03"`
+	Minions    []MinionRun `json:"minions"`
+}
+
+// MinionRun is one minion's part in a job. Duration is measured from the
+// time the job load was stored to the time the return was stored.
+type MinionRun struct {
+	ID              string     `json:"id" example:"web01"`
+	Status          string     `json:"status" example:"succeeded"`
+	Expected        bool       `json:"expected" example:"true"`
+	Retcode         *int       `json:"retcode" example:"0"`
+	ReturnedAt      *time.Time `json:"returned_at" example:"2006-01-02T15:04:05.999999-07:00"`
+	DurationSeconds *float64   `json:"duration_seconds" example:"2.5"`
+}
+
+// minionReturn is the part of a salt_returns row the summary needs.
+type minionReturn struct {
+	ID         string
+	SuccessStr string `gorm:"column:success"`
+	Retcode    *string
+	AlterTime  *time.Time
+}
+
+// GetJIDSummary summarizes a job and its returns.
+//
+//	@Summary		Summarize a job.
+//	@Description	Parse the job load and compare the minions it expected with the returns stored for it, reporting which minions returned, failed or never answered, how long each took and the overall status. A minion fails when its return is unsuccessful or has a non-zero retcode. The job fails when any minion failed, is incomplete when any expected minion has not answered, and otherwise succeeded. Redaction rules written for the load, such as load.arg, also mask the arg, tgt and user of the summary.
+//	@Tags			JID
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	JobSummary
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/jid/{jid}/summary [get]
+//	@Param			jid	path	string	true	"jid to summarize"
+//	@Security		Bearer
+func GetJIDSummary(c *gin.Context) {
+	log := logger.GetLogger()
+
+	id := c.Param("jid")
+	log.Debug("Received request to summarize jid", zap.String("jid", id))
+
+	var job model.JID
+	if err := db.DB.Table(table).Where("jid = ?", id).Scopes(visibleJobs(c)).Find(&job).Error; err != nil {
+		log.Error("Failed to fetch jid data", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch jid data.")
+		return
+	}
+	if job.JID != id {
+		log.Debug("No jids present", zap.String("jid", id))
+		httputil.NewError(c, http.StatusNotFound, "No jid present.")
+		return
+	}
+	// The summary copies arg, tgt and user out of the load, where the
+	// redaction rules written for GET /jid/{jid} can still find them.
+	redact.Response(c, &job)
+
+	var returns []minionReturn
+	err := db.DB.Table(returnsTable).
+		Select("id", "success", "full_ret::jsonb->>'retcode' AS retcode", "alter_time").
+		Scopes(middleware.VisibleMinions(c, "id")).
+		Where("jid = ?", id).
+		Order("id").
+		Find(&returns).Error
+	if err != nil {
+		log.Error("Failed to fetch salt returns", zap.String("jid", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch salt returns.")
+		return
+	}
+
+	summary := summarize(job, returns, func(minion string) bool {
+		return middleware.MinionVisible(c, minion)
+	})
+
+	log.Debug("Successfully summarized jid", zap.String("jid", id), zap.String("status", summary.Status))
+	c.JSON(http.StatusOK, summary)
+}
+
+func summarize(job model.JID, returns []minionReturn, visible func(string) bool) JobSummary {
+	load, _ := job.Load.Data.(map[string]any)
+	summary := JobSummary{
+		JID:       job.JID,
+		Fun:       loadString(load, "fun"),
+		Arg:       custom.JSON{Data: load["arg"]},
+		Target:    custom.JSON{Data: load["tgt"]},
+		User:      loadString(load, "user"),
+		StartTime: job.AlterTime,
+		Expected:  []string{},
+		Returned:  []string{},
+		Failed:    []string{},
+		Missing:   []string{},
+		Minions:   []MinionRun{},
+	}
+	if summary.Arg.Data == nil {
+		summary.Arg.Data = []any{}
+	}
+	// Loads written before Salt 3001 name the target type expr_form.
+	summary.TargetType = loadString(load, "tgt_type")
+	if summary.TargetType == "" {
+		summary.TargetType = loadString(load, "expr_form")
+	}
+
+	expected := make(map[string]bool)
+	if minions, ok := load["minions"].([]any); ok {
+		for _, value := range minions {
+			minion, ok := value.(string)
+			if ok && !expected[minion] && visible(minion) {
+				expected[minion] = true
+				summary.Expected = append(summary.Expected, minion)
+			}
+		}
+	}
+	sort.Strings(summary.Expected)
+
+	returned := make(map[string]bool, len(returns))
+	for _, ret := range returns {
+		returned[ret.ID] = true
+		run := MinionRun{ID: ret.ID, Status: StatusSucceeded, Expected: expected[ret.ID], ReturnedAt: ret.AlterTime}
+		if ret.Retcode != nil {
+			if retcode, err := strconv.Atoi(*ret.Retcode); err == nil {
+				run.Retcode = &retcode
+			}
+		}
+		if ret.SuccessStr != "true" || (run.Retcode != nil && *run.Retcode != 0) {
+			run.Status = StatusFailed
+			summary.Failed = append(summary.Failed, ret.ID)
+		}
+		if job.AlterTime != nil && ret.AlterTime != nil {
+			duration := ret.AlterTime.Sub(*job.AlterTime).Seconds()
+			run.DurationSeconds = &duration
+		}
+		summary.Returned = append(summary.Returned, ret.ID)
+		summary.Minions = append(summary.Minions, run)
+	}
+	for _, minion := range summary.Expected {
+		if !returned[minion] {
+			summary.Missing = append(summary.Missing, minion)
+			summary.Minions = append(summary.Minions, MinionRun{ID: minion, Status: StatusMissing, Expected: true})
+		}
+	}
+	sort.Slice(summary.Minions, func(i, j int) bool {
+		return summary.Minions[i].ID < summary.Minions[j].ID
+	})
+
+	switch {
+	case len(summary.Failed) > 0:
+		summary.Status = StatusFailed
+	case len(summary.Missing) > 0:
+		summary.Status = StatusIncomplete
+	case len(summary.Returned) == 0:
+		summary.Status = StatusNoMinions
+	default:
+		summary.Status = StatusSucceeded
+	}
+	return summary
+}
+
+func loadString(load map[string]any, key string) string {
+	value, _ := load[key].(string)
+	return value
+}
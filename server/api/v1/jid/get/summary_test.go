@@ -0,0 +1,204 @@
+package jid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	agartha "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/model/custom"
+	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestSummarize(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) *time.Time {
+		stamp := started.Add(time.Duration(seconds) * time.Second)
+		return &stamp
+	}
+	retcode := func(value string) *string {
+		return &value
+	}
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		name        string
+		load        string
+		returns     []minionReturn
+		wantStatus  string
+		wantFailed  []string
+		wantMissing []string
+	}{
+		{
+			name: "every minion succeeded",
+			load: `{"fun": "test.ping", "minions": ["web01", "web02"]}`,
+			returns: []minionReturn{
+				{ID: "web01", SuccessStr: "true", Retcode: retcode("0"), AlterTime: at(1)},
+				{ID: "web02", SuccessStr: "true", AlterTime: at(2)},
+			},
+			wantStatus: StatusSucceeded, wantFailed: []string{}, wantMissing: []string{},
+		},
+		{
+			name: "non-zero retcode fails a successful return",
+			load: `{"fun": "state.apply", "minions": ["web01", "web02", "web03"]}`,
+			returns: []minionReturn{
+				{ID: "web01", SuccessStr: "true", Retcode: retcode("2"), AlterTime: at(3)},
+				{ID: "web02", SuccessStr: "false", AlterTime: at(4)},
+			},
+			wantStatus: StatusFailed, wantFailed: []string{"web01", "web02"}, wantMissing: []string{"web03"},
+		},
+		{
+			name:        "minion never answered",
+			load:        `{"fun": "test.ping", "minions": ["web02", "web01"]}`,
+			returns:     []minionReturn{{ID: "web01", SuccessStr: "true", AlterTime: at(1)}},
+			wantStatus:  StatusIncomplete,
+			wantFailed:  []string{},
+			wantMissing: []string{"web02"},
+		},
+		{
+			name:       "no minions targeted",
+			load:       `{"fun": "test.ping", "minions": []}`,
+			wantStatus: StatusNoMinions, wantFailed: []string{}, wantMissing: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var load custom.JSON
+			require.NoError(t, json.Unmarshal([]byte(tt.load), &load))
+			summary := summarize(model.JID{JID: "20240501120000000000", Load: load, AlterTime: &started}, tt.returns, all)
+
+			require.Equal(t, tt.wantStatus, summary.Status)
+			require.Equal(t, tt.wantFailed, summary.Failed)
+			require.Equal(t, tt.wantMissing, summary.Missing)
+			require.Len(t, summary.Minions, len(summary.Returned)+len(summary.Missing))
+			for _, run := range summary.Minions {
+				if run.Status == StatusMissing {
+					require.Nil(t, run.DurationSeconds)
+					continue
+				}
+				require.Equal(t, run.ReturnedAt.Sub(started).Seconds(), *run.DurationSeconds)
+			}
+		})
+	}
+}
+
+func TestGetJIDSummaryOnlyShowsVisibleMinions(t *testing.T) {
+	mock := installMockDatabase(t)
+	table, returnsTable = "jids", "salt_returns"
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings"`)).
+		WithArgs(uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[{"web*": ["test.*"]}]`))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jids" WHERE jid = $1 AND `+visibleJobsWhere(2))).
+		WithArgs("20240501120000000000", "^web.*$", "^web.*$").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "load", "alter_time"}).
+			AddRow("20240501120000000000", `{"fun": "test.ping", "arg": [], "tgt": "*", "tgt_type": "glob", "user": "alice", "minions": ["db01", "web01", "web02"]}`, started))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","success",full_ret::jsonb->>'retcode' AS retcode,"alter_time" FROM "salt_returns" WHERE jid = $1 AND (id ~ $2) ORDER BY id`)).
+		WithArgs("20240501120000000000", "^web.*$").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "success", "retcode", "alter_time"}).
+			AddRow("web01", "true", "0", started.Add(1500*time.Millisecond)))
+
+	router := gin.New()
+	router.GET("/jid/:jid/summary", func(c *gin.Context) {
+		c.Set("auth_user", agartha.AuthUser{ID: 7})
+	}, middleware.SaltPermissionRequired(db.DB, middleware.ReadSaltData), GetJIDSummary)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/jid/20240501120000000000/summary", nil))
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `{
+		"jid": "20240501120000000000",
+		"fun": "test.ping",
+		"arg": [],
+		"tgt": "*",
+		"tgt_type": "glob",
+		"user": "alice",
+		"start_time": "2024-05-01T12:00:00Z",
+		"status": "incomplete",
+		"expected": ["web01", "web02"],
+		"returned": ["web01"],
+		"failed": [],
+		"missing": ["web02"],
+		"minions": [
+			{"id": "web01", "status": "succeeded", "expected": true, "retcode": 0, "returned_at": "2024-05-01T12:00:01.5Z", "duration_seconds": 1.5},
+			{"id": "web02", "status": "missing", "expected": true, "retcode": null, "returned_at": null, "duration_seconds": null}
+		]
+	}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetJIDSummaryAppliesLoadRedactionRules(t *testing.T) {
+	mock := installMockDatabase(t)
+	table, returnsTable = "jids", "salt_returns"
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, redact.Configure(config.RedactionOptions{Paths: []string{"load.arg.*"}, Mask: "********"}))
+	t.Cleanup(func() { require.NoError(t, redact.Configure(config.RedactionOptions{})) })
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings"`)).
+		WithArgs(uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[".*"]`))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "jids" WHERE jid = $1`)).
+		WithArgs("20240501120000000000").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "load", "alter_time"}).
+			AddRow("20240501120000000000", `{"fun": "user.add", "arg": ["bob", "hunter2"], "tgt": "web01", "minions": ["web01"]}`, started))
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"capability"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","success",full_ret::jsonb->>'retcode' AS retcode,"alter_time" FROM "salt_returns" WHERE jid = $1 ORDER BY id`)).
+		WithArgs("20240501120000000000").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "success", "retcode", "alter_time"}))
+
+	router := gin.New()
+	router.GET("/jid/:jid/summary", func(c *gin.Context) {
+		c.Set("auth_user", agartha.AuthUser{ID: 7})
+	}, middleware.SaltPermissionRequired(db.DB, middleware.ReadSaltData), GetJIDSummary)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/jid/20240501120000000000/summary", nil))
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.Equal(t, "true", response.Header().Get(redact.Header))
+	var summary JobSummary
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &summary))
+	require.Equal(t, []any{"********", "********"}, summary.Arg.Data)
+	require.Equal(t, "web01", summary.Target.Data)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
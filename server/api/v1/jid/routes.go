@@ -12,6 +12,7 @@ func AddRoutes(rg *gin.RouterGroup) {
 
 	grp.GET("", get.GetJIDs)
 	grp.GET("/:jid", get.GetJID)
+	grp.GET("/:jid/summary", get.GetJIDSummary)
 	// grp.GET("/:jid/:alter_time", get.GetJIDTime)
 }
 
@@ -15,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/PaulChristophel/agartha/server/api/validate"
+	"github.com/PaulChristophel/agartha/server/audit"
 	"github.com/PaulChristophel/agartha/server/logger"
 	"github.com/PaulChristophel/agartha/server/middleware"
 	"github.com/gin-contrib/sessions"
@@ -147,17 +148,14 @@ func cacheSaltPermissions(c *gin.Context, database *gorm.DB) func(*http.Response
 	username, usernameTypeOK := usernameValue.(string)
 	userID, userIDTypeOK := userIDValue.(uint)
 
-	return func(response *http.Response) error {
-		if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
-			return nil
-		}
+	store := func(response *http.Response) (json.RawMessage, error) {
 		if !usernameOK || !userIDOK || !usernameTypeOK || !userIDTypeOK {
-			return fmt.Errorf("validated user context is missing")
+			return nil, fmt.Errorf("validated user context is missing")
 		}
 
 		body, err := io.ReadAll(response.Body)
 		if err != nil {
-			return fmt.Errorf("read Salt login response: %w", err)
+			return nil, fmt.Errorf("read Salt login response: %w", err)
 		}
 		response.Body = io.NopCloser(bytes.NewReader(body))
 		response.ContentLength = int64(len(body))
@@ -170,18 +168,18 @@ func cacheSaltPermissions(c *gin.Context, database *gorm.DB) func(*http.Response
 			} `json:"return"`
 		}
 		if err := json.Unmarshal(body, &login); err != nil || len(login.Return) != 1 {
-			return fmt.Errorf("invalid Salt login response")
+			return nil, fmt.Errorf("invalid Salt login response")
 		}
 		if login.Return[0].User != username {
-			return fmt.Errorf("salt login identity does not match authenticated user")
+			return nil, fmt.Errorf("salt login identity does not match authenticated user")
 		}
 		if _, err := validate.Token(login.Return[0].Token); err != nil {
-			return fmt.Errorf("invalid Salt token in login response")
+			return nil, fmt.Errorf("invalid Salt token in login response")
 		}
 		session := sessions.Default(c)
 		session.Set("salt_token", login.Return[0].Token)
 		if err := session.Save(); err != nil {
-			return fmt.Errorf("save Salt token in session: %w", err)
+			return nil, fmt.Errorf("save Salt token in session: %w", err)
 		}
 		permissions := login.Return[0].Perms
 		if len(permissions) == 0 {
@@ -189,18 +187,39 @@ func cacheSaltPermissions(c *gin.Context, database *gorm.DB) func(*http.Response
 		}
 		var decoded any
 		if err := json.Unmarshal(permissions, &decoded); err != nil {
-			return fmt.Errorf("invalid Salt permissions in login response")
+			return nil, fmt.Errorf("invalid Salt permissions in login response")
 		}
 
 		result := database.Model(&struct {
 			UserID uint `gorm:"column:user_id"`
-		}{}).Table("user_settings").Where("user_id = ?", userID).Update("salt_permissions", string(permissions))
+		}{}).Table("user_settings").Where("user_id = ?", userID).Updates(map[string]any{
+			"salt_permissions":            string(permissions),
+			"salt_permissions_updated_at": time.Now(),
+		})
 		if result.Error != nil {
-			return fmt.Errorf("cache Salt permissions: %w", result.Error)
+			return nil, fmt.Errorf("cache Salt permissions: %w", result.Error)
 		}
 		if result.RowsAffected != 1 {
-			return fmt.Errorf("cache Salt permissions: user settings row not found")
+			return nil, fmt.Errorf("cache Salt permissions: user settings row not found")
+		}
+		return permissions, nil
+	}
+
+	return func(response *http.Response) error {
+		if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+			return nil
+		}
+		permissions, err := store(response)
+		event := audit.Event{
+			Action:  audit.ActionSaltPermissionsRefresh,
+			Outcome: audit.OutcomeSuccess,
+			Detail:  string(permissions),
+		}
+		if err != nil {
+			event.Outcome = audit.OutcomeFailure
+			event.Detail = err.Error()
 		}
-		return nil
+		audit.Record(c, event)
+		return err
 	}
 }
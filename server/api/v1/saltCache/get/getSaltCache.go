@@ -14,7 +14,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -30,6 +32,7 @@ import (
 //	@Success		200	{object}	dto.SaltCachePageResponse
 //	@Failure		400	{object}	httputil.HTTPError400
 //	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
 //	@Failure		404	{object}	httputil.HTTPError404
 //	@Failure		500	{object}	httputil.HTTPError500
 //	@router			/api/v1/salt_cache [get]
@@ -109,7 +112,7 @@ func GetSaltCache(c *gin.Context) {
 	log.Debug("Using selection: ", zap.Strings("selection", selection))
 
 	// Create a base query with selected fields
-	baseQuery := db.Select(selection).Model(&model.SaltCache{})
+	baseQuery := db.Select(selection).Model(&model.SaltCache{}).Scopes(middleware.VisibleMinions(c, model.SaltCacheMinionID))
 
 	// Apply filters based on provided bank and key
 	if bank != "" {
@@ -162,6 +165,10 @@ func GetSaltCache(c *gin.Context) {
 			httputil.NewError(c, http.StatusBadRequest, "Invalid 'jsonpath_filter' value")
 			return
 		}
+		if jsonPathFilter.HidesRedactedData(c, jsonPathFilters, "data") {
+			httputil.NewError(c, http.StatusForbidden, "Filtering on redacted data requires the view-secrets capability.")
+			return
+		}
 		expr := gorm.Expr(jsonPathFilterQuery)
 		log.Debug("Applied jsonpath_filter", zap.String("expr", expr.SQL))
 		baseQuery = baseQuery.Where(expr)
@@ -236,6 +243,11 @@ func GetSaltCache(c *gin.Context) {
 	}
 
 	log.Debug("Returned salt cache data successfully")
+	subsets := redact.Subsets{}
+	if jsonpath != "" {
+		subsets["data"] = jsonPathFilter.RedactionSelections(strings.Split(jsonpath, ","))
+	}
+	redact.SubsetResponse(c, &response, subsets)
 	// Return paginated results
 	c.JSON(http.StatusOK, response)
 }
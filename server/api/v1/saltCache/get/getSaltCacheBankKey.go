@@ -9,7 +9,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -50,7 +52,7 @@ func GetSaltCacheBankKey(c *gin.Context) {
 
 	log.Debug("Received parameters", zap.String("bank", bank), zap.String("key", key))
 
-	if err := dbConn.Where("bank = ? AND psql_key = ?", bank, key).Find(&saltCache).Error; err != nil {
+	if err := dbConn.Scopes(middleware.VisibleMinions(c, model.SaltCacheMinionID)).Where("bank = ? AND psql_key = ?", bank, key).Find(&saltCache).Error; err != nil {
 		log.Error("Failed to fetch salt cache data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch salt cache data.")
 		return
@@ -62,6 +64,7 @@ func GetSaltCacheBankKey(c *gin.Context) {
 		return
 	}
 
+	redact.Response(c, &saltCache)
 	c.JSON(http.StatusOK, saltCache)
 }
 
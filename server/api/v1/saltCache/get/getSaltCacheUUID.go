@@ -8,7 +8,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -43,7 +45,7 @@ func GetSaltCacheUUID(c *gin.Context) {
 	}
 
 	// Find the saltCache with the given UUID
-	if err := db.Where("id = ?", uuid).Find(&saltCache).Find(&saltCache).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, model.SaltCacheMinionID)).Where("id = ?", uuid).Find(&saltCache).Find(&saltCache).Error; err != nil {
 		log.Error("Failed to fetch salt cache data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch salt cache data.")
 		return
@@ -57,6 +59,7 @@ func GetSaltCacheUUID(c *gin.Context) {
 	}
 
 	log.Debug("Successfully retrieved salt cache", zap.String("uuid", uuid))
+	redact.Response(c, &saltCache)
 	// Return the saltCache with the UUID
 	c.JSON(http.StatusOK, saltCache)
 }
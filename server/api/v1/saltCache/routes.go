@@ -7,30 +7,39 @@ import (
 	get "github.com/PaulChristophel/agartha/server/api/v1/saltCache/get"
 	post "github.com/PaulChristophel/agartha/server/api/v1/saltCache/post"
 	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 var (
 	isRefreshing bool = false
 )
 
-func AddRoutes(rg *gin.RouterGroup) {
+// AddRoutes registers the salt_cache routes. Reads are open to holders of
+// view-pillar and key refreshes to holders of refresh-views; everyone else,
+// and every change to the cache, needs Salt permissions.
+func AddRoutes(rg *gin.RouterGroup, database *gorm.DB) {
 	grp := rg.Group("/salt_cache")
+	salt := middleware.SaltPermissionForMethodRequired(database)
+	view := middleware.CapabilityRequired(database, model.CapabilityViewPillar, salt)
+	refresh := middleware.CapabilityRequired(database, model.CapabilityRefreshViews, salt)
 
 	post.SetRefreshing(&isRefreshing)
 	get.SetRefreshing(&isRefreshing)
 
-	grp.DELETE("/uuid/:uuid", delete.DeleteSaltCacheUUID)
-	grp.DELETE("/bank/:bank", delete.DeleteSaltCacheBank)
-	grp.DELETE("/:bank/*key", delete.DeleteSaltCacheBankKey)
-	grp.GET("", get.GetSaltCache)
-	grp.GET("/fun_keys", get.ListSaltCacheDataKeys)
-	grp.GET("/fun_keys/refresh", get.RefreshKeys)
-	grp.GET("/uuid/:uuid", get.GetSaltCacheUUID)
-	grp.GET("/:bank/*key", get.GetSaltCacheBankKey)
-	grp.POST("/", post.CreateSaltCache)
-	grp.POST("/fun_keys/refresh", post.RefreshKeys)
+	grp.DELETE("/uuid/:uuid", salt, delete.DeleteSaltCacheUUID)
+	grp.DELETE("/bank/:bank", salt, delete.DeleteSaltCacheBank)
+	grp.DELETE("/:bank/*key", salt, delete.DeleteSaltCacheBankKey)
+	grp.GET("", view, get.GetSaltCache)
+	grp.GET("/fun_keys", view, get.ListSaltCacheDataKeys)
+	grp.GET("/fun_keys/refresh", refresh, get.RefreshKeys)
+	grp.GET("/uuid/:uuid", view, get.GetSaltCacheUUID)
+	grp.GET("/:bank/*key", view, get.GetSaltCacheBankKey)
+	grp.POST("/", salt, post.CreateSaltCache)
+	grp.POST("/fun_keys/refresh", refresh, post.RefreshKeys)
 }
 
 func SetOptions(saltTables config.SaltDBTables) {
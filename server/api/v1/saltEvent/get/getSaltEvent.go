@@ -7,7 +7,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -45,7 +47,7 @@ func GetSaltEvent(c *gin.Context) {
 	}
 
 	// Find the event with the given id
-	if err := db.Find(&saltEvent, "id = ?", idInt64).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, model.SaltEventMinionID)).Find(&saltEvent, "id = ?", idInt64).Error; err != nil {
 		log.Error("Failed to fetch event data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch event data.")
 		return
@@ -61,6 +63,7 @@ func GetSaltEvent(c *gin.Context) {
 	// Log the successful retrieval of the event
 	log.Info("Successfully retrieved event", zap.Int64("id", idInt64))
 
+	redact.Response(c, &saltEvent)
 	// Return the event data
 	c.JSON(http.StatusOK, saltEvent)
 }
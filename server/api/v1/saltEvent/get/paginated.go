@@ -13,7 +13,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -86,7 +88,7 @@ func GetSaltEvents(c *gin.Context) {
 		log.Debug("Limit exceeds maximum for detailed data, setting to 10")
 	}
 
-	filterQuery := db.Select(selection).Model(&model.SaltEvent{})
+	filterQuery := db.Select(selection).Model(&model.SaltEvent{}).Scopes(middleware.VisibleMinions(c, model.SaltEventMinionID))
 	if tag != "" {
 		if strings.Contains(tag, "*") {
 			filterQuery = filterQuery.Where("tag LIKE ?", strings.ReplaceAll(tag, "*", "%"))
@@ -202,6 +204,7 @@ func GetSaltEvents(c *gin.Context) {
 	}
 
 	log.Debug("Returning salt_events", zap.Int("count", len(saltEvents)))
+	redact.Response(c, &response)
 	c.JSON(http.StatusOK, response)
 }
 
@@ -12,6 +12,8 @@ import (
 	"github.com/PaulChristophel/agartha/server/config"
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	agartha "github.com/PaulChristophel/agartha/server/model/agartha"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/postgres"
@@ -82,6 +84,57 @@ func TestGetSaltEventsBuildsFilteredPaginatedQuery(t *testing.T) {
 	require.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestSaltEventsOnlyShowTheVisibleMinions(t *testing.T) {
+	alterTime := time.Date(2026, 8, 1, 12, 0, 0, 0, time.UTC)
+	serve := func(path string, handler gin.HandlerFunc, url string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.GET(path, func(c *gin.Context) {
+			c.Set("auth_user", agartha.AuthUser{ID: 7})
+		}, middleware.SaltPermissionRequired(db.DB, middleware.ReadSaltData), handler)
+		request := httptest.NewRequest(http.MethodGet, url, nil)
+		request.Host = "example.com"
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	expectPermissions := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings"`)).
+			WithArgs(uint(7), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[{"web*": ["test.*"]}]`))
+	}
+
+	t.Run("list", func(t *testing.T) {
+		_, mock := installMockDatabase(t)
+		expectPermissions(mock)
+		const where = `WHERE alter_time >= $1 AND ((data::jsonb ->> 'id') ~ $2)`
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "salt_events" `+where)).
+			WithArgs(sqlmock.AnyArg(), "^web.*$").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","tag","alter_time","master_id" FROM "salt_events" `+where+` ORDER BY id desc LIMIT $3`)).
+			WithArgs(sqlmock.AnyArg(), "^web.*$", 50).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "alter_time", "master_id"}).
+				AddRow(3, "salt/job/20260801120000000000/ret/web01", alterTime, "master_1"))
+
+		response := serve("/salt_event", GetSaltEvents, "/salt_event")
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("event of another minion", func(t *testing.T) {
+		_, mock := installMockDatabase(t)
+		expectPermissions(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "salt_events" WHERE id = $1 AND ((data::jsonb ->> 'id') ~ $2)`)).
+			WithArgs(int64(4), "^web.*$").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "tag", "data", "alter_time", "master_id"}))
+
+		response := serve("/salt_event/:id", GetSaltEvent, "/salt_event/4")
+
+		require.Equal(t, http.StatusNotFound, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestGetSaltEventsReturnsDatabaseErrors(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -6,22 +6,28 @@ import (
 	post "github.com/PaulChristophel/agartha/server/api/v1/saltKeys/post"
 	"github.com/PaulChristophel/agartha/server/config"
 	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// AddRoutes registers v1 salt_keys API routes.
+// AddRoutes registers v1 salt_keys API routes. Holders of manage-keys may
+// manage minion keys without wheel permissions; raw key material always
+// requires full @wheel access.
 func AddRoutes(rg *gin.RouterGroup, database *gorm.DB) {
 	grp := rg.Group("/salt_keys")
 	rawKeyAdmin := middleware.SaltWheelAdministrationRequired(database)
+	manageKeys := func(function string) gin.HandlerFunc {
+		return middleware.CapabilityRequired(database, model.CapabilityManageKeys, middleware.SaltWheelPermissionRequired(database, function))
+	}
 
 	grp.GET("", rawKeyAdmin, get.GetSaltKeys)
-	grp.GET("/minion_keys", middleware.SaltWheelPermissionRequired(database, "key.list_all"), get.GetMinionKeys)
+	grp.GET("/minion_keys", manageKeys("key.list_all"), get.GetMinionKeys)
 	grp.GET("/:bank/*key", rawKeyAdmin, get.GetSaltKeysBankKey)
 	grp.POST("", rawKeyAdmin, post.CreateSaltKey)
-	grp.POST("/minion_keys/accept", middleware.SaltWheelPermissionRequired(database, "key.accept"), post.AcceptMinionKeys)
-	grp.POST("/minion_keys/reject", middleware.SaltWheelPermissionRequired(database, "key.reject"), post.RejectMinionKeys)
-	grp.POST("/minion_keys/delete", middleware.SaltWheelPermissionRequired(database, "key.delete"), post.DeleteMinionKeys)
+	grp.POST("/minion_keys/accept", manageKeys("key.accept"), post.AcceptMinionKeys)
+	grp.POST("/minion_keys/reject", manageKeys("key.reject"), post.RejectMinionKeys)
+	grp.POST("/minion_keys/delete", manageKeys("key.delete"), post.DeleteMinionKeys)
 	grp.DELETE("/bank/:bank", rawKeyAdmin, delete.DeleteSaltKeysBank)
 	grp.DELETE("/:bank/*key", rawKeyAdmin, delete.DeleteSaltKeysBankKey)
 }
@@ -14,7 +14,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -30,6 +32,7 @@ import (
 //	@Success		200	{object}	dto.SaltMinionPageResponse
 //	@Failure		400	{object}	httputil.HTTPError400
 //	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
 //	@Failure		404	{object}	httputil.HTTPError404
 //	@Failure		500	{object}	httputil.HTTPError500
 //	@router			/api/v1/salt_minion [get]
@@ -132,7 +135,7 @@ func GetSaltMinion(c *gin.Context) {
 	}
 
 	// Create a base query with selected fields
-	baseQuery := db.Select(selection).Model(&model.SaltMinion{})
+	baseQuery := db.Select(selection).Model(&model.SaltMinion{}).Scopes(middleware.VisibleMinions(c, "minion_id"))
 
 	// Apply filters based on provided minionID and key
 	if minionID != "" {
@@ -175,6 +178,10 @@ func GetSaltMinion(c *gin.Context) {
 			httputil.NewError(c, http.StatusBadRequest, "Invalid 'jsonpath_grains_filter' value")
 			return
 		}
+		if jsonPathFilter.HidesRedactedData(c, jsonPathFilters, "grains") {
+			httputil.NewError(c, http.StatusForbidden, "Filtering on redacted data requires the view-secrets capability.")
+			return
+		}
 		expr := gorm.Expr(jsonPathFilterQuery)
 		log.Debug("Applied jsonpath_grains_filter", zap.String("expr", expr.SQL))
 		baseQuery = baseQuery.Where(expr)
@@ -188,6 +195,10 @@ func GetSaltMinion(c *gin.Context) {
 			httputil.NewError(c, http.StatusBadRequest, "Invalid 'jsonpath_pillar_filter' value")
 			return
 		}
+		if jsonPathFilter.HidesRedactedData(c, jsonPathFilters, "pillar") {
+			httputil.NewError(c, http.StatusForbidden, "Filtering on redacted data requires the view-secrets capability.")
+			return
+		}
 		expr := gorm.Expr(jsonPathFilterQuery)
 		log.Debug("Applied jsonpath_pillar_filter", zap.String("expr", expr.SQL))
 		baseQuery = baseQuery.Where(expr)
@@ -269,6 +280,14 @@ func GetSaltMinion(c *gin.Context) {
 	}
 
 	log.Debug("Returned salt minion data successfully")
+	subsets := redact.Subsets{}
+	if jsonpathGrains != "" {
+		subsets["grains"] = jsonPathFilter.RedactionSelections(strings.Split(jsonpathGrains, ","))
+	}
+	if jsonpathPillar != "" {
+		subsets["pillar"] = jsonPathFilter.RedactionSelections(strings.Split(jsonpathPillar, ","))
+	}
+	redact.SubsetResponse(c, &response, subsets)
 	// Return paginated results
 	c.JSON(http.StatusOK, response)
 }
@@ -8,7 +8,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -43,7 +45,7 @@ func GetSaltMinionID(c *gin.Context) {
 	}
 
 	// Find the saltCache with the given minionID
-	if err := db.Where("minion_id = ?", minionID).Find(&saltMinion).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, "minion_id")).Where("minion_id = ?", minionID).Find(&saltMinion).Error; err != nil {
 		log.Error("Failed to fetch salt minion data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch salt minion data.")
 		return
@@ -57,6 +59,7 @@ func GetSaltMinionID(c *gin.Context) {
 	}
 
 	log.Debug("Successfully retrieved salt minion", zap.String("minion_id", minionID))
+	redact.Response(c, &saltMinion)
 	// Return the saltCache with the Id
 	c.JSON(http.StatusOK, saltMinion)
 }
@@ -8,7 +8,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -43,7 +45,7 @@ func GetSaltMinionUUID(c *gin.Context) {
 	}
 
 	// Find the saltMinion with the given UUID
-	if err := db.Where("id = ?", uuid).Find(&saltMinion).Find(&saltMinion).Error; err != nil {
+	if err := db.Scopes(middleware.VisibleMinions(c, "minion_id")).Where("id = ?", uuid).Find(&saltMinion).Find(&saltMinion).Error; err != nil {
 		log.Error("Failed to fetch salt minion data", zap.Error(err))
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch salt minion data.")
 		return
@@ -57,6 +59,7 @@ func GetSaltMinionUUID(c *gin.Context) {
 	}
 
 	log.Debug("Successfully retrieved salt minion", zap.String("uuid", uuid))
+	redact.Response(c, &saltMinion)
 	// Return the saltMinion with the UUID
 	c.JSON(http.StatusOK, saltMinion)
 }
@@ -5,25 +5,34 @@ import (
 	// "strings"
 	get "github.com/PaulChristophel/agartha/server/api/v1/saltMinion/get"
 	post "github.com/PaulChristophel/agartha/server/api/v1/saltMinion/post"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 var (
 	isRefreshing bool = false
 )
 
-func AddRoutes(rg *gin.RouterGroup) {
+// AddRoutes registers the salt_minion routes. Minion grains and pillar are
+// open to holders of view-pillar and key refreshes to holders of
+// refresh-views; everyone else needs Salt permissions.
+func AddRoutes(rg *gin.RouterGroup, database *gorm.DB) {
 	grp := rg.Group("/salt_minion")
+	salt := middleware.SaltPermissionForMethodRequired(database)
+	view := middleware.CapabilityRequired(database, model.CapabilityViewPillar, salt)
+	refresh := middleware.CapabilityRequired(database, model.CapabilityRefreshViews, salt)
 
 	post.SetRefreshing(&isRefreshing)
 	get.SetRefreshing(&isRefreshing)
 
-	grp.GET("", get.GetSaltMinion)
-	grp.GET("/uuid/:uuid", get.GetSaltMinionUUID)
-	grp.GET("/grains_keys", get.ListSaltMinionGrainsKeys)
-	grp.GET("/pillar_keys", get.ListSaltMinionPillarKeys)
-	grp.GET("/keys/refresh", get.RefreshKeys)
-	grp.GET("/:minion_id", get.GetSaltMinionID)
-	grp.POST("/keys/refresh", post.RefreshKeys)
+	grp.GET("", view, get.GetSaltMinion)
+	grp.GET("/uuid/:uuid", view, get.GetSaltMinionUUID)
+	grp.GET("/grains_keys", view, get.ListSaltMinionGrainsKeys)
+	grp.GET("/pillar_keys", view, get.ListSaltMinionPillarKeys)
+	grp.GET("/keys/refresh", refresh, get.RefreshKeys)
+	grp.GET("/:minion_id", view, get.GetSaltMinionID)
+	grp.POST("/keys/refresh", refresh, post.RefreshKeys)
 }
@@ -7,7 +7,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -68,7 +70,7 @@ func GetSaltReturnID(c *gin.Context) {
 	}
 
 	// Find the saltReturn in the database with the specified jid and id
-	result := db.Select(selection).Where(&model.SaltReturn{JID: jid, ID: id}).First(&saltReturn)
+	result := db.Select(selection).Scopes(middleware.VisibleMinions(c, "id")).Where(&model.SaltReturn{JID: jid, ID: id}).First(&saltReturn)
 
 	// If no saltReturn is present return an error
 	if result.RowsAffected == 0 {
@@ -78,6 +80,7 @@ func GetSaltReturnID(c *gin.Context) {
 	}
 
 	log.Debug("Returning salt return by JID and ID", zap.String("jid", jid), zap.String("id", id))
+	redact.Response(c, &saltReturn)
 	// Else return saltReturn
 	c.JSON(http.StatusOK, saltReturn)
 }
@@ -7,7 +7,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -65,7 +67,7 @@ func GetSaltReturnJID(c *gin.Context) {
 	}
 
 	// find all saltReturns in the database with the specified jid
-	db.Select(selection).Where("jid = ?", jid).Find(&saltReturns)
+	db.Select(selection).Scopes(middleware.VisibleMinions(c, "id")).Where("jid = ?", jid).Find(&saltReturns)
 
 	// If no saltReturn is present return an error
 	if len(saltReturns) == 0 {
@@ -75,6 +77,7 @@ func GetSaltReturnJID(c *gin.Context) {
 	}
 
 	log.Debug("Returning salt returns by JID", zap.Int("count", len(saltReturns)))
+	redact.Response(c, &saltReturns)
 	// Else return saltReturns
 	c.JSON(http.StatusOK, saltReturns)
 }
@@ -13,7 +13,9 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	model "github.com/PaulChristophel/agartha/server/model/salt"
+	"github.com/PaulChristophel/agartha/server/redact"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -107,7 +109,7 @@ func GetSaltReturns(c *gin.Context) {
 	}
 
 	// Construct the base query with filters
-	filterQuery := db.Select(selection).Model(&model.SaltReturn{})
+	filterQuery := db.Select(selection).Model(&model.SaltReturn{}).Scopes(middleware.VisibleMinions(c, "id"))
 
 	if id != "" {
 		if strings.Contains(id, "*") {
@@ -243,6 +245,7 @@ func GetSaltReturns(c *gin.Context) {
 		return
 	}
 
+	redact.Response(c, &response)
 	// Return the paginated salt returns
 	c.JSON(http.StatusOK, response)
 	log.Debug("Returned salt return data successfully")
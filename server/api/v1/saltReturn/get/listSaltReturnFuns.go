@@ -11,6 +11,7 @@ import (
 	"github.com/PaulChristophel/agartha/server/dto"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -57,7 +58,7 @@ func ListSaltReturnFuns(c *gin.Context) {
 	selection := []string{"fun"}
 
 	// Construct the base query with filters
-	filterQuery := db.Distinct(selection)
+	filterQuery := db.Distinct(selection).Scopes(middleware.VisibleMinions(c, "id"))
 
 	if since != "" {
 		fromTime, err := time.Parse(time.RFC3339, since)
@@ -0,0 +1,62 @@
+package apiToken
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RevokeAPIToken revokes one of the current user's personal API tokens. The
+// row is kept so the token's history remains visible.
+//
+//	@Summary		Revoke a personal API token.
+//	@Description	Revoke one of the current user's personal API tokens. Requests using it are rejected immediately.
+//	@Tags			APIToken
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/api_token/{id} [delete]
+//	@Param			id	path	int	true	"ID of the token"
+//	@Security		Bearer
+func RevokeAPIToken(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid token ID.")
+		return
+	}
+
+	result := db.Model(&model.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, user.ID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		log.Error("Failed to revoke API token", zap.Uint64("id", id), zap.Error(result.Error))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to revoke API token.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		httputil.NewError(c, http.StatusNotFound, "No active api_token data present.")
+		return
+	}
+
+	log.Info("Revoked API token", zap.Uint64("id", id), zap.Uint("user_id", user.ID))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package apiToken
+
+import (
+	"net/http"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetAPITokens lists the current user's personal API tokens.
+//
+//	@Summary		List personal API tokens.
+//	@Description	List the current user's personal API tokens, including revoked and expired ones. Token values are never returned.
+//	@Tags			APIToken
+//	@Produce		json
+//	@Success		200	{array}		model.APIToken
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/api_token [get]
+//	@Security		Bearer
+func GetAPITokens(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+
+	tokens := []model.APIToken{}
+	if err := db.Where("user_id = ?", user.ID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		log.Error("Failed to list API tokens", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list API tokens.")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
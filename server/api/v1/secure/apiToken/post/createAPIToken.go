@@ -0,0 +1,102 @@
+package apiToken
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// CreateAPITokenRequest is the request body for creating a personal API token.
+type CreateAPITokenRequest struct {
+	Name      string     `json:"name" binding:"required" example:"ci-pipeline"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2030-01-01T00:00:00Z"`
+	ReadOnly  bool       `json:"read_only" example:"true"`
+}
+
+// CreateAPITokenResponse carries the token value, which is only ever returned
+// by this call.
+type CreateAPITokenResponse struct {
+	model.APIToken
+	Token string `json:"token" example:"agartha_pat_3q2-7w..."`
+}
+
+// CreateAPIToken issues a personal API token for the current user.
+//
+//	@Summary		Create a personal API token.
+//	@Description	Create a named personal API token for the current user. Present it as "Authorization: Bearer {token}". The token value is shown only once. Omit expires_at for a token that does not expire.
+//	@Tags			APIToken
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	CreateAPITokenResponse
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/api_token [post]
+//	@Param			req	body	CreateAPITokenRequest	true	"Token to create."
+//	@Security		Bearer
+func CreateAPIToken(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	// A leaked token must not be able to mint replacements for itself.
+	if _, ok := middleware.APITokenID(c); ok {
+		httputil.NewError(c, http.StatusForbidden, "API tokens cannot create other API tokens.")
+		return
+	}
+
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 150 {
+		httputil.NewError(c, http.StatusBadRequest, "Name must be between 1 and 150 characters.")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		httputil.NewError(c, http.StatusBadRequest, "expires_at must be in the future.")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Error("Failed to generate API token", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create API token.")
+		return
+	}
+	value := model.APITokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	token := model.APIToken{
+		UserID:    user.ID,
+		Name:      req.Name,
+		TokenHash: model.HashAPIToken(value),
+		ReadOnly:  req.ReadOnly,
+		ExpiresAt: req.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		log.Error("Failed to create API token", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create API token.")
+		return
+	}
+
+	log.Info("Created API token", zap.Uint("id", token.ID), zap.Uint("user_id", user.ID), zap.Bool("read_only", token.ReadOnly))
+	c.JSON(http.StatusCreated, CreateAPITokenResponse{APIToken: token, Token: value})
+}
@@ -0,0 +1,116 @@
+package apiToken
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// capturedArgument matches any value and remembers it for later assertions.
+type capturedArgument struct {
+	value driver.Value
+}
+
+func (argument *capturedArgument) Match(value driver.Value) bool {
+	argument.value = value
+	return true
+}
+
+func TestCreateAPIToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		viaToken   bool
+		wantStatus int
+	}{
+		{name: "missing name", body: `{"read_only":true}`, wantStatus: http.StatusBadRequest},
+		{name: "expired", body: `{"name":"ci","expires_at":"2001-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest},
+		{name: "created by an API token", body: `{"name":"ci"}`, viaToken: true, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+
+			response := serveCreateAPIToken(tt.body, tt.viaToken)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("created", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		hash := &capturedArgument{}
+		expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`INSERT INTO "api_token"`).
+			WithArgs(7, "ci", hash, true, expiresAt, nil, nil, sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectCommit()
+
+		response := serveCreateAPIToken(`{"name":" ci ","read_only":true,"expires_at":"`+expiresAt.Format(time.RFC3339)+`"}`, false)
+
+		require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+		var created CreateAPITokenResponse
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &created))
+		require.Equal(t, uint(3), created.ID)
+		require.True(t, strings.HasPrefix(created.Token, model.APITokenPrefix))
+		require.Equal(t, model.HashAPIToken(created.Token), hash.value)
+		require.NotContains(t, response.Body.String(), hash.value)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveCreateAPIToken(body string, viaToken bool) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/api_token", func(c *gin.Context) {
+		c.Set("auth_user", model.AuthUser{ID: 7, Username: "alice", IsActive: true})
+		if viaToken {
+			c.Set("api_token_id", uint(2))
+		}
+	}, CreateAPIToken)
+	request := httptest.NewRequest(http.MethodPost, "/api_token", bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
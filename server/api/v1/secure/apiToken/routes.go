@@ -0,0 +1,18 @@
+package apiToken
+
+import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/apiToken/delete"
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/apiToken/get"
+	post "github.com/PaulChristophel/agartha/server/api/v1/secure/apiToken/post"
+	"github.com/gin-gonic/gin"
+)
+
+// AddRoutes registers the personal API token routes. Every route acts on the
+// authenticated user's own tokens.
+func AddRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/api_token")
+
+	grp.GET("", get.GetAPITokens)
+	grp.POST("", post.CreateAPIToken)
+	grp.DELETE("/:id", delete.RevokeAPIToken)
+}
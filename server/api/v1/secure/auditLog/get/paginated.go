@@ -0,0 +1,168 @@
+package auditLog
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/api/validate"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/dto"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetAuditLogs lists authentication and authorization decisions.
+//
+//	@Summary		Get the audit log (paginated).
+//	@Description	Get logins, logouts, permission denials and Salt permission refreshes, most recent first. Requires superuser access.
+//	@Tags			AuditLog
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dto.AuditLogPageResponse
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/audit_log [get]
+//	@Param			username	query	string	false	"Filter on username (Supports wildcards * and ? for single char matches.)"
+//	@Param			user_id		query	int		false	"Filter on user ID"
+//	@Param			action		query	string	false	"Filter on action (login, logout, authorize or salt_permissions_refresh)"
+//	@Param			outcome		query	string	false	"Filter on outcome (success, failure or denied)"
+//	@Param			since		query	string	false	"Filter items from this date (RFC3339 format)."
+//	@Param			until		query	string	false	"Filter items up to this date (RFC3339 format)."
+//	@Param			page		query	int		false	"Page number of results to retrieve"
+//	@Param			per_page	query	int		false	"Number of items per page"
+//	@Param			order_by	query	string	false	"Order by column(s). Comma separated list of columns to order by (e.g. username,created_at desc)"
+//	@Security		Bearer
+func GetAuditLogs(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+	var entries []model.AuditLog
+
+	page, err := positiveQueryInt(c, "page", 1)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := positiveQueryInt(c, "per_page", 50)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	filterQuery := db.Model(&model.AuditLog{})
+	if username := c.Query("username"); username != "" {
+		if strings.ContainsAny(username, "*?") {
+			pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_").Replace(username)
+			filterQuery = filterQuery.Where("username LIKE ?", pattern)
+		} else {
+			filterQuery = filterQuery.Where("username = ?", username)
+		}
+	}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, "invalid user_id parameter")
+			return
+		}
+		filterQuery = filterQuery.Where("user_id = ?", uint(userID))
+	}
+	for _, column := range []string{"action", "outcome"} {
+		if value := c.Query(column); value != "" {
+			filterQuery = filterQuery.Where(column+" = ?", value)
+		}
+	}
+	if since := c.Query("since"); since != "" {
+		fromTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'since' date format")
+			return
+		}
+		filterQuery = filterQuery.Where("created_at >= ?", fromTime)
+	}
+	if until := c.Query("until"); until != "" {
+		toTime, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, "invalid 'until' date format")
+			return
+		}
+		filterQuery = filterQuery.Where("created_at <= ?", toTime)
+	}
+
+	validColumns := []string{"id", "created_at", "user_id", "username", "action", "outcome", "method", "client_ip"}
+	validatedOrderBy, err := validate.OrderBy(c.Query("order_by"), validColumns, "", []string{})
+	if err != nil {
+		log.Debug("Invalid order_by value", zap.Error(err))
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if validatedOrderBy != "" {
+		filterQuery = filterQuery.Order(validatedOrderBy)
+	} else {
+		filterQuery = filterQuery.Order("id desc")
+	}
+
+	var totalCount int64
+	if err := filterQuery.Count(&totalCount).Error; err != nil {
+		log.Error("Failed to count audit log entries", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to count audit log entries.")
+		return
+	}
+
+	resultsQuery := filterQuery.Offset((page - 1) * limit).Limit(limit)
+	if err := resultsQuery.Find(&entries).Error; err != nil {
+		log.Error("Failed to retrieve audit log entries", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to retrieve audit log entries.")
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, c.Request.URL.Path)
+
+	var nextPage, previousPage string
+	if page > 1 {
+		previousPage = fmt.Sprintf("%s?page=%d&per_page=%d", baseURL, page-1, limit)
+	}
+	if int64((page-1)*limit+len(entries)) < totalCount {
+		nextPage = fmt.Sprintf("%s?page=%d&per_page=%d", baseURL, page+1, limit)
+	}
+
+	if len(entries) == 0 {
+		log.Debug("No audit log entries found")
+		httputil.NewError(c, http.StatusNotFound, "No audit_log data present.")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AuditLogPageResponse{
+		Results: entries,
+		Paging: dto.PageResponse{
+			PerPage:  int64(limit),
+			NumPages: int64(math.Ceil(float64(totalCount) / float64(limit))),
+			Count:    totalCount,
+			Next:     nextPage,
+			Previous: previousPage,
+		},
+	})
+}
+
+func positiveQueryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	value, err := strconv.Atoi(c.DefaultQuery(name, strconv.Itoa(defaultValue)))
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,117 @@
+package auditLog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGetAuditLogsValidatesPaginationAndFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{name: "invalid page", url: "/audit_log?page=zero", body: `{"code":400,"message":"invalid page parameter"}`},
+		{name: "invalid user id", url: "/audit_log?user_id=alice", body: `{"code":400,"message":"invalid user_id parameter"}`},
+		{name: "invalid since", url: "/audit_log?since=yesterday", body: `{"code":400,"message":"invalid 'since' date format"}`},
+		{name: "invalid order", url: "/audit_log?order_by=detail", body: `{"code":400,"message":"invalid column name 'detail'. Valid columns: [id created_at user_id username action outcome method client_ip]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			response := serveAuditLogRequest(tt.url)
+
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.JSONEq(t, tt.body, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAuditLogsBuildsFilteredPaginatedQuery(t *testing.T) {
+	mock := installMockDatabase(t)
+	createdAt, err := time.Parse(time.RFC3339, "2026-08-01T12:00:00Z")
+	require.NoError(t, err)
+
+	where := `WHERE username LIKE $1 AND action = $2 AND outcome = $3 AND created_at >= $4`
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "audit_log" `+where)).
+		WithArgs(`svc\_%`, "login", "failure", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_log" `+where+` ORDER BY id desc LIMIT $5 OFFSET $6`)).
+		WithArgs(`svc\_%`, "login", "failure", sqlmock.AnyArg(), 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "user_id", "username", "action", "outcome", "method", "client_ip", "user_agent", "path", "detail"}).
+			AddRow(1, createdAt, nil, "svc_salt", "login", "failure", "ldap", "192.0.2.1", "curl/8", "/auth/token", "invalid credentials"))
+
+	response := serveAuditLogRequest("/audit_log?username=svc_*&action=login&outcome=failure&since=2026-08-01T00:00:00Z&page=2&per_page=2")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `{
+		"paging": {
+			"per_page": 2,
+			"num_pages": 2,
+			"count": 3,
+			"next": "",
+			"previous": "http://example.com/audit_log?page=1&per_page=2"
+		},
+		"results": [{
+			"id": 1,
+			"created_at": "2026-08-01T12:00:00Z",
+			"user_id": null,
+			"username": "svc_salt",
+			"action": "login",
+			"outcome": "failure",
+			"method": "ldap",
+			"client_ip": "192.0.2.1",
+			"user_agent": "curl/8",
+			"path": "/auth/token",
+			"detail": "invalid credentials"
+		}]
+	}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveAuditLogRequest(url string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/audit_log", GetAuditLogs)
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	request.Host = "example.com"
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
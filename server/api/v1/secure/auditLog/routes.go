@@ -0,0 +1,14 @@
+package auditLog
+
+import (
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/auditLog/get"
+	"github.com/gin-gonic/gin"
+)
+
+// AddAdminRoutes registers the audit log routes. The caller is responsible
+// for restricting rg to superusers.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/audit_log")
+
+	grp.GET("", get.GetAuditLogs)
+}
@@ -0,0 +1,60 @@
+package authUser
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DeleteAuthUser removes an Agartha user and, through foreign keys, their
+// settings and session mappings.
+//
+//	@Summary		Delete an Agartha user.
+//	@Description	Delete an Agartha user. Requires superuser access or the admin-users capability. Administrators cannot delete themselves.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id} [delete]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func DeleteAuthUser(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+	if currentUser, ok := middleware.AuthenticatedUser(c); ok && currentUser.ID == uint(id) {
+		httputil.NewError(c, http.StatusBadRequest, "Administrators cannot delete themselves.")
+		return
+	}
+
+	var user model.AuthUser
+	result := db.Delete(&user, id)
+	if result.Error != nil {
+		log.Error("Failed to delete auth user", zap.Uint64("id", id), zap.Error(result.Error))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to delete user.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+		return
+	}
+
+	log.Info("Deleted auth user", zap.Uint64("id", id))
+	c.Status(http.StatusNoContent)
+}
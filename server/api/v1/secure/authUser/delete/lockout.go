@@ -0,0 +1,61 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DeleteAuthUserLockout unlocks a user by forgetting their failed logins.
+//
+//	@Summary		Unlock a user.
+//	@Description	Forget the failed password logins counted against an Agartha user's username, ending any delay or lockout. Lockouts of client IP addresses are managed at /api/v1/secure/login_failure. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/lockout [delete]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func DeleteAuthUserLockout(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.First(&user, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+		return
+	} else if err != nil {
+		log.Error("Failed to retrieve auth user", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to unlock user.")
+		return
+	}
+
+	subject := model.LoginFailureSubject(model.LoginFailureUsername, user.Username)
+	if err := model.ClearLoginFailures(db, model.LoginFailureUsername, subject); err != nil {
+		log.Error("Failed to unlock auth user", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to unlock user.")
+		return
+	}
+
+	log.Info("Unlocked auth user", zap.Uint64("user_id", id), zap.String("username", user.Username))
+	c.Status(http.StatusNoContent)
+}
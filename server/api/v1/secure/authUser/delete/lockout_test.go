@@ -0,0 +1,79 @@
+package authUser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestDeleteAuthUserLockout(t *testing.T) {
+	tests := []struct {
+		name       string
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+	}{
+		{
+			name: "missing user",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE "auth_user"."id" = $1 ORDER BY "auth_user"."id" LIMIT $2`)).
+					WithArgs(12, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "unlocked",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE "auth_user"."id" = $1 ORDER BY "auth_user"."id" LIMIT $2`)).
+					WithArgs(12, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(12, "Bob"))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "login_failure" WHERE kind = $1 AND subject = $2`)).
+					WithArgs("username", "bob").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			_, err := logger.InitLogger(gin.TestMode)
+			require.NoError(t, err)
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+			})
+			require.NoError(t, err)
+			previousDB := db.DB
+			db.DB = gormDB
+			t.Cleanup(func() {
+				db.DB = previousDB
+				mock.ExpectClose()
+				require.NoError(t, sqlDB.Close())
+			})
+			tt.expect(mock)
+
+			router := gin.New()
+			router.DELETE("/auth_user/:id/lockout", DeleteAuthUserLockout)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodDelete, "/auth_user/12/lockout", nil))
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
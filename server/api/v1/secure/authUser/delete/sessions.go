@@ -0,0 +1,109 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DeleteAuthUserSessions ends every browser session of a user and revokes
+// every access and refresh token issued to them. Personal API tokens are
+// left alone.
+//
+//	@Summary		End all of a user's sessions.
+//	@Description	End every browser session of an Agartha user and revoke their access and refresh tokens. Personal API tokens are not affected. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/session [delete]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func DeleteAuthUserSessions(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var user model.AuthUser
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		return user.InvalidateCredentials(tx)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+		return
+	}
+	if err != nil {
+		log.Error("Failed to end sessions", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to end sessions.")
+		return
+	}
+
+	log.Info("Ended every session of auth user", zap.Uint64("user_id", id))
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteAuthUserSession ends one browser session of a user.
+//
+//	@Summary		End one of a user's sessions.
+//	@Description	End one browser session of an Agartha user and revoke the access and refresh tokens issued to it. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/session/{session_id} [delete]
+//	@Param			id			path	int		true	"ID of the user"
+//	@Param			session_id	path	string	true	"ID of the session"
+//	@Security		Bearer
+func DeleteAuthUserSession(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	var found bool
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		found, err = model.EndSession(tx, uint(id), c.Param("session_id"))
+		return err
+	})
+	if err != nil {
+		log.Error("Failed to end session", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to end session.")
+		return
+	}
+	if !found {
+		httputil.NewError(c, http.StatusNotFound, "No active session data present.")
+		return
+	}
+
+	log.Info("Ended session of auth user", zap.Uint64("user_id", id))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,48 @@
+package authUser
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetAuthUserGroups lists the directory groups recorded at a user's last
+// login.
+//
+//	@Summary		List a user's directory groups.
+//	@Description	List the directory groups, including nested ones, that were resolved at the user's last directory login. Users can read their own groups; superusers can read anyone's.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		200	{array}		model.UserGroup
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/group [get]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func GetAuthUserGroups(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	groups := []model.UserGroup{}
+	if err := db.Where("user_id = ?", id).Order("source, name").Find(&groups).Error; err != nil {
+		log.Error("Failed to list user groups", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list groups.")
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
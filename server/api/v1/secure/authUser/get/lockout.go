@@ -0,0 +1,66 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GetAuthUserLockout shows the failed logins counted against a user.
+//
+//	@Summary		Get a user's failed login count and lockout.
+//	@Description	Get the recent failed password logins counted against an Agartha user's username, including the delay or lockout in effect. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		200	{object}	model.LoginFailure
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/lockout [get]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func GetAuthUserLockout(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.First(&user, id).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+		return
+	} else if err != nil {
+		log.Error("Failed to retrieve auth user", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to retrieve lockout.")
+		return
+	}
+
+	var failure model.LoginFailure
+	subject := model.LoginFailureSubject(model.LoginFailureUsername, user.Username)
+	err = db.Where("kind = ? AND subject = ?", model.LoginFailureUsername, subject).First(&failure).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NewError(c, http.StatusNotFound, "No login_failure data present.")
+		return
+	}
+	if err != nil {
+		log.Error("Failed to retrieve lockout", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to retrieve lockout.")
+		return
+	}
+
+	c.JSON(http.StatusOK, failure)
+}
@@ -0,0 +1,163 @@
+package authUser
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/api/validate"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/dto"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GetAuthUsers lists Agartha users for administrators.
+//
+//	@Summary		Get a list of Agartha users (paginated).
+//	@Description	Get paginated Agartha users. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	dto.AuthUserPageResponse
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user [get]
+//	@Param			username		query	string	false	"Filter on username (Supports wildcards * and ? for single char matches.)"
+//	@Param			email			query	string	false	"Filter on email (Supports wildcards * and ? for single char matches.)"
+//	@Param			is_active		query	bool	false	"Filter on active users"
+//	@Param			is_superuser	query	bool	false	"Filter on superusers"
+//	@Param			page			query	int		false	"Page number of results to retrieve"
+//	@Param			per_page		query	int		false	"Number of items per page"
+//	@Param			order_by		query	string	false	"Order by column(s). Comma separated list of columns to order by (e.g. username,date_joined desc)"
+//	@Security		Bearer
+func GetAuthUsers(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+	var authUsers []model.AuthUser
+
+	username := c.Query("username")
+	email := c.Query("email")
+	log.Debug("Received request to list auth users", zap.String("username", username), zap.String("email", email))
+
+	page, err := positiveQueryInt(c, "page", 1)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := positiveQueryInt(c, "per_page", 50)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	filterQuery := db.Model(&model.AuthUser{})
+	filterQuery = whereText(filterQuery, "username", username)
+	filterQuery = whereText(filterQuery, "email", email)
+	for _, column := range []string{"is_active", "is_superuser"} {
+		value := c.Query(column)
+		if value == "" {
+			continue
+		}
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", column))
+			return
+		}
+		filterQuery = filterQuery.Where(column+" = ?", boolValue)
+	}
+
+	validColumns := []string{"id", "username", "first_name", "last_name", "email", "is_active", "is_superuser", "last_login", "date_joined"}
+	orderBy := c.Query("order_by")
+	validatedOrderBy, err := validate.OrderBy(orderBy, validColumns, "", []string{})
+	if err != nil {
+		log.Debug("Invalid order_by value", zap.Error(err))
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if validatedOrderBy != "" {
+		filterQuery = filterQuery.Order(validatedOrderBy)
+	} else {
+		filterQuery = filterQuery.Order("username asc")
+	}
+
+	var totalCount int64
+	if err := filterQuery.Count(&totalCount).Error; err != nil {
+		log.Error("Failed to count auth users", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to count auth users.")
+		return
+	}
+
+	resultsQuery := filterQuery.Offset((page - 1) * limit).Limit(limit)
+	if err := resultsQuery.Find(&authUsers).Error; err != nil {
+		log.Error("Failed to retrieve auth users", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to retrieve auth users.")
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	baseURL := fmt.Sprintf("%s://%s%s", scheme, c.Request.Host, c.Request.URL.Path)
+
+	var nextPage, previousPage string
+	if page > 1 {
+		previousPage = fmt.Sprintf("%s?page=%d&per_page=%d", baseURL, page-1, limit)
+	}
+	if int64((page-1)*limit+len(authUsers)) < totalCount {
+		nextPage = fmt.Sprintf("%s?page=%d&per_page=%d", baseURL, page+1, limit)
+	}
+
+	response := dto.AuthUserPageResponse{
+		Results: authUsers,
+		Paging: dto.PageResponse{
+			PerPage:  int64(limit),
+			NumPages: int64(math.Ceil(float64(totalCount) / float64(limit))),
+			Count:    totalCount,
+			Next:     nextPage,
+			Previous: previousPage,
+		},
+	}
+
+	if len(authUsers) == 0 {
+		log.Debug("No auth users found")
+		httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// whereText applies an exact match, or a LIKE match when the filter contains
+// the * or ? wildcards. Literal LIKE metacharacters are escaped.
+func whereText(query *gorm.DB, column, filter string) *gorm.DB {
+	if filter == "" {
+		return query
+	}
+	if !strings.ContainsAny(filter, "*?") {
+		return query.Where(column+" = ?", filter)
+	}
+	pattern := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "*", "%", "?", "_").Replace(filter)
+	return query.Where(column+" LIKE ?", pattern)
+}
+
+func positiveQueryInt(c *gin.Context, name string, defaultValue int) (int, error) {
+	value, err := strconv.Atoi(c.DefaultQuery(name, strconv.Itoa(defaultValue)))
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("invalid %s parameter", name)
+	}
+	return value, nil
+}
@@ -0,0 +1,115 @@
+package authUser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestGetAuthUsersValidatesPaginationAndFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		body string
+	}{
+		{name: "invalid page", url: "/auth_user?page=zero", body: `{"code":400,"message":"invalid page parameter"}`},
+		{name: "invalid active filter", url: "/auth_user?is_active=maybe", body: `{"code":400,"message":"invalid is_active parameter"}`},
+		{name: "invalid order", url: "/auth_user?order_by=password", body: `{"code":400,"message":"invalid column name 'password'. Valid columns: [id username first_name last_name email is_active is_superuser last_login date_joined]"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			response := serveAuthUsersRequest(tt.url)
+
+			require.Equal(t, http.StatusBadRequest, response.Code)
+			require.JSONEq(t, tt.body, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetAuthUsersBuildsFilteredPaginatedQuery(t *testing.T) {
+	mock := installMockDatabase(t)
+	dateJoined, err := time.Parse(time.RFC3339, "2026-08-01T12:00:00Z")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "auth_user" WHERE username LIKE $1 AND is_superuser = $2`)).
+		WithArgs(`svc\_%`, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE username LIKE $1 AND is_superuser = $2 ORDER BY date_joined desc LIMIT $3 OFFSET $4`)).
+		WithArgs(`svc\_%`, false, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password", "username", "is_active", "date_joined"}).
+			AddRow(3, "hash", "svc_salt", true, dateJoined))
+
+	response := serveAuthUsersRequest("/auth_user?username=svc_*&is_superuser=false&page=2&per_page=2&order_by=date_joined%20desc")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `{
+		"paging": {
+			"per_page": 2,
+			"num_pages": 2,
+			"count": 3,
+			"next": "",
+			"previous": "http://example.com/auth_user?page=1&per_page=2"
+		},
+		"results": [{
+			"id": 3,
+			"last_login": null,
+			"is_superuser": false,
+			"username": "svc_salt",
+			"first_name": "",
+			"last_name": "",
+			"email": "",
+			"is_staff": false,
+			"is_active": true,
+			"date_joined": "2026-08-01T12:00:00Z",
+			"provider": ""
+		}]
+	}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveAuthUsersRequest(url string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.GET("/auth_user", GetAuthUsers)
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	request.Host = "example.com"
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
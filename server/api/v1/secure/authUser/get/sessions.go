@@ -0,0 +1,47 @@
+package authUser
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetAuthUserSessions lists another user's active browser sessions.
+//
+//	@Summary		List a user's active sessions.
+//	@Description	List an Agartha user's unexpired browser sessions, most recently used first. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		200	{array}		model.ActiveSession
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/session [get]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func GetAuthUserSessions(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	active, err := model.ActiveSessions(db, uint(id))
+	if err != nil {
+		log.Error("Failed to list sessions", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list sessions.")
+		return
+	}
+
+	c.JSON(http.StatusOK, active)
+}
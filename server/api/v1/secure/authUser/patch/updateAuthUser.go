@@ -0,0 +1,112 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UpdateAuthUserRequest lists the user fields an administrator may change.
+// Omitted fields are left unchanged.
+type UpdateAuthUserRequest struct {
+	FirstName   *string `json:"first_name" example:"Alice"`
+	LastName    *string `json:"last_name" example:"Admin"`
+	Email       *string `json:"email" example:"alice@example.com"`
+	IsActive    *bool   `json:"is_active" example:"false"`
+	IsSuperuser *bool   `json:"is_superuser" example:"false"`
+}
+
+// UpdateAuthUser changes a user's names, email, active flag or superuser flag.
+//
+//	@Summary		Update an Agartha user.
+//	@Description	Update the names, email, is_active or is_superuser fields of an Agartha user. Requires superuser access or the admin-users capability. Administrators cannot deactivate or demote themselves.
+//	@Tags			AuthUser
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	model.AuthUser
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id} [patch]
+//	@Param			id	path	int						true	"ID of the user"
+//	@Param			req	body	UpdateAuthUserRequest	true	"Fields to update."
+//	@Security		Bearer
+func UpdateAuthUser(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+	var req UpdateAuthUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	currentUser, ok := middleware.AuthenticatedUser(c)
+	if req.IsSuperuser != nil && !(ok && currentUser.IsSuperuser) {
+		httputil.NewError(c, http.StatusForbidden, "Only superusers can change superuser access.")
+		return
+	}
+	// Refuse changes that would lock the acting administrator out.
+	if ok && currentUser.ID == uint(id) {
+		if (req.IsActive != nil && !*req.IsActive) || (req.IsSuperuser != nil && !*req.IsSuperuser) {
+			httputil.NewError(c, http.StatusBadRequest, "Administrators cannot deactivate or demote themselves.")
+			return
+		}
+	}
+
+	updates := map[string]any{}
+	if req.FirstName != nil {
+		updates["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		updates["last_name"] = *req.LastName
+	}
+	if req.Email != nil {
+		updates["email"] = *req.Email
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.IsSuperuser != nil {
+		updates["is_superuser"] = *req.IsSuperuser
+	}
+	if len(updates) == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "No fields to update.")
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+			return
+		}
+		log.Error("Failed to load auth user", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update user.")
+		return
+	}
+	if err := db.Model(&user).Updates(updates).Error; err != nil {
+		log.Error("Failed to update auth user", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update user.")
+		return
+	}
+
+	log.Info("Updated auth user", zap.Uint64("id", id), zap.Any("fields", updates))
+	c.JSON(http.StatusOK, user)
+}
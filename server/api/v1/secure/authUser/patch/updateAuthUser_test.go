@@ -0,0 +1,114 @@
+package authUser
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestUpdateAuthUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		caller     model.AuthUser
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no fields",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"No fields to update."}`,
+		},
+		{
+			name: "missing user",
+			body: `{"is_active":false}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`)).
+					WithArgs(12, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":404,"message":"No auth_user data present."}`,
+		},
+		{
+			name:       "superuser flag from an administrator without superuser access",
+			body:       `{"is_superuser":true}`,
+			caller:     model.AuthUser{ID: 3, Username: "helpdesk"},
+			wantStatus: http.StatusForbidden,
+			wantBody:   `{"code":403,"message":"Only superusers can change superuser access."}`,
+		},
+		{
+			name:   "updated",
+			body:   `{"email":"bob@example.test","is_superuser":true}`,
+			caller: model.AuthUser{ID: 1, Username: "admin", IsSuperuser: true},
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`)).
+					WithArgs(12, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "is_active"}).AddRow(12, "bob", "old@example.test", true))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "auth_user" SET "email"=$1,"is_superuser"=$2 WHERE "id" = $3`)).
+					WithArgs("bob@example.test", true, 12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			_, err := logger.InitLogger(gin.TestMode)
+			require.NoError(t, err)
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+			})
+			require.NoError(t, err)
+			previousDB := db.DB
+			db.DB = gormDB
+			t.Cleanup(func() {
+				db.DB = previousDB
+				mock.ExpectClose()
+				require.NoError(t, sqlDB.Close())
+			})
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			router := gin.New()
+			router.PATCH("/auth_user/:id", func(c *gin.Context) {
+				c.Set("auth_user", tt.caller)
+			}, UpdateAuthUser)
+			request := httptest.NewRequest(http.MethodPatch, "/auth_user/12", bytes.NewBufferString(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK {
+				require.Contains(t, response.Body.String(), `"email":"bob@example.test"`)
+				require.Contains(t, response.Body.String(), `"is_superuser":true`)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/auth"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CreateAuthUserRequest is the request body for creating a local Agartha user.
+type CreateAuthUserRequest struct {
+	Username    string `json:"username" binding:"required" example:"alice"`
+	Password    string `json:"password" binding:"required" example:"correct horse battery staple"`
+	FirstName   string `json:"first_name" example:"Alice"`
+	LastName    string `json:"last_name" example:"Admin"`
+	Email       string `json:"email" example:"alice@example.com"`
+	IsActive    *bool  `json:"is_active" example:"true"`
+	IsSuperuser bool   `json:"is_superuser" example:"false"`
+}
+
+// CreateAuthUser creates a local Agartha user.
+//
+//	@Summary		Create a local Agartha user.
+//	@Description	Create a local Agartha user with a pgcrypto hashed password that meets the password policy. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	model.AuthUser
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user [post]
+//	@Param			req	body	CreateAuthUserRequest	true	"User to create."
+//	@Security		Bearer
+func CreateAuthUser(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	var req CreateAuthUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if currentUser, ok := middleware.AuthenticatedUser(c); req.IsSuperuser && !(ok && currentUser.IsSuperuser) {
+		httputil.NewError(c, http.StatusForbidden, "Only superusers can create superusers.")
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		httputil.NewError(c, http.StatusBadRequest, "Username is required.")
+		return
+	}
+	if err := auth.CheckPasswordPolicy(req.Password, req.Username); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&model.AuthUser{}).Where("username = ?", req.Username).Count(&existing).Error; err != nil {
+		log.Error("Failed to check for an existing auth user", zap.String("username", req.Username), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create user.")
+		return
+	}
+	if existing > 0 {
+		httputil.NewError(c, http.StatusConflict, "A user with that username already exists.")
+		return
+	}
+
+	user := model.AuthUser{
+		Username:    req.Username,
+		Password:    req.Password,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		IsActive:    req.IsActive == nil || *req.IsActive,
+		IsSuperuser: req.IsSuperuser,
+		DateJoined:  time.Now(),
+	}
+	if err := user.Create(db); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			httputil.NewError(c, http.StatusConflict, "A user with that username already exists.")
+			return
+		}
+		log.Error("Failed to create auth user", zap.String("username", req.Username), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create user.")
+		return
+	}
+
+	log.Info("Created auth user", zap.String("username", user.Username), zap.Uint("id", user.ID))
+	c.JSON(http.StatusCreated, user)
+}
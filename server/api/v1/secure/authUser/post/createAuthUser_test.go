@@ -0,0 +1,188 @@
+package authUser
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestCreateAuthUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "missing password",
+			body:       `{"username":"bob"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "password violates policy",
+			body:       `{"username":"bob","password":"secret"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"Password must be at least 12 characters long."}`,
+		},
+		{
+			name:       "password contains username",
+			body:       `{"username":"bob","password":"Bob-secret-2024"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"Password must not contain the username."}`,
+		},
+		{
+			name: "duplicate username",
+			body: `{"username":"bob","password":"Correct-Horse-42"}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "auth_user" WHERE username = $1`)).
+					WithArgs("bob").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"code":409,"message":"A user with that username already exists."}`,
+		},
+		{
+			name: "created",
+			body: `{"username":" bob ","password":"Correct-Horse-42","email":"bob@example.test","is_active":false}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "auth_user" WHERE username = $1`)).
+					WithArgs("bob").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectQuery(`INSERT INTO auth_user`).
+					WithArgs("Correct-Horse-42", nil, false, "bob", "", "", "bob@example.test", false, false, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+			},
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			response := serveAuthUserRequest(http.MethodPost, "/auth_user", "", CreateAuthUser, tt.body)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+			require.NotContains(t, response.Body.String(), "Correct-Horse-42")
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestSetAuthUserPassword(t *testing.T) {
+	const userQuery = `SELECT * FROM "auth_user" WHERE "auth_user"."id" = $1 ORDER BY "auth_user"."id" LIMIT $2`
+	const localHash = "$2a$08$abcdefghijklmnopqrstuv"
+
+	tests := []struct {
+		name       string
+		id         string
+		password   string
+		found      bool
+		provider   string
+		hash       string
+		reset      bool
+		wantStatus int
+	}{
+		{name: "invalid id", id: "alice", password: "N3w-password!", wantStatus: http.StatusBadRequest},
+		{name: "missing user", id: "12", password: "N3w-password!", wantStatus: http.StatusNotFound},
+		{name: "password violates policy", id: "12", password: "n3w", found: true, hash: localHash, wantStatus: http.StatusBadRequest},
+		{name: "password contains username", id: "12", password: "Bob-password-1", found: true, hash: localHash, wantStatus: http.StatusBadRequest},
+		{name: "directory account", id: "12", password: "N3w-password!", found: true, wantStatus: http.StatusConflict},
+		{name: "named provider account", id: "12", password: "N3w-password!", found: true, provider: "corp", hash: localHash, wantStatus: http.StatusConflict},
+		{name: "reset", id: "12", password: "N3w-password!", found: true, hash: localHash, reset: true, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			if tt.id == "12" {
+				rows := sqlmock.NewRows([]string{"id", "username", "password", "provider"})
+				if tt.found {
+					rows.AddRow(12, "bob", tt.hash, tt.provider)
+				}
+				mock.ExpectQuery(regexp.QuoteMeta(userQuery)).WithArgs(uint64(12), 1).WillReturnRows(rows)
+			}
+			if tt.reset {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_user SET password = crypt($1, gen_salt('bf', 8)) WHERE id = $2`)).
+					WithArgs(tt.password, 12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM session_user_map WHERE user_id = $1)`)).
+					WithArgs(12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO revoked_token`)).
+					WithArgs(12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`)).
+					WithArgs(12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM session_user_map WHERE user_id = $1`)).
+					WithArgs(12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = '' WHERE user_id = $1`)).
+					WithArgs(12).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			response := serveAuthUserRequest(http.MethodPost, "/auth_user/"+tt.id+"/password", "/auth_user/:id/password", SetAuthUserPassword, `{"password":"`+tt.password+`"}`)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveAuthUserRequest(method, url, route string, handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	if route == "" {
+		route = url
+	}
+	router := gin.New()
+	router.Handle(method, route, handler)
+	request := httptest.NewRequest(method, url, bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
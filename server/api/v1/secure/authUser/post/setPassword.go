@@ -0,0 +1,88 @@
+package authUser
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/auth"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SetPasswordRequest is the request body for resetting a local password.
+type SetPasswordRequest struct {
+	Password string `json:"password" binding:"required" example:"correct horse battery staple"`
+}
+
+// SetAuthUserPassword resets a user's local password.
+//
+//	@Summary		Reset a local Agartha password.
+//	@Description	Replace the local password of an Agartha user, applying the password policy and ending every session and refresh token issued under the old password. Accounts provisioned by LDAP, SSO or another external provider have no local password and are refused with 409. Requires superuser access or the admin-users capability.
+//	@Tags			AuthUser
+//	@Accept			json
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/password [post]
+//	@Param			id	path	int					true	"ID of the user"
+//	@Param			req	body	SetPasswordRequest	true	"New password."
+//	@Security		Bearer
+func SetAuthUserPassword(c *gin.Context) {
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.DB.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+			return
+		}
+		log.Error("Failed to fetch auth user", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to reset password.")
+		return
+	}
+	if user.ExternallyManaged() {
+		// A local password would let the account log in without the
+		// directory that grants its groups and Salt permissions.
+		httputil.NewError(c, http.StatusConflict, "Account is managed by an external authentication provider.")
+		return
+	}
+	if err := auth.CheckPasswordPolicy(req.Password, user.Username); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := auth.ReplacePassword(db.DB, &user, req.Password); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+			return
+		}
+		log.Error("Failed to reset auth user password", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to reset password.")
+		return
+	}
+
+	log.Info("Reset auth user password", zap.Uint64("id", id))
+	c.Status(http.StatusNoContent)
+}
@@ -1,13 +1,34 @@
 package authUser
 
 import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/authUser/delete"
 	get "github.com/PaulChristophel/agartha/server/api/v1/secure/authUser/get"
+	patch "github.com/PaulChristophel/agartha/server/api/v1/secure/authUser/patch"
+	post "github.com/PaulChristophel/agartha/server/api/v1/secure/authUser/post"
 	"github.com/gin-gonic/gin"
 )
 
 func AddRoutes(rg *gin.RouterGroup) {
 	grp := rg.Group("/auth_user")
 
-	// grp.GET("/", GetauthUsers)
 	grp.GET("/:id", get.GetAuthUser)
+	grp.GET("/:id/group", get.GetAuthUserGroups)
+}
+
+// AddAdminRoutes registers the user administration routes. The caller is
+// responsible for restricting rg to superusers and holders of the
+// admin-users capability.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/auth_user")
+
+	grp.GET("", get.GetAuthUsers)
+	grp.POST("", post.CreateAuthUser)
+	grp.PATCH("/:id", patch.UpdateAuthUser)
+	grp.POST("/:id/password", post.SetAuthUserPassword)
+	grp.DELETE("/:id", delete.DeleteAuthUser)
+	grp.GET("/:id/session", get.GetAuthUserSessions)
+	grp.DELETE("/:id/session", delete.DeleteAuthUserSessions)
+	grp.DELETE("/:id/session/:session_id", delete.DeleteAuthUserSession)
+	grp.GET("/:id/lockout", get.GetAuthUserLockout)
+	grp.DELETE("/:id/lockout", delete.DeleteAuthUserLockout)
 }
@@ -0,0 +1,54 @@
+package group
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DeleteGroup removes an Agartha group and, through foreign keys, its role
+// assignments and memberships.
+//
+//	@Summary		Delete an Agartha group.
+//	@Description	Delete an Agartha group. Its members lose the capabilities of its roles on their next request. Requires superuser access.
+//	@Tags			Group
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/group/{id} [delete]
+//	@Param			id	path	int	true	"ID of the group"
+//	@Security		Bearer
+func DeleteGroup(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid group ID.")
+		return
+	}
+
+	result := db.Delete(&model.Group{}, id)
+	if result.Error != nil {
+		log.Error("Failed to delete group", zap.Uint64("id", id), zap.Error(result.Error))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to delete group.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		httputil.NewError(c, http.StatusNotFound, "No group data present.")
+		return
+	}
+
+	log.Info("Deleted group", zap.Uint64("id", id))
+	c.Status(http.StatusNoContent)
+}
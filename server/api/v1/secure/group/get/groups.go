@@ -0,0 +1,43 @@
+package group
+
+import (
+	"net/http"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetGroups lists every Agartha group with its roles and members.
+//
+//	@Summary		List Agartha groups.
+//	@Description	List every Agartha group with the IDs of its roles and members, ordered by name. Requires superuser access.
+//	@Tags			Group
+//	@Produce		json
+//	@Success		200	{array}		model.Group
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/group [get]
+//	@Security		Bearer
+func GetGroups(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	groups := []model.Group{}
+	if err := db.Order("name").Find(&groups).Error; err != nil {
+		log.Error("Failed to list groups", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list groups.")
+		return
+	}
+	if err := model.LoadGroupAssignments(db, groups); err != nil {
+		log.Error("Failed to load group assignments", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list groups.")
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
@@ -0,0 +1,147 @@
+package group
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UpdateGroupRequest lists the group fields an administrator may change.
+// Omitted fields are left unchanged; role_ids and user_ids replace the whole
+// assignment.
+type UpdateGroupRequest struct {
+	Name        *string `json:"name" example:"helpdesk"`
+	Description *string `json:"description" example:"First-line support"`
+	RoleIDs     *[]uint `json:"role_ids" example:"1"`
+	UserIDs     *[]uint `json:"user_ids" example:"7"`
+}
+
+// UpdateGroup changes a group's name, description, roles or members.
+//
+//	@Summary		Update an Agartha group.
+//	@Description	Update the name, description, roles or members of an Agartha group. role_ids and user_ids replace the current assignments. Requires superuser access.
+//	@Tags			Group
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	model.Group
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/group/{id} [patch]
+//	@Param			id	path	int					true	"ID of the group"
+//	@Param			req	body	UpdateGroupRequest	true	"Fields to update."
+//	@Security		Bearer
+func UpdateGroup(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid group ID.")
+		return
+	}
+	var req UpdateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := map[string]any{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 150 {
+			httputil.NewError(c, http.StatusBadRequest, "Name must be between 1 and 150 characters.")
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if len(updates) == 0 && req.RoleIDs == nil && req.UserIDs == nil {
+		httputil.NewError(c, http.StatusBadRequest, "No fields to update.")
+		return
+	}
+
+	var group model.Group
+	if err := db.First(&group, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No group data present.")
+			return
+		}
+		log.Error("Failed to load group", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update group.")
+		return
+	}
+	if name, ok := updates["name"]; ok && name != group.Name {
+		var existing int64
+		if err := db.Model(&model.Group{}).Where("name = ? AND id <> ?", name, id).Count(&existing).Error; err != nil {
+			log.Error("Failed to check for an existing group", zap.Any("name", name), zap.Error(err))
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to update group.")
+			return
+		}
+		if existing > 0 {
+			httputil.NewError(c, http.StatusConflict, "A group with that name already exists.")
+			return
+		}
+	}
+	var roleIDs, userIDs []uint
+	if req.RoleIDs != nil {
+		roleIDs = *req.RoleIDs
+	}
+	if req.UserIDs != nil {
+		userIDs = *req.UserIDs
+	}
+	if err := model.CheckGroupAssignments(db, roleIDs, userIDs); err != nil {
+		if errors.Is(err, model.ErrUnknownGroupAssignment) {
+			httputil.NewError(c, http.StatusBadRequest, "role_ids and user_ids must name existing roles and users.")
+			return
+		}
+		log.Error("Failed to check group assignments", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update group.")
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&group).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.RoleIDs != nil {
+			if err := model.ReplaceGroupRoles(tx, group.ID, roleIDs); err != nil {
+				return err
+			}
+		}
+		if req.UserIDs != nil {
+			return model.ReplaceGroupMembers(tx, group.ID, userIDs)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Failed to update group", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update group.")
+		return
+	}
+	groups := []model.Group{group}
+	if err := model.LoadGroupAssignments(db, groups); err != nil {
+		log.Error("Failed to load group assignments", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update group.")
+		return
+	}
+
+	log.Info("Updated group", zap.Uint64("id", id), zap.Any("fields", updates), zap.Uints("role_ids", groups[0].RoleIDs), zap.Uints("user_ids", groups[0].UserIDs))
+	c.JSON(http.StatusOK, groups[0])
+}
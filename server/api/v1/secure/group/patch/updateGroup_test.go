@@ -0,0 +1,147 @@
+package group
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const selectGroup = `SELECT * FROM "rbac_group" WHERE id = $1 ORDER BY "rbac_group"."id" LIMIT $2`
+
+func TestUpdateGroup(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "no fields",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"No fields to update."}`,
+		},
+		{
+			name: "missing group",
+			body: `{"user_ids":[7]}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(selectGroup)).
+					WithArgs(2, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"code":404,"message":"No group data present."}`,
+		},
+		{
+			name: "unknown role",
+			body: `{"role_ids":[1,9]}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(selectGroup)).
+					WithArgs(2, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "helpdesk"))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "rbac_role" WHERE id IN ($1,$2)`)).
+					WithArgs(1, 9).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"role_ids and user_ids must name existing roles and users."}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			response := serveUpdateGroup(tt.body)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("members replaced", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(selectGroup)).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "helpdesk"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "auth_user" WHERE id IN ($1,$2)`)).
+			WithArgs(7, 8).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "rbac_group_member" WHERE group_id = $1`)).
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "rbac_group_member" ("group_id","user_id") VALUES ($1,$2),($3,$4)`)).
+			WithArgs(2, 7, 2, 8).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "rbac_group_role" WHERE group_id IN ($1) ORDER BY group_id, role_id`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"group_id", "role_id"}).AddRow(2, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "rbac_group_member" WHERE group_id IN ($1) ORDER BY group_id, user_id`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"group_id", "user_id"}).AddRow(2, 7).AddRow(2, 8))
+
+		response := serveUpdateGroup(`{"user_ids":[7,8,7]}`)
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		var updated model.Group
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &updated))
+		require.Equal(t, []uint{1}, updated.RoleIDs)
+		require.Equal(t, []uint{7, 8}, updated.UserIDs)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveUpdateGroup(body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.PATCH("/group/:id", UpdateGroup)
+	request := httptest.NewRequest(http.MethodPatch, "/group/2", bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
@@ -0,0 +1,100 @@
+package group
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CreateGroupRequest is the request body for creating an Agartha group.
+type CreateGroupRequest struct {
+	Name        string `json:"name" binding:"required" example:"helpdesk"`
+	Description string `json:"description" example:"First-line support"`
+	RoleIDs     []uint `json:"role_ids" example:"1"`
+	UserIDs     []uint `json:"user_ids" example:"7"`
+}
+
+// CreateGroup creates an Agartha group.
+//
+//	@Summary		Create an Agartha group.
+//	@Description	Create a group, optionally assigning roles and members. Members receive the capabilities of every role assigned to the group. Requires superuser access.
+//	@Tags			Group
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	model.Group
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/group [post]
+//	@Param			req	body	CreateGroupRequest	true	"Group to create."
+//	@Security		Bearer
+func CreateGroup(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	var req CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 150 {
+		httputil.NewError(c, http.StatusBadRequest, "Name must be between 1 and 150 characters.")
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&model.Group{}).Where("name = ?", req.Name).Count(&existing).Error; err != nil {
+		log.Error("Failed to check for an existing group", zap.String("name", req.Name), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create group.")
+		return
+	}
+	if existing > 0 {
+		httputil.NewError(c, http.StatusConflict, "A group with that name already exists.")
+		return
+	}
+	if err := model.CheckGroupAssignments(db, req.RoleIDs, req.UserIDs); err != nil {
+		if errors.Is(err, model.ErrUnknownGroupAssignment) {
+			httputil.NewError(c, http.StatusBadRequest, "role_ids and user_ids must name existing roles and users.")
+			return
+		}
+		log.Error("Failed to check group assignments", zap.String("name", req.Name), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create group.")
+		return
+	}
+
+	group := model.Group{Name: req.Name, Description: req.Description}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		if err := model.ReplaceGroupRoles(tx, group.ID, req.RoleIDs); err != nil {
+			return err
+		}
+		return model.ReplaceGroupMembers(tx, group.ID, req.UserIDs)
+	})
+	if err != nil {
+		log.Error("Failed to create group", zap.String("name", req.Name), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create group.")
+		return
+	}
+	groups := []model.Group{group}
+	if err := model.LoadGroupAssignments(db, groups); err != nil {
+		log.Error("Failed to load group assignments", zap.Uint("id", group.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create group.")
+		return
+	}
+
+	log.Info("Created group", zap.Uint("id", group.ID), zap.String("name", group.Name), zap.Uints("role_ids", groups[0].RoleIDs), zap.Uints("user_ids", groups[0].UserIDs))
+	c.JSON(http.StatusCreated, groups[0])
+}
@@ -0,0 +1,20 @@
+package group
+
+import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/group/delete"
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/group/get"
+	patch "github.com/PaulChristophel/agartha/server/api/v1/secure/group/patch"
+	post "github.com/PaulChristophel/agartha/server/api/v1/secure/group/post"
+	"github.com/gin-gonic/gin"
+)
+
+// AddAdminRoutes registers the group administration routes. The caller is
+// responsible for restricting rg to superusers.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/group")
+
+	grp.GET("", get.GetGroups)
+	grp.POST("", post.CreateGroup)
+	grp.PATCH("/:id", patch.UpdateGroup)
+	grp.DELETE("/:id", delete.DeleteGroup)
+}
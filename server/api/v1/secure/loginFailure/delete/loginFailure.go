@@ -0,0 +1,54 @@
+package loginFailure
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DeleteLoginFailure forgets one failed login counter, lifting its delay or
+// lockout.
+//
+//	@Summary		Clear a failed login counter.
+//	@Description	Forget the failed logins counted against one username or client IP address, ending any delay or lockout. Requires superuser access or the admin-users capability.
+//	@Tags			LoginFailure
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/login_failure/{id} [delete]
+//	@Param			id	path	int	true	"ID of the failed login counter"
+//	@Security		Bearer
+func DeleteLoginFailure(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid login failure ID.")
+		return
+	}
+
+	result := db.Delete(&model.LoginFailure{}, id)
+	if result.Error != nil {
+		log.Error("Failed to delete login failure", zap.Uint64("id", id), zap.Error(result.Error))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to delete login failure.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		httputil.NewError(c, http.StatusNotFound, "No login_failure data present.")
+		return
+	}
+
+	log.Info("Cleared login failures", zap.Uint64("id", id))
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package loginFailure
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetLoginFailures lists the usernames and client IP addresses with recent
+// failed logins.
+//
+//	@Summary		List failed login counters.
+//	@Description	List the usernames and client IP addresses with recent failed password logins, most recent first. Requires superuser access or the admin-users capability.
+//	@Tags			LoginFailure
+//	@Produce		json
+//	@Success		200	{array}		model.LoginFailure
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/login_failure [get]
+//	@Param			kind	query	string	false	"Filter on kind (username or client_ip)"
+//	@Param			locked	query	bool	false	"Only list counters that are currently locked out"
+//	@Security		Bearer
+func GetLoginFailures(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	query := db.Model(&model.LoginFailure{})
+	switch kind := c.Query("kind"); kind {
+	case "":
+	case model.LoginFailureUsername, model.LoginFailureClientIP:
+		query = query.Where("kind = ?", kind)
+	default:
+		httputil.NewError(c, http.StatusBadRequest, "invalid kind parameter")
+		return
+	}
+	if value := c.Query("locked"); value != "" {
+		locked, err := strconv.ParseBool(value)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, "invalid locked parameter")
+			return
+		}
+		if locked {
+			query = query.Where("locked_until > ?", time.Now())
+		} else {
+			query = query.Where("locked_until IS NULL OR locked_until <= ?", time.Now())
+		}
+	}
+
+	failures := []model.LoginFailure{}
+	if err := query.Order("last_failure_at desc").Find(&failures).Error; err != nil {
+		log.Error("Failed to list login failures", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list login failures.")
+		return
+	}
+
+	c.JSON(http.StatusOK, failures)
+}
@@ -0,0 +1,17 @@
+package loginFailure
+
+import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/loginFailure/delete"
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/loginFailure/get"
+	"github.com/gin-gonic/gin"
+)
+
+// AddAdminRoutes registers the failed login administration routes. The
+// caller is responsible for restricting rg to superusers and holders of the
+// admin-users capability.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/login_failure")
+
+	grp.GET("", get.GetLoginFailures)
+	grp.DELETE("/:id", delete.DeleteLoginFailure)
+}
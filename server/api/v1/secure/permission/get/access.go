@@ -0,0 +1,135 @@
+package permission
+
+import (
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+)
+
+// requirement is the Salt permission check a route applies to ordinary users.
+type requirement int
+
+const (
+	requireRead requirement = iota
+	requireExecute
+	requireWheel
+	requireRawKeys
+	requireSuperuser
+)
+
+// guardedRoute mirrors the authorization applied to an Agartha route in
+// routes/main.go and the route packages.
+type guardedRoute struct {
+	method      string
+	path        string
+	capability  model.Capability
+	requirement requirement
+	function    string
+}
+
+var guardedRoutes = []guardedRoute{
+	{method: "GET", path: "/api/v1/jid", capability: model.CapabilityViewReturns, requirement: requireRead},
+	{method: "GET", path: "/api/v1/salt_return", capability: model.CapabilityViewReturns, requirement: requireRead},
+	{method: "GET", path: "/api/v1/salt_event", capability: model.CapabilityViewReturns, requirement: requireRead},
+	{method: "GET", path: "/api/v1/high_state", capability: model.CapabilityViewReturns, requirement: requireRead},
+	{method: "GET", path: "/api/v1/conformity", capability: model.CapabilityViewReturns, requirement: requireRead},
+	{method: "POST", path: "/api/v1/conformity/refresh", capability: model.CapabilityRefreshViews, requirement: requireExecute},
+	{method: "GET", path: "/api/v1/salt_minion", capability: model.CapabilityViewPillar, requirement: requireRead},
+	{method: "POST", path: "/api/v1/salt_minion/keys/refresh", capability: model.CapabilityRefreshViews, requirement: requireExecute},
+	{method: "GET", path: "/api/v1/salt_cache", capability: model.CapabilityViewPillar, requirement: requireRead},
+	{method: "GET", path: "/api/v2/salt_cache", capability: model.CapabilityViewPillar, requirement: requireRead},
+	{method: "POST", path: "/api/v1/salt_cache/fun_keys/refresh", capability: model.CapabilityRefreshViews, requirement: requireExecute},
+	{method: "POST", path: "/api/v1/salt_cache", requirement: requireExecute},
+	{method: "DELETE", path: "/api/v1/salt_cache", requirement: requireExecute},
+	{method: "GET", path: "/api/v1/netapi/stats", requirement: requireRead},
+	{method: "POST", path: "/api/v1/netapi", requirement: requireExecute},
+	{method: "POST", path: "/api/v1/netapi/hook", requirement: requireExecute},
+	{method: "GET", path: "/api/v1/salt_keys/minion_keys", capability: model.CapabilityManageKeys, requirement: requireWheel, function: "key.list_all"},
+	{method: "POST", path: "/api/v1/salt_keys/minion_keys/accept", capability: model.CapabilityManageKeys, requirement: requireWheel, function: "key.accept"},
+	{method: "POST", path: "/api/v1/salt_keys/minion_keys/reject", capability: model.CapabilityManageKeys, requirement: requireWheel, function: "key.reject"},
+	{method: "POST", path: "/api/v1/salt_keys/minion_keys/delete", capability: model.CapabilityManageKeys, requirement: requireWheel, function: "key.delete"},
+	{method: "GET", path: "/api/v1/salt_keys", requirement: requireRawKeys},
+	{method: "DELETE", path: "/api/v1/salt_keys", requirement: requireRawKeys},
+	{method: "GET", path: "/api/v1/secure/auth_user", capability: model.CapabilityAdminUsers, requirement: requireSuperuser},
+	{method: "GET", path: "/api/v1/secure/login_failure", capability: model.CapabilityAdminUsers, requirement: requireSuperuser},
+	{method: "GET", path: "/api/v1/secure/audit_log", requirement: requireSuperuser},
+	{method: "GET", path: "/api/v1/secure/role", requirement: requireSuperuser},
+	{method: "GET", path: "/api/v1/secure/group", requirement: requireSuperuser},
+}
+
+// RouteAccess reports whether a user may use an Agartha route. Routes below
+// Path follow the same rule.
+type RouteAccess struct {
+	Method    string `json:"method" example:"GET"`
+	Path      string `json:"path" example:"/api/v1/salt_return"`
+	Allowed   bool   `json:"allowed" example:"true"`
+	GrantedBy string `json:"granted_by,omitempty" example:"salt_permissions"`
+	Requires  string `json:"requires" example:"view-returns capability or Salt read permission"`
+}
+
+// Sources of access reported in RouteAccess.GrantedBy.
+const (
+	grantedBySuperuser       = "superuser"
+	grantedByStaff           = "staff"
+	grantedByCapability      = "capability"
+	grantedBySaltPermissions = "salt_permissions"
+)
+
+func routeAccess(user model.AuthUser, capabilities []model.Capability, access middleware.SaltAccess) []RouteAccess {
+	granted := make(map[model.Capability]bool, len(capabilities))
+	for _, capability := range capabilities {
+		granted[capability] = true
+	}
+
+	routes := make([]RouteAccess, 0, len(guardedRoutes))
+	for _, route := range guardedRoutes {
+		result := RouteAccess{Method: route.method, Path: route.path, Requires: route.describe()}
+		switch {
+		case user.IsSuperuser:
+			result.GrantedBy = grantedBySuperuser
+		case route.capability != "" && granted[route.capability]:
+			result.GrantedBy = grantedByCapability
+		case route.requirement == requireSuperuser:
+		case user.IsStaff:
+			result.GrantedBy = grantedByStaff
+		case route.allowedBy(access):
+			result.GrantedBy = grantedBySaltPermissions
+		}
+		result.Allowed = result.GrantedBy != ""
+		routes = append(routes, result)
+	}
+	return routes
+}
+
+func (r guardedRoute) allowedBy(access middleware.SaltAccess) bool {
+	switch r.requirement {
+	case requireRead:
+		return access.ReadSaltData
+	case requireExecute:
+		return access.ExecuteSaltCommands
+	case requireWheel:
+		return access.WheelFunctionAllowed(r.function)
+	case requireRawKeys:
+		return access.RawKeyAdministration
+	}
+	return false
+}
+
+func (r guardedRoute) describe() string {
+	var salt string
+	switch r.requirement {
+	case requireRead:
+		salt = "Salt read permission"
+	case requireExecute:
+		salt = "Salt command permission"
+	case requireWheel:
+		salt = "@wheel permission for " + r.function
+	case requireRawKeys:
+		salt = "full @wheel permission"
+	case requireSuperuser:
+		salt = "superuser access"
+	}
+	if r.capability == "" {
+		return salt
+	}
+	return string(r.capability) + " capability or " + salt
+}
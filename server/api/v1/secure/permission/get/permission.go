@@ -0,0 +1,133 @@
+package permission
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Permissions explains what a user may do in Agartha and why.
+type Permissions struct {
+	UserID                   uint                  `json:"user_id" example:"7"`
+	Username                 string                `json:"username" example:"alice"`
+	IsSuperuser              bool                  `json:"is_superuser" example:"false"`
+	IsStaff                  bool                  `json:"is_staff" example:"false"`
+	Capabilities             []model.Capability    `json:"capabilities" example:"view-returns"`
+	SaltPermissions          any                   `json:"salt_permissions" swaggertype:"object"`
+	SaltPermissionsUpdatedAt *time.Time            `json:"salt_permissions_updated_at" example:"2006-01-02T15:04:05.999999-07:00"`
+	SaltAccess               middleware.SaltAccess `json:"salt_access"`
+	Routes                   []RouteAccess         `json:"routes"`
+}
+
+// GetPermissions explains the current user's permissions.
+//
+//	@Summary		Explain the current user's permissions.
+//	@Description	Decode the Salt permissions cached at the current user's last Salt login and show the Salt access, role capabilities and Agartha routes they grant.
+//	@Tags			Permission
+//	@Produce		json
+//	@Success		200	{object}	Permissions
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/permission [get]
+//	@Security		Bearer
+func GetPermissions(c *gin.Context) {
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	respond(c, user)
+}
+
+// GetAuthUserPermissions explains another user's permissions.
+//
+//	@Summary		Explain a user's permissions.
+//	@Description	Decode the Salt permissions cached at an Agartha user's last Salt login and show the Salt access, role capabilities and Agartha routes they grant. Requires superuser access.
+//	@Tags			AuthUser
+//	@Produce		json
+//	@Success		200	{object}	Permissions
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/permission [get]
+//	@Param			id	path	int	true	"ID of the user"
+//	@Security		Bearer
+func GetAuthUserPermissions(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+			return
+		}
+		log.Error("Failed to fetch user", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch permissions.")
+		return
+	}
+	respond(c, user)
+}
+
+func respond(c *gin.Context, user model.AuthUser) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	permissions := Permissions{
+		UserID:          user.ID,
+		Username:        user.Username,
+		IsSuperuser:     user.IsSuperuser,
+		IsStaff:         user.IsStaff,
+		SaltPermissions: []any{},
+	}
+
+	var settings model.UserSettings
+	err := db.Select("salt_permissions", "salt_permissions_updated_at").Where("user_id = ?", user.ID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error("Failed to fetch Salt permissions", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch permissions.")
+		return
+	}
+	if err == nil {
+		if err := json.Unmarshal([]byte(settings.SaltPermissions), &permissions.SaltPermissions); err != nil {
+			log.Error("Failed to decode Salt permissions", zap.Uint("user_id", user.ID), zap.Error(err))
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to decode Salt permissions.")
+			return
+		}
+		permissions.SaltPermissionsUpdatedAt = settings.SaltPermissionsUpdatedAt
+	}
+
+	capabilities, err := model.UserCapabilities(db, user.ID)
+	if err != nil {
+		log.Error("Failed to fetch capabilities", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to fetch permissions.")
+		return
+	}
+	if capabilities == nil {
+		capabilities = []model.Capability{}
+	}
+	permissions.Capabilities = capabilities
+	permissions.SaltAccess = middleware.DescribeSaltAccess(permissions.SaltPermissions)
+	permissions.Routes = routeAccess(user, capabilities, permissions.SaltAccess)
+
+	c.JSON(http.StatusOK, permissions)
+}
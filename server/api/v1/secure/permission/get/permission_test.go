@@ -0,0 +1,171 @@
+package permission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const settingsQuery = `SELECT "salt_permissions","salt_permissions_updated_at" FROM "user_settings" WHERE user_id = $1 ORDER BY "user_settings"."user_id" LIMIT $2`
+
+func TestGetPermissionsExplainsSaltAccessAndRoutes(t *testing.T) {
+	mock := installMockDatabase(t)
+	cachedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+		WithArgs(uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions", "salt_permissions_updated_at"}).
+			AddRow(`[{"web*": ["test.*"]}, "@jobs", {"@wheel": ["key.list_all", "key.accept"]}]`, cachedAt))
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"capability"}).AddRow("view-pillar"))
+
+	response := servePermissions(model.AuthUser{ID: 7, Username: "alice"}, "/permission")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var permissions Permissions
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &permissions))
+	require.Equal(t, "alice", permissions.Username)
+	require.Equal(t, []model.Capability{model.CapabilityViewPillar}, permissions.Capabilities)
+	require.True(t, cachedAt.Equal(*permissions.SaltPermissionsUpdatedAt))
+	require.True(t, permissions.SaltAccess.ReadSaltData)
+	require.True(t, permissions.SaltAccess.ExecuteSaltCommands)
+	require.False(t, permissions.SaltAccess.RawKeyAdministration)
+	require.Equal(t, []string{"key.list_all", "key.accept"}, permissions.SaltAccess.WheelFunctions)
+	require.True(t, permissions.SaltAccess.AllMinions)
+
+	routes := make(map[string]RouteAccess)
+	for _, route := range permissions.Routes {
+		routes[route.Method+" "+route.Path] = route
+	}
+	require.Equal(t, "salt_permissions", routes["GET /api/v1/salt_return"].GrantedBy)
+	require.Equal(t, "capability", routes["GET /api/v1/salt_minion"].GrantedBy)
+	require.True(t, routes["POST /api/v1/salt_keys/minion_keys/accept"].Allowed)
+	require.False(t, routes["POST /api/v1/salt_keys/minion_keys/delete"].Allowed)
+	require.Equal(t, "manage-keys capability or @wheel permission for key.delete", routes["POST /api/v1/salt_keys/minion_keys/delete"].Requires)
+	require.False(t, routes["GET /api/v1/salt_keys"].Allowed)
+	require.False(t, routes["GET /api/v1/secure/audit_log"].Allowed)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetPermissionsWithoutCachedSaltPermissions(t *testing.T) {
+	mock := installMockDatabase(t)
+	mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+		WithArgs(uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions", "salt_permissions_updated_at"}))
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"capability"}))
+
+	response := servePermissions(model.AuthUser{ID: 7, Username: "alice"}, "/permission")
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `[]`, extract(t, response, "salt_permissions"))
+	require.JSONEq(t, `null`, extract(t, response, "salt_permissions_updated_at"))
+	require.JSONEq(t, `{"read_salt_data": false, "execute_salt_commands": false, "wheel_functions": [], "raw_key_administration": false, "all_minions": false, "minion_targets": []}`, extract(t, response, "salt_access"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuthUserPermissions(t *testing.T) {
+	const userQuery = `SELECT * FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`
+
+	t.Run("invalid ID", func(t *testing.T) {
+		installMockDatabase(t)
+		response := servePermissions(model.AuthUser{ID: 1, IsSuperuser: true}, "/auth_user/abc/permission")
+		require.Equal(t, http.StatusBadRequest, response.Code)
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).
+			WithArgs(uint64(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		response := servePermissions(model.AuthUser{ID: 1, IsSuperuser: true}, "/auth_user/9/permission")
+		require.Equal(t, http.StatusNotFound, response.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("staff user", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).
+			WithArgs(uint64(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_staff"}).AddRow(9, "bob", true))
+		mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+			WithArgs(uint(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"salt_permissions", "salt_permissions_updated_at"}).AddRow(`[]`, nil))
+		mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+			WithArgs(uint(9)).
+			WillReturnRows(sqlmock.NewRows([]string{"capability"}))
+
+		response := servePermissions(model.AuthUser{ID: 1, IsSuperuser: true}, "/auth_user/9/permission")
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		var permissions Permissions
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &permissions))
+		require.Equal(t, uint(9), permissions.UserID)
+		for _, route := range permissions.Routes {
+			if route.Requires == "superuser access" || route.Requires == "admin-users capability or superuser access" {
+				require.False(t, route.Allowed, route.Path)
+				continue
+			}
+			require.Equal(t, "staff", route.GrantedBy, route.Path)
+		}
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func extract(t *testing.T, response *httptest.ResponseRecorder, field string) string {
+	t.Helper()
+	var body map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &body))
+	return string(body[field])
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func servePermissions(user model.AuthUser, path string) *httptest.ResponseRecorder {
+	router := gin.New()
+	setUser := func(c *gin.Context) {
+		c.Set("auth_user", user)
+	}
+	router.GET("/permission", setUser, GetPermissions)
+	router.GET("/auth_user/:id/permission", setUser, GetAuthUserPermissions)
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, path, nil))
+	return response
+}
@@ -0,0 +1,167 @@
+package permission
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	view "github.com/PaulChristophel/agartha/server/model/salt/view"
+	"github.com/PaulChristophel/agartha/server/saltacl"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// EvaluateRequest is a Salt command to check against cached Salt
+// permissions. Fields follow the salt-api lowstate format.
+type EvaluateRequest struct {
+	Client     string         `json:"client" example:"local"`
+	Fun        string         `json:"fun" binding:"required" example:"state.apply"`
+	Target     string         `json:"tgt" example:"web*"`
+	TargetType string         `json:"tgt_type" example:"glob"`
+	Args       []any          `json:"arg" swaggertype:"array,string"`
+	Kwargs     map[string]any `json:"kwarg" swaggertype:"object"`
+}
+
+// Evaluation is the outcome of checking a Salt command against a user's
+// cached Salt permissions.
+type Evaluation struct {
+	UserID   uint   `json:"user_id" example:"7"`
+	Username string `json:"username" example:"alice"`
+	saltacl.Decision
+}
+
+// EvaluatePermissions checks a Salt command against the current user's
+// cached Salt permissions.
+//
+//	@Summary		Check whether the current user may run a Salt command.
+//	@Description	Evaluate a local, runner or wheel command against the Salt permissions cached at the current user's last Salt login, the way Salt eauth would, without running it. Local commands are resolved against the known minions; grain, pillar and other targets that need minion data are rejected.
+//	@Tags			Permission
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	Evaluation
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/permission/evaluate [post]
+//	@Param			req	body	EvaluateRequest	true	"Salt command to evaluate."
+//	@Security		Bearer
+func EvaluatePermissions(c *gin.Context) {
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	evaluate(c, user, user)
+}
+
+// EvaluateAuthUserPermissions checks a Salt command against another user's
+// cached Salt permissions.
+//
+//	@Summary		Check whether a user may run a Salt command.
+//	@Description	Evaluate a local, runner or wheel command against the Salt permissions cached at an Agartha user's last Salt login, the way Salt eauth would, without running it. Requires superuser access.
+//	@Tags			AuthUser
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	Evaluation
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/auth_user/{id}/permission/evaluate [post]
+//	@Param			id	path	int				true	"ID of the user"
+//	@Param			req	body	EvaluateRequest	true	"Salt command to evaluate."
+//	@Security		Bearer
+func EvaluateAuthUserPermissions(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	viewer, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid user ID.")
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No auth_user data present.")
+			return
+		}
+		log.Error("Failed to fetch user", zap.Uint64("user_id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to evaluate permissions.")
+		return
+	}
+	evaluate(c, viewer, user)
+}
+
+// evaluate checks the request body against the subject's Salt permissions.
+// Superusers and staff are not special here: Salt checks their commands
+// against their eauth grants like anyone else's.
+func evaluate(c *gin.Context, viewer, subject model.AuthUser) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	var req EvaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	permissions, err := middleware.CachedSaltPermissions(db, subject.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Error("Failed to fetch Salt permissions", zap.Uint("user_id", subject.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to evaluate permissions.")
+		return
+	}
+
+	request := saltacl.Request{
+		Client:     req.Client,
+		Fun:        req.Fun,
+		Target:     req.Target,
+		TargetType: req.TargetType,
+		Args:       req.Args,
+		Kwargs:     req.Kwargs,
+	}
+	var minions []string
+	if client, _, _ := strings.Cut(req.Client, "_"); client == "" || client == saltacl.ClientLocal {
+		if err := db.Model(&view.SaltMinion{}).Order("minion_id").Pluck("minion_id", &minions).Error; err != nil {
+			log.Error("Failed to fetch minion IDs", zap.Error(err))
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to evaluate permissions.")
+			return
+		}
+	}
+
+	decision, err := saltacl.NewPolicy(permissions).Evaluate(request, minions)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Only superusers evaluate other users' permissions, so anyone else is
+	// looking at their own and sees the minions those permissions target.
+	if !viewer.IsSuperuser && !viewer.IsStaff && decision.Minions != nil {
+		scope := saltacl.MinionScope(permissions)
+		visible := make([]string, 0, len(decision.Minions))
+		for _, id := range decision.Minions {
+			if scope.Match(id) {
+				visible = append(visible, id)
+			}
+		}
+		decision.Minions = visible
+	}
+
+	c.JSON(http.StatusOK, Evaluation{UserID: subject.ID, Username: subject.Username, Decision: decision})
+}
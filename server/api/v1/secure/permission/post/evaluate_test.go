@@ -0,0 +1,171 @@
+package permission
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const (
+	settingsQuery = `SELECT "salt_permissions" FROM "user_settings" WHERE user_id = $1 ORDER BY "user_settings"."user_id" LIMIT $2`
+	minionsQuery  = `SELECT "minion_id" FROM "vw_salt_minions" ORDER BY minion_id`
+)
+
+func TestEvaluatePermissionsLocalCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantAllowed bool
+		wantMinions []string
+	}{
+		{name: "targeted grant", body: `{"fun": "state.apply", "tgt": "web*"}`, wantAllowed: true, wantMinions: []string{"web01", "web02"}},
+		{name: "target outside grant", body: `{"client": "local_async", "fun": "state.apply", "tgt": "*"}`, wantMinions: []string{"web01", "web02"}},
+		{name: "argument constraint", body: `{"fun": "cmd.run", "tgt": "web01", "arg": ["rm -rf /"]}`, wantMinions: []string{"web01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+				WithArgs(uint(7), 1).
+				WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).
+					AddRow(`[{"web*": ["state.*", {"cmd.run": {"args": ["uptime"]}}]}]`))
+			mock.ExpectQuery(regexp.QuoteMeta(minionsQuery)).
+				WillReturnRows(sqlmock.NewRows([]string{"minion_id"}).AddRow("db01").AddRow("web01").AddRow("web02"))
+
+			response := serveEvaluate(model.AuthUser{ID: 7, Username: "alice"}, "/permission/evaluate", tt.body)
+
+			require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+			var evaluation Evaluation
+			require.NoError(t, json.Unmarshal(response.Body.Bytes(), &evaluation))
+			require.Equal(t, uint(7), evaluation.UserID)
+			require.Equal(t, tt.wantAllowed, evaluation.Allowed, evaluation.Reason)
+			require.Equal(t, tt.wantMinions, evaluation.Minions)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEvaluatePermissionsWheelCommandWithoutCachedPermissions(t *testing.T) {
+	mock := installMockDatabase(t)
+	mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+		WithArgs(uint(7), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}))
+
+	response := serveEvaluate(model.AuthUser{ID: 7, IsStaff: true}, "/permission/evaluate", `{"client": "wheel", "fun": "key.accept"}`)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.JSONEq(t, `{"user_id": 7, "username": "", "allowed": false, "reason": "no @wheel or @key grant allows key.accept"}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvaluatePermissionsRejectsMalformedCommands(t *testing.T) {
+	t.Run("missing function", func(t *testing.T) {
+		installMockDatabase(t)
+		response := serveEvaluate(model.AuthUser{ID: 7}, "/permission/evaluate", `{"tgt": "*"}`)
+		require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+	})
+
+	for _, body := range []string{
+		`{"fun": "test.ping", "tgt": "G@os:Debian", "tgt_type": "compound"}`,
+		`{"client": "ssh", "fun": "test.ping"}`,
+		`{"client": "runner", "fun": "jobs"}`,
+	} {
+		t.Run(body, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+				WithArgs(uint(7), 1).
+				WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[".*"]`))
+			if strings.Contains(body, "tgt") {
+				mock.ExpectQuery(regexp.QuoteMeta(minionsQuery)).
+					WillReturnRows(sqlmock.NewRows([]string{"minion_id"}).AddRow("web01"))
+			}
+
+			response := serveEvaluate(model.AuthUser{ID: 7}, "/permission/evaluate", body)
+			require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEvaluateAuthUserPermissions(t *testing.T) {
+	const userQuery = `SELECT * FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`
+
+	t.Run("unknown user", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).
+			WithArgs(uint64(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		response := serveEvaluate(model.AuthUser{ID: 1, IsSuperuser: true}, "/auth_user/9/permission/evaluate", `{"fun": "test.ping", "tgt": "*"}`)
+		require.Equal(t, http.StatusNotFound, response.Code)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("runner grant", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(userQuery)).
+			WithArgs(uint64(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username"}).AddRow(9, "bob"))
+		mock.ExpectQuery(regexp.QuoteMeta(settingsQuery)).
+			WithArgs(uint(9), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(`[{"@jobs": ["list_.*"]}]`))
+
+		response := serveEvaluate(model.AuthUser{ID: 1, IsSuperuser: true}, "/auth_user/9/permission/evaluate", `{"client": "runner", "fun": "jobs.list_jobs"}`)
+
+		require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+		require.JSONEq(t, `{"user_id": 9, "username": "bob", "allowed": true, "reason": "@jobs grants jobs.list_jobs"}`, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveEvaluate(user model.AuthUser, path, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	setUser := func(c *gin.Context) {
+		c.Set("auth_user", user)
+	}
+	router.POST("/permission/evaluate", setUser, EvaluatePermissions)
+	router.POST("/auth_user/:id/permission/evaluate", setUser, EvaluateAuthUserPermissions)
+
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
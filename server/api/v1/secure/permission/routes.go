@@ -0,0 +1,21 @@
+package permission
+
+import (
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/permission/get"
+	post "github.com/PaulChristophel/agartha/server/api/v1/secure/permission/post"
+	"github.com/gin-gonic/gin"
+)
+
+// AddRoutes registers the routes explaining and evaluating the authenticated
+// user's own permissions.
+func AddRoutes(rg *gin.RouterGroup) {
+	rg.GET("/permission", get.GetPermissions)
+	rg.POST("/permission/evaluate", post.EvaluatePermissions)
+}
+
+// AddAdminRoutes registers the routes explaining and evaluating any user's
+// permissions. The caller is responsible for restricting rg to superusers.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	rg.GET("/auth_user/:id/permission", get.GetAuthUserPermissions)
+	rg.POST("/auth_user/:id/permission/evaluate", post.EvaluateAuthUserPermissions)
+}
@@ -0,0 +1,54 @@
+package role
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DeleteRole removes an Agartha role and, through foreign keys, its
+// capabilities and group assignments.
+//
+//	@Summary		Delete an Agartha role.
+//	@Description	Delete an Agartha role. Members of the groups it was assigned to lose its capabilities on their next request. Requires superuser access.
+//	@Tags			Role
+//	@Produce		json
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/role/{id} [delete]
+//	@Param			id	path	int	true	"ID of the role"
+//	@Security		Bearer
+func DeleteRole(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid role ID.")
+		return
+	}
+
+	result := db.Delete(&model.Role{}, id)
+	if result.Error != nil {
+		log.Error("Failed to delete role", zap.Uint64("id", id), zap.Error(result.Error))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to delete role.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		httputil.NewError(c, http.StatusNotFound, "No role data present.")
+		return
+	}
+
+	log.Info("Deleted role", zap.Uint64("id", id))
+	c.Status(http.StatusNoContent)
+}
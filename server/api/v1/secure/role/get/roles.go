@@ -0,0 +1,43 @@
+package role
+
+import (
+	"net/http"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetRoles lists every Agartha role with the capabilities it grants.
+//
+//	@Summary		List Agartha roles.
+//	@Description	List every Agartha role with the capabilities it grants, ordered by name. Requires superuser access.
+//	@Tags			Role
+//	@Produce		json
+//	@Success		200	{array}		model.Role
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/role [get]
+//	@Security		Bearer
+func GetRoles(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	roles := []model.Role{}
+	if err := db.Order("name").Find(&roles).Error; err != nil {
+		log.Error("Failed to list roles", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list roles.")
+		return
+	}
+	if err := model.LoadRoleCapabilities(db, roles); err != nil {
+		log.Error("Failed to load role capabilities", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list roles.")
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
@@ -0,0 +1,132 @@
+package role
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// UpdateRoleRequest lists the role fields an administrator may change.
+// Omitted fields are left unchanged; capabilities replaces the whole grant.
+type UpdateRoleRequest struct {
+	Name         *string             `json:"name" example:"helpdesk"`
+	Description  *string             `json:"description" example:"Read-only job visibility"`
+	Capabilities *[]model.Capability `json:"capabilities" example:"view-returns"`
+}
+
+// UpdateRole changes a role's name, description or capabilities.
+//
+//	@Summary		Update an Agartha role.
+//	@Description	Update the name, description or capabilities of an Agartha role. The capabilities list replaces the role's current grant and takes effect on the next request of every member. Requires superuser access.
+//	@Tags			Role
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	model.Role
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/role/{id} [patch]
+//	@Param			id	path	int					true	"ID of the role"
+//	@Param			req	body	UpdateRoleRequest	true	"Fields to update."
+//	@Security		Bearer
+func UpdateRole(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid role ID.")
+		return
+	}
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := map[string]any{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 150 {
+			httputil.NewError(c, http.StatusBadRequest, "Name must be between 1 and 150 characters.")
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	var capabilities []model.Capability
+	if req.Capabilities != nil {
+		capabilities, err = model.NormalizeCapabilities(*req.Capabilities)
+		if err != nil {
+			httputil.NewError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(updates) == 0 && req.Capabilities == nil {
+		httputil.NewError(c, http.StatusBadRequest, "No fields to update.")
+		return
+	}
+
+	var role model.Role
+	if err := db.First(&role, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusNotFound, "No role data present.")
+			return
+		}
+		log.Error("Failed to load role", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update role.")
+		return
+	}
+	if name, ok := updates["name"]; ok && name != role.Name {
+		var existing int64
+		if err := db.Model(&model.Role{}).Where("name = ? AND id <> ?", name, id).Count(&existing).Error; err != nil {
+			log.Error("Failed to check for an existing role", zap.Any("name", name), zap.Error(err))
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to update role.")
+			return
+		}
+		if existing > 0 {
+			httputil.NewError(c, http.StatusConflict, "A role with that name already exists.")
+			return
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&role).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Capabilities == nil {
+			return nil
+		}
+		return model.ReplaceRoleCapabilities(tx, role.ID, capabilities)
+	})
+	if err != nil {
+		log.Error("Failed to update role", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update role.")
+		return
+	}
+	roles := []model.Role{role}
+	if err := model.LoadRoleCapabilities(db, roles); err != nil {
+		log.Error("Failed to load role capabilities", zap.Uint64("id", id), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to update role.")
+		return
+	}
+
+	log.Info("Updated role", zap.Uint64("id", id), zap.Any("fields", updates), zap.Any("capabilities", roles[0].Capabilities))
+	c.JSON(http.StatusOK, roles[0])
+}
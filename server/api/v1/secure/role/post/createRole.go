@@ -0,0 +1,86 @@
+package role
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CreateRoleRequest is the request body for creating an Agartha role.
+type CreateRoleRequest struct {
+	Name         string             `json:"name" binding:"required" example:"helpdesk"`
+	Description  string             `json:"description" example:"Read-only job visibility"`
+	Capabilities []model.Capability `json:"capabilities" example:"view-returns"`
+}
+
+// CreateRole creates an Agartha role.
+//
+//	@Summary		Create an Agartha role.
+//	@Description	Create a role granting capabilities to the members of the groups it is assigned to. Capabilities are view-returns, view-pillar, manage-keys, refresh-views, admin-users and view-secrets. Requires superuser access.
+//	@Tags			Role
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	model.Role
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/role [post]
+//	@Param			req	body	CreateRoleRequest	true	"Role to create."
+//	@Security		Bearer
+func CreateRole(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	var req CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 150 {
+		httputil.NewError(c, http.StatusBadRequest, "Name must be between 1 and 150 characters.")
+		return
+	}
+	capabilities, err := model.NormalizeCapabilities(req.Capabilities)
+	if err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var existing int64
+	if err := db.Model(&model.Role{}).Where("name = ?", req.Name).Count(&existing).Error; err != nil {
+		log.Error("Failed to check for an existing role", zap.String("name", req.Name), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create role.")
+		return
+	}
+	if existing > 0 {
+		httputil.NewError(c, http.StatusConflict, "A role with that name already exists.")
+		return
+	}
+
+	role := model.Role{Name: req.Name, Description: req.Description}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return model.ReplaceRoleCapabilities(tx, role.ID, capabilities)
+	})
+	if err != nil {
+		log.Error("Failed to create role", zap.String("name", req.Name), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to create role.")
+		return
+	}
+	role.Capabilities = capabilities
+
+	log.Info("Created role", zap.Uint("id", role.ID), zap.String("name", role.Name), zap.Any("capabilities", capabilities))
+	c.JSON(http.StatusCreated, role)
+}
@@ -0,0 +1,129 @@
+package role
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestCreateRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "missing name",
+			body:       `{"capabilities":["view-returns"]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown capability",
+			body:       `{"name":"helpdesk","capabilities":["view-returns","run-anything"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"code":400,"message":"unknown capability \"run-anything\""}`,
+		},
+		{
+			name: "duplicate name",
+			body: `{"name":"helpdesk"}`,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "rbac_role" WHERE name = $1`)).
+					WithArgs("helpdesk").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			},
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := installMockDatabase(t)
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			response := serveCreateRole(tt.body)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("created", func(t *testing.T) {
+		mock := installMockDatabase(t)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "rbac_role" WHERE name = $1`)).
+			WithArgs("helpdesk").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "rbac_role" ("name","description") VALUES ($1,$2) RETURNING "id"`)).
+			WithArgs("helpdesk", "Job visibility").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "rbac_role_capability" WHERE role_id = $1`)).
+			WithArgs(4).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "rbac_role_capability" ("role_id","capability") VALUES ($1,$2),($3,$4)`)).
+			WithArgs(4, "view-returns", 4, "view-pillar").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		response := serveCreateRole(`{"name":" helpdesk ","description":"Job visibility","capabilities":["view-returns","view-pillar","view-returns"]}`)
+
+		require.Equal(t, http.StatusCreated, response.Code, response.Body.String())
+		var created model.Role
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &created))
+		require.Equal(t, uint(4), created.ID)
+		require.Equal(t, []model.Capability{model.CapabilityViewReturns, model.CapabilityViewPillar}, created.Capabilities)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func installMockDatabase(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	previousDB := db.DB
+	db.DB = gormDB
+	t.Cleanup(func() {
+		db.DB = previousDB
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	return mock
+}
+
+func serveCreateRole(body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/role", CreateRole)
+	request := httptest.NewRequest(http.MethodPost, "/role", bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
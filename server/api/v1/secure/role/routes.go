@@ -0,0 +1,20 @@
+package role
+
+import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/role/delete"
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/role/get"
+	patch "github.com/PaulChristophel/agartha/server/api/v1/secure/role/patch"
+	post "github.com/PaulChristophel/agartha/server/api/v1/secure/role/post"
+	"github.com/gin-gonic/gin"
+)
+
+// AddAdminRoutes registers the role administration routes. The caller is
+// responsible for restricting rg to superusers.
+func AddAdminRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/role")
+
+	grp.GET("", get.GetRoles)
+	grp.POST("", post.CreateRole)
+	grp.PATCH("/:id", patch.UpdateRole)
+	grp.DELETE("/:id", delete.DeleteRole)
+}
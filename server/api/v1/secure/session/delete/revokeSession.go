@@ -0,0 +1,59 @@
+package session
+
+import (
+	"net/http"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// RevokeSession ends one of the current user's browser sessions together
+// with the access and refresh tokens issued to it.
+//
+//	@Summary		Revoke a session.
+//	@Description	End one of the current user's browser sessions. The session's access and refresh tokens are revoked immediately.
+//	@Tags			Session
+//	@Produce		json
+//	@Success		204
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/session/{id} [delete]
+//	@Param			id	path	string	true	"ID of the session"
+//	@Security		Bearer
+func RevokeSession(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	id := c.Param("id")
+
+	var found bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		found, err = model.EndSession(tx, user.ID, id)
+		return err
+	})
+	if err != nil {
+		log.Error("Failed to revoke session", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to revoke session.")
+		return
+	}
+	if !found {
+		httputil.NewError(c, http.StatusNotFound, "No active session data present.")
+		return
+	}
+
+	log.Info("Revoked session", zap.Uint("user_id", user.ID))
+	c.Status(http.StatusNoContent)
+}
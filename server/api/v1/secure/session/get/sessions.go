@@ -0,0 +1,53 @@
+package session
+
+import (
+	"net/http"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// GetSessions lists the current user's active browser sessions.
+//
+//	@Summary		List active sessions.
+//	@Description	List the current user's unexpired browser sessions, most recently used first. The session making the request is marked as current.
+//	@Tags			Session
+//	@Produce		json
+//	@Success		200	{array}		model.ActiveSession
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/api/v1/secure/session [get]
+//	@Security		Bearer
+func GetSessions(c *gin.Context) {
+	db := db.DB
+	log := logger.GetLogger()
+
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+
+	active, err := model.ActiveSessions(db, user.ID)
+	if err != nil {
+		log.Error("Failed to list sessions", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to list sessions.")
+		return
+	}
+
+	if _, exists := c.Get(sessions.DefaultKey); exists {
+		if current := sessions.Default(c).ID(); current != "" {
+			for i := range active {
+				active[i].Current = active[i].ID == current
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, active)
+}
@@ -0,0 +1,16 @@
+package session
+
+import (
+	delete "github.com/PaulChristophel/agartha/server/api/v1/secure/session/delete"
+	get "github.com/PaulChristophel/agartha/server/api/v1/secure/session/get"
+	"github.com/gin-gonic/gin"
+)
+
+// AddRoutes registers the browser session routes. Every route acts on the
+// authenticated user's own sessions.
+func AddRoutes(rg *gin.RouterGroup) {
+	grp := rg.Group("/session")
+
+	grp.GET("", get.GetSessions)
+	grp.DELETE("/:id", delete.RevokeSession)
+}
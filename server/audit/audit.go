@@ -0,0 +1,150 @@
+// Package audit records authentication and authorization decisions in the
+// audit_log table and copies them to the configured sinks.
+package audit
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin                  = "login"
+	ActionLogout                 = "logout"
+	ActionAuthorize              = "authorize"
+	ActionSaltPermissionsRefresh = "salt_permissions_refresh"
+)
+
+// Outcomes recorded in the audit log. Denied marks a request refused by
+// policy, such as a permission check or a login lockout, rather than by
+// invalid credentials.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// Event describes what happened. The user is taken from the request context
+// when UserID and Username are left empty.
+type Event struct {
+	Action   string
+	Outcome  string
+	UserID   *uint
+	Username string
+	Method   string
+	Detail   string
+}
+
+// Sink receives a copy of every audit entry.
+type Sink interface {
+	Write(entry model.AuditLog) error
+	Close() error
+}
+
+var (
+	mu       sync.RWMutex
+	database *gorm.DB
+	sinks    []Sink
+)
+
+// Configure stores audit entries in database and opens the sinks described by
+// options, closing any opened by an earlier call. A nil database disables the
+// audit_log table.
+func Configure(db *gorm.DB, options config.AuditOptions) error {
+	var opened []Sink
+	if options.File != "" {
+		sink, err := NewFileSink(options.File)
+		if err != nil {
+			return err
+		}
+		opened = append(opened, sink)
+	}
+	if options.Syslog.Enabled {
+		sink, err := NewSyslogSink(options.Syslog)
+		if err != nil {
+			for _, sink := range opened {
+				_ = sink.Close()
+			}
+			return err
+		}
+		opened = append(opened, sink)
+	}
+
+	mu.Lock()
+	previous := sinks
+	database = db
+	sinks = opened
+	mu.Unlock()
+
+	var errs []error
+	for _, sink := range previous {
+		errs = append(errs, sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the configured sinks and stops writing to the audit_log table.
+func Close() error {
+	return Configure(nil, config.AuditOptions{})
+}
+
+// Record writes event to the audit log. Failures are logged rather than
+// returned so that auditing never changes the outcome of the request.
+func Record(c *gin.Context, event Event) {
+	entry := model.AuditLog{
+		CreatedAt: time.Now(),
+		UserID:    event.UserID,
+		Username:  event.Username,
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		Method:    event.Method,
+		Detail:    event.Detail,
+	}
+	if entry.UserID == nil && entry.Username == "" {
+		if userID, ok := c.Get("user_id"); ok {
+			if id, ok := userID.(uint); ok {
+				entry.UserID = &id
+			}
+		}
+		entry.Username = c.GetString("username")
+	}
+	if c.Request != nil {
+		entry.ClientIP = c.ClientIP()
+		entry.UserAgent = truncate(c.Request.UserAgent(), 512)
+		entry.Path = truncate(c.Request.URL.Path, 512)
+	}
+	entry.Username = truncate(entry.Username, 255)
+
+	mu.RLock()
+	defer mu.RUnlock()
+	if database != nil {
+		if err := database.Create(&entry).Error; err != nil {
+			logger.GetLogger().Error("Failed to write audit log", zap.String("action", entry.Action), zap.Error(err))
+		}
+	}
+	for _, sink := range sinks {
+		if err := sink.Write(entry); err != nil {
+			logger.GetLogger().Error("Failed to write audit sink", zap.String("action", entry.Action), zap.Error(err))
+		}
+	}
+}
+
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	value = value[:limit]
+	for !utf8.ValidString(value) {
+		value = value[:len(value)-1]
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestRecordWritesTableAndFile(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "audit.ndjson")
+	require.NoError(t, Configure(gormDB, config.AuditOptions{File: path}))
+	t.Cleanup(func() { require.NoError(t, Close()) })
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_log"`)).
+		WithArgs(uint(7), "alice", ActionAuthorize, OutcomeDenied, "", "192.0.2.1", "curl/8", "/api/v1/salt_event", "Salt permission required.", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "id"}).AddRow(time.Now(), 1))
+	mock.ExpectCommit()
+
+	gin.SetMode(gin.TestMode)
+	_, err = logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/salt_event", nil)
+	c.Request.Header.Set("User-Agent", "curl/8")
+	c.Set("user_id", uint(7))
+	c.Set("username", "alice")
+
+	Record(c, Event{Action: ActionAuthorize, Outcome: OutcomeDenied, Detail: "Salt permission required."})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entry model.AuditLog
+	require.NoError(t, json.Unmarshal(data, &entry))
+	require.Equal(t, uint(1), entry.ID)
+	require.Equal(t, uint(7), *entry.UserID)
+	require.Equal(t, "alice", entry.Username)
+	require.Equal(t, OutcomeDenied, entry.Outcome)
+	require.Equal(t, byte('\n'), data[len(data)-1])
+}
+
+func TestTruncateKeepsCharactersWhole(t *testing.T) {
+	require.Equal(t, "ab", truncate("abé", 3))
+	require.Equal(t, "abé", truncate("abé", 4))
+}
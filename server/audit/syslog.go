@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+)
+
+// SyslogSink sends audit entries to syslog as JSON with the authpriv
+// facility. Failures and denials are logged as warnings.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(options config.AuditSyslogOptions) (*SyslogSink, error) {
+	writer, err := syslog.Dial(options.Network, options.Address, syslog.LOG_AUTHPRIV|syslog.LOG_INFO, options.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("connect to syslog: %w", err)
+	}
+	return &SyslogSink{writer: writer}, nil
+}
+
+func (sink *SyslogSink) Write(entry model.AuditLog) error {
+	message, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if entry.Outcome == OutcomeSuccess {
+		return sink.writer.Info(string(message))
+	}
+	return sink.writer.Warning(string(message))
+}
+
+func (sink *SyslogSink) Close() error {
+	return sink.writer.Close()
+}
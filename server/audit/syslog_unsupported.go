@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"errors"
+
+	"github.com/PaulChristophel/agartha/server/config"
+)
+
+func NewSyslogSink(config.AuditSyslogOptions) (Sink, error) {
+	return nil, errors.New("syslog auditing is not supported on this platform")
+}
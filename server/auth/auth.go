@@ -8,10 +8,32 @@ func AddRoutes(rg *gin.RouterGroup) {
 	grp := rg.Group("/")
 
 	grp.POST("/token", RetrieveToken)
+	grp.POST("/token/refresh", RefreshAccessToken)
 	grp.POST("/logout", Logout)
 	grp.GET("/method", GetMethod)
+	grp.GET("/saml/metadata", GetSAMLMetadata)
+	grp.GET("/saml/login", SAMLLogin)
+	grp.POST("/saml/acs", SAMLAssertionConsumer)
+	grp.GET("/oidc/login", OIDCLogin)
+	grp.GET("/oidc/callback", OIDCCallback)
+	grp.POST("/cas/logout", CASSingleLogout)
+	grp.POST("/cert", CertLogin)
+	grp.GET("/cert/login", CertBrowserLogin)
+	grp.POST("/proxy", ProxyLogin)
+	grp.GET("/proxy/login", ProxyBrowserLogin)
+	grp.POST("/password/forgot", ForgotPassword)
+	grp.POST("/password/reset", ResetPassword)
+	grp.POST("/mfa/setup", SetupMFAChallenge)
+	grp.POST("/mfa/verify", VerifyMFA)
 }
 
 func AddSessionRoutes(rg *gin.RouterGroup) {
 	rg.GET("/session", GetSession)
+	rg.POST("/logout/all", LogoutAll)
+	rg.POST("/password", ChangePassword)
+	rg.GET("/mfa", GetMFAStatus)
+	rg.POST("/mfa/enroll", EnrollMFA)
+	rg.POST("/mfa/confirm", ConfirmMFA)
+	rg.POST("/mfa/recovery_codes", RegenerateMFARecoveryCodes)
+	rg.DELETE("/mfa", DisableMFA)
 }
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxCASLogoutRequest bounds the logoutRequest form value.
+const maxCASLogoutRequest = 64 << 10
+
+// casLogoutRequest is the SAML LogoutRequest a CAS server posts when a
+// single sign-on session ends. SessionIndex carries the service ticket.
+type casLogoutRequest struct {
+	XMLName      xml.Name `xml:"LogoutRequest"`
+	SessionIndex string   `xml:"SessionIndex"`
+}
+
+// LogoutRedirect tells the client where to finish logging out.
+type LogoutRedirect struct {
+	LogoutURL string `json:"logout_url" example:"https://cas.example.com/cas/logout?service=https%3A%2F%2Fagartha.example.com%2Fcas"`
+}
+
+// CASSingleLogout ends the sessions started with the service ticket named in
+// a CAS back-channel logout request.
+//
+//	@Summary		Handles CAS single logout.
+//	@Description	Receives the back-channel logout request a CAS server sends when its single sign-on session ends, and ends the Agartha sessions started with that service ticket. The same handler also listens on the path of cas.service_url.
+//	@Tags			Auth
+//	@Accept			x-www-form-urlencoded
+//	@Param			logoutRequest	formData	string	true	"SAML LogoutRequest"
+//	@Success		200
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/cas/logout [post]
+func CASSingleLogout(c *gin.Context) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("cas"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "CAS authentication is not enabled.")
+		return
+	}
+
+	raw := c.PostForm("logoutRequest")
+	var req casLogoutRequest
+	if raw == "" || len(raw) > maxCASLogoutRequest || xml.Unmarshal([]byte(raw), &req) != nil || strings.TrimSpace(req.SessionIndex) == "" {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid CAS logout request.")
+		return
+	}
+	ticket := strings.TrimSpace(req.SessionIndex)
+
+	ended := 0
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var sessionMaps []model.SessionUserMap
+		if err := tx.Where("provider_session_id = ?", ticket).Find(&sessionMaps).Error; err != nil {
+			return err
+		}
+		for _, sessionMap := range sessionMaps {
+			found, err := model.EndSession(tx, sessionMap.UserID, sessionMap.SessionID)
+			if err != nil {
+				return err
+			}
+			if found {
+				ended++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("failed to process CAS single logout", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to end session.")
+		return
+	}
+
+	log.Info("processed CAS single logout", zap.Int("sessions", ended))
+	c.Status(http.StatusOK)
+}
+
+// casLogoutURL is the CAS logout endpoint, returning to the service URL
+// afterwards.
+func casLogoutURL() (string, error) {
+	logoutURL, err := url.Parse(casOptions.Server)
+	if err != nil {
+		return "", err
+	}
+	logoutURL.Path, err = url.JoinPath(logoutURL.Path, casOptions.LogoutPath)
+	if err != nil {
+		return "", err
+	}
+	query := logoutURL.Query()
+	query.Set("service", casOptions.ServiceURL)
+	logoutURL.RawQuery = query.Encode()
+	return logoutURL.String(), nil
+}
+
+// AddCASServiceRoute accepts back-channel logout requests on the path of
+// cas.service_url, which is where CAS servers send them unless told
+// otherwise. Paths already owned by the API are left alone.
+func AddCASServiceRoute(router gin.IRouter) {
+	if _, enabled := providerNamed("cas"); !enabled {
+		return
+	}
+	serviceURL, err := url.Parse(casOptions.ServiceURL)
+	if err != nil {
+		return
+	}
+	path := "/" + strings.Trim(serviceURL.Path, "/")
+	for _, reserved := range []string{"/auth", "/api"} {
+		if path == reserved || strings.HasPrefix(path, reserved+"/") {
+			return
+		}
+	}
+	router.POST(path, CASSingleLogout)
+}
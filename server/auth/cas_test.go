@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+var testCASOptions = config.CASOptions{
+	Server:             "https://cas.agartha.test/cas",
+	ServiceURL:         "https://agartha.agartha.test/cas",
+	ValidatePath:       "/p3/serviceValidate",
+	LoginPath:          "/login",
+	LogoutPath:         "/logout",
+	FirstNameAttribute: "givenName",
+	LastNameAttribute:  "sn",
+	EmailAttribute:     "mail",
+	GroupsAttribute:    "memberOf",
+}
+
+func TestAuthCASReadsReleasedAttributes(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	originalOptions := casOptions
+	originalClient := casHTTPClient
+	casOptions = testCASOptions
+	casHTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		require.Equal(t, "/cas/p3/serviceValidate", r.URL.Path)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`<?xml version="1.0"?>
+				<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas">
+				  <cas:authenticationSuccess>
+				    <cas:user>alice</cas:user>
+				    <cas:attributes>
+				      <cas:givenName>Alice</cas:givenName>
+				      <cas:sn>Admin</cas:sn>
+				      <cas:mail>alice@agartha.test</cas:mail>
+				      <cas:memberOf>salt-admins</cas:memberOf>
+				      <cas:memberOf>operators</cas:memberOf>
+				    </cas:attributes>
+				  </cas:authenticationSuccess>
+				</cas:serviceResponse>`)),
+			Request: r,
+		}, nil
+	})}
+	t.Cleanup(func() {
+		casOptions = originalOptions
+		casHTTPClient = originalClient
+	})
+
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	context.Request = httptest.NewRequest(http.MethodPost, "/auth/token?ticket=ST-1-abc", nil)
+	user, err := authCAS("", context)
+	require.NoError(t, err)
+	require.Equal(t, "Alice", user.FirstName)
+	require.Equal(t, "Admin", user.LastName)
+	require.Equal(t, "alice@agartha.test", user.Email)
+	require.Equal(t, []string{"salt-admins", "operators"}, user.Groups)
+	require.Equal(t, "cas", user.GroupSource)
+	require.Equal(t, "ST-1-abc", user.ProviderSessionID)
+}
+
+func TestCASSingleLogoutEndsTicketSessions(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	mock := newTestAuthDB(t)
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "cas")
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	router := gin.New()
+	router.POST("/auth/cas/logout", CASSingleLogout)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "session_user_map" WHERE provider_session_id = $1`)).
+		WithArgs("ST-1-abc").
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "user_id"}).AddRow("session-1", 7))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "session_user_map" WHERE session_id = $1 AND user_id = $2 LIMIT $3`)).
+		WithArgs("session-1", 7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "user_id", "family_id"}).AddRow("session-1", 7, "family-1"))
+	mock.ExpectExec(`INSERT INTO revoked_token`).WithArgs("family-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_token SET revoked_at`).WithArgs("family-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM sessions`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM session_user_map`).WithArgs("session-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE user_settings SET token = ''`).WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	form := url.Values{"logoutRequest": {`<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="LR-1" Version="2.0" IssueInstant="2026-10-17T08:00:00Z"><saml:NameID>@NOT_USED@</saml:NameID><samlp:SessionIndex>ST-1-abc</samlp:SessionIndex></samlp:LogoutRequest>`}}
+	request := httptest.NewRequest(http.MethodPost, "/auth/cas/logout", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCASSingleLogoutRejectsMalformedRequest(t *testing.T) {
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "cas")
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	router := gin.New()
+	router.POST("/auth/cas/logout", CASSingleLogout)
+	request := httptest.NewRequest(http.MethodPost, "/auth/cas/logout", strings.NewReader("logoutRequest=%3CLogoutRequest%3E"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestLogoutReturnsCASLogoutURLForCASSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	originalOptions := casOptions
+	casOptions = testCASOptions
+	t.Cleanup(func() { casOptions = originalOptions })
+
+	router := gin.New()
+	router.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte("01234567890123456789012345678901"))))
+	router.GET("/seed", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set("auth_method", "cas")
+		require.NoError(t, session.Save())
+	})
+	router.POST("/auth/logout", Logout)
+
+	seedResponse := httptest.NewRecorder()
+	router.ServeHTTP(seedResponse, httptest.NewRequest(http.MethodGet, "/seed", nil))
+	request := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	request.AddCookie(seedResponse.Result().Cookies()[0])
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var redirect LogoutRedirect
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &redirect))
+	require.Equal(t, "https://cas.agartha.test/cas/logout?service=https%3A%2F%2Fagartha.agartha.test%2Fcas", redirect.LogoutURL)
+}
+
+func TestAddCASServiceRouteSkipsAPIPaths(t *testing.T) {
+	originalOptions := casOptions
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "cas")
+	t.Cleanup(func() {
+		casOptions = originalOptions
+		registeredProviders = originalProviders
+	})
+
+	for serviceURL, wantRoute := range map[string]bool{
+		"https://agartha.agartha.test/cas/":       true,
+		"https://agartha.agartha.test/auth/cas":   false,
+		"https://agartha.agartha.test/api/v1/cas": false,
+	} {
+		casOptions.ServiceURL = serviceURL
+		router := gin.New()
+		AddCASServiceRoute(router)
+		require.Equal(t, wantRoute, len(router.Routes()) == 1, serviceURL)
+	}
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidMicrosoftUPN   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 3}
+)
+
+type certMappingRule struct {
+	source   string
+	match    *regexp.Regexp
+	username string
+}
+
+var certRules []certMappingRule
+var certRequiredFor []string
+
+// SetCertOptions compiles the certificate mapping rules. Rules that fail to
+// compile are skipped; ValidateForServe rejects them before the server starts.
+func SetCertOptions(options config.CertOptions) {
+	log := logger.GetLogger()
+	certRules = make([]certMappingRule, 0, len(options.Rules))
+	for _, rule := range options.Rules {
+		match, err := regexp.Compile(rule.Pattern())
+		if err != nil {
+			log.Error("skipping invalid certificate mapping rule", zap.String("match", rule.Match), zap.Error(err))
+			continue
+		}
+		certRules = append(certRules, certMappingRule{source: rule.Source, match: match, username: rule.Username})
+	}
+	certRequiredFor = options.RequiredFor
+}
+
+// CertLogin authenticates with the verified TLS client certificate.
+//
+//	@Summary		Creates a new jwt token from the client certificate.
+//	@Description	Maps the subject or subject alternative names of the verified TLS client certificate to a username and creates a new jwt token for that user.
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	Token
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/cert [post]
+func CertLogin(c *gin.Context) {
+	token, ok := certSession(c)
+	if !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.JSON(http.StatusOK, token)
+}
+
+// CertBrowserLogin authenticates with the verified TLS client certificate
+// and redirects the browser to the application.
+//
+//	@Summary		Starts a client certificate login.
+//	@Description	Maps the verified TLS client certificate to a user, establishes the session and redirects to the application.
+//	@Tags			Auth
+//	@Success		303
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/cert/login [get]
+func CertBrowserLogin(c *gin.Context) {
+	if _, ok := certSession(c); !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+func certSession(c *gin.Context) (Token, bool) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("cert"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "Cert authentication is not enabled.")
+		return Token{}, false
+	}
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 || len(c.Request.TLS.VerifiedChains[0]) == 0 {
+		httputil.NewError(c, http.StatusUnauthorized, "Client certificate required.")
+		return Token{}, false
+	}
+	cert := c.Request.TLS.VerifiedChains[0][0]
+	username, err := certUsername(cert, certRules)
+	if err != nil {
+		log.Error("rejected client certificate login", zap.String("subject", cert.Subject.String()), zap.Error(err))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, "", "cert", cert.Subject.String()+": "+err.Error())
+		return Token{}, false
+	}
+	return establishSession(c, userData{
+		Username: username,
+		Email:    firstOrEmpty(cert.EmailAddresses),
+		Method:   "cert",
+	})
+}
+
+// certUsername applies the first rule with a matching certificate field.
+func certUsername(cert *x509.Certificate, rules []certMappingRule) (string, error) {
+	for _, rule := range rules {
+		for _, value := range certValues(cert, rule.source) {
+			submatches := rule.match.FindStringSubmatchIndex(value)
+			if submatches == nil {
+				continue
+			}
+			username := strings.TrimSpace(string(rule.match.ExpandString(nil, rule.username, value, submatches)))
+			if username != "" {
+				return username, nil
+			}
+		}
+	}
+	return "", errors.New("no mapping rule matched the client certificate")
+}
+
+func certValues(cert *x509.Certificate, source string) []string {
+	switch source {
+	case config.CertSourceSubjectCN:
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	case config.CertSourceSubject:
+		return []string{cert.Subject.String()}
+	case config.CertSourceSANEmail:
+		return cert.EmailAddresses
+	case config.CertSourceSANDNS:
+		return cert.DNSNames
+	case config.CertSourceSANURI:
+		uris := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			uris = append(uris, uri.String())
+		}
+		return uris
+	case config.CertSourceSANUPN:
+		upns, err := certUPNs(cert)
+		if err != nil {
+			logger.GetLogger().Warn("failed to parse subject alternative names", zap.Error(err))
+		}
+		return upns
+	}
+	return nil
+}
+
+// certUPNs reads the user principal names from the otherName entries of the
+// subject alternative name extension, which crypto/x509 does not expose.
+func certUPNs(cert *x509.Certificate) ([]string, error) {
+	var upns []string
+	for _, extension := range cert.Extensions {
+		if !extension.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var names []asn1.RawValue
+		if _, err := asn1.Unmarshal(extension.Value, &names); err != nil {
+			return nil, fmt.Errorf("subject alternative name: %w", err)
+		}
+		for _, name := range names {
+			// otherName [0] IMPLICIT SEQUENCE { type-id OID, value [0] EXPLICIT ANY }
+			if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+				continue
+			}
+			var otherName struct {
+				TypeID asn1.ObjectIdentifier
+				Value  asn1.RawValue
+			}
+			if _, err := asn1.UnmarshalWithParams(name.FullBytes, &otherName, "tag:0"); err != nil {
+				return nil, fmt.Errorf("subject alternative name otherName: %w", err)
+			}
+			if !otherName.TypeID.Equal(oidMicrosoftUPN) || otherName.Value.Class != asn1.ClassContextSpecific || otherName.Value.Tag != 0 {
+				continue
+			}
+			var upn string
+			if _, err := asn1.Unmarshal(otherName.Value.Bytes, &upn); err != nil {
+				return nil, fmt.Errorf("user principal name: %w", err)
+			}
+			upns = append(upns, upn)
+		}
+	}
+	return upns, nil
+}
+
+// certRequired reports whether the roles of user may only log in with a
+// client certificate.
+func certRequired(user model.AuthUser) bool {
+	if _, enabled := providerNamed("cert"); !enabled {
+		return false
+	}
+	for _, role := range certRequiredFor {
+		switch role {
+		case config.CertRoleSuperuser:
+			if user.IsSuperuser {
+				return true
+			}
+		case config.CertRoleStaff:
+			if user.IsStaff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// certLoginSatisfied rejects a login by any other method when the roles of
+// user require a client certificate. On failure the error response has
+// already been written.
+func certLoginSatisfied(c *gin.Context, user model.AuthUser, userData userData) bool {
+	if userData.Method == "cert" || !certRequired(user) {
+		return true
+	}
+	logger.GetLogger().Warn("rejected login without client certificate", zap.String("username", user.Username), zap.String("method", userData.Method))
+	httputil.NewError(c, http.StatusForbidden, "Certificate login required.")
+	auditLogin(c, audit.OutcomeDenied, user.Username, userData.Method, "certificate login required")
+	return false
+}
+
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
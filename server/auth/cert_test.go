@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestSmartcardCertificate returns a certificate shaped like a smartcard
+// logon certificate: a display name as CN, plus an email address and a UPN in
+// the subject alternative names.
+func newTestSmartcardCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	upn, err := asn1.MarshalWithParams("alice@corp.example.test", "utf8")
+	require.NoError(t, err)
+	otherName, err := asn1.MarshalWithParams(struct {
+		TypeID asn1.ObjectIdentifier
+		Value  asn1.RawValue
+	}{oidMicrosoftUPN, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: upn}}, "tag:0")
+	require.NoError(t, err)
+	subjectAltName, err := asn1.Marshal([]asn1.RawValue{
+		{FullBytes: otherName},
+		{Class: asn1.ClassContextSpecific, Tag: 1, Bytes: []byte("alice@example.test")},
+	})
+	require.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "Alice Admin", Organization: []string{"Example"}},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidSubjectAltName, Value: subjectAltName}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return certificate
+}
+
+func useCertTestOptions(t *testing.T, methods []string, options config.CertOptions) {
+	t.Helper()
+
+	originalProviders := registeredProviders
+	originalRules := certRules
+	originalRequiredFor := certRequiredFor
+	registeredProviders = testProviders(t, methods...)
+	SetCertOptions(options)
+	t.Cleanup(func() {
+		registeredProviders = originalProviders
+		certRules = originalRules
+		certRequiredFor = originalRequiredFor
+	})
+}
+
+func TestCertUPNsReadsOtherNames(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	upns, err := certUPNs(newTestSmartcardCertificate(t))
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice@corp.example.test"}, upns)
+}
+
+func TestCertUsernameAppliesFirstMatchingRule(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	certificate := newTestSmartcardCertificate(t)
+	rule := func(source, match, username string) certMappingRule {
+		pattern := config.CertMappingRule{Match: match}.Pattern()
+		return certMappingRule{source: source, match: regexp.MustCompile(pattern), username: username}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		rules []certMappingRule
+		want  string
+	}{
+		{
+			name:  "upn local part",
+			rules: []certMappingRule{rule(config.CertSourceSANUPN, `^([^@]+)@corp\.example\.test$`, "$1")},
+			want:  "alice",
+		},
+		{
+			name: "falls through to later rule",
+			rules: []certMappingRule{
+				rule(config.CertSourceSANDNS, `.*`, "$0"),
+				rule(config.CertSourceSANEmail, `^(?P<user>[^@]+)@example\.test$`, "${user}"),
+			},
+			want: "alice",
+		},
+		{
+			name:  "full subject",
+			rules: []certMappingRule{rule(config.CertSourceSubject, `^CN=([^,]+),O=Example$`, "$1")},
+			want:  "Alice Admin",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			username, err := certUsername(certificate, tt.rules)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, username)
+		})
+	}
+
+	_, err = certUsername(certificate, []certMappingRule{rule(config.CertSourceSANUPN, `@other\.test$`, "$0")})
+	require.Error(t, err)
+
+	// Rules match the whole field: a rule for one account must not also
+	// accept certificates for longer names that merely contain it.
+	_, err = certUsername(certificate, []certMappingRule{rule(config.CertSourceSANUPN, `(ali)ce|bob`, "$1")})
+	require.Error(t, err)
+	username, err := certUsername(certificate, []certMappingRule{rule(config.CertSourceSANUPN, `bob|(alice)@corp\.example\.test`, "$1")})
+	require.NoError(t, err)
+	require.Equal(t, "alice", username)
+}
+
+func TestCertLoginEstablishesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	useCertTestOptions(t, []string{"cert"}, config.CertOptions{
+		Rules: []config.CertMappingRule{{Source: config.CertSourceSANUPN, Match: `([^@]+)@.+`, Username: "$1"}},
+	})
+	mock := newTestAuthDB(t)
+	expectProvisionedUser(mock, 7, userData{Username: "alice", Email: "alice@example.test"})
+
+	request := httptest.NewRequest(http.MethodPost, "/auth/cert", nil)
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{newTestSmartcardCertificate(t)}}}
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.Contains(t, response.Body.String(), `"refresh_token"`)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCertLoginRequiresVerifiedCertificate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	useCertTestOptions(t, []string{"local"}, config.NewConfig().Cert)
+
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/auth/cert", nil))
+	require.Equal(t, http.StatusNotFound, response.Code)
+
+	registeredProviders = testProviders(t, "cert")
+	for _, state := range []*tls.ConnectionState{nil, {PeerCertificates: []*x509.Certificate{newTestSmartcardCertificate(t)}}} {
+		request := httptest.NewRequest(http.MethodGet, "/auth/cert/login", nil)
+		request.TLS = state
+		response := httptest.NewRecorder()
+		newSAMLTestRouter().ServeHTTP(response, request)
+		require.Equal(t, http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestProvisionUserRequiresCertificateForRestrictedRoles(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	useCertTestOptions(t, []string{"local", "cert"}, config.CertOptions{
+		Rules:       config.NewConfig().Cert.Rules,
+		RequiredFor: []string{config.CertRoleSuperuser},
+	})
+	mock := newTestAuthDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active", "is_superuser"}).
+			AddRow(7, "alice", true, true))
+
+	response := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(response)
+	_, ok := provisionUser(context, userData{Username: "alice", Method: "local"})
+
+	require.False(t, ok)
+	require.Equal(t, http.StatusForbidden, response.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ldapMatchingRuleInChain is the Active Directory matching rule that walks
+// group nesting on the server.
+const ldapMatchingRuleInChain = "1.2.840.113556.1.4.1941"
+
+// maxLDAPGroups bounds recursive resolution so a pathological directory
+// cannot stall a login.
+const maxLDAPGroups = 1000
+
+// ldapGroups resolves the groups entry belongs to, using the connection's
+// current bind.
+func ldapGroups(l ldapConnection, entry *ldap.Entry, options config.LDAPOptions) ([]string, error) {
+	switch options.GroupResolution {
+	case config.LDAPGroupResolutionInChain:
+		return ldapGroupsInChain(l, entry.DN, ldapGroupBaseDN(options))
+	case config.LDAPGroupResolutionRecursive:
+		return ldapGroupsRecursive(l, entry.GetAttributeValues("memberOf"))
+	default:
+		return uniqueDNs(entry.GetAttributeValues("memberOf")), nil
+	}
+}
+
+func ldapGroupBaseDN(options config.LDAPOptions) string {
+	if options.GroupBaseDN != "" {
+		return options.GroupBaseDN
+	}
+	return options.BaseDN
+}
+
+func ldapGroupsInChain(l ldapConnection, userDN, baseDN string) ([]string, error) {
+	filter := fmt.Sprintf("(member:%s:=%s)", ldapMatchingRuleInChain, ldap.EscapeFilter(userDN))
+	sr, err := l.Search(ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, maxLDAPGroups, 0, false,
+		filter,
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search nested groups: %w", err)
+	}
+	groups := make([]string, 0, len(sr.Entries))
+	for _, group := range sr.Entries {
+		groups = append(groups, group.DN)
+	}
+	return uniqueDNs(groups), nil
+}
+
+// ldapGroupsRecursive follows memberOf from the user's direct groups through
+// every parent group, for directories without LDAP_MATCHING_RULE_IN_CHAIN.
+func ldapGroupsRecursive(l ldapConnection, direct []string) ([]string, error) {
+	seen := map[string]struct{}{}
+	var groups []string
+	queue := append([]string(nil), direct...)
+	for len(queue) > 0 {
+		group := queue[0]
+		queue = queue[1:]
+		key := normalizeDN(group)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if len(groups) == maxLDAPGroups {
+			return nil, fmt.Errorf("group nesting exceeds %d groups", maxLDAPGroups)
+		}
+		seen[key] = struct{}{}
+		groups = append(groups, group)
+
+		sr, err := l.Search(ldap.NewSearchRequest(
+			group,
+			ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false,
+			"(objectClass=*)",
+			[]string{"memberOf"},
+			nil,
+		))
+		if err != nil {
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read group %s: %w", group, err)
+		}
+		for _, parent := range sr.Entries {
+			queue = append(queue, parent.GetAttributeValues("memberOf")...)
+		}
+	}
+	return groups, nil
+}
+
+// ldapGroupFlags derives the user's flags from the configured group
+// mappings. It returns nil when no mappings are configured, which leaves the
+// stored flags untouched.
+func ldapGroupFlags(groups []string, mappings []config.LDAPGroupMapping) *userFlags {
+	if len(mappings) == 0 {
+		return nil
+	}
+	member := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		member[normalizeDN(group)] = struct{}{}
+	}
+	flags := &userFlags{}
+	for _, mapping := range mappings {
+		if _, ok := member[normalizeDN(mapping.Group)]; ok {
+			flags.IsSuperuser = flags.IsSuperuser || mapping.IsSuperuser
+			flags.IsStaff = flags.IsStaff || mapping.IsStaff
+		}
+	}
+	return flags
+}
+
+func uniqueDNs(dns []string) []string {
+	seen := make(map[string]struct{}, len(dns))
+	unique := make([]string, 0, len(dns))
+	for _, dn := range dns {
+		key := normalizeDN(dn)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		unique = append(unique, dn)
+	}
+	return unique
+}
+
+// normalizeDN returns a comparison key for dn that ignores case and
+// insignificant whitespace.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	return strings.ToLower(parsed.String())
+}
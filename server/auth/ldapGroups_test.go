@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUserDN     = "cn=Alice,ou=people,dc=example,dc=test"
+	testOpsGroup   = "cn=ops,ou=groups,dc=example,dc=test"
+	testSaltGroup  = "cn=salt-admins,ou=groups,dc=example,dc=test"
+	testAdminGroup = "cn=domain-admins,ou=groups,dc=example,dc=test"
+)
+
+func TestAuthLDAPResolvesGroups(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	// alice is a direct member of ops, which is nested in salt-admins, which
+	// is nested in domain-admins.
+	groupResults := map[string]*ldap.SearchResult{
+		testOpsGroup: {Entries: []*ldap.Entry{
+			ldap.NewEntry(testOpsGroup, map[string][]string{"memberOf": {testSaltGroup}}),
+		}},
+		testSaltGroup: {Entries: []*ldap.Entry{
+			ldap.NewEntry(testSaltGroup, map[string][]string{"memberOf": {"CN=Domain-Admins, OU=Groups, DC=example, DC=test", testOpsGroup}}),
+		}},
+		"ou=groups,dc=example,dc=test": {Entries: []*ldap.Entry{
+			ldap.NewEntry(testOpsGroup, nil),
+			ldap.NewEntry(testSaltGroup, nil),
+			ldap.NewEntry(testAdminGroup, nil),
+		}},
+	}
+	mappings := []config.LDAPGroupMapping{
+		{Group: testSaltGroup, IsStaff: true},
+		{Group: testAdminGroup, IsSuperuser: true},
+	}
+
+	tests := []struct {
+		name         string
+		resolution   string
+		wantGroups   []string
+		wantFlags    *userFlags
+		wantSearches int
+	}{
+		{
+			name:       "direct memberOf",
+			resolution: config.LDAPGroupResolutionMemberOf,
+			wantGroups: []string{testOpsGroup},
+			wantFlags:  &userFlags{},
+		},
+		{
+			name:         "recursive memberOf",
+			resolution:   config.LDAPGroupResolutionRecursive,
+			wantGroups:   []string{testOpsGroup, testSaltGroup, "CN=Domain-Admins, OU=Groups, DC=example, DC=test"},
+			wantFlags:    &userFlags{IsSuperuser: true, IsStaff: true},
+			wantSearches: 3,
+		},
+		{
+			name:         "matching rule in chain",
+			resolution:   config.LDAPGroupResolutionInChain,
+			wantGroups:   []string{testOpsGroup, testSaltGroup, testAdminGroup},
+			wantFlags:    &userFlags{IsSuperuser: true, IsStaff: true},
+			wantSearches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connection := &fakeLDAPConnection{
+				searchResult: &ldap.SearchResult{Entries: []*ldap.Entry{
+					ldap.NewEntry(testUserDN, map[string][]string{
+						"sAMAccountName": {"alice"},
+						"memberOf":       {testOpsGroup},
+					}),
+				}},
+				groupResults: groupResults,
+			}
+			originalDial := ldapDialURL
+			provider := ldapProvider{name: "ldap", options: config.LDAPOptions{
+				Server:            "ldaps://directory.example.test:636",
+				User:              "cn=service,dc=example,dc=test",
+				Password:          "service-password",
+				BaseDN:            "dc=example,dc=test",
+				Filter:            "(sAMAccountName=%s)",
+				LDAPDomainDefault: "example.test",
+				UsernameAttribute: "sAMAccountName",
+				GroupBaseDN:       "ou=groups,dc=example,dc=test",
+				GroupResolution:   tt.resolution,
+				GroupMappings:     mappings,
+			}}
+			ldapDialURL = func(string, *tls.Config) (ldapConnection, error) { return connection, nil }
+			t.Cleanup(func() {
+				ldapDialURL = originalDial
+			})
+
+			user, err := provider.authenticate("alice", "user-password")
+			require.NoError(t, err)
+			require.Contains(t, connection.searchRequest.Attributes, "memberOf")
+			require.Equal(t, tt.wantGroups, user.Groups)
+			require.Equal(t, "ldap", user.GroupSource)
+			require.Equal(t, tt.wantFlags, user.Flags)
+			require.Len(t, connection.groupSearches, tt.wantSearches)
+			if tt.resolution == config.LDAPGroupResolutionInChain {
+				require.Equal(t, "(member:1.2.840.113556.1.4.1941:=cn=Alice,ou=people,dc=example,dc=test)", connection.groupSearches[0].Filter)
+			}
+		})
+	}
+}
+
+func TestLDAPGroupFlagsWithoutMappingsLeavesFlagsAlone(t *testing.T) {
+	require.Nil(t, ldapGroupFlags([]string{testAdminGroup}, nil))
+}
+
+func TestProvisionUserAppliesDirectoryFlagsAndGroups(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	mock := newTestAuthDB(t)
+
+	// alice was made superuser by hand but has left the admin group.
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active", "is_superuser", "is_staff"}).
+			AddRow(7, "alice", true, true, false))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "auth_user" SET .*"is_superuser"=\$3.*"is_staff"=\$8`).
+		WithArgs("", sqlmock.AnyArg(), false, "alice", "", "", "", true, true, sqlmock.AnyArg(), "", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "user_group" WHERE user_id = \$1 AND source = \$2`).
+		WithArgs(7, "ldap").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectQuery(`INSERT INTO "user_group" \("user_id","source","name","resolved_at"\) VALUES \(\$1,\$2,\$3,\$4\)`).
+		WithArgs(7, "ldap", testSaltGroup, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	user, ok := provisionUser(context, userData{
+		Username:    "alice",
+		Groups:      []string{testSaltGroup},
+		GroupSource: "ldap",
+		Flags:       &userFlags{IsStaff: true},
+	})
+	require.True(t, ok)
+	require.False(t, user.IsSuperuser)
+	require.True(t, user.IsStaff)
+	require.WithinDuration(t, time.Now(), *user.LastLogin, time.Minute)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProvisionUserRefusesAccountsOfAnotherProvider(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	mock := newTestAuthDB(t)
+	originalProviders := registeredProviders
+	registeredProviders = []Provider{ldapProvider{name: "corp"}, ldapProvider{name: "lab"}}
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	// alice was created through lab; corp's alice is a different person and
+	// must neither log in as her nor overwrite her flags.
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active", "is_superuser", "provider"}).
+			AddRow(7, "alice", true, true, "lab"))
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	_, ok := provisionUser(context, userData{
+		Username:    "alice",
+		Method:      "corp",
+		GroupSource: "corp",
+		Flags:       &userFlags{IsSuperuser: true},
+	})
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProvisionUserBindsNewAccountsToTheNamedProvider(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	mock := newTestAuthDB(t)
+	originalProviders := registeredProviders
+	registeredProviders = []Provider{ldapProvider{name: "corp"}}
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "auth_user"`).
+		WithArgs("", false, "alice", "", "", "", false, true, "corp", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"last_login", "date_joined", "id"}).AddRow(nil, time.Now(), 7))
+	mock.ExpectCommit()
+
+	context, _ := gin.CreateTestContext(httptest.NewRecorder())
+	user, ok := provisionUser(context, userData{Username: "alice", Method: "corp"})
+	require.True(t, ok)
+	require.Equal(t, "corp", user.Provider)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
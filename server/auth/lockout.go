@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var lockoutOptions = config.NewConfig().Lockout
+
+func SetLockoutOptions(options config.LockoutOptions) {
+	lockoutOptions = options
+}
+
+// passwordLoginThrottled reports whether method forwards a guessable
+// password, and so counts towards the lockout.
+func passwordLoginThrottled(method string) bool {
+	typ := providerType(method)
+	return typ == config.AuthProviderLocal || typ == config.AuthProviderLDAP
+}
+
+type loginFailureKey struct {
+	kind    string
+	subject string
+}
+
+func loginFailureKeys(username, clientIP string) []loginFailureKey {
+	return []loginFailureKey{
+		{model.LoginFailureUsername, model.LoginFailureSubject(model.LoginFailureUsername, username)},
+		{model.LoginFailureClientIP, model.LoginFailureSubject(model.LoginFailureClientIP, clientIP)},
+	}
+}
+
+// loginBlockedUntil returns when the next password login for username from
+// clientIP is accepted, or the zero time if it is accepted now.
+func loginBlockedUntil(database *gorm.DB, username, clientIP string, now time.Time) (time.Time, error) {
+	keys := loginFailureKeys(username, clientIP)
+	var failures []model.LoginFailure
+	err := database.
+		Where("(kind = ? AND subject = ?) OR (kind = ? AND subject = ?)", keys[0].kind, keys[0].subject, keys[1].kind, keys[1].subject).
+		Find(&failures).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	var until time.Time
+	for _, failure := range failures {
+		if blocked := failure.BlockedUntil(); blocked.After(now) && blocked.After(until) {
+			until = blocked
+		}
+	}
+	return until, nil
+}
+
+// refuseBlockedLogin writes the error response and returns true while earlier
+// failed passwords or second factors block username or the client IP address.
+func refuseBlockedLogin(c *gin.Context, username, method string) bool {
+	sugar := logger.GetLogger().Sugar()
+	blockedUntil, err := loginBlockedUntil(db.DB, username, c.ClientIP(), time.Now())
+	if err != nil {
+		sugar.Errorf("Error checking failed logins for user %s: %+v", username, err)
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to generate token.")
+		return true
+	}
+	if blockedUntil.IsZero() {
+		return false
+	}
+	sugar.Warnf("Refusing login for user %s from %s until %s", username, c.ClientIP(), blockedUntil.Format(time.RFC3339))
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(blockedUntil).Seconds()))))
+	httputil.NewError(c, http.StatusTooManyRequests, "Too many failed login attempts.")
+	auditLogin(c, audit.OutcomeDenied, username, method, "too many failed login attempts")
+	return true
+}
+
+// countLoginFailure records a rejected password or second factor for
+// username. A storage error is logged so the client still sees the rejection.
+func countLoginFailure(c *gin.Context, username string) {
+	if err := recordLoginFailure(db.DB, username, c.ClientIP(), time.Now()); err != nil {
+		logger.GetLogger().Sugar().Errorf("Failed to record failed login for user %s: %+v", username, err)
+	}
+}
+
+// recordLoginFailure counts a failed password login against both the
+// username and the client IP address and applies the resulting delay or
+// lockout.
+func recordLoginFailure(database *gorm.DB, username, clientIP string, now time.Time) error {
+	return database.Transaction(func(tx *gorm.DB) error {
+		for _, key := range loginFailureKeys(username, clientIP) {
+			failure, err := model.RecordLoginFailure(tx, key.kind, key.subject, now, now.Add(-lockoutOptions.FailureWindow))
+			if err != nil {
+				return err
+			}
+			retryAt, lockedUntil := loginFailurePenalty(key.kind, failure.Failures, now)
+			err = tx.Model(&model.LoginFailure{}).
+				Where("id = ?", failure.ID).
+				Updates(map[string]any{"retry_at": retryAt, "locked_until": lockedUntil}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loginFailurePenalty returns the delay and lockout earned by the given
+// number of consecutive failures. Client IP addresses are only locked out,
+// never delayed, because many users may share one address.
+func loginFailurePenalty(kind string, failures int, now time.Time) (retryAt, lockedUntil *time.Time) {
+	threshold := lockoutOptions.MaxFailures
+	if kind == model.LoginFailureClientIP {
+		threshold = lockoutOptions.ClientIPMaxFailures
+	} else if lockoutOptions.BaseDelay > 0 && failures > 0 {
+		delay := lockoutOptions.MaxDelay
+		if scaled := float64(lockoutOptions.BaseDelay) * math.Pow(2, float64(failures-1)); scaled < float64(delay) {
+			delay = time.Duration(scaled)
+		}
+		retry := now.Add(delay)
+		retryAt = &retry
+	}
+	if threshold > 0 && failures >= threshold {
+		locked := now.Add(lockoutOptions.LockoutDuration)
+		lockedUntil = &locked
+	}
+	return retryAt, lockedUntil
+}
+
+// forgetLoginFailures clears the failures of username once its login has
+// completed, including any second factor. A storage error is only logged,
+// because the session has already been issued.
+func forgetLoginFailures(username string) {
+	if err := clearLoginFailures(db.DB, username); err != nil {
+		logger.GetLogger().Sugar().Errorf("Failed to clear failed logins for user %s: %+v", username, err)
+	}
+}
+
+// clearLoginFailures forgets the failures of username after it logged in.
+// The client IP address keeps its count so that one valid account cannot be
+// used to reset it.
+func clearLoginFailures(database *gorm.DB, username string) error {
+	return model.ClearLoginFailures(database, model.LoginFailureUsername, model.LoginFailureSubject(model.LoginFailureUsername, username))
+}
@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/stretchr/testify/require"
+)
+
+// httptest requests come from this address.
+const testClientIP = "192.0.2.1"
+
+const loginFailureQuery = `SELECT * FROM "login_failure" WHERE (kind = $1 AND subject = $2) OR (kind = $3 AND subject = $4)`
+
+func useLockoutTestOptions(t *testing.T, options config.LockoutOptions) {
+	t.Helper()
+	originalOptions := lockoutOptions
+	SetLockoutOptions(options)
+	t.Cleanup(func() { SetLockoutOptions(originalOptions) })
+}
+
+// expectNoLoginFailures expects RetrieveToken to find no failed logins that
+// block username.
+func expectNoLoginFailures(mock sqlmock.Sqlmock, username string) {
+	mock.ExpectQuery(regexp.QuoteMeta(loginFailureQuery)).
+		WithArgs(model.LoginFailureUsername, username, model.LoginFailureClientIP, testClientIP).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
+// expectLoginFailuresCleared expects a completed login, including any second
+// factor, to reset the failures counted for username.
+func expectLoginFailuresCleared(mock sqlmock.Sqlmock, username string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "login_failure" WHERE kind = $1 AND subject = $2`)).
+		WithArgs(model.LoginFailureUsername, username).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+}
+
+func TestLoginFailurePenalty(t *testing.T) {
+	useLockoutTestOptions(t, config.LockoutOptions{
+		MaxFailures:         3,
+		ClientIPMaxFailures: 10,
+		LockoutDuration:     15 * time.Minute,
+		BaseDelay:           time.Second,
+		MaxDelay:            3 * time.Second,
+	})
+	now := time.Now()
+
+	for _, tt := range []struct {
+		kind       string
+		failures   int
+		wantDelay  time.Duration
+		wantLocked bool
+	}{
+		{model.LoginFailureUsername, 1, time.Second, false},
+		{model.LoginFailureUsername, 2, 2 * time.Second, false},
+		{model.LoginFailureUsername, 3, 3 * time.Second, true},
+		{model.LoginFailureUsername, 200, 3 * time.Second, true},
+		{model.LoginFailureClientIP, 9, 0, false},
+		{model.LoginFailureClientIP, 10, 0, true},
+	} {
+		retryAt, lockedUntil := loginFailurePenalty(tt.kind, tt.failures, now)
+		if tt.wantDelay == 0 {
+			require.Nil(t, retryAt, "%s %d", tt.kind, tt.failures)
+		} else {
+			require.Equal(t, now.Add(tt.wantDelay), *retryAt, "%s %d", tt.kind, tt.failures)
+		}
+		if tt.wantLocked {
+			require.Equal(t, now.Add(15*time.Minute), *lockedUntil, "%s %d", tt.kind, tt.failures)
+		} else {
+			require.Nil(t, lockedUntil, "%s %d", tt.kind, tt.failures)
+		}
+	}
+}
+
+// expectLoginFailureRecorded expects a rejected password or second factor to
+// be counted against username and the test client IP address.
+func expectLoginFailureRecorded(mock sqlmock.Sqlmock, username string) {
+	mock.ExpectBegin()
+	for _, key := range []struct{ kind, subject string }{
+		{model.LoginFailureUsername, username},
+		{model.LoginFailureClientIP, testClientIP},
+	} {
+		mock.ExpectQuery(`INSERT INTO login_failure .* ON CONFLICT \(kind, subject\) DO UPDATE`).
+			WithArgs(key.kind, key.subject, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "subject", "failures"}).AddRow(3, key.kind, key.subject, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "login_failure" SET "locked_until"=$1,"retry_at"=$2 WHERE id = $3`)).
+			WithArgs(nil, sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+}
+
+func TestRetrieveTokenRecordsFailedPasswordLogins(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useLockoutTestOptions(t, config.NewConfig().Lockout)
+
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`FROM auth_user\s+WHERE username = \$1`).
+		WithArgs("Alice", "wrong").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	expectLoginFailureRecorded(mock, "alice")
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/token", `{"username":"Alice","password":"wrong","method":"local"}`)
+
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetrieveTokenClearsFailuresAfterSessionStarts(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`FROM auth_user\s+WHERE username = \$1`).
+		WithArgs("alice", testStrongPassword).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "user_mfa"`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "auth_user" SET .* WHERE "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectSessionStarted(mock, 7)
+	expectLoginFailuresCleared(mock, "alice")
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/token", `{"username":"alice","password":"`+testStrongPassword+`","method":"local"}`)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetrieveTokenRefusesLockedOutLogins(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	auditFile := filepath.Join(t.TempDir(), "audit.ndjson")
+	require.NoError(t, audit.Configure(nil, config.AuditOptions{File: auditFile}))
+	t.Cleanup(func() { require.NoError(t, audit.Close()) })
+
+	mock.ExpectQuery(regexp.QuoteMeta(loginFailureQuery)).
+		WithArgs(model.LoginFailureUsername, "alice", model.LoginFailureClientIP, testClientIP).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "subject", "failures", "retry_at", "locked_until"}).
+			AddRow(3, model.LoginFailureUsername, "alice", 5, time.Now().Add(-time.Minute), time.Now().Add(10*time.Minute)))
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/token", `{"username":"alice","password":"`+testStrongPassword+`","method":"local"}`)
+
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.Equal(t, "600", response.Header().Get("Retry-After"))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	data, err := os.ReadFile(auditFile)
+	require.NoError(t, err)
+	var entry model.AuditLog
+	require.NoError(t, json.Unmarshal(data, &entry))
+	require.Equal(t, audit.ActionLogin, entry.Action)
+	require.Equal(t, audit.OutcomeDenied, entry.Outcome)
+	require.Equal(t, "alice", entry.Username)
+	require.Equal(t, "local", entry.Method)
+	require.Equal(t, testClientIP, entry.ClientIP)
+}
@@ -0,0 +1,773 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	mfaChallengeType     = "mfa_challenge"
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaRecoveryCodeCount = 10
+	mfaTOTPSkew          = 1
+)
+
+var mfaOptions = config.NewConfig().MFA
+
+// errMFAAlreadyEnrolled is returned by saveMFAEnrollment for users with a
+// confirmed second factor, which only DisableMFA may remove.
+var errMFAAlreadyEnrolled = errors.New("MFA is already enrolled")
+
+// MFAChallenge is returned by /auth/token instead of a token when the login
+// needs a second factor. The mfa_token is exchanged at /auth/mfa/verify.
+type MFAChallenge struct {
+	Code               int    `json:"code" example:"401"`
+	Message            string `json:"message" example:"Second factor required."`
+	MFAToken           string `json:"mfa_token"`
+	EnrollmentRequired bool   `json:"enrollment_required" example:"false"`
+}
+
+type MFAEnrollment struct {
+	MFAToken string `json:"mfa_token,omitempty"`
+	Secret   string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URL      string `json:"otpauth_url" example:"otpauth://totp/Agartha:alice?secret=JBSWY3DPEHPK3PXP&issuer=Agartha"`
+}
+
+type MFAStatus struct {
+	Enrolled               bool       `json:"enrolled"`
+	ConfirmedAt            *time.Time `json:"confirmed_at"`
+	RemainingRecoveryCodes int64      `json:"remaining_recovery_codes"`
+}
+
+type MFARecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAToken struct {
+	Token
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type mfaChallengeRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type mfaVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// mfaChallengeClaims carries the first-factor result between /auth/token and
+// /auth/mfa/verify. It deliberately has no user_id claim, so AuthRequired
+// never accepts it as a session token.
+type mfaChallengeClaims struct {
+	Type          string     `json:"typ"`
+	Username      string     `json:"username"`
+	FirstName     string     `json:"first_name,omitempty"`
+	LastName      string     `json:"last_name,omitempty"`
+	Email         string     `json:"email,omitempty"`
+	Method        string     `json:"method"`
+	Groups        []string   `json:"groups,omitempty"`
+	GroupSource   string     `json:"group_source,omitempty"`
+	Flags         *userFlags `json:"flags,omitempty"`
+	Enroll        bool       `json:"enroll,omitempty"`
+	PendingSecret string     `json:"pending_secret,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (claims mfaChallengeClaims) userData() userData {
+	return userData{
+		Username:          claims.Username,
+		FirstName:         claims.FirstName,
+		LastName:          claims.LastName,
+		Email:             claims.Email,
+		SamAccountName:    claims.Username,
+		UserPrincipalName: claims.Username,
+		Method:            claims.Method,
+		Groups:            claims.Groups,
+		GroupSource:       claims.GroupSource,
+		Flags:             claims.Flags,
+	}
+}
+
+func SetMFAOptions(options config.MFAOptions) {
+	mfaOptions = options
+}
+
+// secondFactorRequired reports whether a password login must be completed
+// with a TOTP code. Redirect-based methods leave MFA to the identity provider.
+func secondFactorRequired(method, username string) (required bool, enrolled bool, err error) {
+	typ := providerType(method)
+	if typ != config.AuthProviderLocal && typ != config.AuthProviderLDAP {
+		return false, false, nil
+	}
+	var count int64
+	err = db.DB.Model(&model.UserMFA{}).
+		Joins("JOIN auth_user ON auth_user.id = user_mfa.user_id").
+		Where("auth_user.username = ? AND user_mfa.confirmed_at IS NOT NULL", username).
+		Count(&count).Error
+	if err != nil {
+		return false, false, err
+	}
+	enrolled = count > 0
+	// An entry names either one provider or every provider of a type.
+	for _, requiredMethod := range mfaOptions.RequiredMethods {
+		requiredMethod = strings.TrimSpace(requiredMethod)
+		if strings.EqualFold(requiredMethod, method) || strings.EqualFold(requiredMethod, typ) {
+			return true, enrolled, nil
+		}
+	}
+	return enrolled, enrolled, nil
+}
+
+func issueMFAChallenge(c *gin.Context, method string, userData userData, enroll bool) {
+	challenge, err := signMFAChallenge(mfaChallengeClaims{
+		Username:    userData.Username,
+		FirstName:   userData.FirstName,
+		LastName:    userData.LastName,
+		Email:       userData.Email,
+		Method:      method,
+		Groups:      userData.Groups,
+		GroupSource: userData.GroupSource,
+		Flags:       userData.Flags,
+		Enroll:      enroll,
+	})
+	if err != nil {
+		logger.GetLogger().Error("failed to sign MFA challenge", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to generate token.")
+		return
+	}
+	c.JSON(http.StatusUnauthorized, MFAChallenge{
+		Code:               http.StatusUnauthorized,
+		Message:            "Second factor required.",
+		MFAToken:           challenge,
+		EnrollmentRequired: enroll,
+	})
+}
+
+func signMFAChallenge(claims mfaChallengeClaims) (string, error) {
+	challengeID, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.Type = mfaChallengeType
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        challengeID,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+func parseMFAChallenge(tokenString string) (mfaChallengeClaims, error) {
+	var claims mfaChallengeClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims,
+		func(*jwt.Token) (any, error) { return jwtSecret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return claims, err
+	}
+	if claims.Type != mfaChallengeType || claims.Username == "" || claims.ID == "" {
+		return claims, errors.New("not an MFA challenge")
+	}
+	return claims, nil
+}
+
+// SetupMFAChallenge starts the enrollment a login challenge demands.
+//
+//	@Summary		Starts a required MFA enrollment during login.
+//	@Description	Generates a TOTP secret for a login whose challenge requires enrollment. The returned mfa_token replaces the original one at /auth/mfa/verify.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		mfaChallengeRequest	true	"MFA challenge"
+//	@Success		200		{object}	MFAEnrollment
+//	@Failure		400		{object}	httputil.HTTPError400
+//	@Failure		401		{object}	httputil.HTTPError401
+//	@Failure		500		{object}	httputil.HTTPError500
+//	@router			/auth/mfa/setup [post]
+func SetupMFAChallenge(c *gin.Context) {
+	log := logger.GetLogger()
+	var req mfaChallengeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing MFA token.")
+		return
+	}
+	claims, err := parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid or expired MFA token.")
+		return
+	}
+	if !claims.Enroll {
+		httputil.NewError(c, http.StatusBadRequest, "This login does not require MFA enrollment.")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate TOTP secret", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+	claims.PendingSecret, err = sealMFASecret(secret)
+	if err == nil {
+		req.MFAToken, err = signMFAChallenge(claims)
+	}
+	if err != nil {
+		log.Error("failed to issue MFA enrollment challenge", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollment{
+		MFAToken: req.MFAToken,
+		Secret:   secret,
+		URL:      totp.URL(mfaOptions.Issuer, claims.Username, secret),
+	})
+}
+
+// VerifyMFA completes a login challenged by /auth/token.
+//
+//	@Summary		Completes a login with a second factor.
+//	@Description	Exchanges an MFA challenge and a TOTP or recovery code for a session token. Completing a required enrollment also returns the new recovery codes.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		mfaVerifyRequest	true	"MFA challenge and code"
+//	@Success		200		{object}	MFAToken
+//	@Failure		400		{object}	httputil.HTTPError400
+//	@Failure		401		{object}	httputil.HTTPError401
+//	@Failure		409		{object}	httputil.HTTPError409
+//	@Failure		429		{object}	httputil.HTTPError429
+//	@Failure		500		{object}	httputil.HTTPError500
+//	@router			/auth/mfa/verify [post]
+func VerifyMFA(c *gin.Context) {
+	log := logger.GetLogger()
+	var req mfaVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing MFA token or code.")
+		return
+	}
+	claims, err := parseMFAChallenge(req.MFAToken)
+	if err != nil {
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid or expired MFA token.")
+		return
+	}
+	// Wrong codes count towards the same lockout as wrong passwords, so a
+	// stolen password cannot buy unlimited guesses with fresh challenges.
+	if refuseBlockedLogin(c, claims.Username, claims.Method) {
+		return
+	}
+
+	if claims.Enroll {
+		completeMFAEnrollmentLogin(c, claims, req.Code)
+		return
+	}
+
+	var user model.AuthUser
+	if err := db.DB.Where("username = ? AND is_active = ?", claims.Username, true).First(&user).Error; err != nil {
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		return
+	}
+	ok, err := verifySecondFactor(db.DB, user.ID, req.Code)
+	if err != nil {
+		log.Error("failed to verify second factor", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to verify MFA code.")
+		return
+	}
+	if !ok {
+		log.Warn("rejected MFA code", zap.Uint("user_id", user.ID))
+		countLoginFailure(c, claims.Username)
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid MFA code.")
+		auditLogin(c, audit.OutcomeFailure, claims.Username, claims.Method, "invalid MFA code")
+		return
+	}
+
+	token, ok := establishSession(c, claims.userData())
+	if !ok {
+		return
+	}
+	forgetLoginFailures(claims.Username)
+	expireLegacyAuthCookie(c)
+	c.JSON(http.StatusOK, MFAToken{Token: token})
+}
+
+func completeMFAEnrollmentLogin(c *gin.Context, claims mfaChallengeClaims, code string) {
+	log := logger.GetLogger()
+	if claims.PendingSecret == "" {
+		httputil.NewError(c, http.StatusBadRequest, "MFA enrollment has not been set up.")
+		return
+	}
+	secret, err := openMFASecret(claims.PendingSecret)
+	if err != nil {
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid or expired MFA token.")
+		return
+	}
+	step, ok := totp.Validate(secret, code, time.Now(), mfaTOTPSkew)
+	if !ok {
+		countLoginFailure(c, claims.Username)
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid MFA code.")
+		auditLogin(c, audit.OutcomeFailure, claims.Username, claims.Method, "invalid MFA code")
+		return
+	}
+
+	user, ok := provisionUser(c, claims.userData())
+	if !ok {
+		return
+	}
+	recoveryCodes, err := saveMFAEnrollment(db.DB, user.ID, secret, step)
+	if errors.Is(err, errMFAAlreadyEnrolled) {
+		log.Warn("refused to replace a confirmed MFA enrollment during login", zap.Uint("user_id", user.ID))
+		httputil.NewError(c, http.StatusConflict, "MFA is already enrolled. Log in again with the enrolled second factor.")
+		auditLogin(c, audit.OutcomeDenied, claims.Username, claims.Method, "MFA is already enrolled")
+		return
+	}
+	if err != nil {
+		log.Error("failed to save MFA enrollment", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to save MFA enrollment.")
+		return
+	}
+	log.Info("enrolled TOTP during login", zap.Uint("user_id", user.ID))
+
+	token, ok := startSession(c, user, claims.userData())
+	if !ok {
+		return
+	}
+	forgetLoginFailures(claims.Username)
+	expireLegacyAuthCookie(c)
+	c.JSON(http.StatusOK, MFAToken{Token: token, RecoveryCodes: recoveryCodes})
+}
+
+// GetMFAStatus reports the current user's enrollment.
+//
+//	@Summary		Gets the current user's MFA status.
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	MFAStatus
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/mfa [get]
+//	@Security		Bearer
+func GetMFAStatus(c *gin.Context) {
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "Authentication session is unavailable.")
+		return
+	}
+
+	var status MFAStatus
+	var enrollment model.UserMFA
+	err := db.DB.Where("user_id = ? AND confirmed_at IS NOT NULL", user.ID).First(&enrollment).Error
+	if err == nil {
+		status.Enrolled = true
+		status.ConfirmedAt = enrollment.ConfirmedAt
+		err = db.DB.Model(&model.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).Count(&status.RemainingRecoveryCodes).Error
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	if err != nil {
+		logger.GetLogger().Error("failed to load MFA status", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to load MFA status.")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollMFA starts a TOTP enrollment for the current user.
+//
+//	@Summary		Starts TOTP enrollment.
+//	@Description	Generates a new TOTP secret. The enrollment takes effect once a code is confirmed at /auth/mfa/confirm.
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	MFAEnrollment
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		409	{object}	httputil.HTTPError409
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/mfa/enroll [post]
+//	@Security		Bearer
+func EnrollMFA(c *gin.Context) {
+	log := logger.GetLogger()
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "Authentication session is unavailable.")
+		return
+	}
+
+	var confirmed int64
+	if err := db.DB.Model(&model.UserMFA{}).Where("user_id = ? AND confirmed_at IS NOT NULL", user.ID).Count(&confirmed).Error; err != nil {
+		log.Error("failed to check MFA enrollment", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+	if confirmed > 0 {
+		httputil.NewError(c, http.StatusConflict, "MFA is already enrolled. Disable it before enrolling again.")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate TOTP secret", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+	sealed, err := sealMFASecret(secret)
+	if err != nil {
+		log.Error("failed to encrypt TOTP secret", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+	enrollment := model.UserMFA{UserID: user.ID, Secret: sealed, CreatedAt: time.Now()}
+	err = db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "created_at"}),
+	}).Create(&enrollment).Error
+	if err != nil {
+		log.Error("failed to store MFA enrollment", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start MFA enrollment.")
+		return
+	}
+
+	c.JSON(http.StatusOK, MFAEnrollment{Secret: secret, URL: totp.URL(mfaOptions.Issuer, user.Username, secret)})
+}
+
+// ConfirmMFA activates the current user's pending enrollment.
+//
+//	@Summary		Confirms TOTP enrollment.
+//	@Description	Activates the pending TOTP secret with a current code and returns single-use recovery codes.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		mfaCodeRequest	true	"TOTP code"
+//	@Success		200		{object}	MFARecoveryCodes
+//	@Failure		400		{object}	httputil.HTTPError400
+//	@Failure		401		{object}	httputil.HTTPError401
+//	@Failure		404		{object}	httputil.HTTPError404
+//	@Failure		409		{object}	httputil.HTTPError409
+//	@Failure		500		{object}	httputil.HTTPError500
+//	@router			/auth/mfa/confirm [post]
+//	@Security		Bearer
+func ConfirmMFA(c *gin.Context) {
+	log := logger.GetLogger()
+	user, req, ok := bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	var enrollment model.UserMFA
+	err := db.DB.Where("user_id = ? AND confirmed_at IS NULL", user.ID).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		httputil.NewError(c, http.StatusNotFound, "No pending MFA enrollment.")
+		return
+	}
+	var secret string
+	if err == nil {
+		secret, err = openMFASecret(enrollment.Secret)
+	}
+	if err != nil {
+		log.Error("failed to load pending MFA enrollment", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to confirm MFA enrollment.")
+		return
+	}
+	step, valid := totp.Validate(secret, req.Code, time.Now(), mfaTOTPSkew)
+	if !valid {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid MFA code.")
+		return
+	}
+
+	recoveryCodes, err := saveMFAEnrollment(db.DB, user.ID, secret, step)
+	if errors.Is(err, errMFAAlreadyEnrolled) {
+		httputil.NewError(c, http.StatusConflict, "MFA is already enrolled. Disable it before enrolling again.")
+		return
+	}
+	if err != nil {
+		log.Error("failed to confirm MFA enrollment", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to confirm MFA enrollment.")
+		return
+	}
+	log.Info("enrolled TOTP", zap.Uint("user_id", user.ID))
+	c.JSON(http.StatusOK, MFARecoveryCodes{RecoveryCodes: recoveryCodes})
+}
+
+// RegenerateMFARecoveryCodes replaces the current user's recovery codes.
+//
+//	@Summary		Regenerates MFA recovery codes.
+//	@Description	Replaces every recovery code after verifying a current TOTP or recovery code.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		mfaCodeRequest	true	"TOTP or recovery code"
+//	@Success		200		{object}	MFARecoveryCodes
+//	@Failure		400		{object}	httputil.HTTPError400
+//	@Failure		401		{object}	httputil.HTTPError401
+//	@Failure		429		{object}	httputil.HTTPError429
+//	@Failure		500		{object}	httputil.HTTPError500
+//	@router			/auth/mfa/recovery_codes [post]
+//	@Security		Bearer
+func RegenerateMFARecoveryCodes(c *gin.Context) {
+	log := logger.GetLogger()
+	user, req, ok := bindMFACode(c)
+	if !ok || !requireSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	var recoveryCodes []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		recoveryCodes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Error("failed to regenerate MFA recovery codes", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to regenerate recovery codes.")
+		return
+	}
+	c.JSON(http.StatusOK, MFARecoveryCodes{RecoveryCodes: recoveryCodes})
+}
+
+// DisableMFA removes the current user's enrollment.
+//
+//	@Summary		Disables MFA.
+//	@Description	Removes the TOTP enrollment and recovery codes after verifying a current TOTP or recovery code.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	mfaCodeRequest	true	"TOTP or recovery code"
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		429	{object}	httputil.HTTPError429
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/mfa [delete]
+//	@Security		Bearer
+func DisableMFA(c *gin.Context) {
+	log := logger.GetLogger()
+	user, req, ok := bindMFACode(c)
+	if !ok || !requireSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&model.UserMFA{}).Error
+	})
+	if err != nil {
+		log.Error("failed to disable MFA", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to disable MFA.")
+		return
+	}
+	log.Info("disabled TOTP", zap.Uint("user_id", user.ID))
+	c.Status(http.StatusNoContent)
+}
+
+func bindMFACode(c *gin.Context) (model.AuthUser, mfaCodeRequest, bool) {
+	var req mfaCodeRequest
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "Authentication session is unavailable.")
+		return user, req, false
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing MFA code.")
+		return user, req, false
+	}
+	return user, req, true
+}
+
+// requireSecondFactor writes the error response and returns false unless code
+// is a valid second factor for user. Wrong codes count towards the login
+// lockout, so a stolen session cannot guess codes here either.
+func requireSecondFactor(c *gin.Context, user model.AuthUser, code string) bool {
+	if refuseBlockedLogin(c, user.Username, "mfa") {
+		return false
+	}
+	ok, err := verifySecondFactor(db.DB, user.ID, code)
+	if err != nil {
+		logger.GetLogger().Error("failed to verify second factor", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to verify MFA code.")
+		return false
+	}
+	if !ok {
+		countLoginFailure(c, user.Username)
+		httputil.NewError(c, http.StatusBadRequest, "Invalid MFA code.")
+		return false
+	}
+	return true
+}
+
+// verifySecondFactor accepts a current TOTP code or an unused recovery code.
+// Each TOTP step and recovery code is accepted at most once.
+func verifySecondFactor(database *gorm.DB, userID uint, code string) (bool, error) {
+	var enrollment model.UserMFA
+	err := database.Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&enrollment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		secret, err := openMFASecret(enrollment.Secret)
+		if err != nil {
+			return false, err
+		}
+		step, ok := totp.Validate(secret, code, time.Now(), mfaTOTPSkew)
+		if !ok {
+			return false, nil
+		}
+		result := database.Model(&model.UserMFA{}).
+			Where("user_id = ? AND last_used_step < ?", userID, step).
+			Update("last_used_step", step)
+		return result.RowsAffected == 1, result.Error
+	}
+
+	result := database.Model(&model.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// saveMFAEnrollment stores a confirmed enrollment and issues fresh recovery codes.
+func saveMFAEnrollment(database *gorm.DB, userID uint, secret string, step int64) ([]string, error) {
+	sealed, err := sealMFASecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []string
+	err = database.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		enrollment := model.UserMFA{UserID: userID, Secret: sealed, ConfirmedAt: &now, LastUsedStep: step, CreatedAt: now}
+		// Only a pending enrollment is replaced, so no flow can swap out a
+		// second factor the user already confirmed.
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"secret", "confirmed_at", "last_used_step"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: `"user_mfa"."confirmed_at" IS NULL`}}},
+		}).Create(&enrollment)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errMFAAlreadyEnrolled
+		}
+		var err error
+		recoveryCodes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return recoveryCodes, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, mfaRecoveryCodeCount)
+	rows := make([]model.MFARecoveryCode, mfaRecoveryCodeCount)
+	for i := range codes {
+		buffer := make([]byte, 7)
+		if _, err := rand.Read(buffer); err != nil {
+			return nil, err
+		}
+		encoded := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buffer))[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+		rows[i] = model.MFARecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(codes[i])}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// mfaCipher derives the AES-256-GCM key for TOTP secrets from
+// mfa.encryption_key, falling back to the HTTP secret.
+func mfaCipher() (cipher.AEAD, error) {
+	key := mfaOptions.EncryptionKey
+	if key == "" {
+		key = string(jwtSecret)
+	}
+	if key == "" {
+		return nil, errors.New("no MFA encryption key configured")
+	}
+	sum := sha256.Sum256([]byte("agartha mfa secret\x00" + key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealMFASecret(secret string) (string, error) {
+	aead, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func openMFASecret(sealed string) (string, error) {
+	aead, err := mfaCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("malformed MFA secret")
+	}
+	secret, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt MFA secret: %w", err)
+	}
+	return string(secret), nil
+}
+
+func randomToken(size int) (string, error) {
+	buffer := make([]byte, size)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buffer), nil
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/totp"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func useMFATestOptions(t *testing.T, options config.MFAOptions) {
+	t.Helper()
+	originalOptions := mfaOptions
+	SetMFAOptions(options)
+	t.Cleanup(func() { SetMFAOptions(originalOptions) })
+}
+
+func expectConfirmedMFA(mock sqlmock.Sqlmock, t *testing.T, userID int, secret string) {
+	t.Helper()
+	sealed, err := sealMFASecret(secret)
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_mfa" WHERE user_id = $1 AND confirmed_at IS NOT NULL ORDER BY "user_mfa"."user_id" LIMIT $2`)).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "confirmed_at", "last_used_step"}).AddRow(userID, sealed, time.Now(), 0))
+}
+
+func TestMFASecretSealing(t *testing.T) {
+	newTestAuthDB(t)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+
+	sealed, err := sealMFASecret("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	require.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+	secret, err := openMFASecret(sealed)
+	require.NoError(t, err)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", secret)
+
+	SetMFAOptions(config.MFAOptions{Issuer: "Agartha", EncryptionKey: "a-different-key-of-at-least-32-characters"})
+	_, err = openMFASecret(sealed)
+	require.Error(t, err)
+}
+
+func TestSecondFactorRequired(t *testing.T) {
+	mock := newTestAuthDB(t)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha", RequiredMethods: []string{"ldap"}})
+	originalProviders := registeredProviders
+	registeredProviders = append(testProviders(t, "local", "ldap", "oidc"), ldapProvider{name: "corp"})
+	t.Cleanup(func() { registeredProviders = originalProviders })
+	enrollmentQuery := regexp.QuoteMeta(`SELECT count(*) FROM "user_mfa" JOIN auth_user ON auth_user.id = user_mfa.user_id WHERE auth_user.username = $1 AND user_mfa.confirmed_at IS NOT NULL`)
+
+	mock.ExpectQuery(enrollmentQuery).WithArgs("alice").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	required, enrolled, err := secondFactorRequired("local", "alice")
+	require.NoError(t, err)
+	require.True(t, required)
+	require.True(t, enrolled)
+
+	mock.ExpectQuery(enrollmentQuery).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	required, enrolled, err = secondFactorRequired("local", "bob")
+	require.NoError(t, err)
+	require.False(t, required)
+	require.False(t, enrolled)
+
+	mock.ExpectQuery(enrollmentQuery).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	required, enrolled, err = secondFactorRequired("ldap", "bob")
+	require.NoError(t, err)
+	require.True(t, required)
+	require.False(t, enrolled)
+
+	// Requiring ldap covers every LDAP directory.
+	mock.ExpectQuery(enrollmentQuery).WithArgs("bob").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	required, _, err = secondFactorRequired("corp", "bob")
+	require.NoError(t, err)
+	require.True(t, required)
+
+	required, _, err = secondFactorRequired("oidc", "alice")
+	require.NoError(t, err)
+	require.False(t, required)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetrieveTokenChallengesEnrolledUser(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`FROM auth_user\s+WHERE username = \$1`).
+		WithArgs("alice", testStrongPassword).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "user_mfa"`).
+		WithArgs("alice").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/token", `{"username":"alice","password":"`+testStrongPassword+`","method":"local"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.Empty(t, response.Header().Values("Set-Cookie"))
+
+	var challenge MFAChallenge
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &challenge))
+	require.Equal(t, "Second factor required.", challenge.Message)
+	require.False(t, challenge.EnrollmentRequired)
+	claims, err := parseMFAChallenge(challenge.MFAToken)
+	require.NoError(t, err)
+	require.Equal(t, "alice", claims.Username)
+	require.Equal(t, "local", claims.Method)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifySecondFactorRejectsReplay(t *testing.T) {
+	mock := newTestAuthDB(t)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+	updateStep := regexp.QuoteMeta(`UPDATE "user_mfa" SET "last_used_step"=$1 WHERE user_id = $2 AND last_used_step < $3`)
+
+	expectConfirmedMFA(mock, t, 7, secret)
+	mock.ExpectBegin()
+	mock.ExpectExec(updateStep).WithArgs(step, 7, step).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ok, err := verifySecondFactor(db.DB, 7, code)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	expectConfirmedMFA(mock, t, 7, secret)
+	mock.ExpectBegin()
+	mock.ExpectExec(updateStep).WithArgs(step, 7, step).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	ok, err = verifySecondFactor(db.DB, 7, code)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	expectConfirmedMFA(mock, t, 7, secret)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "mfa_recovery_code" SET "used_at"=$1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 7, hashRecoveryCode("abcde-fghij")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	ok, err = verifySecondFactor(db.DB, 7, "ABCDE FGHIJ")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestVerifyMFARejectsSessionTokensAndCountsFailedCodes(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+	useLockoutTestOptions(t, config.NewConfig().Lockout)
+	router := newPasswordTestRouter()
+
+	sessionToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": "alice",
+		"user_id":  7,
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}).SignedString(jwtSecret)
+	require.NoError(t, err)
+	response := servePasswordRequest(router, "/auth/mfa/verify", `{"mfa_token":"`+sessionToken+`","code":"123456"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	challenge, err := signMFAChallenge(mfaChallengeClaims{Username: "alice", Method: "local"})
+	require.NoError(t, err)
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1 AND is_active = \$2`).
+		WithArgs("alice", true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectQuery(`SELECT \* FROM "user_mfa"`).
+		WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+	expectLoginFailureRecorded(mock, "alice")
+	response = servePasswordRequest(router, "/auth/mfa/verify", `{"mfa_token":"`+challenge+`","code":"000000"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// The lockout is kept in the database, so a fresh challenge from another
+	// password login is refused as well.
+	challenge, err = signMFAChallenge(mfaChallengeClaims{Username: "alice", Method: "local"})
+	require.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(loginFailureQuery)).
+		WithArgs(model.LoginFailureUsername, "alice", model.LoginFailureClientIP, testClientIP).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "subject", "failures", "locked_until"}).
+			AddRow(3, model.LoginFailureUsername, "alice", 5, time.Now().Add(10*time.Minute)))
+	response = servePasswordRequest(router, "/auth/mfa/verify", `{"mfa_token":"`+challenge+`","code":"000000"}`)
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.NotEmpty(t, response.Header().Get("Retry-After"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRequiredMFAEnrollmentDuringLogin(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha", RequiredMethods: []string{"local"}})
+	router := newPasswordTestRouter()
+
+	challenge, err := signMFAChallenge(mfaChallengeClaims{Username: "alice", Method: "local", Enroll: true})
+	require.NoError(t, err)
+	response := servePasswordRequest(router, "/auth/mfa/setup", `{"mfa_token":"`+challenge+`"}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	var enrollment MFAEnrollment
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &enrollment))
+	require.Contains(t, enrollment.URL, "otpauth://totp/Agartha:alice?")
+	require.NotEqual(t, challenge, enrollment.MFAToken)
+
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "auth_user" SET .* WHERE "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "user_mfa" .* ON CONFLICT \("user_id"\) DO UPDATE`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "user_id"}).AddRow(time.Now(), 7))
+	mock.ExpectExec(`DELETE FROM "mfa_recovery_code" WHERE user_id = \$1`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "mfa_recovery_code"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2).AddRow(3).AddRow(4).AddRow(5).AddRow(6).AddRow(7).AddRow(8).AddRow(9).AddRow(10))
+	mock.ExpectCommit()
+	expectSessionStarted(mock, 7)
+	expectLoginFailuresCleared(mock, "alice")
+
+	response = servePasswordRequest(router, "/auth/mfa/verify", `{"mfa_token":"`+enrollment.MFAToken+`","code":"`+code+`"}`)
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var token MFAToken
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	require.True(t, len(token.Token.Token) > len("Bearer "))
+	require.NotEmpty(t, token.RefreshToken)
+	require.Len(t, token.RecoveryCodes, mfaRecoveryCodeCount)
+	require.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, token.RecoveryCodes[0])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFAEnrollmentDuringLoginKeepsAConfirmedSecondFactor(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha", RequiredMethods: []string{"local"}})
+	router := newPasswordTestRouter()
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	sealed, err := sealMFASecret(secret)
+	require.NoError(t, err)
+	challenge, err := signMFAChallenge(mfaChallengeClaims{Username: "alice", Method: "local", Enroll: true, PendingSecret: sealed})
+	require.NoError(t, err)
+	code, err := totp.Code(secret, totp.Step(time.Now()))
+	require.NoError(t, err)
+
+	expectNoLoginFailures(mock, "alice")
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "auth_user" SET .* WHERE "id" = \$\d+`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	// alice confirmed another secret since the challenge was issued, so the
+	// upsert leaves her enrollment alone and no session is started.
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "user_mfa" .* ON CONFLICT \("user_id"\) DO UPDATE SET .* WHERE "user_mfa"\."confirmed_at" IS NULL`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "user_id"}))
+	mock.ExpectRollback()
+
+	response := servePasswordRequest(router, "/auth/mfa/verify", `{"mfa_token":"`+challenge+`","code":"`+code+`"}`)
+	require.Equal(t, http.StatusConflict, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRegenerateMFARecoveryCodesCountsFailedCodes(t *testing.T) {
+	mock := newTestAuthDB(t)
+	usePasswordTestOptions(t, nil)
+	useMFATestOptions(t, config.MFAOptions{Issuer: "Agartha"})
+	useLockoutTestOptions(t, config.NewConfig().Lockout)
+	router := newPasswordTestRouter()
+
+	expectActivePasswordUser(mock)
+	expectNoLoginFailures(mock, "alice")
+	expectConfirmedMFA(mock, t, 7, "JBSWY3DPEHPK3PXP")
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "mfa_recovery_code" SET "used_at"=\$1 WHERE user_id = \$2 AND code_hash = \$3 AND used_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), 7, hashRecoveryCode("aaaaa-bbbbb")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	expectLoginFailureRecorded(mock, "alice")
+	response := servePasswordRequest(router, "/auth/mfa/recovery_codes", `{"code":"aaaaa-bbbbb"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	// Once the lockout applies, codes are no longer checked at all.
+	expectActivePasswordUser(mock)
+	mock.ExpectQuery(regexp.QuoteMeta(loginFailureQuery)).
+		WithArgs(model.LoginFailureUsername, "alice", model.LoginFailureClientIP, testClientIP).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "subject", "failures", "locked_until"}).
+			AddRow(3, model.LoginFailureUsername, "alice", 5, time.Now().Add(10*time.Minute)))
+	response = servePasswordRequest(router, "/auth/mfa/recovery_codes", `{"code":"123456"}`)
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	require.NotEmpty(t, response.Header().Get("Retry-After"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateSessionKey    = "oidc_state"
+	oidcNonceSessionKey    = "oidc_nonce"
+	oidcVerifierSessionKey = "oidc_verifier"
+)
+
+var oidcHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+type oidcRelyingParty struct {
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+var (
+	oidcProviderMu sync.Mutex
+	oidcProvider   *oidcRelyingParty
+)
+
+// OIDCLogin redirects the browser to the OpenID Connect provider.
+//
+//	@Summary		Starts an OpenID Connect login.
+//	@Description	Redirects the browser to the OpenID Connect provider using the authorization code flow with PKCE.
+//	@Tags			Auth
+//	@Success		302
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/oidc/login [get]
+func OIDCLogin(c *gin.Context) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("oidc"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "OIDC authentication is not enabled.")
+		return
+	}
+	rp, err := oidcRelyingPartyFor(c.Request.Context())
+	if err != nil {
+		log.Error("failed to configure OIDC provider", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "OIDC authentication is unavailable.")
+		return
+	}
+
+	state := oauth2.GenerateVerifier()
+	nonce := oauth2.GenerateVerifier()
+	verifier := oauth2.GenerateVerifier()
+	session := sessions.Default(c)
+	session.Set(oidcStateSessionKey, state)
+	session.Set(oidcNonceSessionKey, nonce)
+	session.Set(oidcVerifierSessionKey, verifier)
+	if err := session.Save(); err != nil {
+		log.Error("failed to save OIDC login state", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to save session.")
+		return
+	}
+
+	c.Redirect(http.StatusFound, rp.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)))
+}
+
+// OIDCCallback completes the authorization code flow and establishes the session.
+//
+//	@Summary		Completes an OpenID Connect login.
+//	@Description	Exchanges the authorization code, validates the ID token, provisions the user and redirects to the application.
+//	@Tags			Auth
+//	@Param			code	query	string	true	"Authorization code"
+//	@Param			state	query	string	true	"Login state"
+//	@Success		303
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/oidc/callback [get]
+func OIDCCallback(c *gin.Context) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("oidc"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "OIDC authentication is not enabled.")
+		return
+	}
+
+	userData, err := authOIDC(c)
+	if err != nil {
+		log.Error("rejected OIDC login", zap.Error(err))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, "", "oidc", err.Error())
+		return
+	}
+	if _, ok := establishSession(c, userData); !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// authOIDC redeems the authorization code in the request query against the
+// state, nonce and PKCE verifier saved by OIDCLogin. The saved values are
+// consumed whether or not the exchange succeeds.
+func authOIDC(c *gin.Context) (userData, error) {
+	var userData userData
+
+	if providerError := c.Query("error"); providerError != "" {
+		return userData, fmt.Errorf("OIDC provider returned %s: %s", providerError, c.Query("error_description"))
+	}
+	code := c.Query("code")
+	if code == "" {
+		return userData, errors.New("no OIDC authorization code provided")
+	}
+
+	session := sessions.Default(c)
+	expectedState, _ := session.Get(oidcStateSessionKey).(string)
+	nonce, _ := session.Get(oidcNonceSessionKey).(string)
+	verifier, _ := session.Get(oidcVerifierSessionKey).(string)
+	session.Delete(oidcStateSessionKey)
+	session.Delete(oidcNonceSessionKey)
+	session.Delete(oidcVerifierSessionKey)
+	if err := session.Save(); err != nil {
+		return userData, fmt.Errorf("failed to clear OIDC login state: %w", err)
+	}
+	if expectedState == "" || nonce == "" || verifier == "" {
+		return userData, errors.New("no OIDC login is in progress for this session")
+	}
+	if subtle.ConstantTimeCompare([]byte(expectedState), []byte(c.Query("state"))) != 1 {
+		return userData, errors.New("OIDC state does not match the login request")
+	}
+
+	ctx := oidc.ClientContext(c.Request.Context(), oidcHTTPClient)
+	rp, err := oidcRelyingPartyFor(ctx)
+	if err != nil {
+		return userData, err
+	}
+	token, err := rp.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return userData, fmt.Errorf("failed to exchange OIDC authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return userData, errors.New("OIDC token response did not include an ID token")
+	}
+	idToken, err := rp.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return userData, fmt.Errorf("invalid OIDC ID token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return userData, errors.New("OIDC ID token nonce does not match the login request")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return userData, fmt.Errorf("failed to decode OIDC ID token claims: %w", err)
+	}
+	return oidcUserData(claims, oidcOptions)
+}
+
+func resetOIDCRelyingParty() {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+	oidcProvider = nil
+}
+
+// oidcRelyingPartyFor discovers the issuer on first use so an unreachable
+// provider does not block server startup.
+func oidcRelyingPartyFor(ctx context.Context) (*oidcRelyingParty, error) {
+	oidcProviderMu.Lock()
+	defer oidcProviderMu.Unlock()
+	if oidcProvider != nil {
+		return oidcProvider, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, oidcHTTPClient), oidcOptions.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discover OIDC issuer: %w", err)
+	}
+	oidcProvider = &oidcRelyingParty{
+		oauth2: oauth2.Config{
+			ClientID:     oidcOptions.ClientID,
+			ClientSecret: oidcOptions.ClientSecret,
+			RedirectURL:  oidcOptions.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       oidcOptions.Scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: oidcOptions.ClientID}),
+	}
+	return oidcProvider, nil
+}
+
+func oidcUserData(claims map[string]any, options config.OIDCOptions) (userData, error) {
+	var userData userData
+
+	username := strings.TrimSpace(oidcStringClaim(claims, options.UsernameClaim))
+	if username == "" {
+		return userData, fmt.Errorf("OIDC ID token did not include the %q claim", options.UsernameClaim)
+	}
+
+	userData.Username = username
+	userData.SamAccountName = username
+	userData.UserPrincipalName = username
+	userData.FirstName = oidcStringClaim(claims, options.FirstNameClaim)
+	userData.LastName = oidcStringClaim(claims, options.LastNameClaim)
+	userData.Email = oidcStringClaim(claims, options.EmailClaim)
+	userData.Groups = oidcStringsClaim(claims, options.GroupsClaim)
+	userData.Method = "oidc"
+	return userData, nil
+}
+
+// oidcClaim resolves a claim name, following dots into nested objects so
+// provider-specific layouts such as Keycloak's realm_access.roles can be mapped.
+func oidcClaim(claims map[string]any, name string) any {
+	if name == "" {
+		return nil
+	}
+	if value, ok := claims[name]; ok {
+		return value
+	}
+	var current any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[part]
+	}
+	return current
+}
+
+func oidcStringClaim(claims map[string]any, name string) string {
+	value, _ := oidcClaim(claims, name).(string)
+	return value
+}
+
+func oidcStringsClaim(claims map[string]any, name string) []string {
+	switch value := oidcClaim(claims, name).(type) {
+	case string:
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if item, ok := item.(string); ok && item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const testOIDCClientID = "agartha-test"
+
+type testOIDCAuthorization struct {
+	nonce         string
+	codeChallenge string
+	claims        jwt.MapClaims
+}
+
+// testOIDCIssuer is an in-process OpenID Connect provider that serves
+// discovery, JWKS and the token endpoint for codes registered by the test.
+type testOIDCIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu             sync.Mutex
+	authorizations map[string]testOIDCAuthorization
+}
+
+func newTestOIDCIssuer(t *testing.T) *testOIDCIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &testOIDCIssuer{key: key, authorizations: map[string]testOIDCAuthorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(response http.ResponseWriter, _ *http.Request) {
+		writeTestJSON(response, map[string]any{
+			"issuer":                                issuer.server.URL,
+			"authorization_endpoint":                issuer.server.URL + "/authorize",
+			"token_endpoint":                        issuer.server.URL + "/token",
+			"jwks_uri":                              issuer.server.URL + "/jwks",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(response http.ResponseWriter, _ *http.Request) {
+		writeTestJSON(response, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", issuer.token)
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (issuer *testOIDCIssuer) token(response http.ResponseWriter, request *http.Request) {
+	if err := request.ParseForm(); err != nil {
+		http.Error(response, err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuer.mu.Lock()
+	authorization, ok := issuer.authorizations[request.PostForm.Get("code")]
+	delete(issuer.authorizations, request.PostForm.Get("code"))
+	issuer.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(request.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.codeChallenge {
+		response.WriteHeader(http.StatusBadRequest)
+		writeTestJSON(response, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   issuer.server.URL,
+		"aud":   testOIDCClientID,
+		"sub":   "subject-alice",
+		"nonce": authorization.nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	}
+	for name, value := range authorization.claims {
+		claims[name] = value
+	}
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(issuer.key)
+	if err != nil {
+		http.Error(response, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeTestJSON(response, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+// authorize records the code the provider would issue for the login redirect.
+func (issuer *testOIDCIssuer) authorize(t *testing.T, loginRedirect *url.URL, claims jwt.MapClaims) string {
+	t.Helper()
+
+	query := loginRedirect.Query()
+	require.Equal(t, testOIDCClientID, query.Get("client_id"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	code := "code-" + query.Get("state")
+	issuer.mu.Lock()
+	issuer.authorizations[code] = testOIDCAuthorization{
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		claims:        claims,
+	}
+	issuer.mu.Unlock()
+	return code
+}
+
+func writeTestJSON(response http.ResponseWriter, value any) {
+	response.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(response).Encode(value)
+}
+
+func useTestOIDCOptions(t *testing.T, issuer *testOIDCIssuer) {
+	t.Helper()
+
+	originalProviders := registeredProviders
+	originalOptions := oidcOptions
+	registeredProviders = testProviders(t, "oidc")
+	oidcOptions = config.NewConfig().OIDC
+	oidcOptions.Issuer = issuer.server.URL
+	oidcOptions.ClientID = testOIDCClientID
+	oidcOptions.RedirectURL = "https://agartha.example.test/auth/oidc/callback"
+	oidcOptions.GroupsClaim = "realm_access.roles"
+	resetOIDCRelyingParty()
+	t.Cleanup(func() {
+		registeredProviders = originalProviders
+		oidcOptions = originalOptions
+		resetOIDCRelyingParty()
+	})
+}
+
+func newOIDCTestRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte("01234567890123456789012345678901"))))
+	AddRoutes(router.Group("/auth"))
+	return router
+}
+
+func startTestOIDCLogin(t *testing.T, router *gin.Engine) (*url.URL, *http.Cookie) {
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, response.Code, response.Body.String())
+	location, err := url.Parse(response.Header().Get("Location"))
+	require.NoError(t, err)
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return location, cookies[0]
+}
+
+func finishTestOIDCLogin(router *gin.Engine, sessionCookie *http.Cookie, query url.Values) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+query.Encode(), nil)
+	request.AddCookie(sessionCookie)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestOIDCLoginEstablishesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	issuer := newTestOIDCIssuer(t)
+	useTestOIDCOptions(t, issuer)
+	mock := newTestAuthDB(t)
+	expectProvisionedUser(mock, 7, userData{Username: "alice", FirstName: "Alice", LastName: "Admin", Email: "alice@example.test"})
+
+	router := newOIDCTestRouter()
+	loginRedirect, sessionCookie := startTestOIDCLogin(t, router)
+	require.Equal(t, issuer.server.URL+"/authorize", loginRedirect.Scheme+"://"+loginRedirect.Host+loginRedirect.Path)
+	code := issuer.authorize(t, loginRedirect, jwt.MapClaims{
+		"preferred_username": "alice",
+		"given_name":         "Alice",
+		"family_name":        "Admin",
+		"email":              "alice@example.test",
+		"realm_access":       map[string]any{"roles": []string{"salt-operators"}},
+	})
+
+	response := finishTestOIDCLogin(router, sessionCookie, url.Values{"code": {code}, "state": {loginRedirect.Query().Get("state")}})
+
+	require.Equal(t, http.StatusSeeOther, response.Code, response.Body.String())
+	require.Equal(t, "/", response.Header().Get("Location"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallbackRejectsInvalidLogins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	issuer := newTestOIDCIssuer(t)
+	useTestOIDCOptions(t, issuer)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		query  func(code, state string) url.Values
+	}{
+		{
+			name:   "state mismatch",
+			claims: jwt.MapClaims{"preferred_username": "alice"},
+			query:  func(code, _ string) url.Values { return url.Values{"code": {code}, "state": {"forged"}} },
+		},
+		{
+			name:   "wrong audience",
+			claims: jwt.MapClaims{"preferred_username": "alice", "aud": "another-client"},
+			query:  func(code, state string) url.Values { return url.Values{"code": {code}, "state": {state}} },
+		},
+		{
+			name:   "replayed nonce",
+			claims: jwt.MapClaims{"preferred_username": "alice", "nonce": "stale"},
+			query:  func(code, state string) url.Values { return url.Values{"code": {code}, "state": {state}} },
+		},
+		{
+			name:   "missing username claim",
+			claims: jwt.MapClaims{"email": "alice@example.test"},
+			query:  func(code, state string) url.Values { return url.Values{"code": {code}, "state": {state}} },
+		},
+		{
+			name:   "provider error",
+			claims: jwt.MapClaims{"preferred_username": "alice"},
+			query:  func(string, string) url.Values { return url.Values{"error": {"access_denied"}} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newOIDCTestRouter()
+			loginRedirect, sessionCookie := startTestOIDCLogin(t, router)
+			code := issuer.authorize(t, loginRedirect, tt.claims)
+
+			response := finishTestOIDCLogin(router, sessionCookie, tt.query(code, loginRedirect.Query().Get("state")))
+			require.Equal(t, http.StatusUnauthorized, response.Code, response.Body.String())
+		})
+	}
+}
+
+func TestOIDCCallbackRequiresLoginState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	issuer := newTestOIDCIssuer(t)
+	useTestOIDCOptions(t, issuer)
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=code&state=state", nil)
+	response := httptest.NewRecorder()
+	newOIDCTestRouter().ServeHTTP(response, request)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestOIDCUserDataMapsConfiguredClaims(t *testing.T) {
+	claims := map[string]any{
+		"upn":          "alice@example.test",
+		"given_name":   "Alice",
+		"family_name":  "Admin",
+		"email":        "alice@example.test",
+		"groups":       []any{"salt-admins", "", 7, "salt-operators"},
+		"realm_access": map[string]any{"roles": "salt-readers"},
+	}
+	options := config.NewConfig().OIDC
+	options.UsernameClaim = "upn"
+
+	user, err := oidcUserData(claims, options)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.test", user.Username)
+	require.Equal(t, "Alice", user.FirstName)
+	require.Equal(t, "Admin", user.LastName)
+	require.Equal(t, []string{"salt-admins", "salt-operators"}, user.Groups)
+
+	options.GroupsClaim = "realm_access.roles"
+	user, err = oidcUserData(claims, options)
+	require.NoError(t, err)
+	require.Equal(t, []string{"salt-readers"}, user.Groups)
+
+	options.UsernameClaim = "preferred_username"
+	_, err = oidcUserData(claims, options)
+	require.ErrorContains(t, err, "preferred_username")
+}
@@ -0,0 +1,380 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/notify"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const passwordResetDeliveryTimeout = time.Minute
+
+var passwordOptions = config.NewConfig().Password
+var passwordNotifier notify.Notifier
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type forgotPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// passwordPolicyError explains why a new password was rejected. Its message
+// is safe to return to the client.
+type passwordPolicyError string
+
+func (err passwordPolicyError) Error() string {
+	return string(err)
+}
+
+// SetPasswordOptions configures the local password policy and the notifier
+// used to deliver reset tokens. Password resets are disabled when notifier is nil.
+func SetPasswordOptions(options config.PasswordOptions, notifier notify.Notifier) {
+	passwordOptions = options
+	passwordNotifier = notifier
+}
+
+// ChangePassword replaces the authenticated user's local password.
+//
+//	@Summary		Changes the current user's local password.
+//	@Description	Verifies the current password, applies the password policy and ends every session of the user, including this one.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	changePasswordRequest	true	"Current and new password"
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/password [post]
+//	@Security		Bearer
+func ChangePassword(c *gin.Context) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("local"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "Local authentication is not enabled.")
+		return
+	}
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "Authentication session is unavailable.")
+		return
+	}
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing current or new password.")
+		return
+	}
+	if err := CheckPasswordPolicy(req.NewPassword, user.Username); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.NewPassword == req.CurrentPassword {
+		httputil.NewError(c, http.StatusBadRequest, "The new password must differ from the current password.")
+		return
+	}
+
+	var matches int64
+	err := db.DB.Raw(`SELECT count(*) FROM auth_user WHERE id = ? AND password <> '' AND password = crypt(?, password)`, user.ID, req.CurrentPassword).Scan(&matches).Error
+	if err != nil {
+		log.Error("failed to verify current password", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to change password.")
+		return
+	}
+	if matches != 1 {
+		httputil.NewError(c, http.StatusForbidden, "Current password is incorrect.")
+		return
+	}
+
+	if err := ReplacePassword(db.DB, &user, req.NewPassword); err != nil {
+		log.Error("failed to change password", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to change password.")
+		return
+	}
+	log.Info("changed local password", zap.Uint("user_id", user.ID))
+
+	if err := clearSession(c); err != nil {
+		log.Error("failed to clear session after password change", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ForgotPassword sends a single-use reset token to the user's email address.
+//
+//	@Summary		Requests a local password reset.
+//	@Description	Emails a single-use password reset token to an active local user. The response does not reveal whether the account exists.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	forgotPasswordRequest	true	"Username"
+//	@Success		202
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	log := logger.GetLogger()
+	if !passwordResetEnabled() {
+		httputil.NewError(c, http.StatusNotFound, "Password reset is not enabled.")
+		return
+	}
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing username.")
+		return
+	}
+
+	var user model.AuthUser
+	err := db.DB.Where("username = ? AND is_active = ? AND password <> ''", strings.TrimSpace(req.Username), true).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Info("ignored password reset request for unknown or non-local user", zap.String("username", req.Username))
+		c.Status(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		log.Error("failed to look up user for password reset", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to request password reset.")
+		return
+	}
+	if strings.TrimSpace(user.Email) == "" {
+		log.Warn("cannot deliver password reset to user without an email address", zap.Uint("user_id", user.ID))
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	token, tokenHash, err := newPasswordResetToken()
+	if err != nil {
+		log.Error("failed to generate password reset token", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to request password reset.")
+		return
+	}
+	resetToken := model.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(passwordOptions.ResetTokenTTL),
+		CreatedAt: time.Now(),
+	}
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recently issued token remains usable.
+		if err := tx.Where("user_id = ? AND used_at IS NULL", user.ID).Delete(&model.PasswordResetToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&resetToken).Error
+	})
+	if err != nil {
+		log.Error("failed to store password reset token", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to request password reset.")
+		return
+	}
+
+	// Deliver in the background so response timing does not reveal whether
+	// the account exists.
+	go deliverPasswordReset(passwordNotifier, user, token)
+	c.Status(http.StatusAccepted)
+}
+
+// ResetPassword redeems a reset token and sets a new local password.
+//
+//	@Summary		Completes a local password reset.
+//	@Description	Consumes a password reset token, applies the password policy and ends every session of the user.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body	resetPasswordRequest	true	"Reset token and new password"
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	log := logger.GetLogger()
+	if !passwordResetEnabled() {
+		httputil.NewError(c, http.StatusNotFound, "Password reset is not enabled.")
+		return
+	}
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing reset token or new password.")
+		return
+	}
+	if err := CheckPasswordPolicy(req.NewPassword, ""); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var user model.AuthUser
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var resetToken model.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashPasswordResetToken(req.Token), now).First(&resetToken).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND is_active = ?", resetToken.UserID, true).First(&user).Error; err != nil {
+			return err
+		}
+		if err := CheckPasswordPolicy(req.NewPassword, user.Username); err != nil {
+			return err
+		}
+		// Claim the token conditionally so concurrent redemptions cannot both succeed.
+		claimed := tx.Model(&model.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", resetToken.ID).Update("used_at", now)
+		if claimed.Error != nil {
+			return claimed.Error
+		}
+		if claimed.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := user.SetPassword(tx, req.NewPassword); err != nil {
+			return err
+		}
+		return user.InvalidateCredentials(tx)
+	})
+
+	var policyErr passwordPolicyError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		httputil.NewError(c, http.StatusBadRequest, "Invalid or expired reset token.")
+		return
+	case errors.As(err, &policyErr):
+		httputil.NewError(c, http.StatusBadRequest, policyErr.Error())
+		return
+	case err != nil:
+		log.Error("failed to reset password", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to reset password.")
+		return
+	}
+
+	log.Info("reset local password", zap.Uint("user_id", user.ID))
+	c.Status(http.StatusNoContent)
+}
+
+func passwordResetEnabled() bool {
+	_, enabled := providerNamed("local")
+	return enabled && passwordNotifier != nil
+}
+
+// ReplacePassword stores the new password and revokes every credential issued
+// under the old one in a single transaction. It returns gorm.ErrRecordNotFound
+// when the user does not exist.
+func ReplacePassword(database *gorm.DB, user *model.AuthUser, password string) error {
+	return database.Transaction(func(tx *gorm.DB) error {
+		if err := user.SetPassword(tx, password); err != nil {
+			return err
+		}
+		return user.InvalidateCredentials(tx)
+	})
+}
+
+// CheckPasswordPolicy rejects a password that is too short, uses too few
+// character classes or contains the username. The error message is safe to
+// return to the client.
+func CheckPasswordPolicy(password, username string) error {
+	if utf8.RuneCountInString(password) < passwordOptions.MinLength {
+		return passwordPolicyError(fmt.Sprintf("Password must be at least %d characters long.", passwordOptions.MinLength))
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < passwordOptions.MinCharacterClasses {
+		return passwordPolicyError(fmt.Sprintf("Password must use at least %d of: lowercase letters, uppercase letters, digits and symbols.", passwordOptions.MinCharacterClasses))
+	}
+
+	if username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return passwordPolicyError("Password must not contain the username.")
+	}
+	return nil
+}
+
+func newPasswordResetToken() (string, string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buffer)
+	return token, hashPasswordResetToken(token), nil
+}
+
+func hashPasswordResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func deliverPasswordReset(notifier notify.Notifier, user model.AuthUser, token string) {
+	log := logger.GetLogger()
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetDeliveryTimeout)
+	defer cancel()
+
+	validity := fmt.Sprintf("%d minutes", int(math.Ceil(passwordOptions.ResetTokenTTL.Minutes())))
+	var body strings.Builder
+	fmt.Fprintf(&body, "A password reset was requested for the Agartha account %q.\n\n", user.Username)
+	if passwordOptions.ResetURL != "" {
+		resetURL, err := url.Parse(passwordOptions.ResetURL)
+		if err != nil {
+			log.Error("invalid password reset URL", zap.Error(err))
+			return
+		}
+		query := resetURL.Query()
+		query.Set("token", token)
+		resetURL.RawQuery = query.Encode()
+		fmt.Fprintf(&body, "Open this link within %s to choose a new password:\n\n%s\n\n", validity, resetURL)
+	} else {
+		fmt.Fprintf(&body, "Use this reset token within %s to choose a new password:\n\n%s\n\n", validity, token)
+	}
+	body.WriteString("If you did not request a password reset, you can ignore this message.\n")
+
+	err := notifier.Notify(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Reset your Agartha password",
+		Body:    body.String(),
+	})
+	if err != nil {
+		log.Error("failed to deliver password reset", zap.Uint("user_id", user.ID), zap.Error(err))
+		return
+	}
+	log.Info("delivered password reset", zap.Uint("user_id", user.ID))
+}
@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	"github.com/PaulChristophel/agartha/server/notify"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+const testStrongPassword = "Correct-Horse-42"
+
+type recordingNotifier struct {
+	messages chan notify.Message
+}
+
+func (notifier *recordingNotifier) Notify(_ context.Context, message notify.Message) error {
+	notifier.messages <- message
+	return nil
+}
+
+// capturedArgument matches any value and remembers it for later assertions.
+type capturedArgument struct {
+	value driver.Value
+}
+
+func (argument *capturedArgument) Match(value driver.Value) bool {
+	argument.value = value
+	return true
+}
+
+func usePasswordTestOptions(t *testing.T, notifier notify.Notifier) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	originalProviders := registeredProviders
+	originalOptions := passwordOptions
+	originalNotifier := passwordNotifier
+	registeredProviders = testProviders(t, "local")
+	SetPasswordOptions(config.NewConfig().Password, notifier)
+	t.Cleanup(func() {
+		registeredProviders = originalProviders
+		SetPasswordOptions(originalOptions, originalNotifier)
+	})
+}
+
+func newPasswordTestRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte("01234567890123456789012345678901"))))
+	AddRoutes(router.Group("/auth"))
+	AddSessionRoutes(router.Group("/auth", func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		c.Set("username", "alice")
+	}, middleware.ActiveUserRequired(db.DB)))
+	return router
+}
+
+func servePasswordRequest(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func expectActivePasswordUser(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 AND username = $2 AND is_active = $3 ORDER BY "auth_user"."id" LIMIT $4`)).
+		WithArgs(uint(7), "alice", true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "is_active"}).AddRow(7, "alice", "alice@agartha.test", true))
+}
+
+func expectPasswordReplaced(mock sqlmock.Sqlmock, password string) {
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_user SET password = crypt($1, gen_salt('bf', 8)) WHERE id = $2`)).
+		WithArgs(password, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM session_user_map WHERE user_id = $1)`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO revoked_token .* FROM refresh_token`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM session_user_map WHERE user_id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = '' WHERE user_id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestCheckPasswordPolicy(t *testing.T) {
+	usePasswordTestOptions(t, nil)
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		wantErr  string
+	}{
+		{name: "strong", password: testStrongPassword, username: "alice"},
+		{name: "too short", password: "Sh0rt-pass", wantErr: "at least 12 characters"},
+		{name: "too few classes", password: "onlylowercaseletters", wantErr: "at least 3 of"},
+		{name: "contains username", password: "Alice-Password-1", username: "alice", wantErr: "must not contain the username"},
+		{name: "multibyte characters count once", password: "Pässwörd-ñ1", wantErr: "at least 12 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPasswordPolicy(tt.password, tt.username)
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	usePasswordTestOptions(t, nil)
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		expectActivePasswordUser(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM auth_user WHERE id = $1 AND password <> '' AND password = crypt($2, password)`)).
+			WithArgs(7, "wrong-password").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password", `{"current_password":"wrong-password","new_password":"`+testStrongPassword+`"}`)
+		require.Equal(t, http.StatusForbidden, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("weak new password", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		expectActivePasswordUser(mock)
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password", `{"current_password":"old-password","new_password":"short"}`)
+		require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		expectActivePasswordUser(mock)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM auth_user WHERE id = $1 AND password <> '' AND password = crypt($2, password)`)).
+			WithArgs(7, "old-password").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectBegin()
+		expectPasswordReplaced(mock, testStrongPassword)
+		mock.ExpectCommit()
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password", `{"current_password":"old-password","new_password":"`+testStrongPassword+`"}`)
+		require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+		require.Contains(t, response.Header().Values("Set-Cookie")[0], "Max-Age=0")
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestForgotPasswordDeliversSingleUseToken(t *testing.T) {
+	notifier := &recordingNotifier{messages: make(chan notify.Message, 1)}
+	usePasswordTestOptions(t, notifier)
+	mock := newTestAuthDB(t)
+	storedHash := &capturedArgument{}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE username = $1 AND is_active = $2 AND password <> '' ORDER BY "auth_user"."id" LIMIT $3`)).
+		WithArgs("alice", true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "username", "email", "is_active"}).AddRow(7, "alice", "alice@agartha.test", true))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "password_reset_token" WHERE user_id = $1 AND used_at IS NULL`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "password_reset_token"`).
+		WithArgs(7, storedHash, sqlmock.AnyArg(), nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectCommit()
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/password/forgot", `{"username":"alice"}`)
+	require.Equal(t, http.StatusAccepted, response.Code, response.Body.String())
+
+	var message notify.Message
+	select {
+	case message = <-notifier.messages:
+	case <-time.After(5 * time.Second):
+		t.Fatal("password reset was not delivered")
+	}
+	require.Equal(t, "alice@agartha.test", message.To)
+	lines := strings.Split(strings.TrimSpace(message.Body), "\n\n")
+	require.GreaterOrEqual(t, len(lines), 3)
+	token := lines[2]
+	require.Equal(t, hashPasswordResetToken(token), storedHash.value)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestForgotPasswordDoesNotRevealUnknownUsers(t *testing.T) {
+	notifier := &recordingNotifier{messages: make(chan notify.Message, 1)}
+	usePasswordTestOptions(t, notifier)
+	mock := newTestAuthDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("mallory", true, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	response := servePasswordRequest(newPasswordTestRouter(), "/auth/password/forgot", `{"username":"mallory"}`)
+	require.Equal(t, http.StatusAccepted, response.Code, response.Body.String())
+	require.Empty(t, notifier.messages)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPasswordResetRequiresNotifier(t *testing.T) {
+	usePasswordTestOptions(t, nil)
+
+	for _, path := range []string{"/auth/password/forgot", "/auth/password/reset"} {
+		response := servePasswordRequest(newPasswordTestRouter(), path, `{}`)
+		require.Equal(t, http.StatusNotFound, response.Code, path)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	usePasswordTestOptions(t, &recordingNotifier{messages: make(chan notify.Message, 1)})
+	tokenHash := hashPasswordResetToken("reset-token")
+
+	t.Run("redeems token", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "password_reset_token" WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2 ORDER BY "password_reset_token"."id" LIMIT $3`)).
+			WithArgs(tokenHash, sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(3, 7))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 AND is_active = $2 ORDER BY "auth_user"."id" LIMIT $3`)).
+			WithArgs(7, true, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "password_reset_token" SET "used_at"=$1 WHERE id = $2 AND used_at IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectPasswordReplaced(mock, testStrongPassword)
+		mock.ExpectCommit()
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password/reset", `{"token":"reset-token","new_password":"`+testStrongPassword+`"}`)
+		require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects used or expired token", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "password_reset_token"`).
+			WithArgs(tokenHash, sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectRollback()
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password/reset", `{"token":"reset-token","new_password":"`+testStrongPassword+`"}`)
+		require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+		require.JSONEq(t, `{"code":400,"message":"Invalid or expired reset token."}`, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("keeps token when password contains username", func(t *testing.T) {
+		mock := newTestAuthDB(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT \* FROM "password_reset_token"`).
+			WithArgs(tokenHash, sqlmock.AnyArg(), 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(3, 7))
+		mock.ExpectQuery(`SELECT \* FROM "auth_user"`).
+			WithArgs(7, true, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active"}).AddRow(7, "alice", true))
+		mock.ExpectRollback()
+
+		response := servePasswordRequest(newPasswordTestRouter(), "/auth/password/reset", `{"token":"reset-token","new_password":"Alice-Password-1"}`)
+		require.Equal(t, http.StatusBadRequest, response.Code, response.Body.String())
+		require.JSONEq(t, `{"code":400,"message":"Password must not contain the username."}`, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
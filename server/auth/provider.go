@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Provider is one enabled login method. SetOptions registers a provider for
+// every entry of auth.methods, so adding a method only takes a new Provider
+// and a case in newProvider.
+type Provider interface {
+	// Name identifies the provider in /auth/method, in the method field of
+	// /auth/token and in the session.
+	Name() string
+	// Type names the implementation. Providers of the same type, such as
+	// two LDAP directories, share it.
+	Type() string
+}
+
+// credentialProvider is implemented by providers that verify the
+// credentials posted to /auth/token and return the user they identify.
+type credentialProvider interface {
+	Provider
+	login(c *gin.Context, creds credentials) (userData, error)
+}
+
+// redirectProvider is implemented by providers that log the browser in
+// through routes of their own: the login route redirects to the identity
+// provider and its callback completes the session.
+type redirectProvider interface {
+	Provider
+	loginURL() string
+}
+
+// registeredProviders holds the enabled providers in the order of
+// auth.methods.
+var registeredProviders []Provider
+
+// newProvider builds the provider enabled by name in auth.methods: a built-in
+// method, or one of the named providers of auth.providers.
+func newProvider(name string, ldap config.LDAPOptions, named []config.AuthProviderOptions) (Provider, error) {
+	switch name {
+	case config.AuthProviderLocal:
+		return localProvider{}, nil
+	case config.AuthProviderLDAP:
+		return ldapProvider{name: name, options: ldap}, nil
+	case config.AuthProviderCAS:
+		return casProvider{}, nil
+	case config.AuthProviderSAML:
+		return browserProvider{typ: name, path: "/auth/saml/login"}, nil
+	case config.AuthProviderOIDC:
+		return oidcAuthProvider{}, nil
+	case config.AuthProviderCert:
+		return browserProvider{typ: name, path: "/auth/cert/login"}, nil
+	case config.AuthProviderProxy:
+		return browserProvider{typ: name, path: "/auth/proxy/login"}, nil
+	}
+	for _, options := range named {
+		if options.Name != name {
+			continue
+		}
+		if options.Type == config.AuthProviderLDAP {
+			return ldapProvider{name: options.Name, options: options.LDAP}, nil
+		}
+		return nil, fmt.Errorf("authentication provider %q has unsupported type %q", name, options.Type)
+	}
+	return nil, fmt.Errorf("authentication method %q is not configured", name)
+}
+
+// providerNamed returns the enabled provider called name.
+func providerNamed(name string) (Provider, bool) {
+	for _, provider := range registeredProviders {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// providerType returns the type of the enabled provider called name, or an
+// empty string when there is none.
+func providerType(name string) string {
+	if provider, ok := providerNamed(name); ok {
+		return provider.Type()
+	}
+	return ""
+}
+
+// accountProvider returns the provider an account logging in through method
+// is bound to. Each provider of auth.providers keeps its own accounts, so a
+// user of one directory cannot log in as the same username of another; the
+// built-in methods, whose names are their types, share unbound accounts.
+func accountProvider(method string) string {
+	if provider, ok := providerNamed(method); ok && provider.Name() != provider.Type() {
+		return provider.Name()
+	}
+	return ""
+}
+
+type localProvider struct{}
+
+func (localProvider) Name() string { return config.AuthProviderLocal }
+func (localProvider) Type() string { return config.AuthProviderLocal }
+
+func (localProvider) login(_ *gin.Context, creds credentials) (userData, error) {
+	return authLocal(creds.Username, creds.Password)
+}
+
+// ldapProvider authenticates against one directory. The built-in ldap method
+// and every LDAP entry of auth.providers each get their own.
+type ldapProvider struct {
+	name    string
+	options config.LDAPOptions
+}
+
+func (p ldapProvider) Name() string { return p.name }
+func (ldapProvider) Type() string   { return config.AuthProviderLDAP }
+
+func (p ldapProvider) login(_ *gin.Context, creds credentials) (userData, error) {
+	return p.authenticate(creds.Username, creds.Password)
+}
+
+// casProvider validates the service ticket in the query of /auth/token.
+type casProvider struct{}
+
+func (casProvider) Name() string { return config.AuthProviderCAS }
+func (casProvider) Type() string { return config.AuthProviderCAS }
+
+func (casProvider) login(c *gin.Context, creds credentials) (userData, error) {
+	return authCAS(creds.Username, c)
+}
+
+// oidcAuthProvider logs the browser in through /auth/oidc/login and its
+// callback, and also redeems an authorization code posted to /auth/token.
+type oidcAuthProvider struct{}
+
+func (oidcAuthProvider) Name() string     { return config.AuthProviderOIDC }
+func (oidcAuthProvider) Type() string     { return config.AuthProviderOIDC }
+func (oidcAuthProvider) loginURL() string { return "/auth/oidc/login" }
+
+func (oidcAuthProvider) login(c *gin.Context, _ credentials) (userData, error) {
+	return authOIDC(c)
+}
+
+// browserProvider is a method that only logs in through its own routes, such
+// as SAML, client certificates and reverse proxy headers.
+type browserProvider struct {
+	typ  string
+	path string
+}
+
+func (p browserProvider) Name() string     { return p.typ }
+func (p browserProvider) Type() string     { return p.typ }
+func (p browserProvider) loginURL() string { return p.path }
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// testProviders builds the built-in providers named in methods.
+func testProviders(t *testing.T, methods ...string) []Provider {
+	t.Helper()
+	providers := make([]Provider, 0, len(methods))
+	for _, method := range methods {
+		provider, err := newProvider(method, config.LDAPOptions{}, nil)
+		require.NoError(t, err)
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+func TestSetOptionsRegistersNamedProvidersInConfiguredOrder(t *testing.T) {
+	originalProviders := registeredProviders
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	SetOptions([]byte("secret"), []string{"corp", "local", "saml"}, []config.AuthProviderOptions{
+		{Name: "lab", Type: config.AuthProviderLDAP},
+		{Name: "corp", Type: config.AuthProviderLDAP},
+	}, config.LDAPOptions{}, config.CASOptions{}, config.SAMLOptions{}, config.OIDCOptions{}, true)
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	GetMethod(context)
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.JSONEq(t, `{
+		"auth_methods": ["corp", "local", "saml"],
+		"providers": [
+			{"name": "corp", "type": "ldap"},
+			{"name": "local", "type": "local"},
+			{"name": "saml", "type": "saml", "login_url": "/auth/saml/login"}
+		]
+	}`, recorder.Body.String())
+	require.False(t, passwordLoginThrottled("saml"))
+	require.True(t, passwordLoginThrottled("corp"))
+}
+
+func TestAuthUsesTheDirectoryOfTheNamedProvider(t *testing.T) {
+	connections := map[string]*fakeLDAPConnection{}
+	for _, server := range []string{"ldaps://corp.example.test", "ldaps://lab.example.test"} {
+		connections[server] = &fakeLDAPConnection{
+			searchResult: &ldap.SearchResult{Entries: []*ldap.Entry{
+				ldap.NewEntry("cn=Alice,dc=example,dc=test", map[string][]string{"sAMAccountName": {"alice"}}),
+			}},
+		}
+	}
+	originalDial := ldapDialURL
+	originalProviders := registeredProviders
+	ldapDialURL = func(server string, _ *tls.Config) (ldapConnection, error) { return connections[server], nil }
+	registeredProviders = []Provider{
+		ldapProvider{name: "corp", options: config.LDAPOptions{
+			Server: "ldaps://corp.example.test", BaseDN: "dc=corp,dc=test", Filter: "(sAMAccountName=%s)",
+			LDAPDomainDefault: "corp.test", UsernameAttribute: "sAMAccountName",
+		}},
+		ldapProvider{name: "lab", options: config.LDAPOptions{
+			Server: "ldaps://lab.example.test", BaseDN: "dc=lab,dc=test", Filter: "(sAMAccountName=%s)",
+			LDAPDomainDefault: "lab.test", UsernameAttribute: "sAMAccountName",
+		}},
+	}
+	t.Cleanup(func() {
+		ldapDialURL = originalDial
+		registeredProviders = originalProviders
+	})
+
+	user, err := auth(credentials{Username: "alice", Password: "user-password", Method: "lab"}, nil)
+
+	require.NoError(t, err)
+	require.Equal(t, "lab", user.Method)
+	require.Equal(t, "lab", user.GroupSource)
+	require.Nil(t, connections["ldaps://corp.example.test"].searchRequest)
+	require.Equal(t, "dc=lab,dc=test", connections["ldaps://lab.example.test"].searchRequest.BaseDN)
+	require.Equal(t, "alice@lab.test", connections["ldaps://lab.example.test"].binds[0][0])
+}
+
+func TestAuthRejectsBrowserOnlyProviders(t *testing.T) {
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "saml")
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	_, err := auth(credentials{Method: "saml"}, nil)
+	require.ErrorContains(t, err, "unsupported authentication method")
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var proxyOptions = config.NewConfig().Proxy
+var proxyTrustedNetworks []netip.Prefix
+
+// SetProxyOptions configures header authentication. trustedProxies holds the
+// IP addresses and CIDR ranges of http.trusted_proxies; invalid entries are
+// skipped because ValidateForServe rejects them before the server starts.
+func SetProxyOptions(options config.ProxyOptions, trustedProxies []string) {
+	proxyOptions = options
+	proxyTrustedNetworks = make([]netip.Prefix, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			proxyTrustedNetworks = append(proxyTrustedNetworks, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			proxyTrustedNetworks = append(proxyTrustedNetworks, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+}
+
+// ProxyLogin authenticates with the identity headers set by a trusted
+// reverse proxy.
+//
+//	@Summary		Creates a new jwt token from reverse proxy headers.
+//	@Description	Reads the user set by a trusted authenticating reverse proxy and creates a new jwt token for that user.
+//	@Tags			Auth
+//	@Produce		json
+//	@Success		200	{object}	Token
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/proxy [post]
+func ProxyLogin(c *gin.Context) {
+	token, ok := proxySession(c)
+	if !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.JSON(http.StatusOK, token)
+}
+
+// ProxyBrowserLogin authenticates with the identity headers set by a trusted
+// reverse proxy and redirects the browser to the application.
+//
+//	@Summary		Starts a reverse proxy login.
+//	@Description	Reads the user set by a trusted authenticating reverse proxy, establishes the session and redirects to the application.
+//	@Tags			Auth
+//	@Success		303
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		403	{object}	httputil.HTTPError403
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/proxy/login [get]
+func ProxyBrowserLogin(c *gin.Context) {
+	if _, ok := proxySession(c); !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+func proxySession(c *gin.Context) (Token, bool) {
+	log := logger.GetLogger()
+	if _, enabled := providerNamed("proxy"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "Proxy authentication is not enabled.")
+		return Token{}, false
+	}
+	userData, err := authProxy(c.Request, c.RemoteIP())
+	if err != nil {
+		log.Error("rejected proxy login", zap.String("remote_ip", c.RemoteIP()), zap.Error(err))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, userData.Username, "proxy", err.Error())
+		return Token{}, false
+	}
+	return establishSession(c, userData)
+}
+
+// authProxy reads the identity headers of request, which must come directly
+// from one of the trusted proxies. Forwarded-for headers are ignored: the
+// proxy itself is the peer that vouches for the user.
+func authProxy(request *http.Request, remoteIP string) (userData, error) {
+	var userData userData
+	addr, err := netip.ParseAddr(remoteIP)
+	if err != nil {
+		return userData, errors.New("request peer address is not an IP address")
+	}
+	if !proxyTrusted(addr.Unmap()) {
+		return userData, errors.New("request did not come from a trusted proxy")
+	}
+
+	userData.Username = strings.TrimSpace(request.Header.Get(proxyOptions.UserHeader))
+	if userData.Username == "" {
+		return userData, errors.New("trusted proxy did not set the user header")
+	}
+	userData.FirstName = proxyHeader(request, proxyOptions.FirstNameHeader)
+	userData.LastName = proxyHeader(request, proxyOptions.LastNameHeader)
+	userData.Email = proxyHeader(request, proxyOptions.EmailHeader)
+	if proxyOptions.GroupsHeader != "" {
+		userData.GroupSource = "proxy"
+		userData.Groups = []string{}
+		for _, value := range request.Header.Values(proxyOptions.GroupsHeader) {
+			for _, group := range strings.Split(value, proxyOptions.GroupsSeparator) {
+				if group = strings.TrimSpace(group); group != "" {
+					userData.Groups = append(userData.Groups, group)
+				}
+			}
+		}
+	}
+	userData.Method = "proxy"
+	return userData, nil
+}
+
+func proxyTrusted(addr netip.Addr) bool {
+	for _, network := range proxyTrustedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func proxyHeader(request *http.Request, name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSpace(request.Header.Get(name))
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func useProxyTestOptions(t *testing.T, trustedProxies ...string) {
+	t.Helper()
+
+	originalProviders := registeredProviders
+	originalOptions := proxyOptions
+	originalNetworks := proxyTrustedNetworks
+	registeredProviders = testProviders(t, "proxy")
+	SetProxyOptions(config.NewConfig().Proxy, trustedProxies)
+	t.Cleanup(func() {
+		registeredProviders = originalProviders
+		proxyOptions = originalOptions
+		proxyTrustedNetworks = originalNetworks
+	})
+}
+
+func newProxyRequest(remoteAddr string, headers map[string][]string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/auth/proxy/login", nil)
+	request.RemoteAddr = remoteAddr
+	for name, values := range headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	return request
+}
+
+func TestAuthProxyReadsHeadersFromTrustedProxies(t *testing.T) {
+	useProxyTestOptions(t, "10.0.0.0/8", "192.0.2.10")
+
+	for _, remoteIP := range []string{"10.1.2.3", "192.0.2.10", "::ffff:10.1.2.3"} {
+		userData, err := authProxy(newProxyRequest("", map[string][]string{
+			"X-Remote-User":   {" alice "},
+			"X-Remote-Email":  {"alice@example.test"},
+			"X-Remote-Groups": {"salt-admins, operators", "auditors"},
+		}), remoteIP)
+		require.NoError(t, err, remoteIP)
+		require.Equal(t, "alice", userData.Username)
+		require.Equal(t, "alice@example.test", userData.Email)
+		require.Equal(t, "proxy", userData.GroupSource)
+		require.Equal(t, []string{"salt-admins", "operators", "auditors"}, userData.Groups)
+		require.Equal(t, "proxy", userData.Method)
+	}
+}
+
+func TestAuthProxyRejectsUntrustedPeersAndMissingUser(t *testing.T) {
+	useProxyTestOptions(t, "10.0.0.0/8")
+
+	_, err := authProxy(newProxyRequest("", map[string][]string{
+		"X-Remote-User":   {"alice"},
+		"X-Forwarded-For": {"10.1.2.3"},
+	}), "203.0.113.5")
+	require.ErrorContains(t, err, "trusted proxy")
+
+	_, err = authProxy(newProxyRequest("", nil), "10.1.2.3")
+	require.ErrorContains(t, err, "user header")
+}
+
+func TestProxyBrowserLoginEstablishesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	useProxyTestOptions(t, "10.0.0.1")
+	mock := newTestAuthDB(t)
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs("alice", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "auth_user"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "user_group" WHERE user_id = \$1 AND source = \$2`).
+		WithArgs(7, "proxy").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO "user_group"`).
+		WithArgs(7, "proxy", "operators", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	expectSessionStarted(mock, 7)
+
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, newProxyRequest("10.0.0.1:51234", map[string][]string{
+		"X-Remote-User":   {"alice"},
+		"X-Remote-Groups": {"operators"},
+	}))
+
+	require.Equal(t, http.StatusSeeOther, response.Code, response.Body.String())
+	require.Equal(t, "/", response.Header().Get("Location"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestProxyLoginRejectsDirectRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	useProxyTestOptions(t, "10.0.0.1")
+
+	response := httptest.NewRecorder()
+	request := newProxyRequest("203.0.113.5:51234", map[string][]string{"X-Remote-User": {"admin"}})
+	request.Method = http.MethodPost
+	request.URL.Path = "/auth/proxy"
+	newSAMLTestRouter().ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
@@ -0,0 +1,331 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var tokenOptions = config.NewConfig().Token
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func SetTokenOptions(options config.TokenOptions) {
+	tokenOptions = options
+}
+
+// newTokenFamily names the token family for a fresh login. Logins are rare
+// enough to trim the user's expired refresh tokens and the revocation list
+// here.
+func newTokenFamily(database *gorm.DB, userID uint) (string, error) {
+	now := time.Now()
+	if err := database.Where("user_id = ? AND expires_at < ?", userID, now).Delete(&model.RefreshToken{}).Error; err != nil {
+		return "", err
+	}
+	if err := database.Where("expires_at < ?", now).Delete(&model.RevokedToken{}).Error; err != nil {
+		return "", err
+	}
+	return randomToken(24)
+}
+
+// issueTokens signs an access token for user and stores the refresh token
+// that replaces it, both in the given token family.
+func issueTokens(database *gorm.DB, user model.AuthUser, familyID string) (Token, error) {
+	now := time.Now()
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return Token{}, err
+	}
+	claims := jwt.MapClaims{
+		"username": user.Username,
+		"user_id":  user.ID,
+		"iat":      now.Unix(),
+		"exp":      now.Add(tokenOptions.AccessTokenTTL).Unix(),
+		"jti":      tokenID,
+		"sid":      familyID,
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		return Token{}, err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return Token{}, err
+	}
+	err = database.Create(&model.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: model.HashRefreshToken(refreshToken),
+		ExpiresAt: now.Add(tokenOptions.RefreshTokenTTL),
+		CreatedAt: now,
+	}).Error
+	if err != nil {
+		return Token{}, err
+	}
+
+	return Token{
+		Token:        "Bearer " + accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(tokenOptions.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeTokenFamily ends a refresh token chain and every access token issued
+// from it.
+func revokeTokenFamily(database *gorm.DB, userID uint, familyID string) error {
+	now := time.Now()
+	err := database.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.RevokedToken{
+		ID:        familyID,
+		UserID:    userID,
+		ExpiresAt: now.Add(tokenOptions.RefreshTokenTTL),
+		RevokedAt: now,
+	}).Error
+	if err != nil {
+		return err
+	}
+	return database.Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// RefreshAccessToken exchanges a refresh token for a new token pair.
+//
+//	@Summary		Rotates a refresh token.
+//	@Description	Exchanges a refresh token for a new access token and a new refresh token. Each refresh token is single use; presenting one twice revokes every token descended from the same login.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		refreshRequest	true	"Refresh token"
+//	@Success		200		{object}	Token
+//	@Failure		400		{object}	httputil.HTTPError400
+//	@Failure		401		{object}	httputil.HTTPError401
+//	@Failure		500		{object}	httputil.HTTPError500
+//	@router			/auth/token/refresh [post]
+func RefreshAccessToken(c *gin.Context) {
+	log := logger.GetLogger()
+	var req refreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Missing refresh token.")
+		return
+	}
+
+	var current model.RefreshToken
+	var tokens Token
+	reused := false
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Joins("User").Where("refresh_token.token_hash = ?", model.HashRefreshToken(req.RefreshToken)).First(&current).Error
+		if err != nil {
+			return err
+		}
+		if current.RevokedAt != nil || !current.ExpiresAt.After(now) || !current.User.IsActive {
+			return gorm.ErrRecordNotFound
+		}
+		if current.UsedAt == nil {
+			// Claim the token conditionally so that two concurrent exchanges
+			// are treated as reuse rather than both succeeding.
+			claimed := tx.Model(&model.RefreshToken{}).Where("id = ? AND used_at IS NULL", current.ID).Update("used_at", now)
+			if claimed.Error != nil {
+				return claimed.Error
+			}
+			reused = claimed.RowsAffected != 1
+		} else {
+			reused = true
+		}
+		if reused {
+			// Commit the revocation; the caller still receives a 401.
+			return revokeTokenFamily(tx, current.UserID, current.FamilyID)
+		}
+
+		if tokens, err = issueTokens(tx, current.User, current.FamilyID); err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE user_settings SET token = crypt(?, gen_salt('bf', 8)) WHERE user_id = ?`, tokens.Token, current.UserID).Error
+	})
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid or expired refresh token.")
+		return
+	case err != nil:
+		log.Error("failed to refresh token", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to refresh token.")
+		return
+	case reused:
+		log.Warn("refresh token reused; revoked token family", zap.Uint("user_id", current.UserID), zap.String("sid", current.FamilyID))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid or expired refresh token.")
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout expires the browser session and revokes the tokens it was issued
+// with, together with the family of any refresh token in the request body.
+// Sessions started through CAS are also sent to the CAS logout endpoint.
+//
+//	@Summary		Logs out the current session.
+//	@Description	Expires the session cookie and revokes the presented access token's refresh token family. Pass refresh_token to revoke a bearer client's token family as well. When the session was started through CAS, the response carries the CAS logout URL the browser should visit to end the single sign-on session.
+//	@Tags			Auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		logoutRequest	false	"Refresh token to revoke"
+//	@Success		200		{object}	LogoutRedirect
+//	@Success		204
+//	@Failure		400	{object}	httputil.HTTPError400
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/logout [post]
+func Logout(c *gin.Context) {
+	log := logger.GetLogger()
+	var req logoutRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			httputil.NewError(c, http.StatusBadRequest, "Invalid logout request.")
+			return
+		}
+	}
+	if err := revokePresentedTokens(c, req.RefreshToken); err != nil {
+		log.Error("failed to revoke tokens during logout", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to revoke tokens.")
+		return
+	}
+	session := sessions.Default(c)
+	method, _ := session.Get("auth_method").(string)
+	event := audit.Event{Action: audit.ActionLogout, Outcome: audit.OutcomeSuccess, Method: method}
+	if userID, ok := session.Get("user_id").(uint); ok {
+		event.UserID = &userID
+		event.Username, _ = session.Get("username").(string)
+	}
+	if err := clearSession(c); err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to clear authentication session.")
+		return
+	}
+	audit.Record(c, event)
+	if method == "cas" {
+		logoutURL, err := casLogoutURL()
+		if err != nil {
+			log.Error("failed to build CAS logout URL", zap.Error(err))
+		} else {
+			c.JSON(http.StatusOK, LogoutRedirect{LogoutURL: logoutURL})
+			return
+		}
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll ends every session and revokes every token family of the
+// current user. Personal API tokens are managed separately and stay valid.
+//
+//	@Summary		Logs out everywhere.
+//	@Description	Ends every browser session of the current user and revokes every access and refresh token issued to them. Personal API tokens are not affected.
+//	@Tags			Auth
+//	@Success		204
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/logout/all [post]
+//	@Security		Bearer
+func LogoutAll(c *gin.Context) {
+	log := logger.GetLogger()
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return user.InvalidateCredentials(tx)
+	})
+	if err != nil {
+		log.Error("failed to end every session", zap.Uint("user_id", user.ID), zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to end sessions.")
+		return
+	}
+	log.Info("ended every session", zap.Uint("user_id", user.ID))
+	audit.Record(c, audit.Event{
+		Action:   audit.ActionLogout,
+		Outcome:  audit.OutcomeSuccess,
+		UserID:   &user.ID,
+		Username: user.Username,
+		Detail:   "ended every session",
+	})
+	if err := clearSession(c); err != nil {
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to clear authentication session.")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// revokePresentedTokens revokes the token family named by the access token in
+// the Authorization header or the session, and by refreshToken when given.
+func revokePresentedTokens(c *gin.Context, refreshToken string) error {
+	families := map[string]uint{}
+
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		if _, exists := c.Get(sessions.DefaultKey); exists {
+			authHeader, _ = sessions.Default(c).Get("auth_token").(string)
+		}
+	}
+	if accessToken, found := strings.CutPrefix(authHeader, "Bearer "); found && !strings.HasPrefix(accessToken, model.APITokenPrefix) {
+		// An expired access token still identifies the family to revoke.
+		token, err := jwt.Parse(
+			accessToken,
+			func(token *jwt.Token) (any, error) { return jwtSecret, nil },
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithoutClaimsValidation(),
+		)
+		if err == nil {
+			if claims, ok := token.Claims.(jwt.MapClaims); ok {
+				familyID, _ := claims["sid"].(string)
+				userID, _ := claims["user_id"].(float64)
+				if familyID != "" && userID >= 1 {
+					families[familyID] = uint(userID)
+				}
+			}
+		}
+	}
+
+	if refreshToken != "" {
+		var stored model.RefreshToken
+		err := db.DB.Where("token_hash = ?", model.HashRefreshToken(refreshToken)).First(&stored).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			families[stored.FamilyID] = stored.UserID
+		}
+	}
+
+	if len(families) == 0 {
+		return nil
+	}
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		for familyID, userID := range families {
+			if err := revokeTokenFamily(tx, userID, familyID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
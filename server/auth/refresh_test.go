@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const refreshTokenQuery = `SELECT .* FROM "refresh_token" LEFT JOIN "auth_user" "User" ON "refresh_token"."user_id" = "User"."id" WHERE refresh_token.token_hash = \$1`
+
+func newRefreshTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	_, _ = logger.InitLogger(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte("01234567890123456789012345678901"))))
+	AddRoutes(router.Group("/auth"))
+	AddSessionRoutes(router.Group("/auth", func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		c.Set("username", "alice")
+	}, middleware.ActiveUserRequired(db.DB)))
+	return router
+}
+
+func refreshTokenRows(usedAt any) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "family_id", "expires_at", "used_at", "revoked_at", "User__id", "User__username", "User__is_active"}).
+		AddRow(11, 7, "family-1", time.Now().Add(time.Hour), usedAt, nil, 7, "alice", true)
+}
+
+func TestRefreshAccessTokenRotatesWithinFamily(t *testing.T) {
+	mock := newTestAuthDB(t)
+	router := newRefreshTestRouter()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(refreshTokenQuery).
+		WithArgs(model.HashRefreshToken("old-refresh"), 1).
+		WillReturnRows(refreshTokenRows(nil))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_token" SET "used_at"=$1 WHERE id = $2 AND used_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), 11).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "refresh_token"`).
+		WithArgs(7, "family-1", sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = crypt($1, gen_salt('bf', 8)) WHERE user_id = $2`)).
+		WithArgs(sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	response := servePasswordRequest(router, "/auth/token/refresh", `{"refresh_token":"old-refresh"}`)
+
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+	var token Token
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &token))
+	require.NotEmpty(t, token.RefreshToken)
+	require.NotEqual(t, "old-refresh", token.RefreshToken)
+	require.Equal(t, int64(tokenOptions.AccessTokenTTL.Seconds()), token.ExpiresIn)
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token.Token[len("Bearer "):], claims, func(*jwt.Token) (any, error) { return jwtSecret, nil })
+	require.NoError(t, err)
+	require.Equal(t, "family-1", claims["sid"])
+	require.NotEmpty(t, claims["jti"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshAccessTokenReuseRevokesFamily(t *testing.T) {
+	mock := newTestAuthDB(t)
+	router := newRefreshTestRouter()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(refreshTokenQuery).
+		WithArgs(model.HashRefreshToken("stolen-refresh"), 1).
+		WillReturnRows(refreshTokenRows(time.Now().Add(-time.Minute)))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "revoked_token" ("id","user_id","expires_at","revoked_at") VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING`)).
+		WithArgs("family-1", 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked_at"}).AddRow(time.Now()))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "refresh_token" SET "revoked_at"=$1 WHERE family_id = $2 AND revoked_at IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), "family-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	response := servePasswordRequest(router, "/auth/token/refresh", `{"refresh_token":"stolen-refresh"}`)
+
+	require.Equal(t, http.StatusUnauthorized, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRefreshAccessTokenRejectsUnknownToken(t *testing.T) {
+	mock := newTestAuthDB(t)
+	router := newRefreshTestRouter()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(refreshTokenQuery).
+		WithArgs(model.HashRefreshToken("unknown"), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	response := servePasswordRequest(router, "/auth/token/refresh", `{"refresh_token":"unknown"}`)
+
+	require.Equal(t, http.StatusUnauthorized, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutRevokesPresentedTokenFamily(t *testing.T) {
+	mock := newTestAuthDB(t)
+	router := newRefreshTestRouter()
+
+	// Logging out with an access token that already expired must still
+	// revoke the family it belongs to.
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": "alice",
+		"user_id":  7,
+		"exp":      time.Now().Add(-time.Minute).Unix(),
+		"jti":      "access-1",
+		"sid":      "family-1",
+	}).SignedString(jwtSecret)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "revoked_token"`).
+		WithArgs("family-1", 7, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked_at"}).AddRow(time.Now()))
+	mock.ExpectExec(`UPDATE "refresh_token" SET "revoked_at"=\$1 WHERE family_id = \$2`).
+		WithArgs(sqlmock.AnyArg(), "family-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	request := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	request.Header.Set("Authorization", "Bearer "+accessToken)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLogoutAllInvalidatesEveryCredential(t *testing.T) {
+	mock := newTestAuthDB(t)
+	router := newRefreshTestRouter()
+
+	expectActivePasswordUser(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM session_user_map WHERE user_id = $1)`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(`INSERT INTO revoked_token .* FROM refresh_token`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM session_user_map WHERE user_id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = '' WHERE user_id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	response := servePasswordRequest(router, "/auth/logout/all", ``)
+
+	require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/audit"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/httputil"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/gin-gonic/gin"
+	dsig "github.com/russellhaering/goxmldsig"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	samlRequestCookieName = "agarthaSAMLRequest"
+	samlRequestCookiePath = "/auth/saml"
+	samlRequestMaxAge     = 5 * time.Minute
+	samlMetadataPath      = "/auth/saml/metadata"
+	samlACSPath           = "/auth/saml/acs"
+)
+
+var samlHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+var (
+	samlProviderMu sync.Mutex
+	samlProvider   *saml.ServiceProvider
+)
+
+// GetSAMLMetadata publishes the service provider metadata for IdP registration.
+//
+//	@Summary		Gets the SAML service provider metadata.
+//	@Description	Gets the SAML service provider metadata used to register Agartha with the identity provider.
+//	@Tags			Auth
+//	@Produce		xml
+//	@Success		200
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/saml/metadata [get]
+func GetSAMLMetadata(c *gin.Context) {
+	sp, ok := samlServiceProvider(c)
+	if !ok {
+		return
+	}
+	c.XML(http.StatusOK, sp.Metadata())
+}
+
+// SAMLLogin redirects the browser to the identity provider.
+//
+//	@Summary		Starts a SAML login.
+//	@Description	Redirects the browser to the identity provider with a SAML authentication request.
+//	@Tags			Auth
+//	@Success		302
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/saml/login [get]
+func SAMLLogin(c *gin.Context) {
+	log := logger.GetLogger()
+	sp, ok := samlServiceProvider(c)
+	if !ok {
+		return
+	}
+
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		log.Error("failed to create SAML authentication request", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start SAML login.")
+		return
+	}
+	redirectURL, err := request.Redirect("", sp)
+	if err != nil {
+		log.Error("failed to encode SAML authentication request", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to start SAML login.")
+		return
+	}
+
+	// The ACS POST arrives cross-site from the IdP, where the Lax session
+	// cookie is withheld, so the pending request ID travels in its own cookie.
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     samlRequestCookieName,
+		Value:    request.ID,
+		Path:     samlRequestCookiePath,
+		MaxAge:   int(samlRequestMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   sessionCookieSecure,
+		SameSite: samlRequestSameSite(),
+	})
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+// SAMLAssertionConsumer validates the IdP response and establishes the session.
+//
+//	@Summary		Consumes a SAML assertion.
+//	@Description	Validates the signed SAML response posted by the identity provider, provisions the user and redirects to the application.
+//	@Tags			Auth
+//	@Accept			x-www-form-urlencoded
+//	@Param			SAMLResponse	formData	string	true	"Base64 encoded SAML response"
+//	@Success		303
+//	@Failure		401	{object}	httputil.HTTPError401
+//	@Failure		404	{object}	httputil.HTTPError404
+//	@Failure		500	{object}	httputil.HTTPError500
+//	@router			/auth/saml/acs [post]
+func SAMLAssertionConsumer(c *gin.Context) {
+	log := logger.GetLogger()
+	sp, ok := samlServiceProvider(c)
+	if !ok {
+		return
+	}
+
+	var possibleRequestIDs []string
+	if requestCookie, err := c.Request.Cookie(samlRequestCookieName); err == nil && requestCookie.Value != "" {
+		possibleRequestIDs = append(possibleRequestIDs, requestCookie.Value)
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     samlRequestCookieName,
+		Value:    "",
+		Path:     samlRequestCookiePath,
+		Expires:  time.Unix(1, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   sessionCookieSecure,
+		SameSite: samlRequestSameSite(),
+	})
+
+	if err := c.Request.ParseForm(); err != nil {
+		httputil.NewError(c, http.StatusBadRequest, "Invalid SAML response.")
+		return
+	}
+	assertion, err := sp.ParseResponse(c.Request, possibleRequestIDs)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = invalid.PrivateErr
+		}
+		log.Error("rejected SAML response", zap.Error(err))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, "", "saml", err.Error())
+		return
+	}
+
+	fresh, err := consumeSAMLAssertion(db.DB, assertion, saml.TimeNow())
+	if err != nil {
+		log.Error("failed to record SAML assertion", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to verify SAML response.")
+		return
+	}
+	if !fresh {
+		log.Warn("rejected replayed SAML assertion", zap.String("assertion_id", assertion.ID))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, "", "saml", "SAML assertion was already used")
+		return
+	}
+
+	userData, err := samlUserData(assertion, samlOptions)
+	if err != nil {
+		log.Error("SAML assertion did not identify a user", zap.Error(err))
+		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, "", "saml", err.Error())
+		return
+	}
+
+	if _, ok := establishSession(c, userData); !ok {
+		return
+	}
+	expireLegacyAuthCookie(c)
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// consumeSAMLAssertion records the ID of a validated assertion and reports
+// whether it was seen for the first time. The ID is kept for as long as the
+// assertion's issue instant and conditions would let it pass validation.
+func consumeSAMLAssertion(database *gorm.DB, assertion *saml.Assertion, now time.Time) (bool, error) {
+	expiresAt := assertion.IssueInstant.Add(saml.MaxIssueDelay)
+	if assertion.Conditions != nil {
+		if notOnOrAfter := assertion.Conditions.NotOnOrAfter.Add(saml.MaxClockSkew); notOnOrAfter.After(expiresAt) {
+			expiresAt = notOnOrAfter
+		}
+	}
+
+	fresh := false
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", now).Delete(&model.SAMLAssertion{}).Error; err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.SAMLAssertion{ID: assertion.ID, ExpiresAt: expiresAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		fresh = result.RowsAffected == 1
+		return nil
+	})
+	return fresh, err
+}
+
+func resetSAMLServiceProvider() {
+	samlProviderMu.Lock()
+	defer samlProviderMu.Unlock()
+	samlProvider = nil
+}
+
+func samlRequestSameSite() http.SameSite {
+	if sessionCookieSecure {
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+// samlServiceProvider returns the configured service provider, loading the
+// IdP metadata on first use so a slow IdP does not block server startup.
+func samlServiceProvider(c *gin.Context) (*saml.ServiceProvider, bool) {
+	if _, enabled := providerNamed("saml"); !enabled {
+		httputil.NewError(c, http.StatusNotFound, "SAML authentication is not enabled.")
+		return nil, false
+	}
+
+	samlProviderMu.Lock()
+	defer samlProviderMu.Unlock()
+	if samlProvider != nil {
+		return samlProvider, true
+	}
+	sp, err := newSAMLServiceProvider(c.Request.Context(), samlOptions)
+	if err != nil {
+		logger.GetLogger().Error("failed to configure SAML service provider", zap.Error(err))
+		httputil.NewError(c, http.StatusInternalServerError, "SAML authentication is unavailable.")
+		return nil, false
+	}
+	samlProvider = sp
+	return samlProvider, true
+}
+
+func newSAMLServiceProvider(ctx context.Context, options config.SAMLOptions) (*saml.ServiceProvider, error) {
+	rootURL, err := url.Parse(options.ServerURL)
+	if err != nil || rootURL.Scheme == "" || rootURL.Host == "" {
+		return nil, fmt.Errorf("invalid SAML server URL %q", options.ServerURL)
+	}
+	metadataURL := *rootURL.JoinPath(samlMetadataPath)
+	acsURL := *rootURL.JoinPath(samlACSPath)
+
+	var idpMetadata *saml.EntityDescriptor
+	if options.MetadataFile != "" {
+		data, err := os.ReadFile(options.MetadataFile)
+		if err != nil {
+			return nil, fmt.Errorf("read SAML IdP metadata: %w", err)
+		}
+		idpMetadata, err = samlsp.ParseMetadata(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse SAML IdP metadata: %w", err)
+		}
+	} else {
+		source, err := url.Parse(options.MetadataURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAML metadata URL: %w", err)
+		}
+		idpMetadata, err = samlsp.FetchMetadata(ctx, samlHTTPClient, *source)
+		if err != nil {
+			return nil, fmt.Errorf("fetch SAML IdP metadata: %w", err)
+		}
+	}
+
+	sp := &saml.ServiceProvider{
+		EntityID:          options.EntityID,
+		HTTPClient:        samlHTTPClient,
+		MetadataURL:       metadataURL,
+		AcsURL:            acsURL,
+		IDPMetadata:       idpMetadata,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+
+	if options.SessionCert != "" {
+		keyPair, err := tls.LoadX509KeyPair(options.SessionCert, options.SessionKey)
+		if err != nil {
+			return nil, fmt.Errorf("load SAML service provider key pair: %w", err)
+		}
+		signer, ok := keyPair.PrivateKey.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("SAML service provider key cannot sign")
+		}
+		certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+		if err != nil {
+			return nil, fmt.Errorf("parse SAML service provider certificate: %w", err)
+		}
+		sp.Key = signer
+		sp.Certificate = certificate
+		sp.SignatureMethod = dsig.RSASHA256SignatureMethod
+	}
+
+	// A pinned IdP certificate replaces the signing certificates published in
+	// the metadata.
+	if options.ServerCert != "" {
+		encoded, err := os.ReadFile(options.ServerCert)
+		if err != nil {
+			return nil, fmt.Errorf("read SAML IdP certificate: %w", err)
+		}
+		block, _ := pem.Decode(encoded)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, errors.New("SAML IdP certificate must be PEM encoded")
+		}
+		pinned := base64.StdEncoding.EncodeToString(block.Bytes)
+		sp.IDPCertificate = &pinned
+	}
+
+	return sp, nil
+}
+
+func samlUserData(assertion *saml.Assertion, options config.SAMLOptions) (userData, error) {
+	var userData userData
+
+	username := samlAttribute(assertion, options.UsernameAttribute)
+	if options.UsernameAttribute == "" && assertion.Subject != nil && assertion.Subject.NameID != nil {
+		username = assertion.Subject.NameID.Value
+	}
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return userData, errors.New("SAML assertion did not include a username")
+	}
+
+	userData.Username = username
+	userData.SamAccountName = username
+	userData.UserPrincipalName = username
+	userData.FirstName = samlAttribute(assertion, options.FirstNameAttribute)
+	userData.LastName = samlAttribute(assertion, options.LastNameAttribute)
+	userData.Email = samlAttribute(assertion, options.EmailAttribute)
+	if options.GroupsAttribute != "" {
+		userData.Groups = samlAttributeValues(assertion, options.GroupsAttribute)
+		userData.GroupSource = "saml"
+	}
+	userData.Method = "saml"
+	return userData, nil
+}
+
+// samlAttribute returns the first value of the attribute whose Name or
+// FriendlyName matches the configured attribute.
+func samlAttribute(assertion *saml.Assertion, name string) string {
+	if values := samlAttributeValues(assertion, name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// samlAttributeValues returns the non-empty values of every attribute whose
+// Name or FriendlyName matches the configured attribute.
+func samlAttributeValues(assertion *saml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var values []string
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if attribute.Name != name && attribute.FriendlyName != name {
+				continue
+			}
+			for _, value := range attribute.Values {
+				if value := strings.TrimSpace(value.Value); value != "" {
+					values = append(values, value)
+				}
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,382 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/crewjam/saml"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type testSAMLIdentityProvider struct {
+	idp          *saml.IdentityProvider
+	metadataFile string
+}
+
+func newTestSAMLIdentityProvider(t *testing.T) *testSAMLIdentityProvider {
+	t.Helper()
+
+	key, certificate := newTestSAMLKeyPair(t, "idp.example.test")
+	metadataURL, err := url.Parse("https://idp.example.test/saml/metadata")
+	require.NoError(t, err)
+	ssoURL, err := url.Parse("https://idp.example.test/saml/sso")
+	require.NoError(t, err)
+	idp := &saml.IdentityProvider{
+		Key:         key,
+		Certificate: certificate,
+		MetadataURL: *metadataURL,
+		SSOURL:      *ssoURL,
+	}
+
+	metadata, err := xml.Marshal(idp.Metadata())
+	require.NoError(t, err)
+	metadataFile := filepath.Join(t.TempDir(), "idp-metadata.xml")
+	require.NoError(t, os.WriteFile(metadataFile, metadata, 0o600))
+	return &testSAMLIdentityProvider{idp: idp, metadataFile: metadataFile}
+}
+
+func newTestSAMLKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, certificate
+}
+
+// respond builds the signed SAMLResponse form value the IdP would post back
+// for the service provider's authentication request.
+func (provider *testSAMLIdentityProvider) respond(
+	t *testing.T,
+	sp *saml.ServiceProvider,
+	request *saml.AuthnRequest,
+	session *saml.Session,
+) string {
+	t.Helper()
+
+	spMetadata := sp.Metadata()
+	spDescriptor := &spMetadata.SPSSODescriptors[0]
+	var acsEndpoint *saml.IndexedEndpoint
+	for index := range spDescriptor.AssertionConsumerServices {
+		if spDescriptor.AssertionConsumerServices[index].Binding == saml.HTTPPostBinding {
+			acsEndpoint = &spDescriptor.AssertionConsumerServices[index]
+		}
+	}
+	require.NotNil(t, acsEndpoint)
+
+	idpRequest := &saml.IdpAuthnRequest{
+		IDP:                     provider.idp,
+		HTTPRequest:             httptest.NewRequest(http.MethodGet, provider.idp.SSOURL.String(), nil),
+		Request:                 *request,
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         spDescriptor,
+		ACSEndpoint:             acsEndpoint,
+		Now:                     saml.TimeNow(),
+	}
+	require.NoError(t, saml.DefaultAssertionMaker{}.MakeAssertion(idpRequest, session))
+	form, err := idpRequest.PostBinding()
+	require.NoError(t, err)
+	return form.SAMLResponse
+}
+
+func useTestSAMLOptions(t *testing.T, options config.SAMLOptions) {
+	t.Helper()
+
+	originalProviders := registeredProviders
+	originalOptions := samlOptions
+	registeredProviders = testProviders(t, "saml")
+	samlOptions = options
+	resetSAMLServiceProvider()
+	t.Cleanup(func() {
+		registeredProviders = originalProviders
+		samlOptions = originalOptions
+		resetSAMLServiceProvider()
+	})
+}
+
+func newSAMLTestRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte("01234567890123456789012345678901"))))
+	AddRoutes(router.Group("/auth"))
+	return router
+}
+
+func postSAMLResponse(router *gin.Engine, samlResponse string, requestID string) *httptest.ResponseRecorder {
+	form := url.Values{"SAMLResponse": {samlResponse}}
+	request := httptest.NewRequest(http.MethodPost, "https://agartha.example.test/auth/saml/acs", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if requestID != "" {
+		request.AddCookie(&http.Cookie{Name: samlRequestCookieName, Value: requestID})
+	}
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestSAMLRoutesRequireEnabledMethod(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "local")
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/auth/saml/metadata", nil))
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestSAMLMetadataPublishesAssertionConsumerService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	idp := newTestSAMLIdentityProvider(t)
+	useTestSAMLOptions(t, config.SAMLOptions{
+		MetadataFile: idp.metadataFile,
+		ServerURL:    "https://agartha.example.test",
+	})
+
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/auth/saml/metadata", nil))
+
+	require.Equal(t, http.StatusOK, response.Code)
+	var metadata saml.EntityDescriptor
+	require.NoError(t, xml.Unmarshal(response.Body.Bytes(), &metadata))
+	require.Equal(t, "https://agartha.example.test/auth/saml/metadata", metadata.EntityID)
+	require.Equal(t, "https://agartha.example.test/auth/saml/acs", metadata.SPSSODescriptors[0].AssertionConsumerServices[0].Location)
+}
+
+func TestSAMLLoginRedirectsToIdentityProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	idp := newTestSAMLIdentityProvider(t)
+	useTestSAMLOptions(t, config.SAMLOptions{
+		MetadataFile: idp.metadataFile,
+		ServerURL:    "https://agartha.example.test",
+	})
+
+	response := httptest.NewRecorder()
+	newSAMLTestRouter().ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/auth/saml/login", nil))
+
+	require.Equal(t, http.StatusFound, response.Code)
+	location, err := url.Parse(response.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "idp.example.test", location.Host)
+	require.NotEmpty(t, location.Query().Get("SAMLRequest"))
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, samlRequestCookieName, cookies[0].Name)
+	require.NotEmpty(t, cookies[0].Value)
+	require.True(t, cookies[0].HttpOnly)
+}
+
+func TestSAMLAssertionConsumerEstablishesSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	idp := newTestSAMLIdentityProvider(t)
+	useTestSAMLOptions(t, config.SAMLOptions{
+		MetadataFile:       idp.metadataFile,
+		ServerURL:          "https://agartha.example.test",
+		UsernameAttribute:  "uid",
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "sn",
+		EmailAttribute:     "mail",
+	})
+	mock := newTestAuthDB(t)
+	expectSAMLAssertionConsumed(mock, true)
+	expectProvisionedUser(mock, 7, userData{Username: "alice", FirstName: "Alice", LastName: "Admin", Email: "alice@example.test"})
+
+	router := newSAMLTestRouter()
+	sp, err := newSAMLServiceProvider(t.Context(), samlOptions)
+	require.NoError(t, err)
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	require.NoError(t, err)
+	samlResponse := idp.respond(t, sp, request, &saml.Session{
+		NameID:        "alice-persistent-id",
+		UserName:      "alice",
+		UserEmail:     "alice@example.test",
+		UserGivenName: "Alice",
+		UserSurname:   "Admin",
+	})
+
+	response := postSAMLResponse(router, samlResponse, request.ID)
+
+	require.Equal(t, http.StatusSeeOther, response.Code, response.Body.String())
+	require.Equal(t, "/", response.Header().Get("Location"))
+	var sessionCookie *http.Cookie
+	for _, responseCookie := range response.Result().Cookies() {
+		if responseCookie.Name == "agarthaAuthSession" {
+			sessionCookie = responseCookie
+		}
+	}
+	require.NotNil(t, sessionCookie)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSAMLAssertionConsumerRejectsReplayedAssertions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	idp := newTestSAMLIdentityProvider(t)
+	useTestSAMLOptions(t, config.SAMLOptions{
+		MetadataFile: idp.metadataFile,
+		ServerURL:    "https://agartha.example.test",
+	})
+	mock := newTestAuthDB(t)
+	// The assertion ID is already recorded, so no user is provisioned.
+	expectSAMLAssertionConsumed(mock, false)
+
+	sp, err := newSAMLServiceProvider(t.Context(), samlOptions)
+	require.NoError(t, err)
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	require.NoError(t, err)
+	samlResponse := idp.respond(t, sp, request, &saml.Session{NameID: "alice"})
+
+	response := postSAMLResponse(newSAMLTestRouter(), samlResponse, request.ID)
+
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConsumeSAMLAssertionKeepsIDsUntilTheAssertionExpires(t *testing.T) {
+	mock := newTestAuthDB(t)
+	issued := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	notOnOrAfter := issued.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "saml_assertion" WHERE expires_at < \$1`).
+		WithArgs(issued).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO "saml_assertion" \("id","expires_at"\) VALUES \(\$1,\$2\) ON CONFLICT DO NOTHING`).
+		WithArgs("id-1", notOnOrAfter.Add(saml.MaxClockSkew)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	fresh, err := consumeSAMLAssertion(db.DB, &saml.Assertion{
+		ID:           "id-1",
+		IssueInstant: issued,
+		Conditions:   &saml.Conditions{NotOnOrAfter: notOnOrAfter},
+	}, issued)
+	require.NoError(t, err)
+	require.True(t, fresh)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSAMLAssertionConsumerRejectsUntrustedResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+	idp := newTestSAMLIdentityProvider(t)
+	useTestSAMLOptions(t, config.SAMLOptions{
+		MetadataFile: idp.metadataFile,
+		ServerURL:    "https://agartha.example.test",
+	})
+	sp, err := newSAMLServiceProvider(t.Context(), samlOptions)
+	require.NoError(t, err)
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	require.NoError(t, err)
+
+	impostor := newTestSAMLIdentityProvider(t)
+	impostor.idp.MetadataURL = idp.idp.MetadataURL
+	forged := impostor.respond(t, sp, request, &saml.Session{NameID: "mallory"})
+	genuine := idp.respond(t, sp, request, &saml.Session{NameID: "alice"})
+
+	tests := []struct {
+		name         string
+		samlResponse string
+		requestID    string
+	}{
+		{name: "signed by an unknown key", samlResponse: forged, requestID: request.ID},
+		{name: "unsolicited response", samlResponse: genuine},
+		{name: "malformed response", samlResponse: "not-a-saml-response", requestID: request.ID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := postSAMLResponse(newSAMLTestRouter(), tt.samlResponse, tt.requestID)
+			require.Equal(t, http.StatusUnauthorized, response.Code)
+		})
+	}
+}
+
+func TestSAMLUserDataMapsConfiguredAttributes(t *testing.T) {
+	assertion := &saml.Assertion{
+		Subject: &saml.Subject{NameID: &saml.NameID{Value: "alice@example.test"}},
+		AttributeStatements: []saml.AttributeStatement{{
+			Attributes: []saml.Attribute{
+				{Name: "urn:oid:2.5.4.42", FriendlyName: "givenName", Values: []saml.AttributeValue{{Value: "Alice"}}},
+				{Name: "urn:oid:2.5.4.4", FriendlyName: "sn", Values: []saml.AttributeValue{{Value: "Admin"}}},
+				{Name: "email", Values: []saml.AttributeValue{{Value: ""}, {Value: "alice@example.test"}}},
+				{Name: "uid", Values: []saml.AttributeValue{{Value: " alice "}}},
+				{Name: "memberOf", Values: []saml.AttributeValue{{Value: "ops"}, {Value: ""}}},
+				{Name: "memberOf", Values: []saml.AttributeValue{{Value: "salt-admins"}}},
+			},
+		}},
+	}
+
+	user, err := samlUserData(assertion, config.SAMLOptions{
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "urn:oid:2.5.4.4",
+		EmailAttribute:     "email",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.test", user.Username)
+	require.Equal(t, "Alice", user.FirstName)
+	require.Equal(t, "Admin", user.LastName)
+	require.Equal(t, "alice@example.test", user.Email)
+	require.Empty(t, user.GroupSource)
+
+	user, err = samlUserData(assertion, config.SAMLOptions{UsernameAttribute: "uid", GroupsAttribute: "memberOf"})
+	require.NoError(t, err)
+	require.Equal(t, "alice", user.Username)
+	require.Equal(t, []string{"ops", "salt-admins"}, user.Groups)
+	require.Equal(t, "saml", user.GroupSource)
+
+	_, err = samlUserData(assertion, config.SAMLOptions{UsernameAttribute: "missing"})
+	require.ErrorContains(t, err, "did not include a username")
+}
+
+// expectSAMLAssertionConsumed expects the ACS to record the assertion ID,
+// which succeeds only when the assertion was not used before.
+func expectSAMLAssertionConsumed(mock sqlmock.Sqlmock, fresh bool) {
+	var inserted int64
+	if fresh {
+		inserted = 1
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "saml_assertion" WHERE expires_at < \$1`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO "saml_assertion"`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, inserted))
+	mock.ExpectCommit()
+}
@@ -14,7 +14,7 @@ import (
 
 func TestLogoutExpiresSecureHttpOnlySessionCookie(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	SetOptions([]byte("secret"), []string{"local"}, config.LDAPOptions{}, config.CASOptions{}, true)
+	SetOptions([]byte("secret"), []string{"local"}, nil, config.LDAPOptions{}, config.CASOptions{}, config.SAMLOptions{}, config.OIDCOptions{}, true)
 
 	store := cookie.NewStore([]byte("01234567890123456789012345678901"))
 	store.Options(sessions.Options{
@@ -60,7 +60,7 @@ func TestLogoutExpiresSecureHttpOnlySessionCookie(t *testing.T) {
 
 func TestExpireLegacyAuthCookieUsesLegacyAuthPath(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	SetOptions([]byte("secret"), []string{"local"}, config.LDAPOptions{}, config.CASOptions{}, true)
+	SetOptions([]byte("secret"), []string{"local"}, nil, config.LDAPOptions{}, config.CASOptions{}, config.SAMLOptions{}, config.OIDCOptions{}, true)
 
 	response := httptest.NewRecorder()
 	context, _ := gin.CreateTestContext(response)
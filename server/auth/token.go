@@ -5,8 +5,10 @@ import (
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/PaulChristophel/agartha/server/audit"
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/logger"
 	"gorm.io/gorm"
@@ -17,7 +19,6 @@ import (
 	"github.com/PaulChristophel/agartha/server/middleware"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type credentials struct {
@@ -27,7 +28,9 @@ type credentials struct {
 }
 
 type Token struct {
-	Token string `json:"token" example:"Bearer foo.bar.blah"`
+	Token        string `json:"token" example:"Bearer foo.bar.blah"`
+	RefreshToken string `json:"refresh_token,omitempty" example:"3q2-7wEXAMPLE"`
+	ExpiresIn    int64  `json:"expires_in,omitempty" example:"900"`
 }
 
 type CASServiceResponse struct {
@@ -36,11 +39,56 @@ type CASServiceResponse struct {
 }
 
 type AuthenticationSuccess struct {
-	User string `xml:"user"`
+	User       string        `xml:"user"`
+	Attributes CASAttributes `xml:"attributes"`
+}
+
+// CASAttributes holds the attributes released by a CAS 3.0 server. A
+// multi-valued attribute repeats its element.
+type CASAttributes struct {
+	Values []CASAttribute `xml:",any"`
+}
+
+type CASAttribute struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+// All returns every value of the named attribute.
+func (attributes CASAttributes) All(name string) []string {
+	var values []string
+	if name == "" {
+		return values
+	}
+	for _, attribute := range attributes.Values {
+		if attribute.XMLName.Local == name {
+			if value := strings.TrimSpace(attribute.Value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// First returns the first value of the named attribute.
+func (attributes CASAttributes) First(name string) string {
+	if values := attributes.All(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
 }
 
 type AuthMethods struct {
-	AuthMethods []string `json:"auth_methods" example:"local"`
+	AuthMethods []string       `json:"auth_methods" example:"local"`
+	Providers   []AuthProvider `json:"providers"`
+}
+
+// AuthProvider describes an enabled login method. LoginURL is set for
+// methods that log in through the browser rather than /auth/token.
+type AuthProvider struct {
+	Name     string `json:"name" example:"corp"`
+	Type     string `json:"type" example:"ldap"`
+	LoginURL string `json:"login_url,omitempty" example:"/auth/saml/login"`
 }
 
 const (
@@ -51,7 +99,7 @@ const (
 // Get auth methods
 //
 //	@Summary		Gets the list of available auth methods.
-//	@Description	Gets the list of available auth methods.
+//	@Description	Gets the names of the enabled auth methods, in configured order, and the type and browser login URL of each.
 //	@Tags			Auth
 //	@Accept			json
 //	@Produce		json
@@ -62,16 +110,20 @@ const (
 //	@Failure		500	{object}	httputil.HTTPError500
 //	@router			/auth/method [get]
 func GetMethod(c *gin.Context) {
-	authMethods := make([]string, 0, len(enabledMethods))
-	for _, method := range []string{"local", "ldap", "cas"} {
-		if _, enabled := enabledMethods[method]; enabled {
-			authMethods = append(authMethods, method)
+	methods := AuthMethods{
+		AuthMethods: make([]string, 0, len(registeredProviders)),
+		Providers:   make([]AuthProvider, 0, len(registeredProviders)),
+	}
+	for _, provider := range registeredProviders {
+		described := AuthProvider{Name: provider.Name(), Type: provider.Type()}
+		if redirect, ok := provider.(redirectProvider); ok {
+			described.LoginURL = redirect.loginURL()
 		}
+		methods.AuthMethods = append(methods.AuthMethods, described.Name)
+		methods.Providers = append(methods.Providers, described)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"auth_methods": authMethods,
-	})
+	c.JSON(http.StatusOK, methods)
 }
 
 // Token generate a new jwt token
@@ -86,38 +138,97 @@ func GetMethod(c *gin.Context) {
 //	@Failure		400			{object}	httputil.HTTPError400
 //	@Failure		401			{object}	httputil.HTTPError401
 //	@Failure		403			{object}	httputil.HTTPError403
+//	@Failure		429			{object}	httputil.HTTPError429
 //	@Failure		500			{object}	httputil.HTTPError500
 //	@router			/auth/token [post]
 func RetrieveToken(c *gin.Context) {
 	log := logger.GetLogger()
 	sugar := log.Sugar()
 	var creds credentials
-	db := db.DB // Assuming db.DB is a *gorm.DB instance
 
 	if err := c.ShouldBindJSON(&creds); err != nil {
 		httputil.NewError(c, http.StatusBadRequest, "Missing username or password.")
 		return
 	}
 
+	throttled := passwordLoginThrottled(creds.Method)
+	if throttled && refuseBlockedLogin(c, creds.Username, creds.Method) {
+		return
+	}
+
 	userData, err := auth(creds, c)
 	if err != nil {
 		sugar.Errorf("Error authenticating user %s: %+v", creds.Username, err)
+		if throttled {
+			countLoginFailure(c, creds.Username)
+		}
 		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+		auditLogin(c, audit.OutcomeFailure, creds.Username, creds.Method, err.Error())
+		return
+	}
+	required, enrolled, err := secondFactorRequired(creds.Method, userData.Username)
+	if err != nil {
+		sugar.Errorf("Error checking MFA enrollment for user %s: %+v", userData.Username, err)
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to generate token.")
 		return
 	}
+	if required {
+		issueMFAChallenge(c, creds.Method, userData, !enrolled)
+		return
+	}
+	token, ok := establishSession(c, userData)
+	if !ok {
+		return
+	}
+	if throttled {
+		forgetLoginFailures(creds.Username)
+	}
+
+	// Older releases created this cookie without an explicit Path while handling
+	// /auth/token, so browsers defaulted it to /auth. Remove that legacy cookie
+	// after saving the current Path=/ session; otherwise it shadows the current
+	// cookie on /auth/session after logout.
+	expireLegacyAuthCookie(c)
+	c.JSON(http.StatusOK, token)
+}
+
+// establishSession provisions the authenticated user, issues the token pair
+// and binds it to the cookie session. Every authentication method completes
+// its login through here. On failure the error response has already been
+// written.
+func establishSession(c *gin.Context, userData userData) (Token, bool) {
+	user, ok := provisionUser(c, userData)
+	if !ok {
+		return Token{}, false
+	}
+	return startSession(c, user, userData)
+}
+
+// provisionUser creates the authenticated user on first login, or records the
+// login of an existing active user. On failure the error response has already
+// been written.
+func provisionUser(c *gin.Context, userData userData) (model.AuthUser, bool) {
+	log := logger.GetLogger()
+	sugar := log.Sugar()
+	db := db.DB // Assuming db.DB is a *gorm.DB instance
+
 	authenticatedUsername := userData.Username
 	if authenticatedUsername == "" {
-		sugar.Errorf("Authentication provider returned an empty username for %s", creds.Username)
+		sugar.Errorf("Authentication provider returned an empty username")
 		httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
-		return
+		auditLogin(c, audit.OutcomeFailure, "", userData.Method, "authentication provider returned an empty username")
+		return model.AuthUser{}, false
 	}
 
+	accountProvider := accountProvider(userData.Method)
+
 	// Check if user exists and handle accordingly
 	var user model.AuthUser
 	result := db.Where("username = ?", authenticatedUsername).First(&user)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		// Create new user if not exist
 		user = model.AuthUser{
+			Provider:    accountProvider,
 			Username:    authenticatedUsername,
 			Password:    "", // Store a hashed password or omit if password shouldn't be stored
 			FirstName:   userData.FirstName,
@@ -128,58 +239,101 @@ func RetrieveToken(c *gin.Context) {
 			IsActive:    true,
 			DateJoined:  time.Now(),
 		}
+		if userData.Flags != nil {
+			user.IsSuperuser = userData.Flags.IsSuperuser
+			user.IsStaff = userData.Flags.IsStaff
+		}
+		if !certLoginSatisfied(c, user, userData) {
+			return model.AuthUser{}, false
+		}
 		if err := db.Create(&user).Error; err != nil {
 			sugar.Errorf("Failed to create authenticated user %s: %v", authenticatedUsername, err)
 			httputil.NewError(c, http.StatusInternalServerError, "Failed to create user.")
-			return
+			return model.AuthUser{}, false
 		}
 	} else if result.Error != nil {
 		sugar.Errorf("Database error: %v", result.Error)
 		httputil.NewError(c, http.StatusInternalServerError, "Database error.")
-		return
+		return model.AuthUser{}, false
 	} else {
 		// Optionally update user data or last login time here if necessary
+		if user.Provider != accountProvider {
+			sugar.Warnf("Refusing %s login of user %s bound to provider %q", userData.Method, authenticatedUsername, user.Provider)
+			httputil.NewError(c, http.StatusUnauthorized, "Invalid credentials.")
+			auditLogin(c, audit.OutcomeDenied, authenticatedUsername, userData.Method, "account belongs to another authentication provider")
+			return model.AuthUser{}, false
+		}
 		if !user.IsActive {
 			httputil.NewError(c, http.StatusUnauthorized, "User account is inactive.")
-			return
+			auditLogin(c, audit.OutcomeDenied, authenticatedUsername, userData.Method, "user account is inactive")
+			return model.AuthUser{}, false
 		}
 		currentTime := time.Now()
 		user.LastLogin = &currentTime
+		if flags := userData.Flags; flags != nil && (user.IsSuperuser != flags.IsSuperuser || user.IsStaff != flags.IsStaff) {
+			sugar.Infof("Updating flags of user %s from directory groups: is_superuser=%t is_staff=%t", authenticatedUsername, flags.IsSuperuser, flags.IsStaff)
+			user.IsSuperuser = flags.IsSuperuser
+			user.IsStaff = flags.IsStaff
+		}
+		if !certLoginSatisfied(c, user, userData) {
+			return model.AuthUser{}, false
+		}
 		if err := db.Save(&user).Error; err != nil {
 			sugar.Errorf("Failed to update authenticated user %s: %v", authenticatedUsername, err)
 			httputil.NewError(c, http.StatusInternalServerError, "Failed to update user.")
-			return
+			return model.AuthUser{}, false
 		}
 	}
 
-	// Create JWT token
-	maxAge := (time.Hour * 8)
-	utime := time.Now().Add(maxAge).Unix()
-	claims := jwt.MapClaims{
-		"username": authenticatedUsername,
-		"user_id":  user.ID,
-		"exp":      utime,
-		// "expires_at": utime,
+	if userData.GroupSource != "" {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return model.ReplaceUserGroups(tx, user.ID, userData.GroupSource, userData.Groups)
+		})
+		if err != nil {
+			sugar.Errorf("Failed to store groups of user %s: %v", authenticatedUsername, err)
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to update user.")
+			return model.AuthUser{}, false
+		}
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+
+	return user, true
+}
+
+// startSession issues a new token family for user and binds it to the cookie
+// session. The session lasts as long as the first refresh token. userData
+// names the login method and the identity provider's session, if any.
+func startSession(c *gin.Context, user model.AuthUser, userData userData) (Token, bool) {
+	log := logger.GetLogger()
+	sugar := log.Sugar()
+	db := db.DB
+	authenticatedUsername := user.Username
+
+	familyID, err := newTokenFamily(db, user.ID)
 	if err != nil {
-		sugar.Errorf("Error signing token: %v", err)
+		sugar.Errorf("Error starting token family: %v", err)
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to generate token.")
-		return
+		return Token{}, false
+	}
+	token, err := issueTokens(db, user, familyID)
+	if err != nil {
+		sugar.Errorf("Error issuing tokens: %v", err)
+		httputil.NewError(c, http.StatusInternalServerError, "Failed to generate token.")
+		return Token{}, false
 	}
+	utime := time.Now().Add(tokenOptions.RefreshTokenTTL).Unix()
 
 	// Create session and save session data to session_user_map
 	session = sessions.Default(c)
 	session.Set("username", authenticatedUsername)
 	session.Set("user_id", user.ID)
 	session.Set("exp", strconv.FormatInt(utime, 10))
-	session.Set("auth_token", "Bearer "+tokenString)
+	session.Set("auth_token", token.Token)
+	session.Set("auth_method", userData.Method)
 	// session.Set("expires_at", strconv.FormatInt(utime, 10))
 	if err := session.Save(); err != nil {
 		sugar.Errorf("Failed to save session: %v", err)
 		httputil.NewError(c, http.StatusInternalServerError, "Failed to save session.")
-		return
+		return Token{}, false
 	}
 
 	// Check if a session map already exists for the session ID
@@ -188,24 +342,48 @@ func RetrieveToken(c *gin.Context) {
 	if findResult.Error != nil && !errors.Is(findResult.Error, gorm.ErrRecordNotFound) {
 		sugar.Errorf("Error retrieving session map: %v", findResult.Error)
 		httputil.NewError(c, http.StatusInternalServerError, "Database error during session map retrieval.")
-		return
+		return Token{}, false
 	}
 
+	now := time.Now()
+	clientIP := c.ClientIP()
+	userAgent := truncateUTF8(c.Request.UserAgent(), 512)
 	if findResult.RowsAffected == 0 { // No existing session map, create a new one
 		sessionMap = model.SessionUserMap{
-			SessionID: session.ID(),
-			UserID:    user.ID,
-			CreatedAt: time.Now(),
+			SessionID:  session.ID(),
+			UserID:     user.ID,
+			FamilyID:   familyID,
+			ClientIP:   clientIP,
+			UserAgent:  userAgent,
+			LastSeenAt: &now,
+			CreatedAt:  now,
+
+			ProviderSessionID: userData.ProviderSessionID,
 		}
 		if err := db.Create(&sessionMap).Error; err != nil {
 			sugar.Errorf("Failed to create session map: %v", err)
 			httputil.NewError(c, http.StatusInternalServerError, "Failed to save session map.")
-			return
+			return Token{}, false
 		}
-	} // No need to update if it exists; if you have fields to update, handle them here
+	} else { // A new login on an existing session replaces its owner and token family
+		err := db.Model(&sessionMap).Updates(map[string]any{
+			"user_id":      user.ID,
+			"family_id":    familyID,
+			"client_ip":    clientIP,
+			"user_agent":   userAgent,
+			"last_seen_at": now,
+
+			"provider_session_id": userData.ProviderSessionID,
+		}).Error
+		if err != nil {
+			sugar.Errorf("Failed to update session map: %v", err)
+			httputil.NewError(c, http.StatusInternalServerError, "Failed to save session map.")
+			return Token{}, false
+		}
+	}
 
 	var settings model.UserSettings
-	result = db.Where("user_id = ?", user.ID).First(&settings)
+	result := db.Where("user_id = ?", user.ID).First(&settings)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		// Create new user if not exist
 		settings = model.UserSettings{
@@ -217,39 +395,63 @@ func RetrieveToken(c *gin.Context) {
 		if err != nil {
 			sugar.Errorf("Error setting default user settings: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error during creation"})
-			return
+			return Token{}, false
 		}
 		// Here we use a raw SQL query to insert with the crypt function
 		sql := `INSERT INTO user_settings (user_id, token, created, salt_permissions, settings)
                 VALUES (?, crypt(?, gen_salt('bf', 8)), ?, ?, ?)
                 RETURNING user_id;`
-		err = db.Raw(sql, settings.UserID, "Bearer "+tokenString, settings.Created, settings.SaltPermissions, settings.Settings).Scan(&settings.UserID).Error
+		err = db.Raw(sql, settings.UserID, token.Token, settings.Created, settings.SaltPermissions, settings.Settings).Scan(&settings.UserID).Error
 		if err != nil {
 			sugar.Errorf("Error inserting user settings with hashed token: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error during creation"})
-			return
+			return Token{}, false
 		}
 
 	} else if result.Error != nil {
 		sugar.Errorf("Database error: %v", result.Error)
 		httputil.NewError(c, http.StatusInternalServerError, "Database error.")
-		return
+		return Token{}, false
 	} else {
 		sql := `UPDATE user_settings SET token = crypt(?, gen_salt('bf', 8)) WHERE user_id = ?`
-		err := db.Exec(sql, "Bearer "+tokenString, user.ID).Error
+		err := db.Exec(sql, token.Token, user.ID).Error
 		if err != nil {
 			sugar.Errorf("Error updating user settings with hashed token: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error during update"})
-			return
+			return Token{}, false
 		}
 	}
 
-	// Older releases created this cookie without an explicit Path while handling
-	// /auth/token, so browsers defaulted it to /auth. Remove that legacy cookie
-	// after saving the current Path=/ session; otherwise it shadows the current
-	// cookie on /auth/session after logout.
-	expireLegacyAuthCookie(c)
-	c.JSON(http.StatusOK, Token{Token: "Bearer " + tokenString})
+	userID := user.ID
+	audit.Record(c, audit.Event{
+		Action:   audit.ActionLogin,
+		Outcome:  audit.OutcomeSuccess,
+		UserID:   &userID,
+		Username: authenticatedUsername,
+		Method:   userData.Method,
+	})
+	return token, true
+}
+
+// auditLogin records a login that did not start a session. username is the
+// name presented, which need not belong to an Agartha user.
+func auditLogin(c *gin.Context, outcome, username, method, detail string) {
+	audit.Record(c, audit.Event{
+		Action:   audit.ActionLogin,
+		Outcome:  outcome,
+		Username: username,
+		Method:   method,
+		Detail:   detail,
+	})
+}
+
+// truncateUTF8 shortens value to at most limit bytes without splitting a
+// character.
+func truncateUTF8(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	return strings.ToValidUTF8(value[:limit], "")
 }
 
 // GetSession returns the active user associated with the HttpOnly session cookie.
@@ -262,8 +464,8 @@ func GetSession(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-// Logout expires the browser session and removes all server-side credentials.
-func Logout(c *gin.Context) {
+// clearSession deletes the current cookie session and expires its cookie.
+func clearSession(c *gin.Context) error {
 	session := sessions.Default(c)
 	session.Clear()
 	session.Options(sessions.Options{
@@ -274,11 +476,10 @@ func Logout(c *gin.Context) {
 		SameSite: http.SameSiteLaxMode,
 	})
 	if err := session.Save(); err != nil {
-		httputil.NewError(c, http.StatusInternalServerError, "Failed to clear authentication session.")
-		return
+		return err
 	}
 	expireLegacyAuthCookie(c)
-	c.Status(http.StatusNoContent)
+	return nil
 }
 
 func expireLegacyAuthCookie(c *gin.Context) {
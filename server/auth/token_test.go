@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func newTestAuthDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		mock.ExpectClose()
+		require.NoError(t, sqlDB.Close())
+	})
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+	originalDB := db.DB
+	originalSecret := jwtSecret
+	db.DB = gormDB
+	jwtSecret = []byte("secret")
+	t.Cleanup(func() {
+		db.DB = originalDB
+		jwtSecret = originalSecret
+	})
+	return mock
+}
+
+// expectProvisionedUser expects establishSession to create a first-time user
+// with the given identity and bind a new session to it.
+func expectProvisionedUser(mock sqlmock.Sqlmock, userID int, user userData) {
+	mock.ExpectQuery(`SELECT \* FROM "auth_user" WHERE username = \$1`).
+		WithArgs(user.Username, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "auth_user"`).
+		WithArgs("", false, user.Username, user.FirstName, user.LastName, user.Email, false, true, "", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"last_login", "date_joined", "id"}).AddRow(nil, time.Now(), userID))
+	mock.ExpectCommit()
+	expectSessionStarted(mock, userID)
+}
+
+// expectSessionStarted expects startSession to issue a new token family for
+// the user and bind it to a new session.
+func expectSessionStarted(mock sqlmock.Sqlmock, userID int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "refresh_token" WHERE user_id = \$1 AND expires_at < \$2`).
+		WithArgs(userID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "revoked_token" WHERE expires_at < \$1`).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "refresh_token"`).
+		WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "session_user_map"`).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "session_user_map"`).
+		WithArgs(sqlmock.AnyArg(), userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(time.Now()))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "user_settings" WHERE user_id = \$1`).
+		WithArgs(userID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "token"}).AddRow(userID, ""))
+	mock.ExpectExec(`UPDATE user_settings SET token = crypt\(\$1, gen_salt\('bf', 8\)\) WHERE user_id = \$2`).
+		WithArgs(sqlmock.AnyArg(), userID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
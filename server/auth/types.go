@@ -6,8 +6,10 @@ import (
 	"time"
 
 	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
 	"github.com/gin-contrib/sessions"
 	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
 )
 
 type ldapConnection interface {
@@ -20,21 +22,30 @@ type ldapConnection interface {
 var jwtSecret []byte
 var sessionCookieSecure bool
 var session sessions.Session
-var ldapOptions config.LDAPOptions
 var casOptions config.CASOptions
-var enabledMethods = map[string]struct{}{}
+var samlOptions config.SAMLOptions
+var oidcOptions config.OIDCOptions
 var casHTTPClient = &http.Client{Timeout: 10 * time.Second}
-var ldapDialURL = func(server string) (ldapConnection, error) {
-	return ldap.DialURL(server)
+var ldapDialURL = func(server string, tlsConfig *tls.Config) (ldapConnection, error) {
+	return ldap.DialURL(server, ldap.DialWithTLSConfig(tlsConfig))
 }
 
-func SetOptions(secret []byte, methods []string, ldap config.LDAPOptions, cas config.CASOptions, cookieSecure bool) {
+func SetOptions(secret []byte, methods []string, providers []config.AuthProviderOptions, ldap config.LDAPOptions, cas config.CASOptions, saml config.SAMLOptions, oidc config.OIDCOptions, cookieSecure bool) {
 	jwtSecret = secret
 	sessionCookieSecure = cookieSecure
-	ldapOptions = ldap
 	casOptions = cas
-	enabledMethods = make(map[string]struct{}, len(methods))
+	samlOptions = saml
+	resetSAMLServiceProvider()
+	oidcOptions = oidc
+	resetOIDCRelyingParty()
+	registeredProviders = make([]Provider, 0, len(methods))
 	for _, method := range methods {
-		enabledMethods[method] = struct{}{}
+		provider, err := newProvider(method, ldap, providers)
+		if err != nil {
+			// EffectiveAuthMethods rejects these before the server starts.
+			logger.GetLogger().Error("skipping authentication method", zap.String("method", method), zap.Error(err))
+			continue
+		}
+		registeredProviders = append(registeredProviders, provider)
 	}
 }
@@ -2,14 +2,17 @@ package auth
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 
+	"github.com/PaulChristophel/agartha/server/config"
 	"github.com/PaulChristophel/agartha/server/db"
 	"github.com/PaulChristophel/agartha/server/logger"
 	model "github.com/PaulChristophel/agartha/server/model/agartha"
@@ -26,25 +29,37 @@ type userData struct {
 	Email             string
 	SamAccountName    string
 	UserPrincipalName string
+	Groups            []string
+	// Method is the authentication method that produced the identity.
+	Method string
+	// ProviderSessionID identifies the login at the identity provider, such
+	// as a CAS service ticket, for single logout.
+	ProviderSessionID string
+	// GroupSource names the directory Groups were resolved from. Groups are
+	// only stored for display when it is set.
+	GroupSource string
+	// Flags holds the flags derived from directory groups. Nil leaves the
+	// user's stored flags alone.
+	Flags *userFlags
+}
+
+type userFlags struct {
+	IsSuperuser bool `json:"is_superuser"`
+	IsStaff     bool `json:"is_staff"`
 }
 
 func auth(creds credentials, c *gin.Context) (userData, error) {
 	var userData userData
-	var err error
-	if creds.Method != "local" && creds.Method != "ldap" && creds.Method != "cas" {
-		return userData, fmt.Errorf("unsupported authentication method %q", creds.Method)
-	}
-	if _, enabled := enabledMethods[creds.Method]; !enabled {
+	provider, ok := providerNamed(creds.Method)
+	if !ok {
 		return userData, fmt.Errorf("authentication method %q is not enabled", creds.Method)
 	}
-	switch creds.Method {
-	case "ldap":
-		userData, err = authLDAP(creds.Username, creds.Password)
-	case "cas":
-		userData, err = authCAS(creds.Username, c)
-	case "local":
-		userData, err = authLocal(creds.Username, creds.Password)
+	credential, ok := provider.(credentialProvider)
+	if !ok {
+		return userData, fmt.Errorf("unsupported authentication method %q for credential login", creds.Method)
 	}
+	userData, err := credential.login(c, creds)
+	userData.Method = provider.Name()
 
 	return userData, err
 }
@@ -129,15 +144,28 @@ func authCAS(username string, c *gin.Context) (userData, error) {
 	userData.Username = assertedUsername
 	userData.SamAccountName = assertedUsername
 	userData.UserPrincipalName = assertedUsername
+	userData.ProviderSessionID = ticket
+
+	attributes := serviceResponse.AuthenticationSuccess.Attributes
+	userData.FirstName = attributes.First(casOptions.FirstNameAttribute)
+	userData.LastName = attributes.First(casOptions.LastNameAttribute)
+	userData.Email = attributes.First(casOptions.EmailAttribute)
+	if casOptions.GroupsAttribute != "" {
+		userData.Groups = attributes.All(casOptions.GroupsAttribute)
+		userData.GroupSource = "cas"
+	}
 
 	return userData, nil
 }
 
-func authLDAP(username, password string) (userData, error) {
+// authenticate binds to the directory as username and reads the user's
+// attributes and groups. Groups are stored under the provider's name, so
+// each directory keeps its own.
+func (p ldapProvider) authenticate(username, password string) (userData, error) {
 	var log = logger.GetLogger()
 	var userData userData
-	var ldap_server = ldapOptions.Server
-	accountName, userPrincipalName, err := normalizeLDAPUsername(username, ldapOptions.LDAPDomainDefault)
+	var ldap_server = p.options.Server
+	accountName, bindName, err := ldapBindName(username, p.options)
 	if err != nil {
 		return userData, err
 	}
@@ -145,9 +173,16 @@ func authLDAP(username, password string) (userData, error) {
 		return userData, errors.New("LDAP password is empty")
 	}
 	userData.SamAccountName = accountName
-	userData.UserPrincipalName = userPrincipalName
+	userData.UserPrincipalName = accountName
+	if p.options.BindMode == "" || p.options.BindMode == config.LDAPBindModeUPN {
+		userData.UserPrincipalName = bindName
+	}
 
-	l, err := ldapDialURL(ldap_server)
+	tlsConfig, err := ldapTLSConfig(p.options)
+	if err != nil {
+		return userData, err
+	}
+	l, err := ldapDialURL(ldap_server, tlsConfig)
 	if err != nil {
 		return userData, fmt.Errorf("failed to connect: %w", err)
 	}
@@ -159,15 +194,9 @@ func authLDAP(username, password string) (userData, error) {
 		}
 	}()
 
-	if ldapOptions.StartTLS {
-		tlsConfig := &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		}
-		if ldapURL, parseErr := url.Parse(ldap_server); parseErr == nil {
-			if ldapURL.Scheme == "ldaps" {
-				return userData, errors.New("ldap start_tls requires an ldap:// server URL, not ldaps://")
-			}
-			tlsConfig.ServerName = ldapURL.Hostname()
+	if p.options.StartTLS {
+		if ldapURL, parseErr := url.Parse(ldap_server); parseErr == nil && ldapURL.Scheme == "ldaps" {
+			return userData, errors.New("ldap start_tls requires an ldap:// server URL, not ldaps://")
 		}
 
 		if err := l.StartTLS(tlsConfig); err != nil {
@@ -175,28 +204,31 @@ func authLDAP(username, password string) (userData, error) {
 		}
 	}
 
-	// First bind with the user credentials to authenticate
-	log.Debug("binding to ldap server", zap.String("server", ldap_server), zap.String("user", userData.UserPrincipalName))
-	err = l.Bind(userData.UserPrincipalName, password)
-	if err != nil {
-		return userData, fmt.Errorf("failed to bind: %w", err)
+	// First bind with the user credentials to authenticate. In search mode
+	// the DN to bind as is only known after the lookup below.
+	if bindName != "" {
+		log.Debug("binding to ldap server", zap.String("server", ldap_server), zap.String("user", bindName))
+		err = l.Bind(bindName, password)
+		if err != nil {
+			return userData, fmt.Errorf("failed to bind: %w", err)
+		}
 	}
 
 	// Rebind as a service account with permissions to search
-	serviceUser := ldapOptions.User
-	servicePassword := ldapOptions.Password
+	serviceUser := p.options.User
+	servicePassword := p.options.Password
 	err = l.Bind(serviceUser, servicePassword)
 	if err != nil {
 		return userData, fmt.Errorf("failed to bind as service user: %w", err)
 	}
 
 	// Authorization: check that the user satisfies the LDAP filter
-	filter := fmt.Sprintf(ldapOptions.Filter, ldap.EscapeFilter(userData.SamAccountName))
+	filter := fmt.Sprintf(p.options.Filter, ldap.EscapeFilter(userData.SamAccountName))
 	searchRequest := ldap.NewSearchRequest(
-		ldapOptions.BaseDN,
+		p.options.BaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
 		filter,
-		[]string{"dn", "sAMAccountName", "givenName", "sn", "mail"},
+		[]string{"dn", p.options.UsernameAttribute, p.options.FirstNameAttribute, p.options.LastNameAttribute, p.options.EmailAttribute, "memberOf"},
 		nil,
 	)
 	sr, err := l.Search(searchRequest)
@@ -208,18 +240,80 @@ func authLDAP(username, password string) (userData, error) {
 	}
 
 	entry := sr.Entries[0]
-	authenticatedUsername := strings.TrimSpace(entry.GetAttributeValue("sAMAccountName"))
+	if bindName == "" {
+		log.Debug("binding to ldap server", zap.String("server", ldap_server), zap.String("user", entry.DN))
+		if err := l.Bind(entry.DN, password); err != nil {
+			return userData, fmt.Errorf("failed to bind: %w", err)
+		}
+		// Group resolution below needs the service account's permissions.
+		if err := l.Bind(serviceUser, servicePassword); err != nil {
+			return userData, fmt.Errorf("failed to bind as service user: %w", err)
+		}
+	}
+
+	authenticatedUsername := strings.TrimSpace(entry.GetAttributeValue(p.options.UsernameAttribute))
 	if authenticatedUsername == "" {
-		return userData, errors.New("LDAP entry did not include sAMAccountName")
+		return userData, fmt.Errorf("LDAP entry did not include %s", p.options.UsernameAttribute)
 	}
 	userData.Username = authenticatedUsername
 	userData.SamAccountName = authenticatedUsername
-	userData.FirstName = entry.GetAttributeValue("givenName")
-	userData.LastName = entry.GetAttributeValue("sn")
-	userData.Email = entry.GetAttributeValue("mail")
+	userData.FirstName = entry.GetAttributeValue(p.options.FirstNameAttribute)
+	userData.LastName = entry.GetAttributeValue(p.options.LastNameAttribute)
+	userData.Email = entry.GetAttributeValue(p.options.EmailAttribute)
+
+	groups, err := ldapGroups(l, entry, p.options)
+	if err != nil {
+		return userData, err
+	}
+	userData.Groups = groups
+	userData.GroupSource = p.name
+	userData.Flags = ldapGroupFlags(groups, p.options.GroupMappings)
 	return userData, nil
 }
 
+// ldapBindName returns the account name used in the search filter and the
+// name to bind as for the configured bind mode. The bind name is empty in
+// search mode.
+func ldapBindName(username string, options config.LDAPOptions) (string, string, error) {
+	switch options.BindMode {
+	case config.LDAPBindModeDNTemplate, config.LDAPBindModeSearch:
+		accountName := strings.TrimSpace(username)
+		if accountName == "" {
+			return "", "", errors.New("LDAP username is empty")
+		}
+		if options.BindMode == config.LDAPBindModeSearch {
+			return accountName, "", nil
+		}
+		return accountName, fmt.Sprintf(options.BindDNTemplate, ldap.EscapeDN(accountName)), nil
+	default:
+		return normalizeLDAPUsername(username, options.LDAPDomainDefault)
+	}
+}
+
+// ldapTLSConfig builds the TLS settings used for ldaps:// connections and
+// StartTLS.
+func ldapTLSConfig(options config.LDAPOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if ldapURL, err := url.Parse(options.Server); err == nil {
+		tlsConfig.ServerName = ldapURL.Hostname()
+	}
+	if options.CACert != "" {
+		bundle, err := os.ReadFile(options.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("LDAP CA bundle contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
 func normalizeLDAPUsername(username, defaultDomain string) (string, string, error) {
 	username = strings.TrimSpace(username)
 	if username == "" {
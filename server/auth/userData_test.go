@@ -2,9 +2,11 @@ package auth
 
 import (
 	"crypto/tls"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"regexp"
 	"strings"
 	"testing"
@@ -32,6 +34,9 @@ type fakeLDAPConnection struct {
 	closed        bool
 	searchRequest *ldap.SearchRequest
 	searchResult  *ldap.SearchResult
+	// groupResults answers searches after the user search, keyed by base DN.
+	groupResults  map[string]*ldap.SearchResult
+	groupSearches []*ldap.SearchRequest
 }
 
 func (f *fakeLDAPConnection) Bind(username, password string) error {
@@ -45,43 +50,57 @@ func (f *fakeLDAPConnection) Close() error {
 }
 
 func (f *fakeLDAPConnection) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
-	f.searchRequest = request
-	return f.searchResult, nil
+	if f.searchRequest == nil {
+		f.searchRequest = request
+		return f.searchResult, nil
+	}
+	f.groupSearches = append(f.groupSearches, request)
+	if result, ok := f.groupResults[request.BaseDN]; ok {
+		return result, nil
+	}
+	return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
 }
 
 func (f *fakeLDAPConnection) StartTLS(*tls.Config) error {
 	return nil
 }
 
-func TestAuthRejectsUnsupportedMethod(t *testing.T) {
+func TestAuthRejectsUnknownMethod(t *testing.T) {
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "local", "ldap")
+	t.Cleanup(func() { registeredProviders = originalProviders })
+
 	for _, method := range []string{"", "unknown", "LOCAL", " ldap"} {
 		t.Run(method, func(t *testing.T) {
 			_, err := auth(credentials{Method: method}, nil)
-			require.ErrorContains(t, err, "unsupported authentication method")
+			require.ErrorContains(t, err, "not enabled")
 		})
 	}
 }
 
 func TestAuthRejectsDisabledMethod(t *testing.T) {
-	originalMethods := enabledMethods
-	enabledMethods = map[string]struct{}{"local": {}}
-	t.Cleanup(func() { enabledMethods = originalMethods })
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "local")
+	t.Cleanup(func() { registeredProviders = originalProviders })
 
 	_, err := auth(credentials{Method: "ldap"}, nil)
 	require.ErrorContains(t, err, "not enabled")
 }
 
 func TestGetMethodReturnsOnlyEnabledMethods(t *testing.T) {
-	originalMethods := enabledMethods
-	enabledMethods = map[string]struct{}{"local": {}, "cas": {}}
-	t.Cleanup(func() { enabledMethods = originalMethods })
+	originalProviders := registeredProviders
+	registeredProviders = testProviders(t, "local", "cas")
+	t.Cleanup(func() { registeredProviders = originalProviders })
 
 	recorder := httptest.NewRecorder()
 	context, _ := gin.CreateTestContext(recorder)
 	GetMethod(context)
 
 	require.Equal(t, http.StatusOK, recorder.Code)
-	require.JSONEq(t, `{"auth_methods":["local","cas"]}`, recorder.Body.String())
+	require.JSONEq(t, `{
+		"auth_methods": ["local", "cas"],
+		"providers": [{"name": "local", "type": "local"}, {"name": "cas", "type": "cas"}]
+	}`, recorder.Body.String())
 }
 
 func TestNormalizeLDAPUsername(t *testing.T) {
@@ -141,26 +160,28 @@ func TestAuthLDAPBindsAndUsesDirectoryIdentity(t *testing.T) {
 			}),
 		}},
 	}
-	originalOptions := ldapOptions
 	originalDial := ldapDialURL
-	ldapOptions = config.LDAPOptions{
-		Server:            "ldaps://directory.example.test:636",
-		User:              "cn=service,dc=example,dc=test",
-		Password:          "service-password",
-		BaseDN:            "dc=example,dc=test",
-		Filter:            "(&(objectClass=person)(sAMAccountName=%s))",
-		LDAPDomainDefault: "example.test",
-	}
-	ldapDialURL = func(server string) (ldapConnection, error) {
-		require.Equal(t, ldapOptions.Server, server)
+	provider := ldapProvider{name: "ldap", options: config.LDAPOptions{
+		Server:             "ldaps://directory.example.test:636",
+		User:               "cn=service,dc=example,dc=test",
+		Password:           "service-password",
+		BaseDN:             "dc=example,dc=test",
+		Filter:             "(&(objectClass=person)(sAMAccountName=%s))",
+		LDAPDomainDefault:  "example.test",
+		UsernameAttribute:  "sAMAccountName",
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "sn",
+		EmailAttribute:     "mail",
+	}}
+	ldapDialURL = func(server string, _ *tls.Config) (ldapConnection, error) {
+		require.Equal(t, provider.options.Server, server)
 		return connection, nil
 	}
 	t.Cleanup(func() {
-		ldapOptions = originalOptions
 		ldapDialURL = originalDial
 	})
 
-	user, err := authLDAP("requested-alice", "user-password")
+	user, err := provider.authenticate("requested-alice", "user-password")
 	require.NoError(t, err)
 	require.Equal(t, "directory-alice", user.Username)
 	require.Equal(t, "alice@example.test", user.Email)
@@ -168,25 +189,120 @@ func TestAuthLDAPBindsAndUsesDirectoryIdentity(t *testing.T) {
 		{"requested-alice@example.test", "user-password"},
 		{"cn=service,dc=example,dc=test", "service-password"},
 	}, connection.binds)
-	require.Equal(t, ldapOptions.BaseDN, connection.searchRequest.BaseDN)
+	require.Equal(t, provider.options.BaseDN, connection.searchRequest.BaseDN)
 	require.Equal(t, "(&(objectClass=person)(sAMAccountName=requested-alice))", connection.searchRequest.Filter)
 	require.True(t, connection.closed)
 }
 
+func TestAuthLDAPBindModesForNonADDirectories(t *testing.T) {
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	const userDN = "uid=alice,cn=users,cn=accounts,dc=corp,dc=test"
+	const serviceDN = "uid=agartha,cn=sysaccounts,dc=corp,dc=test"
+	tests := []struct {
+		name      string
+		bindMode  string
+		wantBinds [][2]string
+	}{
+		{
+			name:     "dn template",
+			bindMode: config.LDAPBindModeDNTemplate,
+			wantBinds: [][2]string{
+				{userDN, "user-password"},
+				{serviceDN, "service-password"},
+			},
+		},
+		{
+			name:     "search then bind",
+			bindMode: config.LDAPBindModeSearch,
+			wantBinds: [][2]string{
+				{serviceDN, "service-password"},
+				{userDN, "user-password"},
+				{serviceDN, "service-password"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connection := &fakeLDAPConnection{
+				searchResult: &ldap.SearchResult{Entries: []*ldap.Entry{
+					ldap.NewEntry(userDN, map[string][]string{
+						"uid":       {"alice"},
+						"givenName": {"Alice"},
+						"sn":        {"Admin"},
+						"mailAlias": {"alice@corp.test"},
+						"memberOf":  {"cn=admins,cn=groups,cn=accounts,dc=corp,dc=test"},
+					}),
+				}},
+			}
+			originalDial := ldapDialURL
+			provider := ldapProvider{name: "ldap", options: config.LDAPOptions{
+				Server:             "ldap://ipa.corp.test",
+				User:               serviceDN,
+				Password:           "service-password",
+				BaseDN:             "cn=users,cn=accounts,dc=corp,dc=test",
+				Filter:             "(&(objectClass=posixAccount)(uid=%s))",
+				BindMode:           tt.bindMode,
+				BindDNTemplate:     "uid=%s,cn=users,cn=accounts,dc=corp,dc=test",
+				UsernameAttribute:  "uid",
+				FirstNameAttribute: "givenName",
+				LastNameAttribute:  "sn",
+				EmailAttribute:     "mailAlias",
+			}}
+			ldapDialURL = func(server string, tlsConfig *tls.Config) (ldapConnection, error) {
+				require.Equal(t, "ipa.corp.test", tlsConfig.ServerName)
+				return connection, nil
+			}
+			t.Cleanup(func() {
+				ldapDialURL = originalDial
+			})
+
+			user, err := provider.authenticate(" alice ", "user-password")
+			require.NoError(t, err)
+			require.Equal(t, "alice", user.Username)
+			require.Equal(t, "alice@corp.test", user.Email)
+			require.Equal(t, tt.wantBinds, connection.binds)
+			require.Equal(t, "(&(objectClass=posixAccount)(uid=alice))", connection.searchRequest.Filter)
+			require.Contains(t, connection.searchRequest.Attributes, "uid")
+		})
+	}
+}
+
+func TestLDAPBindNameEscapesDNTemplate(t *testing.T) {
+	account, bindName, err := ldapBindName("alice,ou=admins", config.LDAPOptions{
+		BindMode:       config.LDAPBindModeDNTemplate,
+		BindDNTemplate: "uid=%s,cn=users,dc=corp,dc=test",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "alice,ou=admins", account)
+	require.Equal(t, `uid=alice\,ou=admins,cn=users,dc=corp,dc=test`, bindName)
+}
+
+func TestLDAPTLSConfigRejectsInvalidCABundle(t *testing.T) {
+	bundle := t.TempDir() + "/ca.pem"
+	require.NoError(t, os.WriteFile(bundle, []byte("not a certificate"), 0o600))
+
+	_, err := ldapTLSConfig(config.LDAPOptions{Server: "ldaps://ipa.corp.test", CACert: bundle})
+	require.ErrorContains(t, err, "no PEM certificates")
+
+	_, err = ldapTLSConfig(config.LDAPOptions{Server: "ldaps://ipa.corp.test", CACert: bundle + ".missing"})
+	require.ErrorContains(t, err, "failed to read LDAP CA bundle")
+}
+
 func TestAuthLDAPRejectsEmptyPasswordBeforeConnecting(t *testing.T) {
-	originalOptions := ldapOptions
 	originalDial := ldapDialURL
-	ldapOptions.LDAPDomainDefault = "example.test"
-	ldapDialURL = func(string) (ldapConnection, error) {
+	provider := ldapProvider{name: "ldap", options: config.LDAPOptions{LDAPDomainDefault: "example.test"}}
+	ldapDialURL = func(string, *tls.Config) (ldapConnection, error) {
 		t.Fatal("LDAP connection should not be opened for an empty password")
 		return nil, nil
 	}
 	t.Cleanup(func() {
-		ldapOptions = originalOptions
 		ldapDialURL = originalDial
 	})
 
-	_, err := authLDAP("alice", "")
+	_, err := provider.authenticate("alice", "")
 	require.ErrorContains(t, err, "password is empty")
 }
 
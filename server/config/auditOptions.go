@@ -0,0 +1,18 @@
+package config
+
+// AuditOptions configures where audit events are copied besides the
+// audit_log table. Both sinks are off when left empty.
+type AuditOptions struct {
+	// File is appended with one JSON object per line.
+	File   string             `mapstructure:"file" yaml:"file"`
+	Syslog AuditSyslogOptions `mapstructure:"syslog" yaml:"syslog"`
+}
+
+// AuditSyslogOptions sends audit events to syslog. An empty network and
+// address use the local syslog daemon.
+type AuditSyslogOptions struct {
+	Enabled bool   `mapstructure:"enabled" yaml:"enabled"`
+	Network string `mapstructure:"network" yaml:"network"`
+	Address string `mapstructure:"address" yaml:"address"`
+	Tag     string `mapstructure:"tag" yaml:"tag"`
+}
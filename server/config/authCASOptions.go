@@ -6,4 +6,11 @@ type CASOptions struct {
 	ValidatePath string `mapstructure:"validate_path" yaml:"validate_path"`
 	LoginPath    string `mapstructure:"login_path" yaml:"login_path"`
 	LogoutPath   string `mapstructure:"logout_path" yaml:"logout_path"`
+
+	// Attributes released by CAS 3.0 (/p3/serviceValidate). An empty name
+	// leaves the field unset.
+	FirstNameAttribute string `mapstructure:"first_name_attribute" yaml:"first_name_attribute"`
+	LastNameAttribute  string `mapstructure:"last_name_attribute" yaml:"last_name_attribute"`
+	EmailAttribute     string `mapstructure:"email_attribute" yaml:"email_attribute"`
+	GroupsAttribute    string `mapstructure:"groups_attribute" yaml:"groups_attribute"`
 }
@@ -0,0 +1,44 @@
+package config
+
+type CertOptions struct {
+	// Rules are tried in order; the first rule whose source value matches
+	// names the user.
+	Rules []CertMappingRule `mapstructure:"rules" yaml:"rules"`
+	// RequiredFor lists the roles (superuser, staff) that may only log in
+	// with a client certificate.
+	RequiredFor []string `mapstructure:"required_for" yaml:"required_for"`
+}
+
+// CertMappingRule maps one field of a verified client certificate to a
+// username. Match is a regular expression that must match the whole field,
+// as if written ^(?:match)$, and Username is expanded from its submatches,
+// e.g. "$1" or "${user}".
+type CertMappingRule struct {
+	Source   string `mapstructure:"source" yaml:"source"`
+	Match    string `mapstructure:"match" yaml:"match"`
+	Username string `mapstructure:"username" yaml:"username"`
+}
+
+// Pattern returns Match anchored to the whole field. The non-capturing group
+// keeps alternations inside the anchors and leaves submatch numbers unchanged.
+func (r CertMappingRule) Pattern() string {
+	return "^(?:" + r.Match + ")$"
+}
+
+// Certificate fields a mapping rule can read. subject is the full RFC 2253
+// subject DN, and san_upn is the Microsoft user principal name carried by
+// smartcard logon certificates.
+const (
+	CertSourceSubjectCN = "subject_cn"
+	CertSourceSubject   = "subject"
+	CertSourceSANEmail  = "san_email"
+	CertSourceSANDNS    = "san_dns"
+	CertSourceSANURI    = "san_uri"
+	CertSourceSANUPN    = "san_upn"
+)
+
+// Roles that can be restricted to certificate logins.
+const (
+	CertRoleSuperuser = "superuser"
+	CertRoleStaff     = "staff"
+)
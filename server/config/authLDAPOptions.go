@@ -1,11 +1,49 @@
 package config
 
 type LDAPOptions struct {
-	Server            string `mapstructure:"server" yaml:"server"`
-	User              string `mapstructure:"user" yaml:"user"`
-	Password          string `mapstructure:"password" yaml:"password"`
-	BaseDN            string `mapstructure:"base_dn" yaml:"base_dn"`
-	Filter            string `mapstructure:"filter" yaml:"filter"`
-	LDAPDomainDefault string `mapstructure:"ldap_domain_default" yaml:"ldap_domain_default"`
-	StartTLS          bool   `mapstructure:"start_tls" yaml:"start_tls"`
+	Server             string             `mapstructure:"server" yaml:"server"`
+	User               string             `mapstructure:"user" yaml:"user"`
+	Password           string             `mapstructure:"password" yaml:"password"`
+	BaseDN             string             `mapstructure:"base_dn" yaml:"base_dn"`
+	Filter             string             `mapstructure:"filter" yaml:"filter"`
+	LDAPDomainDefault  string             `mapstructure:"ldap_domain_default" yaml:"ldap_domain_default"`
+	StartTLS           bool               `mapstructure:"start_tls" yaml:"start_tls"`
+	CACert             string             `mapstructure:"ca_cert" yaml:"ca_cert"`
+	InsecureSkipVerify bool               `mapstructure:"insecure_skip_verify" yaml:"insecure_skip_verify"`
+	BindMode           string             `mapstructure:"bind_mode" yaml:"bind_mode"`
+	BindDNTemplate     string             `mapstructure:"bind_dn_template" yaml:"bind_dn_template"`
+	UsernameAttribute  string             `mapstructure:"username_attribute" yaml:"username_attribute"`
+	FirstNameAttribute string             `mapstructure:"first_name_attribute" yaml:"first_name_attribute"`
+	LastNameAttribute  string             `mapstructure:"last_name_attribute" yaml:"last_name_attribute"`
+	EmailAttribute     string             `mapstructure:"email_attribute" yaml:"email_attribute"`
+	GroupBaseDN        string             `mapstructure:"group_base_dn" yaml:"group_base_dn"`
+	GroupResolution    string             `mapstructure:"group_resolution" yaml:"group_resolution"`
+	GroupMappings      []LDAPGroupMapping `mapstructure:"group_mappings" yaml:"group_mappings"`
 }
+
+// LDAPGroupMapping grants Agartha flags to members of a directory group,
+// identified by its distinguished name.
+type LDAPGroupMapping struct {
+	Group       string `mapstructure:"group" yaml:"group"`
+	IsSuperuser bool   `mapstructure:"is_superuser" yaml:"is_superuser"`
+	IsStaff     bool   `mapstructure:"is_staff" yaml:"is_staff"`
+}
+
+// LDAP group resolution modes. member_of reads the user's memberOf values,
+// recursive follows memberOf through each parent group, and in_chain asks
+// Active Directory for the transitive membership with
+// LDAP_MATCHING_RULE_IN_CHAIN.
+const (
+	LDAPGroupResolutionMemberOf  = "member_of"
+	LDAPGroupResolutionRecursive = "recursive"
+	LDAPGroupResolutionInChain   = "in_chain"
+)
+
+// LDAP bind modes. upn binds as user@domain (Active Directory), dn_template
+// binds as the DN built from bind_dn_template, and search looks the user up
+// with the service account and binds as the DN it finds.
+const (
+	LDAPBindModeUPN        = "upn"
+	LDAPBindModeDNTemplate = "dn_template"
+	LDAPBindModeSearch     = "search"
+)
@@ -0,0 +1,15 @@
+package config
+
+type OIDCOptions struct {
+	Issuer       string   `mapstructure:"issuer" yaml:"issuer"`
+	ClientID     string   `mapstructure:"client_id" yaml:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret" yaml:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url" yaml:"redirect_url"`
+	Scopes       []string `mapstructure:"scopes" yaml:"scopes"`
+
+	UsernameClaim  string `mapstructure:"username_claim" yaml:"username_claim"`
+	FirstNameClaim string `mapstructure:"first_name_claim" yaml:"first_name_claim"`
+	LastNameClaim  string `mapstructure:"last_name_claim" yaml:"last_name_claim"`
+	EmailClaim     string `mapstructure:"email_claim" yaml:"email_claim"`
+	GroupsClaim    string `mapstructure:"groups_claim" yaml:"groups_claim"`
+}
@@ -2,4 +2,63 @@ package config
 
 type AuthOptions struct {
 	Methods []string `mapstructure:"methods" yaml:"methods"`
+	// Providers configures additional named login methods, such as a second
+	// LDAP directory. A provider is enabled by listing its name in Methods.
+	Providers []AuthProviderOptions `mapstructure:"providers" yaml:"providers"`
+}
+
+// AuthProviderOptions is one named login method. Type selects the
+// implementation and the section holding its settings.
+type AuthProviderOptions struct {
+	Name string      `mapstructure:"name" yaml:"name"`
+	Type string      `mapstructure:"type" yaml:"type"`
+	LDAP LDAPOptions `mapstructure:"ldap" yaml:"ldap"`
+}
+
+// Authentication provider types. Only LDAP directories can be configured
+// more than once.
+const (
+	AuthProviderLocal = "local"
+	AuthProviderLDAP  = "ldap"
+	AuthProviderCAS   = "cas"
+	AuthProviderSAML  = "saml"
+	AuthProviderOIDC  = "oidc"
+	AuthProviderCert  = "cert"
+	AuthProviderProxy = "proxy"
+)
+
+// builtinAuthProviders lists the login methods configured by their own
+// top-level section. Each is named after its type.
+var builtinAuthProviders = []string{
+	AuthProviderLocal,
+	AuthProviderLDAP,
+	AuthProviderCAS,
+	AuthProviderSAML,
+	AuthProviderOIDC,
+	AuthProviderCert,
+	AuthProviderProxy,
+}
+
+// setProviderDefaults fills the LDAP settings a named provider leaves unset
+// with the defaults of the ldap section.
+func (options *AuthOptions) setProviderDefaults() {
+	defaults := NewConfig().LDAP
+	for i := range options.Providers {
+		ldap := &options.Providers[i].LDAP
+		for _, field := range []struct {
+			value    *string
+			fallback string
+		}{
+			{&ldap.BindMode, defaults.BindMode},
+			{&ldap.UsernameAttribute, defaults.UsernameAttribute},
+			{&ldap.FirstNameAttribute, defaults.FirstNameAttribute},
+			{&ldap.LastNameAttribute, defaults.LastNameAttribute},
+			{&ldap.EmailAttribute, defaults.EmailAttribute},
+			{&ldap.GroupResolution, defaults.GroupResolution},
+		} {
+			if *field.value == "" {
+				*field.value = field.fallback
+			}
+		}
+	}
 }
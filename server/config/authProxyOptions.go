@@ -0,0 +1,13 @@
+package config
+
+// ProxyOptions names the headers an authenticating reverse proxy sets for
+// the logged-in user. They are only read from requests whose peer address is
+// listed in http.trusted_proxies. An empty header name leaves the field unset.
+type ProxyOptions struct {
+	UserHeader      string `mapstructure:"user_header" yaml:"user_header"`
+	GroupsHeader    string `mapstructure:"groups_header" yaml:"groups_header"`
+	GroupsSeparator string `mapstructure:"groups_separator" yaml:"groups_separator"`
+	FirstNameHeader string `mapstructure:"first_name_header" yaml:"first_name_header"`
+	LastNameHeader  string `mapstructure:"last_name_header" yaml:"last_name_header"`
+	EmailHeader     string `mapstructure:"email_header" yaml:"email_header"`
+}
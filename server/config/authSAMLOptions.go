@@ -1,10 +1,17 @@
 package config
 
 type SAMLOptions struct {
-	MetadataURL string `mapstructure:"metadata_url" yaml:"metadata_url"`
-	SessionCert string `mapstructure:"session_certificate" yaml:"session_certificate"`
-	SessionKey  string `mapstructure:"session_key" yaml:"session_key"`
-	ServerCert  string `mapstructure:"server_certificate" yaml:"server_certificate"`
-	ServerURL   string `mapstructure:"server_url" yaml:"server_url"`
-	EntityID    string `mapstructure:"entity_id" yaml:"entity_id"`
+	MetadataURL  string `mapstructure:"metadata_url" yaml:"metadata_url"`
+	MetadataFile string `mapstructure:"metadata_file" yaml:"metadata_file"`
+	SessionCert  string `mapstructure:"session_certificate" yaml:"session_certificate"`
+	SessionKey   string `mapstructure:"session_key" yaml:"session_key"`
+	ServerCert   string `mapstructure:"server_certificate" yaml:"server_certificate"`
+	ServerURL    string `mapstructure:"server_url" yaml:"server_url"`
+	EntityID     string `mapstructure:"entity_id" yaml:"entity_id"`
+
+	UsernameAttribute  string `mapstructure:"username_attribute" yaml:"username_attribute"`
+	FirstNameAttribute string `mapstructure:"first_name_attribute" yaml:"first_name_attribute"`
+	LastNameAttribute  string `mapstructure:"last_name_attribute" yaml:"last_name_attribute"`
+	EmailAttribute     string `mapstructure:"email_attribute" yaml:"email_attribute"`
+	GroupsAttribute    string `mapstructure:"groups_attribute" yaml:"groups_attribute"`
 }
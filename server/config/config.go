@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/mail"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
 )
@@ -16,13 +19,26 @@ import (
 const envVarPrefix = "agartha"
 
 type Config struct {
-	HTTP HTTPOptions `mapstructure:"http" yaml:"http"`
-	Auth AuthOptions `mapstructure:"auth" yaml:"auth"`
-	LDAP LDAPOptions `mapstructure:"ldap" yaml:"ldap"`
-	SAML SAMLOptions `mapstructure:"saml" yaml:"saml"`
-	DB   DBOptions   `mapstructure:"db" yaml:"db"`
-	Salt SaltOptions `mapstructure:"salt" yaml:"salt"`
-	CAS  CASOptions  `mapstructure:"cas" yaml:"cas"`
+	HTTP  HTTPOptions  `mapstructure:"http" yaml:"http"`
+	Auth  AuthOptions  `mapstructure:"auth" yaml:"auth"`
+	LDAP  LDAPOptions  `mapstructure:"ldap" yaml:"ldap"`
+	SAML  SAMLOptions  `mapstructure:"saml" yaml:"saml"`
+	DB    DBOptions    `mapstructure:"db" yaml:"db"`
+	Salt  SaltOptions  `mapstructure:"salt" yaml:"salt"`
+	CAS   CASOptions   `mapstructure:"cas" yaml:"cas"`
+	OIDC  OIDCOptions  `mapstructure:"oidc" yaml:"oidc"`
+	Cert  CertOptions  `mapstructure:"cert" yaml:"cert"`
+	Proxy ProxyOptions `mapstructure:"proxy" yaml:"proxy"`
+
+	Password PasswordOptions `mapstructure:"password" yaml:"password"`
+	SMTP     SMTPOptions     `mapstructure:"smtp" yaml:"smtp"`
+	MFA      MFAOptions      `mapstructure:"mfa" yaml:"mfa"`
+	Token    TokenOptions    `mapstructure:"token" yaml:"token"`
+	Lockout  LockoutOptions  `mapstructure:"lockout" yaml:"lockout"`
+	Audit    AuditOptions    `mapstructure:"audit" yaml:"audit"`
+
+	Redaction RedactionOptions `mapstructure:"redaction" yaml:"redaction"`
+	JobState  JobStateOptions  `mapstructure:"job_state" yaml:"job_state"`
 }
 
 func NewConfig() *Config {
@@ -39,13 +55,19 @@ func NewConfig() *Config {
 			ShutdownTimeout:   15 * time.Second,
 		},
 		LDAP: LDAPOptions{
-			Server:            "ldap.example.com",
-			User:              "user",
-			Password:          "password",
-			BaseDN:            "dc=example,dc=com",
-			Filter:            "(objectClass=*)",
-			LDAPDomainDefault: "example.com",
-			StartTLS:          false,
+			Server:             "ldap.example.com",
+			User:               "user",
+			Password:           "password",
+			BaseDN:             "dc=example,dc=com",
+			Filter:             "(objectClass=*)",
+			LDAPDomainDefault:  "example.com",
+			StartTLS:           false,
+			BindMode:           LDAPBindModeUPN,
+			UsernameAttribute:  "sAMAccountName",
+			FirstNameAttribute: "givenName",
+			LastNameAttribute:  "sn",
+			EmailAttribute:     "mail",
+			GroupResolution:    LDAPGroupResolutionMemberOf,
 		},
 		DB: DBOptions{
 			Host:                "localhost",
@@ -75,12 +97,18 @@ func NewConfig() *Config {
 			Insecure:    false,
 		},
 		SAML: SAMLOptions{
-			MetadataURL: "",
-			SessionCert: "",
-			ServerCert:  "",
-			ServerURL:   "",
-			EntityID:    "",
-			SessionKey:  "",
+			MetadataURL:        "",
+			MetadataFile:       "",
+			SessionCert:        "",
+			ServerCert:         "",
+			ServerURL:          "",
+			EntityID:           "",
+			SessionKey:         "",
+			UsernameAttribute:  "",
+			FirstNameAttribute: "givenName",
+			LastNameAttribute:  "sn",
+			EmailAttribute:     "mail",
+			GroupsAttribute:    "memberOf",
 		},
 		CAS: CASOptions{
 			Server:       "https://cas.example.com",
@@ -88,6 +116,86 @@ func NewConfig() *Config {
 			ValidatePath: "/serviceValidate",
 			LoginPath:    "/login",
 			LogoutPath:   "/logout",
+
+			FirstNameAttribute: "givenName",
+			LastNameAttribute:  "sn",
+			EmailAttribute:     "mail",
+			GroupsAttribute:    "memberOf",
+		},
+		OIDC: OIDCOptions{
+			Issuer:         "",
+			ClientID:       "",
+			ClientSecret:   "",
+			RedirectURL:    "",
+			Scopes:         []string{"openid", "profile", "email"},
+			UsernameClaim:  "preferred_username",
+			FirstNameClaim: "given_name",
+			LastNameClaim:  "family_name",
+			EmailClaim:     "email",
+			GroupsClaim:    "groups",
+		},
+		Cert: CertOptions{
+			Rules: []CertMappingRule{
+				{Source: CertSourceSubjectCN, Match: "^(.+)$", Username: "$1"},
+			},
+			RequiredFor: []string{},
+		},
+		Proxy: ProxyOptions{
+			UserHeader:      "X-Remote-User",
+			GroupsHeader:    "X-Remote-Groups",
+			GroupsSeparator: ",",
+			FirstNameHeader: "",
+			LastNameHeader:  "",
+			EmailHeader:     "X-Remote-Email",
+		},
+		Password: PasswordOptions{
+			MinLength:           12,
+			MinCharacterClasses: 3,
+			ResetTokenTTL:       30 * time.Minute,
+			ResetURL:            "",
+		},
+		SMTP: SMTPOptions{
+			Host:        "",
+			Port:        587,
+			ImplicitTLS: false,
+		},
+		MFA: MFAOptions{
+			Issuer:          "Agartha",
+			RequiredMethods: []string{},
+			EncryptionKey:   "",
+		},
+		Token: TokenOptions{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 8 * time.Hour,
+		},
+		Lockout: LockoutOptions{
+			MaxFailures:         5,
+			ClientIPMaxFailures: 50,
+			FailureWindow:       15 * time.Minute,
+			LockoutDuration:     15 * time.Minute,
+			BaseDelay:           time.Second,
+			MaxDelay:            30 * time.Second,
+		},
+		Audit: AuditOptions{
+			File: "",
+			Syslog: AuditSyslogOptions{
+				Enabled: false,
+				Network: "",
+				Address: "",
+				Tag:     "agartha",
+			},
+		},
+		Redaction: RedactionOptions{
+			Paths:  []string{},
+			Keys:   []string{"*password*", "*passwd*", "*secret*", "*private_key*"},
+			Values: []string{`-----BEGIN [A-Z ]*PRIVATE KEY-----`},
+			Mask:   "********",
+		},
+		JobState: JobStateOptions{
+			Enabled:      true,
+			Interval:     30 * time.Second,
+			Timeout:      10 * time.Minute,
+			ReturnWindow: 24 * time.Hour,
 		},
 	}
 }
@@ -137,21 +245,71 @@ func (c Config) ValidateForServe() error {
 		errs = append(errs, err)
 	}
 	if contains(methods, "ldap") {
-		if err := validateLDAP(c.LDAP); err != nil {
+		if err := validateLDAP("ldap", c.LDAP); err != nil {
 			errs = append(errs, err)
 		}
 	}
+	if err := validateAuthProviders(c.Auth.Providers, methods); err != nil {
+		errs = append(errs, err)
+	}
 	if contains(methods, "cas") {
 		if err := validateCAS(c.CAS); err != nil {
 			errs = append(errs, err)
 		}
 	}
+	if contains(methods, "saml") {
+		if err := validateSAML(c.SAML); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if contains(methods, "oidc") {
+		if err := validateOIDC(c.OIDC); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if contains(methods, "cert") {
+		if err := validateCert(c.Cert, c.HTTP); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if contains(methods, "proxy") {
+		if err := validateProxy(c.Proxy, c.HTTP); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := validatePassword(c.Password); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateMFA(c.MFA, c.Auth.Providers); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateToken(c.Token); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateLockout(c.Lockout); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateAudit(c.Audit); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateRedaction(c.Redaction); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateJobState(c.JobState); err != nil {
+		errs = append(errs, err)
+	}
+	if strings.TrimSpace(c.SMTP.Host) != "" {
+		if err := validateSMTP(c.SMTP); err != nil {
+			errs = append(errs, err)
+		}
+	}
 
 	return errors.Join(errs...)
 }
 
 // EffectiveAuthMethods returns the explicitly configured authentication
-// allowlist. No provider is enabled implicitly.
+// allowlist. No provider is enabled implicitly. Besides the built-in methods
+// it may name any of auth.providers.
 func (c Config) EffectiveAuthMethods() ([]string, error) {
 	methods := c.Auth.Methods
 
@@ -159,9 +317,7 @@ func (c Config) EffectiveAuthMethods() ([]string, error) {
 	normalized := make([]string, 0, len(methods))
 	for _, method := range methods {
 		method = strings.ToLower(strings.TrimSpace(method))
-		switch method {
-		case "local", "ldap", "cas":
-		default:
+		if !contains(builtinAuthProviders, method) && !c.hasAuthProvider(method) {
 			return nil, fmt.Errorf("auth.methods contains unsupported method %q", method)
 		}
 		if _, exists := seen[method]; exists {
@@ -176,6 +332,48 @@ func (c Config) EffectiveAuthMethods() ([]string, error) {
 	return normalized, nil
 }
 
+func (c Config) hasAuthProvider(name string) bool {
+	for _, provider := range c.Auth.Providers {
+		if provider.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var authProviderNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// validateAuthProviders checks auth.providers. Only the providers named in
+// methods need complete settings.
+func validateAuthProviders(providers []AuthProviderOptions, methods []string) error {
+	var errs []error
+	seen := make(map[string]struct{}, len(providers))
+	for i, provider := range providers {
+		prefix := fmt.Sprintf("auth.providers[%d]", i)
+		switch {
+		case !authProviderNamePattern.MatchString(provider.Name):
+			errs = append(errs, fmt.Errorf("%s.name %q must be lowercase letters, digits, - or _", prefix, provider.Name))
+		case contains(builtinAuthProviders, provider.Name):
+			errs = append(errs, fmt.Errorf("%s.name %q is reserved for the built-in method", prefix, provider.Name))
+		default:
+			if _, exists := seen[provider.Name]; exists {
+				errs = append(errs, fmt.Errorf("%s.name %q is configured more than once", prefix, provider.Name))
+			}
+			seen[provider.Name] = struct{}{}
+		}
+		if provider.Type != AuthProviderLDAP {
+			errs = append(errs, fmt.Errorf("%s.type %q is not supported; only ldap can be configured more than once", prefix, provider.Type))
+			continue
+		}
+		if contains(methods, provider.Name) {
+			if err := validateLDAP(prefix+".ldap", provider.LDAP); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func contains(values []string, wanted string) bool {
 	for _, value := range values {
 		if value == wanted {
@@ -218,32 +416,77 @@ func (c Config) ValidateDatabase() error {
 	return errors.Join(errs...)
 }
 
-func validateLDAP(options LDAPOptions) error {
+// validateLDAP checks the LDAP settings found under prefix, such as "ldap"
+// or "auth.providers[0].ldap".
+func validateLDAP(prefix string, options LDAPOptions) error {
 	var errs []error
 	parsed, err := url.Parse(options.Server)
 	if err != nil || parsed.Host == "" || (parsed.Scheme != "ldap" && parsed.Scheme != "ldaps") {
-		errs = append(errs, errors.New("ldap.server must be an absolute ldap:// or ldaps:// URL"))
+		errs = append(errs, fmt.Errorf("%s.server must be an absolute ldap:// or ldaps:// URL", prefix))
 	} else if isExampleHost(parsed.Hostname()) {
-		errs = append(errs, errors.New("ldap.server must not use an example.com placeholder host"))
+		errs = append(errs, fmt.Errorf("%s.server must not use an example.com placeholder host", prefix))
+	}
+	required := map[string]string{
+		prefix + ".user":    options.User,
+		prefix + ".base_dn": options.BaseDN,
+		prefix + ".filter":  options.Filter,
+	}
+	switch options.BindMode {
+	case "", LDAPBindModeUPN:
+		required[prefix+".ldap_domain_default"] = options.LDAPDomainDefault
+	case LDAPBindModeDNTemplate:
+		required[prefix+".bind_dn_template"] = options.BindDNTemplate
+		if strings.Count(options.BindDNTemplate, "%s") != 1 {
+			errs = append(errs, fmt.Errorf("%s.bind_dn_template must contain exactly one %%s username placeholder", prefix))
+		}
+	case LDAPBindModeSearch:
+	default:
+		errs = append(errs, fmt.Errorf("%s.bind_mode must be %s, %s or %s", prefix, LDAPBindModeUPN, LDAPBindModeDNTemplate, LDAPBindModeSearch))
 	}
-	for name, value := range map[string]string{
-		"ldap.user":                options.User,
-		"ldap.base_dn":             options.BaseDN,
-		"ldap.filter":              options.Filter,
-		"ldap.ldap_domain_default": options.LDAPDomainDefault,
-	} {
+	for name, value := range required {
 		if strings.TrimSpace(value) == "" || isPlaceholder(value) {
 			errs = append(errs, fmt.Errorf("%s must be configured when LDAP authentication is enabled", name))
 		}
 	}
+	for name, value := range map[string]string{
+		prefix + ".username_attribute":   options.UsernameAttribute,
+		prefix + ".first_name_attribute": options.FirstNameAttribute,
+		prefix + ".last_name_attribute":  options.LastNameAttribute,
+		prefix + ".email_attribute":      options.EmailAttribute,
+	} {
+		if strings.TrimSpace(value) == "" {
+			errs = append(errs, fmt.Errorf("%s must not be empty", name))
+		}
+	}
+	if options.InsecureSkipVerify && strings.TrimSpace(options.CACert) != "" {
+		errs = append(errs, fmt.Errorf("%s.insecure_skip_verify and %s.ca_cert are mutually exclusive", prefix, prefix))
+	}
 	if isPlaceholder(options.Password) {
-		errs = append(errs, errors.New("ldap.password must not be empty or a known placeholder"))
+		errs = append(errs, fmt.Errorf("%s.password must not be empty or a known placeholder", prefix))
 	}
 	if !strings.Contains(options.Filter, "%s") {
-		errs = append(errs, errors.New("ldap.filter must contain a %s username placeholder"))
+		errs = append(errs, fmt.Errorf("%s.filter must contain a %%s username placeholder", prefix))
 	}
 	if options.StartTLS && parsed != nil && parsed.Scheme == "ldaps" {
-		errs = append(errs, errors.New("ldap.start_tls requires an ldap:// server URL, not ldaps://"))
+		errs = append(errs, fmt.Errorf("%s.start_tls requires an ldap:// server URL, not ldaps://", prefix))
+	}
+	switch options.GroupResolution {
+	case "", LDAPGroupResolutionMemberOf, LDAPGroupResolutionRecursive, LDAPGroupResolutionInChain:
+	default:
+		errs = append(errs, fmt.Errorf("%s.group_resolution must be %s, %s or %s", prefix, LDAPGroupResolutionMemberOf, LDAPGroupResolutionRecursive, LDAPGroupResolutionInChain))
+	}
+	if options.GroupBaseDN != "" {
+		if _, err := ldap.ParseDN(options.GroupBaseDN); err != nil {
+			errs = append(errs, fmt.Errorf("%s.group_base_dn is not a valid DN: %w", prefix, err))
+		}
+	}
+	for i, mapping := range options.GroupMappings {
+		if _, err := ldap.ParseDN(mapping.Group); err != nil || strings.TrimSpace(mapping.Group) == "" {
+			errs = append(errs, fmt.Errorf("%s.group_mappings[%d].group must be a group DN", prefix, i))
+		}
+		if !mapping.IsSuperuser && !mapping.IsStaff {
+			errs = append(errs, fmt.Errorf("%s.group_mappings[%d] must grant is_superuser or is_staff", prefix, i))
+		}
 	}
 	return errors.Join(errs...)
 }
@@ -272,6 +515,255 @@ func validateCAS(options CASOptions) error {
 	return errors.Join(errs...)
 }
 
+func validateSAML(options SAMLOptions) error {
+	var errs []error
+	metadataURL := strings.TrimSpace(options.MetadataURL)
+	metadataFile := strings.TrimSpace(options.MetadataFile)
+	switch {
+	case metadataURL == "" && metadataFile == "":
+		errs = append(errs, errors.New("saml.metadata_url or saml.metadata_file must be configured when SAML authentication is enabled"))
+	case metadataURL != "" && metadataFile != "":
+		errs = append(errs, errors.New("saml.metadata_url and saml.metadata_file are mutually exclusive"))
+	case metadataURL != "":
+		parsed, err := url.Parse(metadataURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, errors.New("saml.metadata_url must be an absolute http:// or https:// URL"))
+		} else if isExampleHost(parsed.Hostname()) {
+			errs = append(errs, errors.New("saml.metadata_url must not use an example.com placeholder host"))
+		}
+	}
+	parsed, err := url.Parse(options.ServerURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		errs = append(errs, errors.New("saml.server_url must be an absolute URL"))
+	} else if isExampleHost(parsed.Hostname()) {
+		errs = append(errs, errors.New("saml.server_url must not use an example.com placeholder host"))
+	}
+	if (strings.TrimSpace(options.SessionCert) == "") != (strings.TrimSpace(options.SessionKey) == "") {
+		errs = append(errs, errors.New("saml.session_certificate and saml.session_key must be configured together"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateOIDC(options OIDCOptions) error {
+	var errs []error
+	for name, value := range map[string]string{"oidc.issuer": options.Issuer, "oidc.redirect_url": options.RedirectURL} {
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute http:// or https:// URL", name))
+		} else if isExampleHost(parsed.Hostname()) {
+			errs = append(errs, fmt.Errorf("%s must not use an example.com placeholder host", name))
+		}
+	}
+	if strings.TrimSpace(options.ClientID) == "" {
+		errs = append(errs, errors.New("oidc.client_id must be configured when OIDC authentication is enabled"))
+	}
+	if !contains(options.Scopes, "openid") {
+		errs = append(errs, errors.New("oidc.scopes must include openid"))
+	}
+	if strings.TrimSpace(options.UsernameClaim) == "" {
+		errs = append(errs, errors.New("oidc.username_claim must be configured when OIDC authentication is enabled"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateCert(options CertOptions, http HTTPOptions) error {
+	var errs []error
+	if strings.TrimSpace(http.TLSClientCAFile) == "" {
+		errs = append(errs, errors.New("http.tls_client_ca_file must be configured when certificate authentication is enabled"))
+	}
+	if len(options.Rules) == 0 {
+		errs = append(errs, errors.New("cert.rules must contain at least one mapping rule"))
+	}
+	for i, rule := range options.Rules {
+		switch rule.Source {
+		case CertSourceSubjectCN, CertSourceSubject, CertSourceSANEmail, CertSourceSANDNS, CertSourceSANURI, CertSourceSANUPN:
+		default:
+			errs = append(errs, fmt.Errorf("cert.rules[%d].source %q is not a supported certificate field", i, rule.Source))
+		}
+		if _, err := regexp.Compile(rule.Pattern()); err != nil || rule.Match == "" {
+			errs = append(errs, fmt.Errorf("cert.rules[%d].match must be a valid regular expression", i))
+		}
+		if strings.TrimSpace(rule.Username) == "" {
+			errs = append(errs, fmt.Errorf("cert.rules[%d].username must be configured", i))
+		}
+	}
+	for _, role := range options.RequiredFor {
+		switch role {
+		case CertRoleSuperuser, CertRoleStaff:
+		default:
+			errs = append(errs, fmt.Errorf("cert.required_for contains %q; only %s and %s are supported", role, CertRoleSuperuser, CertRoleStaff))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateProxy(options ProxyOptions, http HTTPOptions) error {
+	var errs []error
+	if len(http.TrustedProxies) == 0 {
+		errs = append(errs, errors.New("http.trusted_proxies must list the authenticating proxy when proxy authentication is enabled"))
+	}
+	if strings.TrimSpace(options.UserHeader) == "" {
+		errs = append(errs, errors.New("proxy.user_header must be configured when proxy authentication is enabled"))
+	}
+	if strings.TrimSpace(options.GroupsHeader) != "" && options.GroupsSeparator == "" {
+		errs = append(errs, errors.New("proxy.groups_separator must be configured with proxy.groups_header"))
+	}
+	return errors.Join(errs...)
+}
+
+func validatePassword(options PasswordOptions) error {
+	var errs []error
+	if options.MinLength < 8 {
+		errs = append(errs, errors.New("password.min_length must be at least 8"))
+	}
+	if options.MinCharacterClasses < 1 || options.MinCharacterClasses > 4 {
+		errs = append(errs, errors.New("password.min_character_classes must be between 1 and 4"))
+	}
+	if options.ResetTokenTTL <= 0 {
+		errs = append(errs, errors.New("password.reset_token_ttl must be greater than zero"))
+	}
+	if options.ResetURL != "" {
+		parsed, err := url.Parse(options.ResetURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			errs = append(errs, errors.New("password.reset_url must be an absolute http:// or https:// URL"))
+		} else if isExampleHost(parsed.Hostname()) {
+			errs = append(errs, errors.New("password.reset_url must not use an example.com placeholder host"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateSMTP(options SMTPOptions) error {
+	var errs []error
+	if options.Port < 1 || options.Port > 65535 {
+		errs = append(errs, errors.New("smtp.port must be between 1 and 65535"))
+	}
+	if isExampleHost(options.Host) {
+		errs = append(errs, errors.New("smtp.host must not use an example.com placeholder host"))
+	}
+	if _, err := mail.ParseAddress(options.From); err != nil {
+		errs = append(errs, errors.New("smtp.from must be a valid email address when SMTP is configured"))
+	}
+	if options.Username != "" && isPlaceholder(options.Password) {
+		errs = append(errs, errors.New("smtp.password must not be empty or a known placeholder when smtp.username is set"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateMFA(options MFAOptions, providers []AuthProviderOptions) error {
+	var errs []error
+	if strings.TrimSpace(options.Issuer) == "" || strings.Contains(options.Issuer, ":") {
+		errs = append(errs, errors.New("mfa.issuer must be a non-empty name without colons"))
+	}
+	passwordMethods := []string{AuthProviderLocal, AuthProviderLDAP}
+	for _, provider := range providers {
+		if provider.Type == AuthProviderLDAP {
+			passwordMethods = append(passwordMethods, provider.Name)
+		}
+	}
+	for _, method := range options.RequiredMethods {
+		if !contains(passwordMethods, strings.ToLower(strings.TrimSpace(method))) {
+			errs = append(errs, fmt.Errorf("mfa.required_methods contains %q; only local and ldap logins support a second factor", method))
+		}
+	}
+	if key := strings.TrimSpace(options.EncryptionKey); key != "" && (isPlaceholder(key) || len(key) < 32) {
+		errs = append(errs, errors.New("mfa.encryption_key must be a non-placeholder secret of at least 32 characters"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateToken(options TokenOptions) error {
+	var errs []error
+	if options.AccessTokenTTL < time.Minute {
+		errs = append(errs, errors.New("token.access_token_ttl must be at least one minute"))
+	}
+	if options.RefreshTokenTTL < options.AccessTokenTTL {
+		errs = append(errs, errors.New("token.refresh_token_ttl must not be shorter than token.access_token_ttl"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateLockout(options LockoutOptions) error {
+	var errs []error
+	if options.MaxFailures < 0 || options.ClientIPMaxFailures < 0 {
+		errs = append(errs, errors.New("lockout.max_failures and lockout.client_ip_max_failures must not be negative"))
+	}
+	for name, value := range map[string]time.Duration{
+		"lockout.failure_window":   options.FailureWindow,
+		"lockout.lockout_duration": options.LockoutDuration,
+	} {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be greater than zero", name))
+		}
+	}
+	if options.BaseDelay < 0 {
+		errs = append(errs, errors.New("lockout.base_delay must not be negative"))
+	}
+	if options.MaxDelay < options.BaseDelay {
+		errs = append(errs, errors.New("lockout.max_delay must be at least lockout.base_delay"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateAudit(options AuditOptions) error {
+	var errs []error
+	if options.Syslog.Enabled {
+		switch options.Syslog.Network {
+		case "", "udp", "tcp", "unix", "unixgram":
+		default:
+			errs = append(errs, fmt.Errorf("audit.syslog.network %q must be udp, tcp, unix or unixgram", options.Syslog.Network))
+		}
+		if (options.Syslog.Network == "") != (strings.TrimSpace(options.Syslog.Address) == "") {
+			errs = append(errs, errors.New("audit.syslog.network and audit.syslog.address must be configured together"))
+		}
+		if strings.TrimSpace(options.Syslog.Tag) == "" {
+			errs = append(errs, errors.New("audit.syslog.tag must be configured when syslog auditing is enabled"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateRedaction(options RedactionOptions) error {
+	var errs []error
+	for _, path := range options.Paths {
+		segments := strings.Split(path, ".")
+		if len(segments) < 2 || contains(segments, "") {
+			errs = append(errs, fmt.Errorf("redaction.paths entry %q must name a response field and at least one key", path))
+		}
+	}
+	for _, key := range options.Keys {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, errors.New("redaction.keys must not contain empty patterns"))
+		}
+	}
+	for _, value := range options.Values {
+		if _, err := regexp.Compile(value); err != nil {
+			errs = append(errs, fmt.Errorf("redaction.values entry %q is not a valid regular expression: %w", value, err))
+		}
+	}
+	if len(options.Paths)+len(options.Keys)+len(options.Values) > 0 && options.Mask == "" {
+		errs = append(errs, errors.New("redaction.mask must be configured when redaction rules are set"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateJobState(options JobStateOptions) error {
+	if !options.Enabled {
+		return nil
+	}
+	var errs []error
+	for name, value := range map[string]time.Duration{
+		"job_state.interval":      options.Interval,
+		"job_state.timeout":       options.Timeout,
+		"job_state.return_window": options.ReturnWindow,
+	} {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be greater than zero", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func isPlaceholder(value string) bool {
 	normalized := strings.ToLower(strings.TrimSpace(value))
 	if normalized == "" || strings.Contains(normalized, "replace_with") || strings.Contains(normalized, "replace-with") || strings.Contains(normalized, "example.com") || strings.Contains(normalized, "dc=example,") {
@@ -327,5 +819,9 @@ func InitConfig() error {
 	}
 
 	AgarthaConfig = &Config{}
-	return v.Unmarshal(AgarthaConfig)
+	if err := v.Unmarshal(AgarthaConfig); err != nil {
+		return err
+	}
+	AgarthaConfig.Auth.setProviderDefaults()
+	return nil
 }
@@ -48,6 +48,88 @@ func TestValidateForServeRejectsIncompleteLDAPConfiguration(t *testing.T) {
 	require.ErrorContains(t, err, "ldap.base_dn")
 }
 
+func TestValidateForServeRejectsInvalidLDAPGroupMappings(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "ldap"}
+	config.LDAP.Server = "ldaps://directory.example.test:636"
+	config.LDAP.Password = "directory-password"
+	config.LDAP.Filter = "(sAMAccountName=%s)"
+	config.LDAP.GroupResolution = "nested"
+	config.LDAP.GroupMappings = []LDAPGroupMapping{
+		{Group: "not a dn", IsStaff: true},
+		{Group: "cn=salt-admins,ou=groups,dc=example,dc=test"},
+	}
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "ldap.group_resolution")
+	require.ErrorContains(t, err, "ldap.group_mappings[0].group")
+	require.ErrorContains(t, err, "ldap.group_mappings[1] must grant")
+}
+
+func TestValidateForServeLDAPBindModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*LDAPOptions)
+		wantError []string
+	}{
+		{
+			name: "dn template does not need a default domain",
+			configure: func(options *LDAPOptions) {
+				options.BindMode = LDAPBindModeDNTemplate
+				options.BindDNTemplate = "uid=%s,cn=users,cn=accounts,dc=corp,dc=test"
+				options.LDAPDomainDefault = ""
+				options.UsernameAttribute = "uid"
+			},
+		},
+		{
+			name: "dn template needs one placeholder",
+			configure: func(options *LDAPOptions) {
+				options.BindMode = LDAPBindModeDNTemplate
+				options.BindDNTemplate = "uid=alice,cn=users,dc=corp,dc=test"
+			},
+			wantError: []string{"ldap.bind_dn_template must contain exactly one %s"},
+		},
+		{
+			name:      "unknown bind mode",
+			configure: func(options *LDAPOptions) { options.BindMode = "kerberos" },
+			wantError: []string{"ldap.bind_mode"},
+		},
+		{
+			name: "empty attribute and conflicting TLS options",
+			configure: func(options *LDAPOptions) {
+				options.BindMode = LDAPBindModeSearch
+				options.EmailAttribute = " "
+				options.CACert = "/etc/agartha/ldap-ca.pem"
+				options.InsecureSkipVerify = true
+			},
+			wantError: []string{"ldap.email_attribute", "ldap.insecure_skip_verify and ldap.ca_cert"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			config.Auth.Methods = []string{"local", "ldap"}
+			config.LDAP.Server = "ldaps://directory.corp.test:636"
+			config.LDAP.User = "cn=service,dc=corp,dc=test"
+			config.LDAP.Password = "directory-password"
+			config.LDAP.BaseDN = "dc=corp,dc=test"
+			config.LDAP.Filter = "(uid=%s)"
+			config.LDAP.LDAPDomainDefault = "corp.test"
+			tt.configure(&config.LDAP)
+
+			err := config.ValidateForServe()
+			if len(tt.wantError) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			for _, want := range tt.wantError {
+				require.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
 func TestValidateForServeRejectsIncompleteCASConfiguration(t *testing.T) {
 	config := validConfig()
 	config.Auth.Methods = []string{"cas"}
@@ -58,6 +140,199 @@ func TestValidateForServeRejectsIncompleteCASConfiguration(t *testing.T) {
 	require.ErrorContains(t, err, "cas.service_url")
 }
 
+func TestValidateForServeRejectsIncompleteSAMLConfiguration(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"saml"}
+	config.SAML.MetadataURL = "https://idp.example.test/metadata"
+	config.SAML.MetadataFile = "/etc/agartha/idp-metadata.xml"
+	config.SAML.ServerURL = "agartha.example.test"
+	config.SAML.SessionCert = "/etc/agartha/saml.crt"
+	config.SAML.SessionKey = ""
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "saml.metadata_url and saml.metadata_file are mutually exclusive")
+	require.ErrorContains(t, err, "saml.server_url")
+	require.ErrorContains(t, err, "saml.session_key")
+}
+
+func TestValidateForServeAcceptsSAMLMetadataFile(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "saml"}
+	config.SAML.MetadataURL = ""
+	config.SAML.MetadataFile = "/etc/agartha/idp-metadata.xml"
+	config.SAML.ServerURL = "https://agartha.example.test"
+
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsIncompleteOIDCConfiguration(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"oidc"}
+	config.OIDC.Issuer = "https://login.example.com/realms/agartha"
+	config.OIDC.RedirectURL = "/auth/oidc/callback"
+	config.OIDC.Scopes = []string{"profile"}
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "oidc.issuer must not use an example.com placeholder host")
+	require.ErrorContains(t, err, "oidc.redirect_url must be an absolute")
+	require.ErrorContains(t, err, "oidc.client_id")
+	require.ErrorContains(t, err, "oidc.scopes must include openid")
+}
+
+func TestValidateForServeAcceptsOIDCConfiguration(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "oidc"}
+	config.OIDC.Issuer = "https://keycloak.example.test/realms/agartha"
+	config.OIDC.ClientID = "agartha"
+	config.OIDC.RedirectURL = "https://agartha.example.test/auth/oidc/callback"
+
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsIncompleteCertConfiguration(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"cert"}
+	config.Cert.Rules = []CertMappingRule{{Source: "issuer", Match: "(", Username: ""}}
+	config.Cert.RequiredFor = []string{"admin"}
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "http.tls_client_ca_file must be configured")
+	require.ErrorContains(t, err, "cert.rules[0].source")
+	require.ErrorContains(t, err, "cert.rules[0].match")
+	require.ErrorContains(t, err, "cert.rules[0].username")
+	require.ErrorContains(t, err, "cert.required_for")
+}
+
+func TestValidateForServeAcceptsCertConfiguration(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "cert"}
+	config.HTTP.TLSCertFile = "/cert.pem"
+	config.HTTP.TLSKeyFile = "/key.pem"
+	config.HTTP.TLSClientCAFile = "/client-ca.pem"
+	config.Cert.RequiredFor = []string{CertRoleSuperuser}
+
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRequiresTrustedProxyForProxyAuthentication(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"proxy"}
+	config.Proxy.UserHeader = " "
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "http.trusted_proxies must list the authenticating proxy")
+	require.ErrorContains(t, err, "proxy.user_header")
+
+	config.HTTP.TrustedProxies = []string{"10.0.0.1"}
+	config.Proxy.UserHeader = "X-Forwarded-User"
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsWeakPasswordPolicy(t *testing.T) {
+	config := validConfig()
+	config.Password.MinLength = 6
+	config.Password.MinCharacterClasses = 5
+	config.Password.ResetTokenTTL = 0
+	config.Password.ResetURL = "agartha.example.test/reset"
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "password.min_length")
+	require.ErrorContains(t, err, "password.min_character_classes")
+	require.ErrorContains(t, err, "password.reset_token_ttl")
+	require.ErrorContains(t, err, "password.reset_url")
+}
+
+func TestValidateForServeValidatesSMTPOnlyWhenConfigured(t *testing.T) {
+	config := validConfig()
+	config.SMTP.From = "not an address"
+	require.NoError(t, config.ValidateForServe())
+
+	config.SMTP.Host = "smtp.example.test"
+	config.SMTP.Username = "agartha"
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "smtp.from")
+	require.ErrorContains(t, err, "smtp.password")
+
+	config.SMTP.From = "Agartha <agartha@example.test>"
+	config.SMTP.Password = "a-unique-smtp-password"
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsMFAForRedirectMethods(t *testing.T) {
+	config := validConfig()
+	config.MFA.RequiredMethods = []string{"local", "oidc"}
+	config.MFA.EncryptionKey = "changeme"
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, `mfa.required_methods contains "oidc"`)
+	require.ErrorContains(t, err, "mfa.encryption_key")
+
+	config.MFA.RequiredMethods = []string{"ldap"}
+	config.MFA.EncryptionKey = ""
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsInvertedTokenLifetimes(t *testing.T) {
+	config := validConfig()
+	config.Token.AccessTokenTTL = time.Hour
+	config.Token.RefreshTokenTTL = 30 * time.Minute
+
+	require.ErrorContains(t, config.ValidateForServe(), "token.refresh_token_ttl must not be shorter than token.access_token_ttl")
+}
+
+func TestValidateForServeRejectsInvalidLockoutPolicy(t *testing.T) {
+	config := validConfig()
+	config.Lockout.MaxFailures = -1
+	config.Lockout.FailureWindow = 0
+	config.Lockout.BaseDelay = time.Minute
+	config.Lockout.MaxDelay = time.Second
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "lockout.max_failures")
+	require.ErrorContains(t, err, "lockout.failure_window")
+	require.ErrorContains(t, err, "lockout.max_delay")
+}
+
+func TestValidateForServeRejectsInvalidRedactionRules(t *testing.T) {
+	config := validConfig()
+	require.NoError(t, config.ValidateForServe())
+
+	config.Redaction.Paths = []string{"pillar", "pillar..password"}
+	config.Redaction.Values = []string{"(unclosed"}
+	config.Redaction.Mask = ""
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, `redaction.paths entry "pillar"`)
+	require.ErrorContains(t, err, `redaction.paths entry "pillar..password"`)
+	require.ErrorContains(t, err, `redaction.values entry "(unclosed"`)
+	require.ErrorContains(t, err, "redaction.mask")
+}
+
+func TestValidateForServeRejectsInvalidJobStateDurations(t *testing.T) {
+	config := validConfig()
+	config.JobState.Interval = 0
+	config.JobState.Timeout = -time.Minute
+	config.JobState.ReturnWindow = 0
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "job_state.interval must be greater than zero")
+	require.ErrorContains(t, err, "job_state.timeout must be greater than zero")
+	require.ErrorContains(t, err, "job_state.return_window must be greater than zero")
+
+	config.JobState.Enabled = false
+	require.NoError(t, config.ValidateForServe())
+}
+
+func TestValidateForServeRejectsInvalidAuditSyslog(t *testing.T) {
+	config := validConfig()
+	config.Audit.Syslog = AuditSyslogOptions{Enabled: true, Network: "http"}
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, `audit.syslog.network "http"`)
+	require.ErrorContains(t, err, "audit.syslog.network and audit.syslog.address must be configured together")
+	require.ErrorContains(t, err, "audit.syslog.tag")
+}
+
 func TestValidateForServeRejectsExampleAuthenticationEndpoints(t *testing.T) {
 	config := validConfig()
 	config.Auth.Methods = []string{"ldap", "cas"}
@@ -72,7 +347,7 @@ func TestValidateForServeRejectsExampleAuthenticationEndpoints(t *testing.T) {
 
 func TestEffectiveAuthMethodsRejectsUnsupportedProvider(t *testing.T) {
 	config := validConfig()
-	config.Auth.Methods = []string{"local", "oidc"}
+	config.Auth.Methods = []string{"local", "kerberos"}
 
 	_, err := config.EffectiveAuthMethods()
 	require.ErrorContains(t, err, "unsupported method")
@@ -123,3 +398,49 @@ func TestInitConfigDecodesDurationAndAuthMethodEnvironmentValues(t *testing.T) {
 	require.Equal(t, 17*time.Second, AgarthaConfig.HTTP.ReadTimeout)
 	require.Equal(t, []string{"local", "ldap"}, AgarthaConfig.Auth.Methods)
 }
+
+func TestEffectiveAuthMethodsAcceptsNamedProviders(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "corp"}
+	config.Auth.Providers = []AuthProviderOptions{{Name: "corp", Type: AuthProviderLDAP}}
+
+	methods, err := config.EffectiveAuthMethods()
+	require.NoError(t, err)
+	require.Equal(t, []string{"local", "corp"}, methods)
+
+	config.Auth.Methods = []string{"local", "partners"}
+	_, err = config.EffectiveAuthMethods()
+	require.ErrorContains(t, err, `unsupported method "partners"`)
+}
+
+func TestValidateForServeValidatesNamedLDAPProviders(t *testing.T) {
+	config := validConfig()
+	config.Auth.Methods = []string{"local", "corp"}
+	config.Auth.Providers = []AuthProviderOptions{
+		{Name: "corp", Type: AuthProviderLDAP, LDAP: LDAPOptions{Server: "ldaps://corp.example.test:636"}},
+		{Name: "corp", Type: AuthProviderLDAP},
+		{Name: "ldap", Type: AuthProviderLDAP},
+		{Name: "Partners", Type: AuthProviderSAML},
+	}
+	config.Auth.setProviderDefaults()
+
+	err := config.ValidateForServe()
+	require.ErrorContains(t, err, "auth.providers[0].ldap.base_dn")
+	require.ErrorContains(t, err, `auth.providers[1].name "corp" is configured more than once`)
+	require.ErrorContains(t, err, `auth.providers[2].name "ldap" is reserved`)
+	require.ErrorContains(t, err, "auth.providers[3].name")
+	require.ErrorContains(t, err, `auth.providers[3].type "saml" is not supported`)
+}
+
+func TestSetProviderDefaultsUsesLDAPDefaults(t *testing.T) {
+	options := AuthOptions{Providers: []AuthProviderOptions{
+		{Name: "corp", Type: AuthProviderLDAP, LDAP: LDAPOptions{UsernameAttribute: "uid"}},
+	}}
+	options.setProviderDefaults()
+
+	defaults := NewConfig().LDAP
+	require.Equal(t, "uid", options.Providers[0].LDAP.UsernameAttribute)
+	require.Equal(t, defaults.BindMode, options.Providers[0].LDAP.BindMode)
+	require.Equal(t, defaults.EmailAttribute, options.Providers[0].LDAP.EmailAttribute)
+	require.Equal(t, defaults.GroupResolution, options.Providers[0].LDAP.GroupResolution)
+}
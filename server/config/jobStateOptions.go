@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// JobStateOptions configures the worker that records whether each job is
+// pending, running, complete, partial or timed out. A job that has not heard
+// from every targeted minion within Timeout of its load being stored is
+// partial, or timed out when no minion answered. The worker looks for new
+// and open jobs every Interval. Partial and timed out jobs started within
+// ReturnWindow are checked again when late returns arrive for them.
+type JobStateOptions struct {
+	Enabled      bool          `mapstructure:"enabled" yaml:"enabled"`
+	Interval     time.Duration `mapstructure:"interval" yaml:"interval"`
+	Timeout      time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	ReturnWindow time.Duration `mapstructure:"return_window" yaml:"return_window"`
+}
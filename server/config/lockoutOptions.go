@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+// LockoutOptions throttles failed local and LDAP logins. Each failure for a
+// username delays the next attempt by BaseDelay, doubling up to MaxDelay.
+// Reaching MaxFailures within FailureWindow locks the username for
+// LockoutDuration; a client IP address is locked the same way after
+// ClientIPMaxFailures. A threshold of zero disables that lockout.
+type LockoutOptions struct {
+	MaxFailures         int           `mapstructure:"max_failures" yaml:"max_failures"`
+	ClientIPMaxFailures int           `mapstructure:"client_ip_max_failures" yaml:"client_ip_max_failures"`
+	FailureWindow       time.Duration `mapstructure:"failure_window" yaml:"failure_window"`
+	LockoutDuration     time.Duration `mapstructure:"lockout_duration" yaml:"lockout_duration"`
+	BaseDelay           time.Duration `mapstructure:"base_delay" yaml:"base_delay"`
+	MaxDelay            time.Duration `mapstructure:"max_delay" yaml:"max_delay"`
+}
@@ -0,0 +1,7 @@
+package config
+
+type MFAOptions struct {
+	Issuer          string   `mapstructure:"issuer" yaml:"issuer"`
+	RequiredMethods []string `mapstructure:"required_methods" yaml:"required_methods"`
+	EncryptionKey   string   `mapstructure:"encryption_key" yaml:"encryption_key"`
+}
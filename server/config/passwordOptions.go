@@ -0,0 +1,10 @@
+package config
+
+import "time"
+
+type PasswordOptions struct {
+	MinLength           int           `mapstructure:"min_length" yaml:"min_length"`
+	MinCharacterClasses int           `mapstructure:"min_character_classes" yaml:"min_character_classes"`
+	ResetTokenTTL       time.Duration `mapstructure:"reset_token_ttl" yaml:"reset_token_ttl"`
+	ResetURL            string        `mapstructure:"reset_url" yaml:"reset_url"`
+}
@@ -0,0 +1,14 @@
+package config
+
+// RedactionOptions masks secrets in grains, pillar, job, return, event and
+// Salt cache data before it is sent to clients. Paths are dot-separated and
+// start with the response field, such as pillar.mysql.*.password or load.arg.
+// Keys match object keys at any depth, ignoring case. Path and key patterns
+// accept * and ? wildcards. Values are regular expressions matched against
+// string values. Matching values are replaced with Mask.
+type RedactionOptions struct {
+	Paths  []string `mapstructure:"paths" yaml:"paths"`
+	Keys   []string `mapstructure:"keys" yaml:"keys"`
+	Values []string `mapstructure:"values" yaml:"values"`
+	Mask   string   `mapstructure:"mask" yaml:"mask"`
+}
@@ -0,0 +1,10 @@
+package config
+
+type SMTPOptions struct {
+	Host        string `mapstructure:"host" yaml:"host"`
+	Port        int    `mapstructure:"port" yaml:"port"`
+	Username    string `mapstructure:"username" yaml:"username"`
+	Password    string `mapstructure:"password" yaml:"password"`
+	From        string `mapstructure:"from" yaml:"from"`
+	ImplicitTLS bool   `mapstructure:"implicit_tls" yaml:"implicit_tls"`
+}
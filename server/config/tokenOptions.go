@@ -0,0 +1,11 @@
+package config
+
+import "time"
+
+// TokenOptions controls the lifetime of issued credentials. Access tokens are
+// short-lived bearer JWTs; refresh tokens rotate on every use and also bound
+// the lifetime of the browser session.
+type TokenOptions struct {
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl" yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl" yaml:"refresh_token_ttl"`
+}
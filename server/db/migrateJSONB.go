@@ -122,6 +122,76 @@ func MigrateJSONB(options config.SaltDBTables) error {
 			return err
 		}
 
+		// Configure PasswordResetToken
+		err = DB.AutoMigrate(&agartha.PasswordResetToken{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure UserMFA
+		err = DB.AutoMigrate(&agartha.UserMFA{}, &agartha.MFARecoveryCode{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure APIToken
+		err = DB.AutoMigrate(&agartha.APIToken{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure RefreshToken
+		err = DB.AutoMigrate(&agartha.RefreshToken{}, &agartha.RevokedToken{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure SAMLAssertion
+		err = DB.AutoMigrate(&agartha.SAMLAssertion{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure UserGroup
+		err = DB.AutoMigrate(&agartha.UserGroup{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure LoginFailure
+		err = DB.AutoMigrate(&agartha.LoginFailure{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure AuditLog
+		err = DB.AutoMigrate(&agartha.AuditLog{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure Role and Group
+		err = DB.AutoMigrate(&agartha.Role{}, &agartha.RoleCapability{}, &agartha.Group{}, &agartha.GroupRole{}, &agartha.GroupMember{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
+		// Configure JobState
+		err = DB.AutoMigrate(&agartha.JobState{})
+		if err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
 		if err := ensureSaltHighstatesView(options.SaltReturns); err != nil {
 			log.Printf("Error during migration: %v", err)
 			return err
@@ -132,6 +202,11 @@ func MigrateJSONB(options config.SaltDBTables) error {
 			return err
 		}
 
+		if err := ensureJIDMetadataTrigger(options.JIDs); err != nil {
+			log.Printf("Error during migration: %v", err)
+			return err
+		}
+
 		exec := DB.Exec(`REFRESH MATERIALIZED VIEW mat_salt_cache_data_keys;`)
 		if exec.Error != nil {
 			log.Printf("Error during migration: %v", exec.Error)
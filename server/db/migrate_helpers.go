@@ -150,6 +150,31 @@ func ensureSaltHighstatesView(saltReturnsTable string) error {
     ) a
     WHERE
         (a.fun::text = 'state.highstate'::text OR a.fun::text = 'state.apply'::text) AND
-        (a.full_ret::jsonb ->> 'fun_args'::text) = '[]'::text`, saltReturnsTable)
+        (a.full_ret::jsonb ->> 'fun_args'::text) = '[]'::text
+    ORDER BY a.id, a.jid DESC`, saltReturnsTable)
+	return DB.Exec(query).Error
+}
+
+// ensureJIDMetadataTrigger keeps jid_metadata in step with the configured jids
+// table. The first time the trigger is installed, existing jobs are copied.
+func ensureJIDMetadataTrigger(jidsTable string) error {
+	var installed bool
+	err := DB.Raw(`SELECT EXISTS (
+        SELECT 1 FROM pg_trigger
+        WHERE tgname = 'trigger_sync_jid_metadata' AND tgrelid = to_regclass(?)
+    )`, jidsTable).Scan(&installed).Error
+	if err != nil || installed {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+    CREATE TRIGGER trigger_sync_jid_metadata
+    AFTER INSERT OR UPDATE OR DELETE ON %s
+    FOR EACH ROW
+    EXECUTE FUNCTION sync_jid_metadata();
+    INSERT INTO jid_metadata
+    SELECT m.*
+    FROM %s j, LATERAL jid_metadata_row(j.jid, j.load::text, j.alter_time) m
+    ON CONFLICT (jid) DO NOTHING;`, jidsTable, jidsTable)
 	return DB.Exec(query).Error
 }
@@ -0,0 +1,4 @@
+-- Dropping the trigger function also drops the trigger on the jids table.
+DROP FUNCTION IF EXISTS sync_jid_metadata() CASCADE;
+DROP FUNCTION IF EXISTS jid_metadata_row(text, text, timestamptz);
+DROP TABLE IF EXISTS jid_metadata;
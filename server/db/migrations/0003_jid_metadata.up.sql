@@ -0,0 +1,80 @@
+-- Job metadata extracted from job loads, so jobs can be filtered by function,
+-- target or user without reading every load. The trigger keeping it current
+-- is installed on the configured jids table at startup.
+
+CREATE TABLE IF NOT EXISTS jid_metadata (
+	jid varchar(20) PRIMARY KEY,
+	fun text,
+	tgt text,
+	tgt_type text,
+	"user" text,
+	arg jsonb,
+	minion_count integer,
+	alter_time timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS jid_metadata_fun_idx ON jid_metadata (fun text_pattern_ops);
+CREATE INDEX IF NOT EXISTS jid_metadata_tgt_idx ON jid_metadata (tgt text_pattern_ops);
+CREATE INDEX IF NOT EXISTS jid_metadata_user_idx ON jid_metadata ("user" text_pattern_ops);
+CREATE INDEX IF NOT EXISTS jid_metadata_alter_time_idx ON jid_metadata (alter_time);
+
+-- A load that is not a JSON object yields a row with only jid and alter_time,
+-- so a malformed load never fails the insert into jids. Lists of functions
+-- and list targets are joined with commas.
+CREATE OR REPLACE FUNCTION jid_metadata_row(p_jid text, p_load text, p_alter_time timestamptz)
+RETURNS jid_metadata AS $$
+DECLARE
+	parsed jsonb;
+	result jid_metadata;
+BEGIN
+	result.jid := p_jid;
+	result.alter_time := p_alter_time;
+	BEGIN
+		parsed := p_load::jsonb;
+	EXCEPTION WHEN others THEN
+		RETURN result;
+	END;
+	IF jsonb_typeof(parsed) IS DISTINCT FROM 'object' THEN
+		RETURN result;
+	END IF;
+
+	result.fun := CASE jsonb_typeof(parsed -> 'fun')
+		WHEN 'array' THEN (SELECT string_agg(value, ',') FROM jsonb_array_elements_text(parsed -> 'fun'))
+		ELSE parsed ->> 'fun'
+	END;
+	result.tgt := CASE jsonb_typeof(parsed -> 'tgt')
+		WHEN 'array' THEN (SELECT string_agg(value, ',') FROM jsonb_array_elements_text(parsed -> 'tgt'))
+		ELSE parsed ->> 'tgt'
+	END;
+	result.tgt_type := COALESCE(parsed ->> 'tgt_type', parsed ->> 'expr_form');
+	result."user" := parsed ->> 'user';
+	result.arg := parsed -> 'arg';
+	IF jsonb_typeof(parsed -> 'minions') = 'array' THEN
+		result.minion_count := jsonb_array_length(parsed -> 'minions');
+	END IF;
+	RETURN result;
+END;
+$$ LANGUAGE plpgsql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION sync_jid_metadata()
+RETURNS TRIGGER AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		DELETE FROM jid_metadata
+		WHERE jid = OLD.jid;
+		RETURN OLD;
+	END IF;
+
+	INSERT INTO jid_metadata
+	SELECT * FROM jid_metadata_row(NEW.jid, NEW.load::text, NEW.alter_time)
+	ON CONFLICT (jid) DO UPDATE SET
+		fun = EXCLUDED.fun,
+		tgt = EXCLUDED.tgt,
+		tgt_type = EXCLUDED.tgt_type,
+		"user" = EXCLUDED."user",
+		arg = EXCLUDED.arg,
+		minion_count = EXCLUDED.minion_count,
+		alter_time = EXCLUDED.alter_time;
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+DROP VIEW IF EXISTS vw_salt_highstate_states;
+DROP FUNCTION IF EXISTS salt_highstate_states(jsonb);
@@ -0,0 +1,46 @@
+-- Highstate returns flattened into one row per state. Return keys have the
+-- form module_|-id_|-name_|-function; the __id__ recorded by Salt is preferred
+-- when present because state ids may themselves contain "_|-".
+
+CREATE OR REPLACE FUNCTION salt_highstate_states(ret jsonb)
+RETURNS TABLE (
+	state_key text,
+	state_id text,
+	fun text,
+	name text,
+	sls text,
+	run_num integer,
+	result boolean,
+	comment text,
+	duration double precision,
+	start_time text,
+	has_changes boolean
+) AS $$
+	SELECT
+		item.key,
+		COALESCE(item.value ->> '__id__', split_part(item.key, '_|-', 2)),
+		split_part(item.key, '_|-', 1) || '.' || split_part(item.key, '_|-', 4),
+		COALESCE(item.value ->> 'name', split_part(item.key, '_|-', 3)),
+		item.value ->> '__sls__',
+		CASE WHEN jsonb_typeof(item.value -> '__run_num__') = 'number' THEN (item.value ->> '__run_num__')::numeric::integer END,
+		CASE WHEN jsonb_typeof(item.value -> 'result') = 'boolean' THEN (item.value ->> 'result')::boolean END,
+		CASE
+			WHEN jsonb_typeof(item.value -> 'comment') = 'string' THEN item.value ->> 'comment'
+			ELSE (item.value -> 'comment')::text
+		END,
+		-- Older Salt releases report durations as strings such as "12.3 ms".
+		substring(item.value ->> 'duration' FROM '^\s*([0-9]+(\.[0-9]+)?)')::double precision,
+		item.value ->> 'start_time',
+		COALESCE(item.value -> 'changes', '{}'::jsonb) NOT IN ('{}'::jsonb, '[]'::jsonb, 'null'::jsonb, '""'::jsonb)
+	FROM jsonb_each(CASE WHEN jsonb_typeof(ret) = 'object' THEN ret ELSE '{}'::jsonb END) AS item(key, value)
+	WHERE jsonb_typeof(item.value) = 'object'
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE VIEW vw_salt_highstate_states AS
+SELECT
+	vw_salt_highstates.id,
+	vw_salt_highstates.jid,
+	vw_salt_highstates.alter_time,
+	states.*
+FROM vw_salt_highstates
+	CROSS JOIN LATERAL salt_highstate_states(vw_salt_highstates.return) AS states;
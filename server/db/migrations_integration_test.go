@@ -51,16 +51,18 @@ func TestSQLMigrationsSaltCacheCompatibility(t *testing.T) {
 	})
 
 	require.NoError(t, migrator.Up())
-	requireMigrationVersion(t, migrator, 2)
+	requireMigrationVersion(t, migrator, 4)
 	requireMinionFixtures(t, verificationDB)
 	requireMaterializedKeys(t, verificationDB)
+	requireJIDMetadataSync(t, verificationDB)
+	requireHighstateStates(t, verificationDB)
 
-	require.NoError(t, migrator.Steps(-1))
+	require.NoError(t, migrator.Steps(-3))
 	requireMigrationVersion(t, migrator, 1)
 	requireLegacyViewAfterDown(t, verificationDB)
 
-	require.NoError(t, migrator.Steps(1))
-	requireMigrationVersion(t, migrator, 2)
+	require.NoError(t, migrator.Steps(3))
+	requireMigrationVersion(t, migrator, 4)
 	requireMinionFixtures(t, verificationDB)
 	requireMaterializedKeys(t, verificationDB)
 }
@@ -96,11 +98,18 @@ func createMigrationBaseline(t *testing.T, database *sql.DB) {
 		CREATE VIEW vw_salt_highstates AS
 		SELECT
 			NULL::text AS id,
+			NULL::varchar(20) AS jid,
 			NULL::timestamptz AS alter_time,
 			NULL::boolean AS success,
 			NULL::jsonb AS return
 		WHERE false;
 
+		CREATE TABLE jids (
+			jid varchar(20) PRIMARY KEY,
+			load text NOT NULL,
+			alter_time timestamptz NOT NULL DEFAULT now()
+		);
+
 		CREATE TABLE sessions (id text PRIMARY KEY);
 		CREATE TABLE session_user_map (session_id text NOT NULL, user_id bigint NOT NULL);
 		CREATE TABLE user_settings (user_id bigint PRIMARY KEY, token text NOT NULL DEFAULT '');
@@ -274,3 +283,86 @@ func requireLegacyViewAfterDown(t *testing.T, database *sql.DB) {
 	).Scan(&modernCount))
 	require.Zero(t, modernCount)
 }
+
+func requireJIDMetadataSync(t *testing.T, database *sql.DB) {
+	t.Helper()
+
+	_, err := database.Exec(`
+		CREATE TRIGGER trigger_sync_jid_metadata
+		AFTER INSERT OR UPDATE OR DELETE ON jids
+		FOR EACH ROW
+		EXECUTE FUNCTION sync_jid_metadata();
+
+		INSERT INTO jids (jid, load) VALUES
+			('20260701010000000001', '{"fun": "state.apply", "arg": ["web"], "tgt": ["web1", "web2"], "tgt_type": "list", "user": "alice", "minions": ["web1", "web2"]}'),
+			('20260701010000000002', '{"fun": ["test.ping", "grains.items"], "tgt": "db*", "expr_form": "glob", "user": "bob"}'),
+			('20260701010000000003', 'not json');
+	`)
+	require.NoError(t, err)
+
+	type metadata struct {
+		JID, Fun, Target, TargetType, User string
+		MinionCount                        sql.NullInt64
+	}
+	rows, err := database.Query(`
+		SELECT jid, COALESCE(fun, ''), COALESCE(tgt, ''), COALESCE(tgt_type, ''), COALESCE("user", ''), minion_count
+		FROM jid_metadata
+		ORDER BY jid
+	`)
+	require.NoError(t, err)
+	var actual []metadata
+	for rows.Next() {
+		var row metadata
+		require.NoError(t, rows.Scan(&row.JID, &row.Fun, &row.Target, &row.TargetType, &row.User, &row.MinionCount))
+		actual = append(actual, row)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Equal(t, []metadata{
+		{JID: "20260701010000000001", Fun: "state.apply", Target: "web1,web2", TargetType: "list", User: "alice", MinionCount: sql.NullInt64{Int64: 2, Valid: true}},
+		{JID: "20260701010000000002", Fun: "test.ping,grains.items", Target: "db*", TargetType: "glob", User: "bob"},
+		{JID: "20260701010000000003"},
+	}, actual)
+
+	_, err = database.Exec(`DELETE FROM jids WHERE jid = '20260701010000000003'`)
+	require.NoError(t, err)
+	var remaining int
+	require.NoError(t, database.QueryRow(`SELECT count(*) FROM jid_metadata`).Scan(&remaining))
+	require.Equal(t, 2, remaining)
+}
+
+func requireHighstateStates(t *testing.T, database *sql.DB) {
+	t.Helper()
+
+	rows, err := database.Query(`
+		SELECT state_id, fun, name, COALESCE(sls, ''), result, duration, has_changes
+		FROM salt_highstate_states($1::jsonb)
+		ORDER BY state_id
+	`, `{
+		"pkg_|-nginx_|-nginx_|-installed": {"__id__": "nginx", "__sls__": "web.nginx", "__run_num__": 0, "name": "nginx", "result": true, "comment": "Installed", "duration": 812.4, "start_time": "10:00:00.000000", "changes": {"nginx": {"new": "1.24", "old": ""}}},
+		"service_|-nginx_service_|-nginx_|-running": {"__id__": "nginx_service", "__sls__": "web.nginx", "__run_num__": 1, "name": "nginx", "result": false, "comment": ["Service nginx failed to start"], "duration": "15.5 ms", "changes": {}},
+		"test_|-noop_|-noop_|-nop": {"result": null, "changes": {}},
+		"retcode": 2
+	}`)
+	require.NoError(t, err)
+
+	type state struct {
+		ID, Fun, Name, SLS string
+		Result             sql.NullBool
+		Duration           sql.NullFloat64
+		HasChanges         bool
+	}
+	var actual []state
+	for rows.Next() {
+		var row state
+		require.NoError(t, rows.Scan(&row.ID, &row.Fun, &row.Name, &row.SLS, &row.Result, &row.Duration, &row.HasChanges))
+		actual = append(actual, row)
+	}
+	require.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+	require.Equal(t, []state{
+		{ID: "nginx", Fun: "pkg.installed", Name: "nginx", SLS: "web.nginx", Result: sql.NullBool{Bool: true, Valid: true}, Duration: sql.NullFloat64{Float64: 812.4, Valid: true}, HasChanges: true},
+		{ID: "nginx_service", Fun: "service.running", Name: "nginx", SLS: "web.nginx", Result: sql.NullBool{Valid: true}, Duration: sql.NullFloat64{Float64: 15.5, Valid: true}},
+		{ID: "noop", Fun: "test.nop", Name: "noop"},
+	}, actual)
+}
@@ -0,0 +1,9 @@
+package dto
+
+import model "github.com/PaulChristophel/agartha/server/model/agartha"
+
+// AuditLogPageResponse structures the paginated response for audit log queries.
+type AuditLogPageResponse struct {
+	Paging  PageResponse     `json:"paging"`
+	Results []model.AuditLog `json:"results"` // Array of audit log entries
+}
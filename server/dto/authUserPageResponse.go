@@ -0,0 +1,9 @@
+package dto
+
+import model "github.com/PaulChristophel/agartha/server/model/agartha"
+
+// AuthUserPageResponse structures the paginated response for Agartha user queries.
+type AuthUserPageResponse struct {
+	Paging  PageResponse     `json:"paging"`
+	Results []model.AuthUser `json:"results"` // Array of Agartha users
+}
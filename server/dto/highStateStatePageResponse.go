@@ -0,0 +1,9 @@
+package dto
+
+import model "github.com/PaulChristophel/agartha/server/model/salt/view"
+
+// HighStateStatePageResponse structures the paginated response for highstate state queries.
+type HighStateStatePageResponse struct {
+	Paging  PageResponse           `json:"paging"`
+	Results []model.HighStateState `json:"results"` // Array of states from highstate returns
+}
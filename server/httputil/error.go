@@ -50,11 +50,21 @@ type HTTPError406 struct {
 	Message string `json:"message" example:"Not Acceptable"`
 }
 
+type HTTPError409 struct {
+	Code    int    `json:"code" example:"409"`
+	Message string `json:"message" example:"Conflict"`
+}
+
 type HTTPError413 struct {
 	Code    int    `json:"code" example:"413"`
 	Message string `json:"message" example:"Request Entity Too Large"`
 }
 
+type HTTPError429 struct {
+	Code    int    `json:"code" example:"429"`
+	Message string `json:"message" example:"Too Many Requests"`
+}
+
 type HTTPError500 struct {
 	Code    int    `json:"code" example:"500"`
 	Message string `json:"message" example:"Internal Server Error"`
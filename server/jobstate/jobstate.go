@@ -0,0 +1,172 @@
+// Package jobstate records in the job_state table whether each Salt job is
+// pending, running, complete, partial or timed out.
+package jobstate
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize bounds how many untracked jobs are read at a time, so the first
+// run against a long job history does not load it all at once.
+const batchSize = 500
+
+// job is the part of a jids row the worker needs.
+type job struct {
+	JID       string `gorm:"column:jid"`
+	Minions   *string
+	AlterTime *time.Time
+}
+
+// Run updates job states every options.Interval until ctx is done. It returns
+// at once when the worker is disabled.
+func Run(ctx context.Context, database *gorm.DB, tables config.SaltDBTables, options config.JobStateOptions) {
+	if !options.Enabled {
+		return
+	}
+	log := logger.GetLogger()
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+	for {
+		if err := Update(database, tables, options, time.Now()); err != nil {
+			log.Error("Failed to update job states", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update records the state of every job not yet tracked and refreshes the
+// state of every pending or running job, as of now. Partial and timed out
+// jobs started within options.ReturnWindow are refreshed as well when a
+// return arrived after their state was last recorded, so that late minions
+// still complete them.
+func Update(database *gorm.DB, tables config.SaltDBTables, options config.JobStateOptions, now time.Time) error {
+	for {
+		var jobs []job
+		err := jobQuery(database, tables).
+			Where("NOT EXISTS (SELECT 1 FROM job_state s WHERE s.jid = j.jid)").
+			Order("j.jid").
+			Limit(batchSize).
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+		if err := save(database, tables, jobs, options.Timeout, now); err != nil {
+			return err
+		}
+		if len(jobs) < batchSize {
+			break
+		}
+	}
+
+	var jobs []job
+	err := jobQuery(database, tables).
+		Joins("JOIN job_state s ON s.jid = j.jid").
+		Where("s.state IN ? OR (s.state IN ? AND s.start_time >= ? AND EXISTS (SELECT 1 FROM "+tables.SaltReturns+" r WHERE r.jid = j.jid AND r.alter_time > s.updated_at))",
+			[]model.JobStatus{model.JobPending, model.JobRunning},
+			[]model.JobStatus{model.JobPartial, model.JobTimedOut},
+			now.Add(-options.ReturnWindow)).
+		Order("j.jid").
+		Find(&jobs).Error
+	if err != nil {
+		return err
+	}
+	return save(database, tables, jobs, options.Timeout, now)
+}
+
+func jobQuery(database *gorm.DB, tables config.SaltDBTables) *gorm.DB {
+	return database.Table(tables.JIDs+" AS j").Select("j.jid", "j.load::jsonb->>'minions' AS minions", "j.alter_time")
+}
+
+func save(database *gorm.DB, tables config.SaltDBTables, jobs []job, timeout time.Duration, now time.Time) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	jids := make([]string, len(jobs))
+	for i, job := range jobs {
+		jids[i] = job.JID
+	}
+
+	var returns []struct {
+		JID string `gorm:"column:jid"`
+		ID  string
+	}
+	if err := database.Table(tables.SaltReturns).Select("DISTINCT jid, id").Where("jid IN ?", jids).Find(&returns).Error; err != nil {
+		return err
+	}
+	returned := make(map[string][]string, len(jobs))
+	for _, ret := range returns {
+		returned[ret.JID] = append(returned[ret.JID], ret.ID)
+	}
+
+	states := make([]model.JobState, len(jobs))
+	for i, job := range jobs {
+		states[i] = Compute(job.JID, expectedMinions(job.Minions), returned[job.JID], job.AlterTime, now, timeout)
+		// Returns stored after now, even while this pass runs, are newer
+		// than the recorded state and bring closed jobs back next time.
+		states[i].UpdatedAt = now
+	}
+	return database.Clauses(clause.OnConflict{UpdateAll: true}).Create(&states).Error
+}
+
+// expectedMinions decodes the minions list of a job load. It returns nil when
+// the load has no list, as for runner and wheel jobs.
+func expectedMinions(encoded *string) []string {
+	if encoded == nil {
+		return nil
+	}
+	var minions []string
+	if err := json.Unmarshal([]byte(*encoded), &minions); err != nil {
+		return nil
+	}
+	return minions
+}
+
+// Compute derives a job's state from the minions its load expects, the
+// minions that returned and the time its load was stored. A job whose load
+// lists no minions, such as a runner job, is complete at its first return.
+func Compute(jid string, expected []string, returned []string, started *time.Time, now time.Time, timeout time.Duration) model.JobState {
+	seen := make(map[string]bool, len(returned))
+	for _, minion := range returned {
+		seen[minion] = true
+	}
+	state := model.JobState{JID: jid, Expected: len(expected), Returned: len(seen), StartTime: started}
+
+	complete := len(seen) > 0
+	if expected != nil {
+		complete = true
+		for _, minion := range expected {
+			if !seen[minion] {
+				complete = false
+				break
+			}
+		}
+	}
+
+	switch {
+	case complete:
+		state.State = model.JobComplete
+	case started != nil && now.Sub(*started) < timeout:
+		state.State = model.JobPending
+		if len(seen) > 0 {
+			state.State = model.JobRunning
+		}
+	case len(seen) > 0:
+		state.State = model.JobPartial
+	default:
+		state.State = model.JobTimedOut
+	}
+	return state
+}
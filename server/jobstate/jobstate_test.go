@@ -0,0 +1,129 @@
+package jobstate
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/config"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestCompute(t *testing.T) {
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	soon := started.Add(time.Minute)
+	late := started.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		expected []string
+		returned []string
+		now      time.Time
+		want     model.JobStatus
+	}{
+		{name: "no returns yet", expected: []string{"web01", "web02"}, now: soon, want: model.JobPending},
+		{name: "some returns", expected: []string{"web01", "web02"}, returned: []string{"web01"}, now: soon, want: model.JobRunning},
+		{name: "every minion returned", expected: []string{"web01", "web02"}, returned: []string{"web02", "web01"}, now: soon, want: model.JobComplete},
+		{name: "every minion returned after the timeout", expected: []string{"web01"}, returned: []string{"web01"}, now: late, want: model.JobComplete},
+		{name: "some returns at the timeout", expected: []string{"web01", "web02"}, returned: []string{"web01", "web01"}, now: late, want: model.JobPartial},
+		{name: "no returns at the timeout", expected: []string{"web01"}, now: late, want: model.JobTimedOut},
+		{name: "no minions targeted", expected: []string{}, now: soon, want: model.JobComplete},
+		{name: "runner job without returns", now: soon, want: model.JobPending},
+		{name: "runner job returned", returned: []string{"master_master"}, now: soon, want: model.JobComplete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := Compute("20240501120000000000", tt.expected, tt.returned, &started, tt.now, 10*time.Minute)
+			require.Equal(t, tt.want, state.State)
+			require.Equal(t, len(tt.expected), state.Expected)
+			require.Equal(t, &started, state.StartTime)
+		})
+	}
+}
+
+func TestUpdateTracksNewAndOpenJobs(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = sqlDB.Close() }()
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	tables := config.SaltDBTables{JIDs: "jids", SaltReturns: "salt_returns"}
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := started.Add(time.Minute)
+	jobColumns := []string{"jid", "minions", "alter_time"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT j.jid,j.load::jsonb->>'minions' AS minions,j.alter_time FROM jids AS j WHERE NOT EXISTS (SELECT 1 FROM job_state s WHERE s.jid = j.jid) ORDER BY j.jid LIMIT $1`)).
+		WithArgs(batchSize).
+		WillReturnRows(sqlmock.NewRows(jobColumns).
+			AddRow("20240501120000000001", `["web01", "web02"]`, started).
+			AddRow("20240501120000000002", nil, started))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT jid, id FROM "salt_returns" WHERE jid IN ($1,$2)`)).
+		WithArgs("20240501120000000001", "20240501120000000002").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "id"}).AddRow("20240501120000000001", "web01"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "job_state" ("jid","state","expected","returned","start_time","updated_at") VALUES ($1,$2,$3,$4,$5,$6),($7,$8,$9,$10,$11,$12) ON CONFLICT ("jid") DO UPDATE SET`)).
+		WithArgs(
+			"20240501120000000001", model.JobRunning, 2, 1, started, sqlmock.AnyArg(),
+			"20240501120000000002", model.JobPending, 0, 0, started, sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+		).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(openJobsQuery)).
+		WithArgs(model.JobPending, model.JobRunning, model.JobPartial, model.JobTimedOut, now.Add(-testOptions.ReturnWindow)).
+		WillReturnRows(sqlmock.NewRows(jobColumns))
+
+	require.NoError(t, Update(database, tables, testOptions, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateCompletesTimedOutJobsWithLateReturns(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = sqlDB.Close() }()
+	database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	require.NoError(t, err)
+
+	tables := config.SaltDBTables{JIDs: "jids", SaltReturns: "salt_returns"}
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := started.Add(time.Hour)
+	jobColumns := []string{"jid", "minions", "alter_time"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT j.jid,j.load::jsonb->>'minions' AS minions,j.alter_time FROM jids AS j WHERE NOT EXISTS`)).
+		WithArgs(batchSize).
+		WillReturnRows(sqlmock.NewRows(jobColumns))
+	// The job was recorded as partial at its timeout; web02 returned since.
+	mock.ExpectQuery(regexp.QuoteMeta(openJobsQuery)).
+		WithArgs(model.JobPending, model.JobRunning, model.JobPartial, model.JobTimedOut, now.Add(-testOptions.ReturnWindow)).
+		WillReturnRows(sqlmock.NewRows(jobColumns).AddRow("20240501120000000001", `["web01", "web02"]`, started))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT jid, id FROM "salt_returns" WHERE jid IN ($1)`)).
+		WithArgs("20240501120000000001").
+		WillReturnRows(sqlmock.NewRows([]string{"jid", "id"}).
+			AddRow("20240501120000000001", "web01").
+			AddRow("20240501120000000001", "web02"))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "job_state" ("jid","state","expected","returned","start_time","updated_at") VALUES ($1,$2,$3,$4,$5,$6) ON CONFLICT ("jid") DO UPDATE SET`)).
+		WithArgs("20240501120000000001", model.JobComplete, 2, 2, started, now, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, Update(database, tables, testOptions, now))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var testOptions = config.JobStateOptions{Timeout: 10 * time.Minute, ReturnWindow: 24 * time.Hour}
+
+// openJobsQuery selects the jobs whose state may still change: open jobs, and
+// closed jobs within the return window that got a return since.
+const openJobsQuery = `SELECT j.jid,j.load::jsonb->>'minions' AS minions,j.alter_time FROM jids AS j JOIN job_state s ON s.jid = j.jid WHERE s.state IN ($1,$2) OR (s.state IN ($3,$4) AND s.start_time >= $5 AND EXISTS (SELECT 1 FROM salt_returns r WHERE r.jid = j.jid AND r.alter_time > s.updated_at)) ORDER BY j.jid`
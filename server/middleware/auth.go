@@ -16,14 +16,29 @@ import (
 	"gorm.io/gorm"
 )
 
-const authUserContextKey = "auth_user"
+const (
+	authUserContextKey         = "auth_user"
+	apiTokenIDContextKey       = "api_token_id"
+	apiTokenReadOnlyContextKey = "api_token_read_only"
 
-func AuthRequired(jwtSecret []byte) gin.HandlerFunc {
+	// apiTokenLastUsedResolution limits last_used_at writes to one per token
+	// per interval.
+	apiTokenLastUsedResolution = time.Minute
+
+	// sessionLastSeenResolution limits last_seen_at writes to one per
+	// session per interval.
+	sessionLastSeenResolution = time.Minute
+)
+
+// AuthRequired accepts a cookie session, a session JWT or a personal API token
+// presented as a Bearer token. Session JWTs must carry jti and sid claims and
+// are rejected once either appears in the revocation list.
+func AuthRequired(jwtSecret []byte, database *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		parts := strings.Fields(authHeader)
 		if len(parts) == 0 {
-			if authenticateSession(c) {
+			if authenticateSession(c, database) {
 				c.Next()
 				return
 			}
@@ -37,6 +52,12 @@ func AuthRequired(jwtSecret []byte) gin.HandlerFunc {
 			return
 		}
 		authToken := parts[1]
+		if strings.HasPrefix(authToken, model.APITokenPrefix) {
+			if authenticateAPIToken(c, database, authToken) {
+				c.Next()
+			}
+			return
+		}
 
 		// parse and validate JWT token
 		token, err := jwt.Parse(
@@ -70,6 +91,24 @@ func AuthRequired(jwtSecret []byte) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		tokenID, jtiOK := claims["jti"].(string)
+		familyID, sidOK := claims["sid"].(string)
+		if !jtiOK || tokenID == "" || !sidOK || familyID == "" {
+			httputil.NewError(c, http.StatusUnauthorized, "Invalid token claims.")
+			c.Abort()
+			return
+		}
+		revoked, err := tokenRevoked(database, tokenID, familyID)
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize user.")
+			c.Abort()
+			return
+		}
+		if revoked {
+			httputil.NewError(c, http.StatusUnauthorized, "Token has been revoked.")
+			c.Abort()
+			return
+		}
 
 		c.Set("username", username)
 		c.Set("user_id", userID)
@@ -78,7 +117,9 @@ func AuthRequired(jwtSecret []byte) gin.HandlerFunc {
 	}
 }
 
-func authenticateSession(c *gin.Context) bool {
+// authenticateSession accepts an unexpired cookie session and records when it
+// was last seen, at most once per sessionLastSeenResolution.
+func authenticateSession(c *gin.Context, database *gorm.DB) bool {
 	if _, exists := c.Get(sessions.DefaultKey); !exists {
 		return false
 	}
@@ -89,8 +130,16 @@ func authenticateSession(c *gin.Context) bool {
 	if !usernameOK || username == "" || !userIDOK || userID == 0 || !expiresOK {
 		return false
 	}
+	now := time.Now()
 	exp, err := strconv.ParseInt(expiresAt, 10, 64)
-	if err != nil || exp <= time.Now().Unix() {
+	if err != nil || exp <= now.Unix() {
+		return false
+	}
+	err = database.Exec(
+		`UPDATE session_user_map SET last_seen_at = ? WHERE session_id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)`,
+		now, session.ID(), now.Add(-sessionLastSeenResolution),
+	).Error
+	if err != nil {
 		return false
 	}
 	c.Set("username", username)
@@ -98,6 +147,69 @@ func authenticateSession(c *gin.Context) bool {
 	return true
 }
 
+// tokenRevoked reports whether the access token, or the refresh token family
+// it was issued from, is on the revocation list.
+func tokenRevoked(database *gorm.DB, tokenID, familyID string) (bool, error) {
+	var count int64
+	err := database.Model(&model.RevokedToken{}).
+		Where("id IN ?", []string{tokenID, familyID}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// authenticateAPIToken resolves a personal API token. On failure the error
+// response has already been written and the request aborted.
+func authenticateAPIToken(c *gin.Context, database *gorm.DB, authToken string) bool {
+	now := time.Now()
+	var token model.APIToken
+	err := database.Joins("User").
+		Where("api_token.token_hash = ? AND api_token.revoked_at IS NULL AND (api_token.expires_at IS NULL OR api_token.expires_at > ?)", model.HashAPIToken(authToken), now).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httputil.NewError(c, http.StatusUnauthorized, "Invalid token.")
+		} else {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize user.")
+		}
+		c.Abort()
+		return false
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= apiTokenLastUsedResolution {
+		err = database.Model(&model.APIToken{}).
+			Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", token.ID, now.Add(-apiTokenLastUsedResolution)).
+			Update("last_used_at", now).Error
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize user.")
+			c.Abort()
+			return false
+		}
+	}
+
+	c.Set("username", token.User.Username)
+	c.Set("user_id", token.UserID)
+	c.Set(apiTokenIDContextKey, token.ID)
+	c.Set(apiTokenReadOnlyContextKey, token.ReadOnly)
+	return true
+}
+
+// APITokenID returns the personal API token that authenticated the request,
+// if any.
+func APITokenID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get(apiTokenIDContextKey)
+	if !exists {
+		return 0, false
+	}
+	id, ok := value.(uint)
+	return id, ok
+}
+
+// ReadOnlyAPIToken reports whether the request was authenticated by a
+// read-only personal API token.
+func ReadOnlyAPIToken(c *gin.Context) bool {
+	return c.GetBool(apiTokenReadOnlyContextKey)
+}
+
 func claimUserID(value any) (uint, error) {
 	userID, ok := value.(float64)
 	if !ok || userID < 1 || userID != float64(uint(userID)) {
@@ -22,28 +22,62 @@ import (
 func TestAuthRequiredSetsValidatedClaims(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	secret := []byte("test-secret")
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": "alice",
-		"user_id":  7,
-		"exp":      4102444800,
-	})
-	signedToken, err := token.SignedString(secret)
-	require.NoError(t, err)
+	revocationQuery := regexp.QuoteMeta(`SELECT count(*) FROM "revoked_token" WHERE id IN ($1,$2)`)
 
-	router := gin.New()
-	router.GET("/protected", AuthRequired(secret), func(c *gin.Context) {
-		username, _ := c.Get("username")
-		userID, _ := c.Get("user_id")
-		c.JSON(http.StatusOK, gin.H{"username": username, "user_id": userID})
-	})
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		revoked    int
+		wantStatus int
+	}{
+		{
+			name:       "valid token",
+			claims:     jwt.MapClaims{"username": "alice", "user_id": 7, "exp": 4102444800, "jti": "access-1", "sid": "family-1"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "revoked token or family",
+			claims:     jwt.MapClaims{"username": "alice", "user_id": 7, "exp": 4102444800, "jti": "access-1", "sid": "family-1"},
+			revoked:    1,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "token without jti",
+			claims:     jwt.MapClaims{"username": "alice", "user_id": 7, "exp": 4102444800, "sid": "family-1"},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
 
-	request := httptest.NewRequest(http.MethodGet, "/protected", nil)
-	request.Header.Set("Authorization", "Bearer "+signedToken)
-	response := httptest.NewRecorder()
-	router.ServeHTTP(response, request)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := authorizationTestDatabase(t)
+			if _, ok := tt.claims["jti"]; ok {
+				mock.ExpectQuery(revocationQuery).
+					WithArgs("access-1", "family-1").
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.revoked))
+			}
+			signedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString(secret)
+			require.NoError(t, err)
 
-	require.Equal(t, http.StatusOK, response.Code)
-	require.JSONEq(t, `{"username":"alice","user_id":7}`, response.Body.String())
+			router := gin.New()
+			router.GET("/protected", AuthRequired(secret, database), func(c *gin.Context) {
+				username, _ := c.Get("username")
+				userID, _ := c.Get("user_id")
+				c.JSON(http.StatusOK, gin.H{"username": username, "user_id": userID})
+			})
+
+			request := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			request.Header.Set("Authorization", "Bearer "+signedToken)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantStatus == http.StatusOK {
+				require.JSONEq(t, `{"username":"alice","user_id":7}`, response.Body.String())
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
 }
 
 func TestAuthRequiredRejectsExpiredJWT(t *testing.T) {
@@ -58,7 +92,7 @@ func TestAuthRequiredRejectsExpiredJWT(t *testing.T) {
 	require.NoError(t, err)
 
 	router := gin.New()
-	router.GET("/protected", AuthRequired(secret), noContent)
+	router.GET("/protected", AuthRequired(secret, nil), noContent)
 	request := httptest.NewRequest(http.MethodGet, "/protected", nil)
 	request.Header.Set("Authorization", "Bearer "+signedToken)
 	response := httptest.NewRecorder()
@@ -81,7 +115,11 @@ func TestAuthRequiredAcceptsServerSideSession(t *testing.T) {
 		require.NoError(t, session.Save())
 		c.Status(http.StatusNoContent)
 	})
-	router.GET("/protected", AuthRequired([]byte("unused")), func(c *gin.Context) {
+	database, mock := authorizationTestDatabase(t)
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE session_user_map SET last_seen_at = $1 WHERE session_id = $2 AND (last_seen_at IS NULL OR last_seen_at < $3)`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	router.GET("/protected", AuthRequired([]byte("unused"), database), func(c *gin.Context) {
 		username, _ := c.Get("username")
 		userID, _ := c.Get("user_id")
 		c.JSON(http.StatusOK, gin.H{"username": username, "user_id": userID})
@@ -100,6 +138,91 @@ func TestAuthRequiredAcceptsServerSideSession(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, response.Code)
 	require.JSONEq(t, `{"username":"alice","user_id":7}`, response.Body.String())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthRequiredAcceptsAPIToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const apiToken = model.APITokenPrefix + "abc123"
+	tokenQuery := `SELECT .* FROM "api_token" LEFT JOIN "auth_user" "User" ON "api_token"."user_id" = "User"."id" WHERE api_token.token_hash = \$1 AND api_token.revoked_at IS NULL`
+
+	tests := []struct {
+		name       string
+		expect     func(sqlmock.Sqlmock)
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name: "active token",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(model.HashAPIToken(apiToken), sqlmock.AnyArg(), 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "read_only", "last_used_at", "User__id", "User__username"}).
+						AddRow(3, 7, true, nil, 7, "alice"))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "api_token" SET "last_used_at"=$1 WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < $3)`)).
+					WithArgs(sqlmock.AnyArg(), 3, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"username":"alice","user_id":7,"api_token_id":3,"read_only":true}`,
+		},
+		{
+			name: "recently used token",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(model.HashAPIToken(apiToken), sqlmock.AnyArg(), 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "read_only", "last_used_at", "User__id", "User__username"}).
+						AddRow(3, 7, false, time.Now(), 7, "alice"))
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"username":"alice","user_id":7,"api_token_id":3,"read_only":false}`,
+		},
+		{
+			name: "unknown, revoked or expired token",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(tokenQuery).
+					WithArgs(model.HashAPIToken(apiToken), sqlmock.AnyArg(), 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				mock.ExpectClose()
+				require.NoError(t, sqlDB.Close())
+			})
+			database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+			})
+			require.NoError(t, err)
+			tt.expect(mock)
+
+			router := gin.New()
+			router.GET("/protected", AuthRequired([]byte("unused"), database), func(c *gin.Context) {
+				username, _ := c.Get("username")
+				userID, _ := c.Get("user_id")
+				tokenID, _ := APITokenID(c)
+				c.JSON(http.StatusOK, gin.H{"username": username, "user_id": userID, "api_token_id": tokenID, "read_only": ReadOnlyAPIToken(c)})
+			})
+			request := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			request.Header.Set("Authorization", "Bearer "+apiToken)
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, request)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			if tt.wantBody != "" {
+				require.JSONEq(t, tt.wantBody, response.Body.String())
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
 }
 
 func TestActiveUserRequired(t *testing.T) {
@@ -5,10 +5,13 @@ import (
 	"errors"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/PaulChristophel/agartha/server/audit"
 	"github.com/PaulChristophel/agartha/server/httputil"
 	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/saltacl"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -17,6 +20,8 @@ import (
 // effective permissions returned by Salt eauth.
 type SaltCapability string
 
+const minionScopeContextKey = "salt_minion_scope"
+
 const (
 	ReadSaltData       SaltCapability = "read Salt data"
 	ExecuteSaltCommand SaltCapability = "execute Salt commands"
@@ -33,6 +38,10 @@ func SaltPermissionRequired(database *gorm.DB, capability SaltCapability) gin.Ha
 			c.Abort()
 			return
 		}
+		if capability == ExecuteSaltCommand && ReadOnlyAPIToken(c) {
+			denyPermission(c, "Permission denied: read-only API tokens cannot "+string(capability)+".")
+			return
+		}
 		if user.IsSuperuser || user.IsStaff {
 			c.Next()
 			return
@@ -48,21 +57,54 @@ func SaltPermissionRequired(database *gorm.DB, capability SaltCapability) gin.Ha
 			allowed = hasExecutableSaltPermission(permissions)
 		}
 		if !allowed {
-			httputil.NewError(c, http.StatusForbidden, "Permission denied: cannot "+string(capability)+".")
-			c.Abort()
+			denyPermission(c, "Permission denied: cannot "+string(capability)+".")
 			return
 		}
+		c.Set(minionScopeContextKey, saltacl.MinionScope(permissions))
 		c.Next()
 	}
 }
 
+// VisibleMinions restricts a query to rows whose column holds a minion ID the
+// caller may read. Requests not authorized from target-scoped Salt
+// permissions are left unrestricted.
+func VisibleMinions(c *gin.Context, column string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		value, exists := c.Get(minionScopeContextKey)
+		scope, ok := value.(saltacl.Scope)
+		if !exists || !ok || scope.All() {
+			return query
+		}
+		condition, args := scope.SQL(column)
+		return query.Where(condition, args...)
+	}
+}
+
+// MinionVisible reports whether the caller may read data about a minion. It
+// applies the same scope as VisibleMinions to IDs that are not in a table,
+// such as the minions listed in a job load.
+func MinionVisible(c *gin.Context, id string) bool {
+	value, exists := c.Get(minionScopeContextKey)
+	scope, ok := value.(saltacl.Scope)
+	return !exists || !ok || scope.Match(id)
+}
+
+// MinionsRestricted reports whether the caller may read data about only some
+// minions. Handlers scoping data that is not keyed by a single minion ID use
+// it to leave unrestricted requests alone.
+func MinionsRestricted(c *gin.Context) bool {
+	value, exists := c.Get(minionScopeContextKey)
+	scope, ok := value.(saltacl.Scope)
+	return exists && ok && !scope.All()
+}
+
 // SaltPermissionForMethodRequired treats safe HTTP methods as reads and all
 // other methods as Salt operations.
 func SaltPermissionForMethodRequired(database *gorm.DB) gin.HandlerFunc {
 	read := SaltPermissionRequired(database, ReadSaltData)
 	execute := SaltPermissionRequired(database, ExecuteSaltCommand)
 	return func(c *gin.Context) {
-		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+		if isSafeMethod(c.Request.Method) {
 			read(c)
 			return
 		}
@@ -81,6 +123,9 @@ func SaltWheelPermissionRequired(database *gorm.DB, function string) gin.Handler
 			c.Abort()
 			return
 		}
+		if !rejectReadOnlyAPITokenChange(c) {
+			return
+		}
 		if user.IsSuperuser || user.IsStaff {
 			c.Next()
 			return
@@ -91,8 +136,7 @@ func SaltWheelPermissionRequired(database *gorm.DB, function string) gin.Handler
 			return
 		}
 		if !hasWheelPermission(permissions, function) {
-			httputil.NewError(c, http.StatusForbidden, "Permission denied: cannot manage minion keys.")
-			c.Abort()
+			denyPermission(c, "Permission denied: cannot manage minion keys.")
 			return
 		}
 		c.Next()
@@ -109,6 +153,9 @@ func SaltWheelAdministrationRequired(database *gorm.DB) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if !rejectReadOnlyAPITokenChange(c) {
+			return
+		}
 		if user.IsSuperuser || user.IsStaff {
 			c.Next()
 			return
@@ -119,8 +166,7 @@ func SaltWheelAdministrationRequired(database *gorm.DB) gin.HandlerFunc {
 			return
 		}
 		if !containsPermissionString(permissions, "@wheel") {
-			httputil.NewError(c, http.StatusForbidden, "Permission denied: raw Salt key administration requires full @wheel access.")
-			c.Abort()
+			denyPermission(c, "Permission denied: raw Salt key administration requires full @wheel access.")
 			return
 		}
 		c.Next()
@@ -129,40 +175,182 @@ func SaltWheelAdministrationRequired(database *gorm.DB) gin.HandlerFunc {
 
 // AdministrationRequired reserves Agartha user and settings administration for
 // superusers. Salt command permissions and is_staff do not cross this boundary.
-// func AdministrationRequired() gin.HandlerFunc {
-// 	return func(c *gin.Context) {
-// 		user, ok := AuthenticatedUser(c)
-// 		if !ok {
-// 			httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
-// 			c.Abort()
-// 			return
-// 		}
-// 		if !user.IsSuperuser {
-// 			httputil.NewError(c, http.StatusForbidden, "Permission denied: Agartha administration requires superuser access.")
-// 			c.Abort()
-// 			return
-// 		}
-// 		c.Next()
-// 	}
-// }
+func AdministrationRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := AuthenticatedUser(c)
+		if !ok {
+			httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+			c.Abort()
+			return
+		}
+		if !user.IsSuperuser {
+			denyPermission(c, "Permission denied: Agartha administration requires superuser access.")
+			return
+		}
+		if !rejectReadOnlyAPITokenChange(c) {
+			return
+		}
+		c.Next()
+	}
+}
 
-func loadSaltPermissions(c *gin.Context, database *gorm.DB, userID uint) (any, bool) {
-	var settings model.UserSettings
-	err := database.Select("salt_permissions").Where("user_id = ?", userID).First(&settings).Error
-	if err != nil {
+// CapabilityRequired authorizes users whose Agartha roles grant capability.
+// Everyone else, superusers included, is handed to otherwise: the check the
+// route applies to users without the capability. Read-only capabilities only
+// cover safe methods.
+func CapabilityRequired(database *gorm.DB, capability model.Capability, otherwise gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := AuthenticatedUser(c)
+		if !ok {
+			httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+			c.Abort()
+			return
+		}
+		if !user.IsSuperuser && (!capability.ReadOnly() || isSafeMethod(c.Request.Method)) {
+			granted, err := model.HasCapability(database, user.ID, capability)
+			if err != nil {
+				httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize request.")
+				c.Abort()
+				return
+			}
+			if granted {
+				if !rejectReadOnlyAPITokenChange(c) {
+					return
+				}
+				c.Next()
+				return
+			}
+		}
+		otherwise(c)
+	}
+}
+
+// SuperuserAccountProtected keeps administrators without superuser access,
+// such as holders of the admin-users capability, away from privileged
+// accounts: superusers, staff, who bypass every Salt check, users whose
+// roles grant a capability beyond reading, and users Salt eauth grants
+// anything. Taking over any of them would grant the administrator more than
+// account management. It checks the user named by the id path parameter.
+func SuperuserAccountProtected(database *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := AuthenticatedUser(c)
+		if !ok {
+			httputil.NewError(c, http.StatusUnauthorized, "User authorization context is missing.")
+			c.Abort()
+			return
+		}
+		// Handlers reject malformed IDs themselves.
+		id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+		if user.IsSuperuser || err != nil {
+			c.Next()
+			return
+		}
+		var target model.AuthUser
+		err = database.Select("is_superuser", "is_staff").Where("id = ?", id).First(&target).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			httputil.NewError(c, http.StatusForbidden, "Permission denied: no Salt permissions are available.")
-		} else {
-			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize Salt access.")
+			c.Next()
+			return
 		}
-		c.Abort()
-		return nil, false
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize request.")
+			c.Abort()
+			return
+		}
+		if target.IsSuperuser || target.IsStaff {
+			denyPermission(c, "Permission denied: only superusers can manage superuser and staff accounts.")
+			return
+		}
+		capabilities, err := model.UserCapabilities(database, uint(id))
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize request.")
+			c.Abort()
+			return
+		}
+		for _, capability := range capabilities {
+			if !capability.ReadOnly() {
+				denyPermission(c, "Permission denied: only superusers can manage accounts holding administrative capabilities.")
+				return
+			}
+		}
+		granted, err := saltAccessGranted(database, uint(id))
+		if err != nil {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize request.")
+			c.Abort()
+			return
+		}
+		if granted {
+			denyPermission(c, "Permission denied: only superusers can manage accounts holding Salt permissions.")
+			return
+		}
+		c.Next()
+	}
+}
+
+// saltAccessGranted reports whether Salt granted the user anything at their
+// last refresh, or whether the user belongs to directory groups. The eauth
+// configuration mapping groups to grants lives on the Salt master, so any
+// recorded group is treated as one that may grant access.
+func saltAccessGranted(database *gorm.DB, userID uint) (bool, error) {
+	permissions, err := CachedSaltPermissions(database, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	if hasSaltPermission(permissions) {
+		return true, nil
+	}
+	var groups int64
+	if err := database.Model(&model.UserGroup{}).Where("user_id = ?", userID).Count(&groups).Error; err != nil {
+		return false, err
+	}
+	return groups > 0, nil
+}
+
+// rejectReadOnlyAPITokenChange aborts any request other than a safe read made
+// with a read-only API token. It returns false when the request was aborted.
+func rejectReadOnlyAPITokenChange(c *gin.Context) bool {
+	if !ReadOnlyAPIToken(c) || isSafeMethod(c.Request.Method) {
+		return true
+	}
+	denyPermission(c, "Permission denied: read-only API tokens cannot make changes.")
+	return false
+}
+
+// denyPermission rejects the request as forbidden and records the denial in
+// the audit log.
+func denyPermission(c *gin.Context, message string) {
+	httputil.NewError(c, http.StatusForbidden, message)
+	audit.Record(c, audit.Event{Action: audit.ActionAuthorize, Outcome: audit.OutcomeDenied, Detail: message})
+	c.Abort()
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// CachedSaltPermissions decodes the Salt permissions cached at a user's last
+// Salt login. It returns gorm.ErrRecordNotFound when none are cached.
+func CachedSaltPermissions(database *gorm.DB, userID uint) (any, error) {
+	var settings model.UserSettings
+	if err := database.Select("salt_permissions").Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return nil, err
 	}
 
 	var permissions any
 	if err := json.Unmarshal([]byte(settings.SaltPermissions), &permissions); err != nil {
-		httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize Salt access.")
-		c.Abort()
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func loadSaltPermissions(c *gin.Context, database *gorm.DB, userID uint) (any, bool) {
+	permissions, err := CachedSaltPermissions(database, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			denyPermission(c, "Permission denied: no Salt permissions are available.")
+		} else {
+			httputil.NewError(c, http.StatusInternalServerError, "Unable to authorize Salt access.")
+			c.Abort()
+		}
 		return nil, false
 	}
 	return permissions, true
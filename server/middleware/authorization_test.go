@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"database/sql/driver"
 	"errors"
 	"net/http"
 	"net/http/httptest"
@@ -287,6 +288,28 @@ func TestOrdinaryAuthenticatedUsersCannotUseAdministrativeCapabilities(t *testin
 	}
 }
 
+func TestAdministrationRequiredOnlyAllowsSuperusers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		user       *model.AuthUser
+		wantStatus int
+	}{
+		{name: "missing authentication context", wantStatus: http.StatusUnauthorized},
+		{name: "ordinary user", user: &model.AuthUser{ID: 7, Username: "alice"}, wantStatus: http.StatusForbidden},
+		{name: "staff", user: &model.AuthUser{ID: 7, Username: "staff", IsStaff: true}, wantStatus: http.StatusForbidden},
+		{name: "superuser", user: &model.AuthUser{ID: 8, Username: "root", IsSuperuser: true}, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := authorizationStatus(t, tt.user, AdministrationRequired(), http.MethodPatch)
+			require.Equal(t, tt.wantStatus, status)
+		})
+	}
+}
+
 func authorizationTestDatabase(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
 	t.Helper()
 
@@ -313,6 +336,42 @@ func expectPermissionJSON(mock sqlmock.Sqlmock, permissions string) {
 		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(permissions))
 }
 
+func TestReadOnlyAPITokensCannotMakeChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	superuser := model.AuthUser{ID: 7, IsSuperuser: true}
+
+	tests := []struct {
+		name          string
+		authorization gin.HandlerFunc
+		method        string
+		wantStatus    int
+	}{
+		{name: "read Salt data", authorization: SaltPermissionRequired(nil, ReadSaltData), method: http.MethodGet, wantStatus: http.StatusNoContent},
+		{name: "execute Salt commands", authorization: SaltPermissionRequired(nil, ExecuteSaltCommand), method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "list minion keys", authorization: SaltWheelPermissionRequired(nil, "key.list_all"), method: http.MethodGet, wantStatus: http.StatusNoContent},
+		{name: "accept minion keys", authorization: SaltWheelPermissionRequired(nil, "key.accept"), method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "delete raw keys", authorization: SaltWheelAdministrationRequired(nil), method: http.MethodDelete, wantStatus: http.StatusForbidden},
+		{name: "administer users", authorization: AdministrationRequired(), method: http.MethodPatch, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readOnly := func(c *gin.Context) {
+				c.Set(apiTokenIDContextKey, uint(3))
+				c.Set(apiTokenReadOnlyContextKey, true)
+			}
+			router := gin.New()
+			router.Handle(tt.method, "/protected", readOnly, func(c *gin.Context) {
+				c.Set(authUserContextKey, superuser)
+			}, tt.authorization, noContent)
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(tt.method, "/protected", nil))
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+		})
+	}
+}
+
 func authorizationStatus(
 	t *testing.T,
 	user *model.AuthUser,
@@ -333,3 +392,195 @@ func authorizationStatus(
 	router.ServeHTTP(response, httptest.NewRequest(method, "/protected", nil))
 	return response.Code
 }
+
+func expectCapabilities(mock sqlmock.Sqlmock, capabilities ...model.Capability) {
+	rows := sqlmock.NewRows([]string{"capability"})
+	for _, capability := range capabilities {
+		rows.AddRow(string(capability))
+	}
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(7)).
+		WillReturnRows(rows)
+}
+
+func TestCapabilityRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	alice := &model.AuthUser{ID: 7, Username: "alice"}
+	forbidden := func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name       string
+		user       *model.AuthUser
+		capability model.Capability
+		method     string
+		granted    []model.Capability
+		queried    bool
+		wantStatus int
+	}{
+		{name: "missing authentication context", capability: model.CapabilityViewReturns, method: http.MethodGet, wantStatus: http.StatusUnauthorized},
+		{name: "granted by a role", user: alice, capability: model.CapabilityViewReturns, method: http.MethodGet, granted: []model.Capability{model.CapabilityViewPillar, model.CapabilityViewReturns}, queried: true, wantStatus: http.StatusNoContent},
+		{name: "not granted", user: alice, capability: model.CapabilityViewReturns, method: http.MethodGet, granted: []model.Capability{model.CapabilityViewPillar}, queried: true, wantStatus: http.StatusForbidden},
+		{name: "read-only capability on a change", user: alice, capability: model.CapabilityViewReturns, method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "change capability", user: alice, capability: model.CapabilityRefreshViews, method: http.MethodPost, granted: []model.Capability{model.CapabilityRefreshViews}, queried: true, wantStatus: http.StatusNoContent},
+		{name: "superuser", user: &model.AuthUser{ID: 8, Username: "root", IsSuperuser: true}, capability: model.CapabilityAdminUsers, method: http.MethodPatch, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := authorizationTestDatabase(t)
+			if tt.queried {
+				expectCapabilities(mock, tt.granted...)
+			}
+
+			status := authorizationStatus(t, tt.user, CapabilityRequired(database, tt.capability, forbidden), tt.method)
+
+			require.Equal(t, tt.wantStatus, status)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("read-only API token", func(t *testing.T) {
+		database, mock := authorizationTestDatabase(t)
+		expectCapabilities(mock, model.CapabilityManageKeys)
+		router := gin.New()
+		router.POST("/protected", func(c *gin.Context) {
+			c.Set(authUserContextKey, *alice)
+			c.Set(apiTokenIDContextKey, uint(3))
+			c.Set(apiTokenReadOnlyContextKey, true)
+		}, CapabilityRequired(database, model.CapabilityManageKeys, noContent), noContent)
+
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/protected", nil))
+		require.Equal(t, http.StatusForbidden, response.Code, response.Body.String())
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("database failure", func(t *testing.T) {
+		database, mock := authorizationTestDatabase(t)
+		mock.ExpectQuery(`FROM rbac_role_capability`).WillReturnError(errors.New("database unavailable"))
+
+		status := authorizationStatus(t, alice, CapabilityRequired(database, model.CapabilityViewReturns, noContent), http.MethodGet)
+		require.Equal(t, http.StatusInternalServerError, status)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSuperuserAccountProtected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const targetQuery = `SELECT "is_superuser","is_staff" FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`
+
+	type target struct {
+		superuser, staff bool
+		capabilities     []string
+		saltPermissions  string
+		groups           int
+	}
+	tests := []struct {
+		name       string
+		user       model.AuthUser
+		target     *target
+		wantStatus int
+	}{
+		{name: "superuser caller", user: model.AuthUser{ID: 8, IsSuperuser: true}, wantStatus: http.StatusNoContent},
+		{name: "ordinary account", user: model.AuthUser{ID: 7}, target: &target{}, wantStatus: http.StatusNoContent},
+		{name: "read-only capabilities", user: model.AuthUser{ID: 7}, target: &target{capabilities: []string{"view-pillar", "view-returns"}}, wantStatus: http.StatusNoContent},
+		{name: "superuser account", user: model.AuthUser{ID: 7}, target: &target{superuser: true}, wantStatus: http.StatusForbidden},
+		{name: "staff account", user: model.AuthUser{ID: 7}, target: &target{staff: true}, wantStatus: http.StatusForbidden},
+		{name: "user administrator", user: model.AuthUser{ID: 7}, target: &target{capabilities: []string{"admin-users"}}, wantStatus: http.StatusForbidden},
+		{name: "secret viewer", user: model.AuthUser{ID: 7}, target: &target{capabilities: []string{"view-returns", "view-secrets"}}, wantStatus: http.StatusForbidden},
+		{name: "no Salt permissions", user: model.AuthUser{ID: 7}, target: &target{saltPermissions: `[]`}, wantStatus: http.StatusNoContent},
+		{name: "Salt permissions", user: model.AuthUser{ID: 7}, target: &target{saltPermissions: `["@wheel"]`}, wantStatus: http.StatusForbidden},
+		{name: "directory groups", user: model.AuthUser{ID: 7}, target: &target{groups: 2}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := authorizationTestDatabase(t)
+			if tt.target != nil {
+				mock.ExpectQuery(regexp.QuoteMeta(targetQuery)).
+					WithArgs(uint64(12), 1).
+					WillReturnRows(sqlmock.NewRows([]string{"is_superuser", "is_staff"}).AddRow(tt.target.superuser, tt.target.staff))
+				privileged := tt.target.superuser || tt.target.staff
+				if !privileged {
+					rows := sqlmock.NewRows([]string{"capability"})
+					for _, capability := range tt.target.capabilities {
+						rows.AddRow(capability)
+						privileged = privileged || !model.Capability(capability).ReadOnly()
+					}
+					mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+						WithArgs(uint(12)).
+						WillReturnRows(rows)
+				}
+				if !privileged {
+					settings := sqlmock.NewRows([]string{"salt_permissions"})
+					if tt.target.saltPermissions != "" {
+						settings.AddRow(tt.target.saltPermissions)
+					}
+					mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings" WHERE user_id = $1`)).
+						WithArgs(uint(12), 1).
+						WillReturnRows(settings)
+				}
+				if !privileged && (tt.target.saltPermissions == "" || tt.target.saltPermissions == `[]`) {
+					mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_group" WHERE user_id = $1`)).
+						WithArgs(uint(12)).
+						WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(tt.target.groups))
+				}
+			}
+			router := gin.New()
+			router.PATCH("/auth_user/:id", func(c *gin.Context) {
+				c.Set(authUserContextKey, tt.user)
+			}, SuperuserAccountProtected(database), noContent)
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodPatch, "/auth_user/12", nil))
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestVisibleMinionsFollowsTargetScopedPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		user        model.AuthUser
+		permissions string
+		wantQuery   string
+		wantArgs    []driver.Value
+	}{
+		{name: "superuser", user: model.AuthUser{ID: 7, IsSuperuser: true}, wantQuery: `SELECT * FROM "salt_return"`},
+		{name: "untargeted function grant", user: model.AuthUser{ID: 7}, permissions: `["test.*", {"web*": [".*"]}]`, wantQuery: `SELECT * FROM "salt_return"`},
+		{name: "glob target", user: model.AuthUser{ID: 7}, permissions: `[{"web*": [".*"]}]`, wantQuery: `SELECT * FROM "salt_return" WHERE (id ~ $1)`, wantArgs: []driver.Value{"^web.*$"}},
+		{name: "compound target", user: model.AuthUser{ID: 7}, permissions: `{"L@db1,db2 or E@cache\\d+": ["state.*"]}`, wantQuery: `SELECT * FROM "salt_return" WHERE ((id IN ($1,$2) OR id ~ $3))`, wantArgs: []driver.Value{"db1", "db2", `^(?:cache[0-9]+)`}},
+		{name: "grain target", user: model.AuthUser{ID: 7}, permissions: `{"G@role:web": [".*"]}`, wantQuery: `SELECT * FROM "salt_return" WHERE FALSE`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, mock := authorizationTestDatabase(t)
+			if tt.permissions != "" {
+				expectPermissionJSON(mock, tt.permissions)
+			}
+			mock.ExpectQuery(regexp.QuoteMeta(tt.wantQuery) + "$").
+				WithArgs(tt.wantArgs...).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+			router := gin.New()
+			router.GET("/protected", func(c *gin.Context) {
+				c.Set(authUserContextKey, tt.user)
+			}, SaltPermissionRequired(database, ReadSaltData), func(c *gin.Context) {
+				var rows []map[string]any
+				require.NoError(t, database.Table("salt_return").Scopes(VisibleMinions(c, "id")).Find(&rows).Error)
+				c.Status(http.StatusNoContent)
+			})
+
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/protected", nil))
+			require.Equal(t, http.StatusNoContent, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import "github.com/PaulChristophel/agartha/server/saltacl"
+
+// KeyWheelFunctions lists the wheel functions guarding the minion key routes.
+var KeyWheelFunctions = []string{"key.list_all", "key.accept", "key.reject", "key.delete"}
+
+// SaltAccess summarizes what cached Salt permissions authorize in Agartha for
+// an ordinary user. It is derived with the same checks as the middleware.
+type SaltAccess struct {
+	ReadSaltData         bool     `json:"read_salt_data"`
+	ExecuteSaltCommands  bool     `json:"execute_salt_commands"`
+	WheelFunctions       []string `json:"wheel_functions"`
+	RawKeyAdministration bool     `json:"raw_key_administration"`
+	AllMinions           bool     `json:"all_minions"`
+	MinionTargets        []string `json:"minion_targets"`
+}
+
+// DescribeSaltAccess evaluates decoded Salt permissions.
+func DescribeSaltAccess(permissions any) SaltAccess {
+	access := SaltAccess{
+		ReadSaltData:         hasSaltPermission(permissions),
+		ExecuteSaltCommands:  hasExecutableSaltPermission(permissions),
+		WheelFunctions:       []string{},
+		RawKeyAdministration: containsPermissionString(permissions, "@wheel"),
+		MinionTargets:        []string{},
+	}
+	for _, function := range KeyWheelFunctions {
+		if hasWheelPermission(permissions, function) {
+			access.WheelFunctions = append(access.WheelFunctions, function)
+		}
+	}
+	if !access.ReadSaltData {
+		return access
+	}
+	scope := saltacl.MinionScope(permissions)
+	access.AllMinions = scope.All()
+	for _, target := range scope.Targets() {
+		access.MinionTargets = append(access.MinionTargets, target.String())
+	}
+	return access
+}
+
+// WheelFunctionAllowed reports whether function is one of the wheel functions
+// the access grants.
+func (a SaltAccess) WheelFunctionAllowed(function string) bool {
+	for _, allowed := range a.WheelFunctions {
+		if allowed == function {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// APITokenPrefix distinguishes personal API tokens from session JWTs in an
+// Authorization header.
+const APITokenPrefix = "agartha_pat_"
+
+// APIToken is a named, revocable personal access token for automation. Only
+// the SHA-256 hash of the token is stored. Read-only tokens may read Salt data
+// but cannot execute Salt commands.
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(150);not null"`
+	TokenHash  string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	ReadOnly   bool       `json:"read_only" gorm:"not null;default:false"`
+	ExpiresAt  *time.Time `json:"expires_at" gorm:"type:timestamp with time zone"`
+	LastUsedAt *time.Time `json:"last_used_at" gorm:"type:timestamp with time zone"`
+	RevokedAt  *time.Time `json:"revoked_at" gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User       AuthUser   `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (APIToken) TableName() string {
+	return "api_token"
+}
+
+// HashAPIToken returns the hex SHA-256 digest stored for token.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
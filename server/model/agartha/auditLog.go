@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// AuditLog records one authentication or authorization decision. UserID is
+// nil when the request could not be tied to an Agartha user, such as a failed
+// login for an unknown username; Username then holds the name presented.
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now();index"`
+	UserID    *uint     `json:"user_id" gorm:"index"`
+	Username  string    `json:"username" gorm:"type:varchar(255);not null;index"`
+	Action    string    `json:"action" gorm:"type:varchar(64);not null;index"`
+	Outcome   string    `json:"outcome" gorm:"type:varchar(16);not null;index"`
+	Method    string    `json:"method" gorm:"type:varchar(32);not null"`
+	ClientIP  string    `json:"client_ip" gorm:"type:varchar(45);not null"`
+	UserAgent string    `json:"user_agent" gorm:"type:varchar(512);not null"`
+	Path      string    `json:"path" gorm:"type:varchar(512);not null"`
+	Detail    string    `json:"detail" gorm:"type:text;not null"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
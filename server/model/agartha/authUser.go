@@ -18,12 +18,23 @@ type AuthUser struct {
 	IsStaff     bool       `json:"is_staff" gorm:"not null"`
 	IsActive    bool       `json:"is_active" gorm:"not null"`
 	DateJoined  time.Time  `json:"date_joined" gorm:"type:timestamp with time zone;not null;default:now();"`
+	// Provider names the entry of auth.providers the account was created by.
+	// Only that provider may log in as the user. It is empty for accounts
+	// shared by the built-in methods.
+	Provider string `json:"provider" gorm:"type:varchar(150);not null;default:''"`
 }
 
 func (AuthUser) TableName() string {
 	return "auth_user"
 }
 
+// ExternallyManaged reports whether the account belongs to an external
+// provider. Accounts provisioned at login are stored without a local
+// password, and only named LDAP providers bind accounts to themselves.
+func (user AuthUser) ExternallyManaged() bool {
+	return user.Provider != "" || user.Password == ""
+}
+
 func (user *AuthUser) Create(db *gorm.DB) error {
 	// Use raw SQL to insert the user and hash the password using crypt and gen_salt because gorm doesn't support crypt directly
 	result := db.Raw(`
@@ -39,6 +50,47 @@ func (user *AuthUser) Create(db *gorm.DB) error {
 	return nil
 }
 
+// SetPassword replaces the user's local password with a bcrypt hash computed by pgcrypto.
+func (user *AuthUser) SetPassword(db *gorm.DB, password string) error {
+	result := db.Exec(`UPDATE auth_user SET password = crypt(?, gen_salt('bf', 8)) WHERE id = ?`, password, user.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// InvalidateCredentials ends every browser session bound to the user, revokes
+// every outstanding refresh token family together with the access tokens
+// issued from it, and clears the hashed bearer token kept in user_settings, so
+// the user must log in again. It is meant to run inside the transaction that
+// changed the credential being protected.
+func (user *AuthUser) InvalidateCredentials(db *gorm.DB) error {
+	if err := db.Exec(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM session_user_map WHERE user_id = ?)`, user.ID).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`
+		INSERT INTO revoked_token (id, user_id, expires_at, revoked_at)
+		SELECT family_id, user_id, MAX(expires_at), now()
+		FROM refresh_token
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > now()
+		GROUP BY family_id, user_id
+		ON CONFLICT (id) DO NOTHING
+	`, user.ID).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`UPDATE refresh_token SET revoked_at = now() WHERE user_id = ? AND revoked_at IS NULL`, user.ID).Error; err != nil {
+		return err
+	}
+	if err := db.Exec(`DELETE FROM session_user_map WHERE user_id = ?`, user.ID).Error; err != nil {
+		return err
+	}
+	return db.Exec(`UPDATE user_settings SET token = '' WHERE user_id = ?`, user.ID).Error
+}
+
 func (user *AuthUser) Delete(db *gorm.DB, id uint) error {
 	if err := db.Delete(&AuthUser{}, id).Error; err != nil {
 		return err
@@ -81,3 +81,44 @@ func TestAuthUserDelete(t *testing.T) {
 	require.NoError(t, user.Delete(db, user.ID))
 	require.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestAuthUserSetPassword(t *testing.T) {
+	db, mock := setupTestDB(t)
+	user := testAuthUser()
+	user.ID = 42
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_user SET password = crypt($1, gen_salt('bf', 8)) WHERE id = $2`)).
+		WithArgs("new-password", user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, user.SetPassword(db, "new-password"))
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE auth_user SET password = crypt($1, gen_salt('bf', 8)) WHERE id = $2`)).
+		WithArgs("new-password", user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.ErrorIs(t, user.SetPassword(db, "new-password"), gorm.ErrRecordNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthUserInvalidateCredentials(t *testing.T) {
+	db, mock := setupTestDB(t)
+	user := testAuthUser()
+	user.ID = 42
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sessions WHERE id IN (SELECT session_id FROM session_user_map WHERE user_id = $1)`)).
+		WithArgs(user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`INSERT INTO revoked_token \(id, user_id, expires_at, revoked_at\)\s+SELECT family_id, user_id, MAX\(expires_at\), now\(\)\s+FROM refresh_token\s+WHERE user_id = \$1 AND revoked_at IS NULL`).
+		WithArgs(user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`)).
+		WithArgs(user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM session_user_map WHERE user_id = $1`)).
+		WithArgs(user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = '' WHERE user_id = $1`)).
+		WithArgs(user.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, user.InvalidateCredentials(db))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,150 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Group collects users so roles can be assigned to all of them at once.
+// RoleIDs and UserIDs are filled in by the administration API.
+type Group struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"type:varchar(150);not null;uniqueIndex"`
+	Description string `json:"description" gorm:"type:text;not null;default:''"`
+	RoleIDs     []uint `json:"role_ids" gorm:"-"`
+	UserIDs     []uint `json:"user_ids" gorm:"-"`
+}
+
+func (Group) TableName() string {
+	return "rbac_group"
+}
+
+// GroupRole assigns a role to a group.
+type GroupRole struct {
+	GroupID uint  `json:"group_id" gorm:"primaryKey"`
+	RoleID  uint  `json:"role_id" gorm:"primaryKey;index"`
+	Group   Group `json:"-" gorm:"foreignKey:GroupID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Role    Role  `json:"-" gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (GroupRole) TableName() string {
+	return "rbac_group_role"
+}
+
+// GroupMember adds a user to a group.
+type GroupMember struct {
+	GroupID uint     `json:"group_id" gorm:"primaryKey"`
+	UserID  uint     `json:"user_id" gorm:"primaryKey;index"`
+	Group   Group    `json:"-" gorm:"foreignKey:GroupID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	User    AuthUser `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (GroupMember) TableName() string {
+	return "rbac_group_member"
+}
+
+// ReplaceGroupRoles stores roleIDs as the complete set of roles assigned to
+// the group. It is meant to run inside a transaction.
+func ReplaceGroupRoles(db *gorm.DB, groupID uint, roleIDs []uint) error {
+	if err := db.Where("group_id = ?", groupID).Delete(&GroupRole{}).Error; err != nil {
+		return err
+	}
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	rows := make([]GroupRole, 0, len(roleIDs))
+	for _, roleID := range uniqueIDs(roleIDs) {
+		rows = append(rows, GroupRole{GroupID: groupID, RoleID: roleID})
+	}
+	return db.Create(&rows).Error
+}
+
+// ReplaceGroupMembers stores userIDs as the group's complete membership. It
+// is meant to run inside a transaction.
+func ReplaceGroupMembers(db *gorm.DB, groupID uint, userIDs []uint) error {
+	if err := db.Where("group_id = ?", groupID).Delete(&GroupMember{}).Error; err != nil {
+		return err
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	rows := make([]GroupMember, 0, len(userIDs))
+	for _, userID := range uniqueIDs(userIDs) {
+		rows = append(rows, GroupMember{GroupID: groupID, UserID: userID})
+	}
+	return db.Create(&rows).Error
+}
+
+// LoadGroupAssignments fills in the RoleIDs and UserIDs of groups.
+func LoadGroupAssignments(db *gorm.DB, groups []Group) error {
+	if len(groups) == 0 {
+		return nil
+	}
+	index := make(map[uint]int, len(groups))
+	ids := make([]uint, 0, len(groups))
+	for i := range groups {
+		index[groups[i].ID] = i
+		ids = append(ids, groups[i].ID)
+		groups[i].RoleIDs = []uint{}
+		groups[i].UserIDs = []uint{}
+	}
+	var roles []GroupRole
+	if err := db.Where("group_id IN ?", ids).Order("group_id, role_id").Find(&roles).Error; err != nil {
+		return err
+	}
+	for _, row := range roles {
+		group := &groups[index[row.GroupID]]
+		group.RoleIDs = append(group.RoleIDs, row.RoleID)
+	}
+	var members []GroupMember
+	if err := db.Where("group_id IN ?", ids).Order("group_id, user_id").Find(&members).Error; err != nil {
+		return err
+	}
+	for _, row := range members {
+		group := &groups[index[row.GroupID]]
+		group.UserIDs = append(group.UserIDs, row.UserID)
+	}
+	return nil
+}
+
+// ErrUnknownGroupAssignment is returned by CheckGroupAssignments when a role
+// or user does not exist.
+var ErrUnknownGroupAssignment = errors.New("unknown role or user")
+
+// CheckGroupAssignments verifies that every role in roleIDs and every user in
+// userIDs exists.
+func CheckGroupAssignments(db *gorm.DB, roleIDs, userIDs []uint) error {
+	for _, assignment := range []struct {
+		model any
+		ids   []uint
+	}{
+		{&Role{}, uniqueIDs(roleIDs)},
+		{&AuthUser{}, uniqueIDs(userIDs)},
+	} {
+		if len(assignment.ids) == 0 {
+			continue
+		}
+		var count int64
+		if err := db.Model(assignment.model).Where("id IN ?", assignment.ids).Count(&count).Error; err != nil {
+			return err
+		}
+		if count != int64(len(assignment.ids)) {
+			return ErrUnknownGroupAssignment
+		}
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]struct{}, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
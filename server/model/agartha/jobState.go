@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// JobStatus is how far a Salt job has got, as tracked by the job state worker.
+type JobStatus string
+
+const (
+	// JobPending is a job no minion has returned for yet.
+	JobPending JobStatus = "pending"
+	// JobRunning is a job some, but not all, targeted minions have returned for.
+	JobRunning JobStatus = "running"
+	// JobComplete is a job every targeted minion has returned for.
+	JobComplete JobStatus = "complete"
+	// JobPartial is a job that reached its timeout with some targeted
+	// minions still missing.
+	JobPartial JobStatus = "partial"
+	// JobTimedOut is a job that reached its timeout without any returns.
+	JobTimedOut JobStatus = "timed-out"
+)
+
+// JobStatuses lists every job status.
+var JobStatuses = []JobStatus{JobPending, JobRunning, JobComplete, JobPartial, JobTimedOut}
+
+// Valid reports whether s is one of JobStatuses.
+func (s JobStatus) Valid() bool {
+	for _, status := range JobStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reports whether a job in status s is still within its timeout. Closed
+// partial and timed out jobs change status only when late returns arrive.
+func (s JobStatus) Open() bool {
+	return s == JobPending || s == JobRunning
+}
+
+// JobState is the tracked status of a job in the jids table. Expected is the
+// number of minions the job load lists, and Returned the number of minions
+// that have returned for it.
+type JobState struct {
+	JID       string     `json:"jid" gorm:"column:jid;type:varchar(20);primaryKey"`
+	State     JobStatus  `json:"state" gorm:"type:varchar(16);not null;index"`
+	Expected  int        `json:"expected" gorm:"not null"`
+	Returned  int        `json:"returned" gorm:"not null"`
+	StartTime *time.Time `json:"start_time" gorm:"type:timestamp with time zone"`
+	UpdatedAt time.Time  `json:"updated_at" gorm:"type:timestamp with time zone;not null"`
+}
+
+func (JobState) TableName() string {
+	return "job_state"
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of LoginFailure subjects.
+const (
+	LoginFailureUsername = "username"
+	LoginFailureClientIP = "client_ip"
+)
+
+// LoginFailure counts the recent failed password logins of one username or
+// client IP address. Attempts are refused until RetryAt, and until
+// LockedUntil once the count reaches the configured threshold. The row is
+// kept in the database so every replica sees the same counters.
+type LoginFailure struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind" gorm:"type:varchar(16);not null;uniqueIndex:idx_login_failure_subject"`
+	Subject       string     `json:"subject" gorm:"type:varchar(255);not null;uniqueIndex:idx_login_failure_subject"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"type:timestamp with time zone;not null"`
+	RetryAt       *time.Time `json:"retry_at" gorm:"type:timestamp with time zone"`
+	LockedUntil   *time.Time `json:"locked_until" gorm:"type:timestamp with time zone;index"`
+}
+
+func (LoginFailure) TableName() string {
+	return "login_failure"
+}
+
+// BlockedUntil returns the earliest time another attempt is accepted. It is
+// the zero time when attempts are allowed immediately.
+func (failure LoginFailure) BlockedUntil() time.Time {
+	var until time.Time
+	for _, candidate := range []*time.Time{failure.RetryAt, failure.LockedUntil} {
+		if candidate != nil && candidate.After(until) {
+			until = *candidate
+		}
+	}
+	return until
+}
+
+// LoginFailureSubject normalizes the username or IP address counted for kind,
+// so that differently cased spellings of a username share one counter.
+func LoginFailureSubject(kind, value string) string {
+	value = strings.TrimSpace(value)
+	if kind == LoginFailureUsername {
+		value = strings.ToLower(value)
+	}
+	return value
+}
+
+// RecordLoginFailure atomically counts a failed login at now. Failures older
+// than windowStart are forgotten, so the count restarts at one.
+func RecordLoginFailure(db *gorm.DB, kind, subject string, now, windowStart time.Time) (LoginFailure, error) {
+	var failure LoginFailure
+	err := db.Raw(`
+		INSERT INTO login_failure (kind, subject, failures, last_failure_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (kind, subject) DO UPDATE SET
+			failures = CASE WHEN login_failure.last_failure_at < ? THEN 1 ELSE login_failure.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING *
+	`, kind, subject, now, windowStart).Scan(&failure).Error
+	return failure, err
+}
+
+// ClearLoginFailures forgets the failures counted for subject.
+func ClearLoginFailures(db *gorm.DB, kind, subject string) error {
+	return db.Where("kind = ? AND subject = ?", kind, subject).Delete(&LoginFailure{}).Error
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordResetToken is a single-use token that lets a local user choose a
+// new password. Only the SHA-256 hash of the token is stored.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"type:timestamp with time zone;not null"`
+	UsedAt    *time.Time `json:"used_at" gorm:"type:timestamp with time zone"`
+	CreatedAt time.Time  `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User      AuthUser   `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_token"
+}
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshToken is a single-use credential that exchanges for a new access
+// token and a replacement refresh token. Tokens issued from one login share a
+// FamilyID, which is also the sid claim of every access token in that chain.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;not null"`
+	FamilyID  string     `json:"family_id" gorm:"type:varchar(64);index;not null"`
+	TokenHash string     `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"type:timestamp with time zone;not null"`
+	UsedAt    *time.Time `json:"used_at" gorm:"type:timestamp with time zone"`
+	RevokedAt *time.Time `json:"revoked_at" gorm:"type:timestamp with time zone"`
+	CreatedAt time.Time  `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User      AuthUser   `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_token"
+}
+
+// HashRefreshToken returns the hex SHA-256 digest stored for token.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
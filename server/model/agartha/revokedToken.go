@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+)
+
+// RevokedToken denies access tokens before they expire. ID holds either the
+// jti of a single access token or the sid of a whole refresh token family.
+// Rows are only needed until ExpiresAt, after which the tokens they cover are
+// rejected on expiry anyway.
+type RevokedToken struct {
+	ID        string    `json:"id" gorm:"type:varchar(64);primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"type:timestamp with time zone;index;not null"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+}
+
+func (RevokedToken) TableName() string {
+	return "revoked_token"
+}
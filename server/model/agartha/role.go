@@ -0,0 +1,169 @@
+package model
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Capability is an Agartha permission granted through roles. Capabilities
+// authorize Agartha's own views and actions independently of Salt eauth.
+type Capability string
+
+const (
+	// CapabilityViewReturns allows reading jobs, returns, events, highstates
+	// and conformity.
+	CapabilityViewReturns Capability = "view-returns"
+	// CapabilityViewPillar allows reading minion grains, pillar and the
+	// Salt cache.
+	CapabilityViewPillar Capability = "view-pillar"
+	// CapabilityManageKeys allows listing, accepting, rejecting and deleting
+	// minion keys.
+	CapabilityManageKeys Capability = "manage-keys"
+	// CapabilityRefreshViews allows refreshing the materialized views behind
+	// conformity, the Salt cache and minion keys.
+	CapabilityRefreshViews Capability = "refresh-views"
+	// CapabilityAdminUsers allows administering accounts other than
+	// superusers, staff and holders of capabilities that are not read-only.
+	CapabilityAdminUsers Capability = "admin-users"
+	// CapabilityViewSecrets exempts Salt data from the configured redaction
+	// rules.
+	CapabilityViewSecrets Capability = "view-secrets"
+)
+
+// Capabilities lists every capability a role can grant.
+var Capabilities = []Capability{
+	CapabilityViewReturns,
+	CapabilityViewPillar,
+	CapabilityManageKeys,
+	CapabilityRefreshViews,
+	CapabilityAdminUsers,
+	CapabilityViewSecrets,
+}
+
+// Valid reports whether c is one of Capabilities.
+func (c Capability) Valid() bool {
+	for _, capability := range Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeCapabilities returns capabilities without duplicates, or an error
+// naming the first entry that is not one of Capabilities.
+func NormalizeCapabilities(capabilities []Capability) ([]Capability, error) {
+	seen := make(map[Capability]struct{}, len(capabilities))
+	normalized := make([]Capability, 0, len(capabilities))
+	for _, capability := range capabilities {
+		if !capability.Valid() {
+			return nil, fmt.Errorf("unknown capability %q", capability)
+		}
+		if _, exists := seen[capability]; exists {
+			continue
+		}
+		seen[capability] = struct{}{}
+		normalized = append(normalized, capability)
+	}
+	return normalized, nil
+}
+
+// ReadOnly reports whether c only grants reads. Read-only capabilities never
+// authorize requests that change anything.
+func (c Capability) ReadOnly() bool {
+	return c == CapabilityViewReturns || c == CapabilityViewPillar
+}
+
+// Role is a named set of capabilities. Users receive a role's capabilities
+// through the groups it is assigned to.
+type Role struct {
+	ID           uint         `json:"id" gorm:"primaryKey"`
+	Name         string       `json:"name" gorm:"type:varchar(150);not null;uniqueIndex"`
+	Description  string       `json:"description" gorm:"type:text;not null;default:''"`
+	Capabilities []Capability `json:"capabilities" gorm:"-"`
+}
+
+func (Role) TableName() string {
+	return "rbac_role"
+}
+
+// RoleCapability grants Capability to every member of a group holding the
+// role.
+type RoleCapability struct {
+	RoleID     uint       `json:"role_id" gorm:"primaryKey"`
+	Capability Capability `json:"capability" gorm:"primaryKey;type:varchar(64)"`
+	Role       Role       `json:"-" gorm:"foreignKey:RoleID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (RoleCapability) TableName() string {
+	return "rbac_role_capability"
+}
+
+const userCapabilitiesQuery = `SELECT DISTINCT rc.capability FROM rbac_role_capability rc
+JOIN rbac_group_role gr ON gr.role_id = rc.role_id
+JOIN rbac_group_member gm ON gm.group_id = gr.group_id
+WHERE gm.user_id = ?
+ORDER BY rc.capability`
+
+// UserCapabilities returns the capabilities granted to the user by the roles
+// of every group they belong to.
+func UserCapabilities(db *gorm.DB, userID uint) ([]Capability, error) {
+	var capabilities []Capability
+	err := db.Raw(userCapabilitiesQuery, userID).Scan(&capabilities).Error
+	return capabilities, err
+}
+
+// HasCapability reports whether one of the user's roles grants capability.
+func HasCapability(db *gorm.DB, userID uint, capability Capability) (bool, error) {
+	capabilities, err := UserCapabilities(db, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, granted := range capabilities {
+		if granted == capability {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReplaceRoleCapabilities stores capabilities, as returned by
+// NormalizeCapabilities, as the role's complete grant. It is meant to run
+// inside a transaction.
+func ReplaceRoleCapabilities(db *gorm.DB, roleID uint, capabilities []Capability) error {
+	if err := db.Where("role_id = ?", roleID).Delete(&RoleCapability{}).Error; err != nil {
+		return err
+	}
+	if len(capabilities) == 0 {
+		return nil
+	}
+	rows := make([]RoleCapability, 0, len(capabilities))
+	for _, capability := range capabilities {
+		rows = append(rows, RoleCapability{RoleID: roleID, Capability: capability})
+	}
+	return db.Create(&rows).Error
+}
+
+// LoadRoleCapabilities fills in the Capabilities of roles.
+func LoadRoleCapabilities(db *gorm.DB, roles []Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	index := make(map[uint]int, len(roles))
+	ids := make([]uint, 0, len(roles))
+	for i := range roles {
+		index[roles[i].ID] = i
+		ids = append(ids, roles[i].ID)
+		roles[i].Capabilities = []Capability{}
+	}
+	var rows []RoleCapability
+	if err := db.Where("role_id IN ?", ids).Order("role_id, capability").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		role := &roles[index[row.RoleID]]
+		role.Capabilities = append(role.Capabilities, row.Capability)
+	}
+	return nil
+}
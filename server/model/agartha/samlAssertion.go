@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+)
+
+// SAMLAssertion remembers the ID of a SAML assertion that started a session,
+// so the same signed response cannot be posted again. Rows are only needed
+// until ExpiresAt, after which the assertion is rejected as expired anyway.
+type SAMLAssertion struct {
+	ID        string    `json:"id" gorm:"type:varchar(255);primaryKey"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"type:timestamp with time zone;index;not null"`
+}
+
+func (SAMLAssertion) TableName() string {
+	return "saml_assertion"
+}
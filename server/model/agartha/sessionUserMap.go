@@ -2,16 +2,87 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // SessionUserMap represents the mapping of session identifiers to user IDs
 type SessionUserMap struct {
-	SessionID string    `json:"id" gorm:"primaryKey;type:varchar(255);not null"`
-	UserID    uint      `json:"user_id" gorm:"index;not null"`
-	CreatedAt time.Time `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
-	User      AuthUser  `json:"user" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	SessionID  string     `json:"id" gorm:"primaryKey;type:varchar(255);not null"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	FamilyID   string     `json:"-" gorm:"type:varchar(64);not null;default:''"`
+	ClientIP   string     `json:"client_ip" gorm:"type:varchar(64);not null;default:''"`
+	UserAgent  string     `json:"user_agent" gorm:"type:varchar(512);not null;default:''"`
+	LastSeenAt *time.Time `json:"last_seen_at" gorm:"type:timestamp with time zone"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User       AuthUser   `json:"user" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	// ProviderSessionID identifies the login at the identity provider, such
+	// as the CAS service ticket, so single logout can find the session.
+	ProviderSessionID string `json:"-" gorm:"type:varchar(255);not null;default:'';index"`
 }
 
 func (SessionUserMap) TableName() string {
 	return "session_user_map"
 }
+
+// ActiveSession is an unexpired browser session as shown to its owner or to
+// an administrator.
+type ActiveSession struct {
+	ID         string     `json:"id"`
+	UserID     uint       `json:"user_id"`
+	ClientIP   string     `json:"client_ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current" gorm:"-"`
+}
+
+// ActiveSessions lists the user's unexpired browser sessions, most recently
+// used first.
+func ActiveSessions(db *gorm.DB, userID uint) ([]ActiveSession, error) {
+	sessions := []ActiveSession{}
+	err := db.Raw(`
+		SELECT m.session_id AS id, m.user_id, m.client_ip, m.user_agent, m.created_at, m.last_seen_at, s.expires_at
+		FROM session_user_map m
+		JOIN sessions s ON s.id = m.session_id
+		WHERE m.user_id = ? AND s.expires_at > now()
+		ORDER BY COALESCE(m.last_seen_at, m.created_at) DESC
+	`, userID).Scan(&sessions).Error
+	return sessions, err
+}
+
+// EndSession deletes one of the user's browser sessions, revokes the token
+// family issued with it and clears the hashed bearer token kept in
+// user_settings. It reports false when the user has no such session. It is
+// meant to run inside a transaction.
+func EndSession(db *gorm.DB, userID uint, sessionID string) (bool, error) {
+	var sessionMap SessionUserMap
+	result := db.Where("session_id = ? AND user_id = ?", sessionID, userID).Limit(1).Find(&sessionMap)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	if sessionMap.FamilyID != "" {
+		if err := db.Exec(`
+			INSERT INTO revoked_token (id, user_id, expires_at, revoked_at)
+			SELECT family_id, user_id, MAX(expires_at), now()
+			FROM refresh_token
+			WHERE family_id = ? AND revoked_at IS NULL AND expires_at > now()
+			GROUP BY family_id, user_id
+			ON CONFLICT (id) DO NOTHING
+		`, sessionMap.FamilyID).Error; err != nil {
+			return false, err
+		}
+		if err := db.Exec(`UPDATE refresh_token SET revoked_at = now() WHERE family_id = ? AND revoked_at IS NULL`, sessionMap.FamilyID).Error; err != nil {
+			return false, err
+		}
+	}
+	if err := db.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID).Error; err != nil {
+		return false, err
+	}
+	if err := db.Exec(`DELETE FROM session_user_map WHERE session_id = ?`, sessionID).Error; err != nil {
+		return false, err
+	}
+	return true, db.Exec(`UPDATE user_settings SET token = '' WHERE user_id = ?`, userID).Error
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+const sessionMapQuery = `SELECT * FROM "session_user_map" WHERE session_id = $1 AND user_id = $2 LIMIT $3`
+
+func TestEndSessionRevokesTokenFamily(t *testing.T) {
+	db, mock := setupTestDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sessionMapQuery)).
+		WithArgs("session-1", 7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id", "user_id", "family_id"}).AddRow("session-1", 7, "family-1"))
+	mock.ExpectExec(`INSERT INTO revoked_token .* WHERE family_id = \$1`).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE refresh_token SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`)).
+		WithArgs("family-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM sessions WHERE id = $1`)).
+		WithArgs("session-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM session_user_map WHERE session_id = $1`)).
+		WithArgs("session-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE user_settings SET token = '' WHERE user_id = $1`)).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	found, err := EndSession(db, 7, "session-1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEndSessionIgnoresOtherUsersSessions(t *testing.T) {
+	db, mock := setupTestDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(sessionMapQuery)).
+		WithArgs("session-1", 8, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"session_id"}))
+
+	found, err := EndSession(db, 8, "session-1")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestActiveSessionsJoinsUnexpiredSessions(t *testing.T) {
+	db, mock := setupTestDB(t)
+
+	mock.ExpectQuery(`FROM session_user_map m\s+JOIN sessions s ON s.id = m.session_id\s+WHERE m.user_id = \$1 AND s.expires_at > now\(\)`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "client_ip", "user_agent"}).
+			AddRow("session-1", 7, "192.0.2.10", "curl/8.0"))
+
+	active, err := ActiveSessions(db, 7)
+	require.NoError(t, err)
+	require.Len(t, active, 1)
+	require.Equal(t, "192.0.2.10", active[0].ClientIP)
+	require.False(t, active[0].Current)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
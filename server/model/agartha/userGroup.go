@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserGroup records a directory group the user belonged to at their last
+// login through Source. It is informational; authorization decisions are
+// made at login time.
+type UserGroup struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index;not null"`
+	Source     string    `json:"source" gorm:"type:varchar(32);not null"`
+	Name       string    `json:"name" gorm:"type:varchar(1024);not null"`
+	ResolvedAt time.Time `json:"resolved_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User       AuthUser  `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (UserGroup) TableName() string {
+	return "user_group"
+}
+
+// ReplaceUserGroups stores groups as the user's complete membership from
+// source. It is meant to run inside a transaction.
+func ReplaceUserGroups(db *gorm.DB, userID uint, source string, groups []string) error {
+	if err := db.Where("user_id = ? AND source = ?", userID, source).Delete(&UserGroup{}).Error; err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	now := time.Now()
+	rows := make([]UserGroup, 0, len(groups))
+	for _, group := range groups {
+		rows = append(rows, UserGroup{UserID: userID, Source: source, Name: group, ResolvedAt: now})
+	}
+	return db.Create(&rows).Error
+}
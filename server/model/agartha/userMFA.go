@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+)
+
+// UserMFA holds a user's TOTP enrollment. The shared secret is encrypted at
+// rest; the enrollment only takes effect once ConfirmedAt is set.
+type UserMFA struct {
+	UserID       uint       `json:"user_id" gorm:"primaryKey"`
+	Secret       string     `json:"-" gorm:"type:text;not null"`
+	ConfirmedAt  *time.Time `json:"confirmed_at" gorm:"type:timestamp with time zone"`
+	LastUsedStep int64      `json:"-" gorm:"not null;default:0"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"type:timestamp with time zone;not null;default:now()"`
+	User         AuthUser   `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// MFARecoveryCode is a single-use fallback for a lost authenticator. Only the
+// SHA-256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID       uint       `json:"id" gorm:"primaryKey"`
+	UserID   uint       `json:"user_id" gorm:"index;not null"`
+	CodeHash string     `json:"-" gorm:"type:char(64);not null"`
+	UsedAt   *time.Time `json:"used_at" gorm:"type:timestamp with time zone"`
+	User     AuthUser   `json:"-" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+}
+
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_code"
+}
@@ -10,12 +10,13 @@ import (
 
 // UserSettings represents the settings and permissions for a user.
 type UserSettings struct {
-	UserID          uint        `json:"user_id" gorm:"primaryKey"`
-	Token           string      `json:"token" gorm:"type:varchar(255);not null"`
-	Created         time.Time   `json:"created" gorm:"type:timestamp with time zone;not null"`
-	SaltPermissions string      `json:"salt_permissions" gorm:"type:text;not null"`
-	Settings        custom.JSON `json:"settings" gorm:"type:jsonb;not null"`
-	User            AuthUser    `json:"user" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	UserID                   uint        `json:"user_id" gorm:"primaryKey"`
+	Token                    string      `json:"token" gorm:"type:varchar(255);not null"`
+	Created                  time.Time   `json:"created" gorm:"type:timestamp with time zone;not null"`
+	SaltPermissions          string      `json:"salt_permissions" gorm:"type:text;not null"`
+	SaltPermissionsUpdatedAt *time.Time  `json:"salt_permissions_updated_at" gorm:"type:timestamp with time zone"`
+	Settings                 custom.JSON `json:"settings" gorm:"type:jsonb;not null"`
+	User                     AuthUser    `json:"user" gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
 }
 
 // TableName returns the table name for the UserSettings struct.
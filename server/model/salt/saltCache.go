@@ -15,6 +15,10 @@ type SaltCache struct {
 	AlterTime *time.Time  `json:"alter_time" gorm:"type:TIMESTAMP WITH TIME ZONE;DEFAULT:now();index:idx_salt_cache_updated" example:"2006-01-02T15:04:05.999999-07:00"`
 }
 
+// SaltCacheMinionID extracts the minion ID from the bank of a per-minion cache
+// row. It is NULL for banks that do not belong to a single minion.
+const SaltCacheMinionID = "CASE WHEN bank LIKE 'minions/%' THEN substr(bank, 9) END"
+
 func (SaltCache) TableName() string {
 	return "salt_cache"
 }
@@ -14,6 +14,10 @@ type SaltEvent struct {
 	ID        int64       `json:"id" gorm:"type:BIGSERIAL;NOT NULL;UNIQUE;index:idx_salt_events_id" example:"15167725"`
 }
 
+// SaltEventMinionID extracts the ID of the minion an event came from or is
+// about. It is NULL for events without one, such as new job announcements.
+const SaltEventMinionID = "(data::jsonb ->> 'id')"
+
 func (SaltEvent) TableName() string {
 	return "salt_events"
 }
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+)
+
+// HighStateState is one state from a highstate return.
+// Result is null for states run with test=True that would make changes.
+type HighStateState struct {
+	ID         string     `json:"id" gorm:"->;type:varchar(255);not null;" example:"server.example.com"`
+	JID        string     `json:"jid" gorm:"->;column:jid;type:varchar(20);not null;" example:"20060102150405999999"`
+	AlterTime  *time.Time `json:"alter_time" gorm:"->;type:TIMESTAMP WITH TIME ZONE;" example:"2006-01-02T15:04:05.999999-07:00"`
+	StateKey   string     `json:"state_key" gorm:"->;type:text;not null" example:"service_|-nginx_service_|-nginx_|-running"`
+	StateID    string     `json:"state_id" gorm:"->;type:text;not null" example:"nginx_service"`
+	Fun        string     `json:"fun" gorm:"->;type:text;not null" example:"service.running"`
+	Name       string     `json:"name" gorm:"->;type:text;not null" example:"nginx"`
+	SLS        *string    `json:"sls" gorm:"->;column:sls;type:text" example:"web.nginx"`
+	RunNum     *int       `json:"run_num" gorm:"->;type:integer" example:"12"`
+	Result     *bool      `json:"result" gorm:"->;type:boolean" example:"false"`
+	Comment    *string    `json:"comment" gorm:"->;type:text" example:"Service nginx failed to start"`
+	Duration   *float64   `json:"duration" gorm:"->;type:double precision" example:"15.5"`
+	StartTime  *string    `json:"start_time" gorm:"->;type:text" example:"15:04:05.999999"`
+	HasChanges bool       `json:"has_changes" gorm:"->;type:boolean;not null" example:"false"`
+}
+
+func (HighStateState) TableName() string {
+	return "vw_salt_highstate_states"
+}
@@ -0,0 +1,31 @@
+// Package notify delivers out-of-band messages, such as password reset
+// links, to Agartha users.
+package notify
+
+import (
+	"context"
+	"strings"
+
+	"github.com/PaulChristophel/agartha/server/config"
+)
+
+// Message is a plain-text notification addressed to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers messages to users.
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// New returns the notifier described by the configuration, or nil when no
+// delivery channel is configured.
+func New(smtp config.SMTPOptions) Notifier {
+	if strings.TrimSpace(smtp.Host) == "" {
+		return nil
+	}
+	return NewSMTPNotifier(smtp)
+}
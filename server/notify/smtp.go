@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PaulChristophel/agartha/server/config"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier sends messages through an SMTP relay. Connections are upgraded
+// with STARTTLS whenever the relay advertises it.
+type SMTPNotifier struct {
+	options config.SMTPOptions
+}
+
+func NewSMTPNotifier(options config.SMTPOptions) *SMTPNotifier {
+	return &SMTPNotifier{options: options}
+}
+
+func (notifier *SMTPNotifier) Notify(ctx context.Context, message Message) error {
+	from, err := mail.ParseAddress(notifier.options.From)
+	if err != nil {
+		return fmt.Errorf("invalid smtp.from address: %w", err)
+	}
+	to, err := mail.ParseAddress(message.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	body, err := buildMessage(from, to, message)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, smtpTimeout)
+		defer cancel()
+	}
+	address := net.JoinHostPort(notifier.options.Host, strconv.Itoa(notifier.options.Port))
+	tlsConfig := &tls.Config{ServerName: notifier.options.Host, MinVersion: tls.VersionTLS12}
+	var conn net.Conn
+	if notifier.options.ImplicitTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to SMTP relay: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	client, err := smtp.NewClient(conn, notifier.options.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("start SMTP session: %w", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	if !notifier.options.ImplicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("start SMTP TLS: %w", err)
+			}
+		}
+	}
+	if notifier.options.Username != "" {
+		auth := smtp.PlainAuth("", notifier.options.Username, notifier.options.Password, notifier.options.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("authenticate to SMTP relay: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT TO: %w", err)
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA: %w", err)
+	}
+	if _, err := writer.Write(body); err != nil {
+		_ = writer.Close()
+		return fmt.Errorf("write SMTP message: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("send SMTP message: %w", err)
+	}
+	return client.Quit()
+}
+
+func buildMessage(from, to *mail.Address, message Message) ([]byte, error) {
+	if strings.ContainsAny(message.Subject, "\r\n") {
+		return nil, errors.New("message subject must not contain line breaks")
+	}
+
+	var buffer bytes.Buffer
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=utf-8"},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buffer, "%s: %s\r\n", header[0], header[1])
+	}
+	buffer.WriteString("\r\n")
+	body := strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n")
+	buffer.WriteString(body)
+	if !strings.HasSuffix(body, "\r\n") {
+		buffer.WriteString("\r\n")
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/stretchr/testify/require"
+)
+
+// testSMTPServer is a minimal SMTP relay that records one delivery.
+type testSMTPServer struct {
+	listener net.Listener
+	received chan testSMTPDelivery
+}
+
+type testSMTPDelivery struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func newTestSMTPServer(t *testing.T) *testSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &testSMTPServer{listener: listener, received: make(chan testSMTPDelivery, 1)}
+	t.Cleanup(func() { _ = listener.Close() })
+	go server.serve()
+	return server
+}
+
+func (server *testSMTPServer) port() int {
+	return server.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (server *testSMTPServer) serve() {
+	conn, err := server.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	var delivery testSMTPDelivery
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(command, " ", 2)[0]); verb {
+		case "EHLO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			credentials, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(command, "AUTH PLAIN "))
+			delivery.auth = string(credentials)
+			reply("235 Authentication successful")
+		case "MAIL":
+			delivery.from = command
+			reply("250 OK")
+		case "RCPT":
+			delivery.to = append(delivery.to, command)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			delivery.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			server.received <- delivery
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifierDeliversMessage(t *testing.T) {
+	server := newTestSMTPServer(t)
+	notifier := New(config.SMTPOptions{
+		Host:     "127.0.0.1",
+		Port:     server.port(),
+		Username: "agartha",
+		Password: "relay-password",
+		From:     "Agartha <agartha@agartha.test>",
+	})
+	require.NotNil(t, notifier)
+
+	err := notifier.Notify(context.Background(), Message{
+		To:      "alice@agartha.test",
+		Subject: "Reset your Agartha password",
+		Body:    "Line one\nLine two",
+	})
+	require.NoError(t, err)
+
+	delivery := <-server.received
+	require.Equal(t, "\x00agartha\x00relay-password", delivery.auth)
+	require.Equal(t, "MAIL FROM:<agartha@agartha.test>", strings.Split(delivery.from, " BODY")[0])
+	require.Equal(t, []string{"RCPT TO:<alice@agartha.test>"}, delivery.to)
+	require.Contains(t, delivery.data, "Subject: Reset your Agartha password\r\n")
+	require.Contains(t, delivery.data, "To: <alice@agartha.test>\r\n")
+	require.True(t, strings.HasSuffix(delivery.data, "\r\n\r\nLine one\r\nLine two\r\n"))
+}
+
+func TestSMTPNotifierRejectsInvalidMessages(t *testing.T) {
+	notifier := NewSMTPNotifier(config.SMTPOptions{Host: "127.0.0.1", Port: 25, From: "agartha@agartha.test"})
+
+	err := notifier.Notify(context.Background(), Message{To: "not an address", Subject: "Subject"})
+	require.ErrorContains(t, err, "recipient")
+
+	err = notifier.Notify(context.Background(), Message{To: "alice@agartha.test", Subject: "Subject\r\nBcc: mallory@agartha.test"})
+	require.ErrorContains(t, err, "line breaks")
+}
+
+func TestNewReturnsNilWithoutDeliveryChannel(t *testing.T) {
+	require.Nil(t, New(config.SMTPOptions{Port: 587}))
+	require.NotNil(t, New(config.SMTPOptions{Host: "127.0.0.1", Port: 25}))
+}
@@ -0,0 +1,423 @@
+// Package redact masks secrets in Salt data before it is returned to clients.
+package redact
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PaulChristophel/agartha/server/config"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/model/custom"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Header is set to true on responses in which values were masked.
+const Header = "X-Agartha-Redacted"
+
+// Rules is a compiled set of redaction rules.
+type Rules struct {
+	paths  [][]*regexp.Regexp
+	keys   []*regexp.Regexp
+	values []*regexp.Regexp
+	mask   string
+}
+
+var (
+	mu      sync.RWMutex
+	current *Rules
+)
+
+// Compile builds rules from options.
+func Compile(options config.RedactionOptions) (*Rules, error) {
+	rules := &Rules{mask: options.Mask}
+	for _, path := range options.Paths {
+		var segments []*regexp.Regexp
+		for _, segment := range strings.Split(path, ".") {
+			segments = append(segments, glob(segment))
+		}
+		rules.paths = append(rules.paths, segments)
+	}
+	for _, key := range options.Keys {
+		rules.keys = append(rules.keys, glob(strings.ToLower(key)))
+	}
+	for _, value := range options.Values {
+		expression, err := regexp.Compile(value)
+		if err != nil {
+			return nil, fmt.Errorf("compile redaction value %q: %w", value, err)
+		}
+		rules.values = append(rules.values, expression)
+	}
+	return rules, nil
+}
+
+// Configure replaces the rules applied to responses. Options without any
+// rule disable redaction.
+func Configure(options config.RedactionOptions) error {
+	rules, err := Compile(options)
+	if err != nil {
+		return err
+	}
+	if rules.empty() {
+		rules = nil
+	}
+	mu.Lock()
+	current = rules
+	mu.Unlock()
+	return nil
+}
+
+// Selection is one value a jsonpath parameter copied into a subset field.
+// Key names it in the subset and Path lists the keys leading to it in the
+// whole field.
+type Selection struct {
+	Key  string
+	Path []string
+}
+
+// Subsets maps the JSON names of fields holding subsets built from jsonpath
+// parameters to the values selected into them.
+type Subsets map[string][]Selection
+
+// Response masks the custom.JSON fields reachable from value, which must be a
+// pointer, slice or map, unless the caller may see secrets. The Header is set
+// when anything was masked.
+func Response(c *gin.Context, value any) {
+	SubsetResponse(c, value, nil)
+}
+
+// SubsetResponse is Response for results whose fields named in subsets hold
+// selected values rather than whole documents. Path rules are matched
+// against the place each value was selected from, so a rule such as
+// pillar.mysql.*.password still masks pillar:mysql:root:password when it is
+// selected on its own.
+func SubsetResponse(c *gin.Context, value any, subsets Subsets) {
+	mu.RLock()
+	rules := current
+	mu.RUnlock()
+	if rules == nil || maySeeSecrets(c) {
+		return
+	}
+	if rules.ApplySubsets(value, subsets) {
+		c.Header(Header, "true")
+	}
+}
+
+// Field is a string a view copied out of Salt data into a plain column. Path
+// lists the keys it was copied from, starting with the JSON name of the
+// data, e.g. return, a state key and comment for a state's comment.
+type Field struct {
+	Value *string
+	Path  []string
+}
+
+// Fields masks each field as Response would mask the value at its path,
+// unless the caller may see secrets. Nil values are skipped. The Header is
+// set when anything was masked.
+func Fields(c *gin.Context, fields []Field) {
+	mu.RLock()
+	rules := current
+	mu.RUnlock()
+	if rules == nil || len(fields) == 0 || maySeeSecrets(c) {
+		return
+	}
+	if rules.ApplyFields(fields) {
+		c.Header(Header, "true")
+	}
+}
+
+// HidesFilter reports whether a filter comparing the value at keys within
+// field could tell the caller something about data masked for them: the value
+// or one of its parents is masked, or path rules mask values below it.
+// Pattern filters can also probe, one character at a time, values masked by
+// key and value rules anywhere in the text they match, so they are refused
+// whenever such rules exist. Handlers refuse these filters, which would
+// otherwise compare against unmasked data.
+func HidesFilter(c *gin.Context, field string, keys []string, pattern bool) bool {
+	mu.RLock()
+	rules := current
+	mu.RUnlock()
+	if rules == nil || maySeeSecrets(c) {
+		return false
+	}
+	if pattern && (len(rules.keys) > 0 || len(rules.values) > 0) {
+		return true
+	}
+	return rules.touches(append([]string{field}, keys...))
+}
+
+func maySeeSecrets(c *gin.Context) bool {
+	user, ok := middleware.AuthenticatedUser(c)
+	if !ok {
+		return false
+	}
+	if user.IsSuperuser {
+		return true
+	}
+	granted, err := model.HasCapability(db.DB, user.ID, model.CapabilityViewSecrets)
+	if err != nil {
+		logger.GetLogger().Error("Failed to load capabilities for redaction", zap.Uint("user_id", user.ID), zap.Error(err))
+		return false
+	}
+	return granted
+}
+
+func (r *Rules) empty() bool {
+	return len(r.paths) == 0 && len(r.keys) == 0 && len(r.values) == 0
+}
+
+// Apply masks the custom.JSON fields reachable from value in place and
+// reports whether anything was masked. Struct fields are matched against
+// path rules by their JSON names.
+func (r *Rules) Apply(value any) bool {
+	return r.ApplySubsets(value, nil)
+}
+
+// ApplySubsets is Apply for values whose fields named in subsets hold
+// selected values.
+func (r *Rules) ApplySubsets(value any, subsets Subsets) bool {
+	return r.walk(reflect.ValueOf(value), "", subsets)
+}
+
+// ApplyFields masks fields in place and reports whether anything was masked.
+func (r *Rules) ApplyFields(fields []Field) bool {
+	masked := false
+	for _, field := range fields {
+		if field.Value == nil {
+			continue
+		}
+		if r.masks(field.Path) {
+			*field.Value = r.mask
+			masked = true
+			continue
+		}
+		if data, valueMasked := r.redact(*field.Value, field.Path); valueMasked {
+			*field.Value = data.(string)
+			masked = true
+		}
+	}
+	return masked
+}
+
+func (r *Rules) walk(value reflect.Value, field string, subsets Subsets) bool {
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return false
+		}
+		return r.walk(value.Elem(), field, subsets)
+	case reflect.Slice, reflect.Array:
+		masked := false
+		for i := 0; i < value.Len(); i++ {
+			masked = r.walk(value.Index(i), field, subsets) || masked
+		}
+		return masked
+	case reflect.Map:
+		masked := false
+		for _, key := range value.MapKeys() {
+			element := reflect.New(value.Type().Elem()).Elem()
+			element.Set(value.MapIndex(key))
+			if r.walk(element, fmt.Sprint(key.Interface()), subsets) {
+				value.SetMapIndex(key, element)
+				masked = true
+			}
+		}
+		return masked
+	case reflect.Struct:
+		if value.Type() == reflect.TypeOf(custom.JSON{}) {
+			if !value.CanSet() {
+				return false
+			}
+			payload := value.Addr().Interface().(*custom.JSON)
+			var data any
+			var masked bool
+			if selections, ok := subsets[field]; ok {
+				data, masked = r.redactSubset(payload.Data, field, selections)
+			} else {
+				data, masked = r.redact(payload.Data, []string{field})
+			}
+			payload.Data = data
+			return masked
+		}
+		masked := false
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			if !structField.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = structField.Name
+			}
+			masked = r.walk(value.Field(i), name, subsets) || masked
+		}
+		return masked
+	}
+	return false
+}
+
+func (r *Rules) redact(data any, path []string) (any, bool) {
+	switch node := data.(type) {
+	case map[string]any:
+		masked := false
+		for key, child := range node {
+			childPath := append(path[:len(path):len(path)], key)
+			if r.matchesKey(key) || r.matchesPath(childPath) {
+				node[key] = r.mask
+				masked = true
+				continue
+			}
+			var childMasked bool
+			node[key], childMasked = r.redact(child, childPath)
+			masked = masked || childMasked
+		}
+		return node, masked
+	case []any:
+		masked := false
+		for i, child := range node {
+			childPath := append(path[:len(path):len(path)], strconv.Itoa(i))
+			if r.matchesPath(childPath) {
+				node[i] = r.mask
+				masked = true
+				continue
+			}
+			var childMasked bool
+			node[i], childMasked = r.redact(child, childPath)
+			masked = masked || childMasked
+		}
+		return node, masked
+	case string:
+		for _, expression := range r.values {
+			if expression.MatchString(node) {
+				return r.mask, true
+			}
+		}
+	}
+	return data, false
+}
+
+// redactSubset masks a subset object by redacting each selected value as if
+// it were still at the path it was selected from.
+func (r *Rules) redactSubset(data any, field string, selections []Selection) (any, bool) {
+	node, ok := data.(map[string]any)
+	if !ok {
+		return r.redact(data, []string{field})
+	}
+	masked := false
+	for key, child := range node {
+		var paths [][]string
+		for _, selection := range selections {
+			if selection.Key == key {
+				paths = append(paths, append([]string{field}, selection.Path...))
+			}
+		}
+		if len(paths) == 0 {
+			paths = [][]string{{field, key}}
+		}
+		for _, path := range paths {
+			if r.masks(path) {
+				child = r.mask
+				masked = true
+				break
+			}
+			var childMasked bool
+			child, childMasked = r.redact(child, path)
+			masked = masked || childMasked
+		}
+		node[key] = child
+	}
+	return node, masked
+}
+
+// masks reports whether redact would replace the value at path, or one of
+// its parents, with the mask. path starts with the field name.
+func (r *Rules) masks(path []string) bool {
+	for i := 1; i < len(path); i++ {
+		if r.matchesKey(path[i]) || r.matchesPath(path[:i+1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// touches reports whether the value at path is masked or contains values
+// that path rules mask.
+func (r *Rules) touches(path []string) bool {
+	if r.masks(path) {
+		return true
+	}
+	for _, pattern := range r.paths {
+		if len(pattern) <= len(path) {
+			continue
+		}
+		below := true
+		for i, segment := range path {
+			if !pattern[i].MatchString(segment) {
+				below = false
+				break
+			}
+		}
+		if below {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rules) matchesKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range r.keys {
+		if pattern.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rules) matchesPath(path []string) bool {
+	for _, pattern := range r.paths {
+		if len(pattern) != len(path) {
+			continue
+		}
+		matched := true
+		for i, segment := range pattern {
+			if !segment.MatchString(path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// glob compiles a pattern in which * matches any run of characters and ?
+// matches a single character.
+func glob(pattern string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for _, character := range pattern {
+		switch character {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(character)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
@@ -0,0 +1,291 @@
+package routes
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PaulChristophel/agartha/server/db"
+	"github.com/PaulChristophel/agartha/server/logger"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestUserAdministrationRoutesRequireAdministrators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		superuser    bool
+		checksRoles  bool
+		capabilities []string
+		expect       func(sqlmock.Sqlmock)
+		wantStatus   int
+	}{
+		{
+			name:        "list as regular user",
+			checksRoles: true,
+			method:      http.MethodGet,
+			path:        "/api/v1/secure/auth_user",
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:        "create as regular user",
+			checksRoles: true,
+			method:      http.MethodPost,
+			path:        "/api/v1/secure/auth_user",
+			body:        `{"username":"bob","password":"secret"}`,
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:        "delete as regular user",
+			checksRoles: true,
+			method:      http.MethodDelete,
+			path:        "/api/v1/secure/auth_user/9",
+			wantStatus:  http.StatusForbidden,
+		},
+		{
+			name:       "demote self",
+			method:     http.MethodPatch,
+			path:       "/api/v1/secure/auth_user/7",
+			body:       `{"is_superuser":false}`,
+			superuser:  true,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "delete self",
+			method:     http.MethodDelete,
+			path:       "/api/v1/secure/auth_user/7",
+			superuser:  true,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "delete a superuser as user administrator",
+			checksRoles:  true,
+			method:       http.MethodDelete,
+			path:         "/api/v1/secure/auth_user/9",
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{superuser: true})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "grant superuser as user administrator",
+			checksRoles:  true,
+			method:       http.MethodPatch,
+			path:         "/api/v1/secure/auth_user/9",
+			body:         `{"is_superuser":true}`,
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "reset a staff account's password as user administrator",
+			checksRoles:  true,
+			method:       http.MethodPost,
+			path:         "/api/v1/secure/auth_user/9/password",
+			body:         `{"password":"Correct-Horse-42"}`,
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{staff: true})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "deactivate a key manager as user administrator",
+			checksRoles:  true,
+			method:       http.MethodPatch,
+			path:         "/api/v1/secure/auth_user/9",
+			body:         `{"is_active":false}`,
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{capabilities: []string{"manage-keys", "view-returns"}})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "reset a Salt operator's directory password as user administrator",
+			checksRoles:  true,
+			method:       http.MethodPost,
+			path:         "/api/v1/secure/auth_user/9/password",
+			body:         `{"password":"Correct-Horse-42"}`,
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{saltPermissions: `["@wheel", {"*": [".*"]}]`})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "reset a directory user's password as user administrator",
+			checksRoles:  true,
+			method:       http.MethodPost,
+			path:         "/api/v1/secure/auth_user/9/password",
+			body:         `{"password":"Correct-Horse-42"}`,
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{saltPermissions: `[]`, groups: 1})
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:         "delete a user as user administrator",
+			checksRoles:  true,
+			method:       http.MethodDelete,
+			path:         "/api/v1/secure/auth_user/9",
+			capabilities: []string{"admin-users"},
+			expect: func(mock sqlmock.Sqlmock) {
+				expectAdministeredAccount(mock, administeredAccount{})
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "auth_user" WHERE "auth_user"."id" = $1`)).
+					WithArgs(9).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:         "manage roles as user administrator",
+			method:       http.MethodGet,
+			path:         "/api/v1/secure/role",
+			capabilities: []string{"admin-users"},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:         "explain another user's permissions as user administrator",
+			method:       http.MethodGet,
+			path:         "/api/v1/secure/auth_user/9/permission",
+			capabilities: []string{"admin-users"},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:         "evaluate another user's permissions as user administrator",
+			method:       http.MethodPost,
+			path:         "/api/v1/secure/auth_user/9/permission/evaluate",
+			capabilities: []string{"admin-users"},
+			wantStatus:   http.StatusForbidden,
+		},
+		{
+			name:      "delete another user",
+			method:    http.MethodDelete,
+			path:      "/api/v1/secure/auth_user/9",
+			superuser: true,
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "auth_user" WHERE "auth_user"."id" = $1`)).
+					WithArgs(9).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqlDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			database, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+				Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+			})
+			require.NoError(t, err)
+
+			previousDB := db.DB
+			previousOptions := options
+			db.DB = database
+			options.Secret = routeAuthorizationSecret
+			t.Cleanup(func() {
+				db.DB = previousDB
+				options = previousOptions
+				mock.ExpectClose()
+				require.NoError(t, sqlDB.Close())
+			})
+
+			expectUnrevokedRouteToken(mock)
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 AND username = $2 AND is_active = $3 ORDER BY "auth_user"."id" LIMIT $4`)).
+				WithArgs(uint(7), routeAuthorizationUsername, true, 1).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "username", "is_active", "is_staff", "is_superuser"}).
+					AddRow(7, routeAuthorizationUsername, true, false, tt.superuser))
+			if tt.checksRoles {
+				expectRouteCapabilities(mock, tt.capabilities...)
+			}
+			if tt.expect != nil {
+				tt.expect(mock)
+			}
+
+			engine := gin.New()
+			engine.Use(sessions.Sessions("agarthaAuthSession", cookie.NewStore([]byte(routeAuthorizationSecret))))
+			addServerRoutes(engine)
+
+			request := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			request.Header.Set("Authorization", "Bearer "+signedRouteAuthorizationToken(t))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			engine.ServeHTTP(response, request)
+
+			require.Equal(t, tt.wantStatus, response.Code, response.Body.String())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// administeredAccount describes user 9 as SuperuserAccountProtected sees it.
+type administeredAccount struct {
+	superuser, staff bool
+	capabilities     []string
+	// saltPermissions is the cached grant; empty means none was cached.
+	saltPermissions string
+	groups          int
+}
+
+// expectAdministeredAccount expects SuperuserAccountProtected to look up user
+// 9, stopping after the first check that marks the account as privileged.
+func expectAdministeredAccount(mock sqlmock.Sqlmock, account administeredAccount) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "is_superuser","is_staff" FROM "auth_user" WHERE id = $1 ORDER BY "auth_user"."id" LIMIT $2`)).
+		WithArgs(uint64(9), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"is_superuser", "is_staff"}).AddRow(account.superuser, account.staff))
+	if account.superuser || account.staff {
+		return
+	}
+	rows := sqlmock.NewRows([]string{"capability"})
+	privileged := false
+	for _, capability := range account.capabilities {
+		rows.AddRow(capability)
+		privileged = privileged || !model.Capability(capability).ReadOnly()
+	}
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(9)).
+		WillReturnRows(rows)
+	if privileged {
+		return
+	}
+	settings := sqlmock.NewRows([]string{"salt_permissions"})
+	if account.saltPermissions != "" {
+		settings.AddRow(account.saltPermissions)
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "salt_permissions" FROM "user_settings" WHERE user_id = $1`)).
+		WithArgs(uint(9), 1).
+		WillReturnRows(settings)
+	if account.saltPermissions != "" && account.saltPermissions != `[]` {
+		return
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_group" WHERE user_id = $1`)).
+		WithArgs(uint(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(account.groups))
+}
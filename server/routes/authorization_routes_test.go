@@ -37,6 +37,8 @@ type authorizationRouteCase struct {
 	allowedStatus      int
 	expectSaltKeys     bool
 	useSaltToken       bool
+	checksRoles        bool
+	capabilities       []string
 }
 
 type closeNotifyRecorder struct {
@@ -64,6 +66,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			allowedPermissions: `["@jobs"]`,
 			deniedPermissions:  `[]`,
 			allowedStatus:      http.StatusOK,
+			checksRoles:        true,
 		},
 		{
 			name:               "v1 Salt command execution",
@@ -99,6 +102,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			deniedPermissions:  `[{"@wheel":["key.accept"]}]`,
 			allowedStatus:      http.StatusNotFound,
 			expectSaltKeys:     true,
+			checksRoles:        true,
 		},
 		{
 			name:               "key.accept",
@@ -107,6 +111,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			allowedPermissions: `[{"@wheel":["key.accept"]}]`,
 			deniedPermissions:  `[{"@wheel":["key.reject"]}]`,
 			allowedStatus:      http.StatusBadRequest,
+			checksRoles:        true,
 		},
 		{
 			name:               "key.reject",
@@ -115,6 +120,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			allowedPermissions: `[{"@wheel":["key.reject"]}]`,
 			deniedPermissions:  `[{"@wheel":["key.delete"]}]`,
 			allowedStatus:      http.StatusBadRequest,
+			checksRoles:        true,
 		},
 		{
 			name:               "key.delete",
@@ -123,6 +129,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			allowedPermissions: `[{"@wheel":["key.delete"]}]`,
 			deniedPermissions:  `[{"@wheel":["key.list_all"]}]`,
 			allowedStatus:      http.StatusBadRequest,
+			checksRoles:        true,
 		},
 		{
 			name:               "raw salt_keys read",
@@ -158,6 +165,7 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 			allowedPermissions: `["@jobs"]`,
 			deniedPermissions:  `[]`,
 			allowedStatus:      http.StatusBadRequest,
+			checksRoles:        true,
 		},
 		{
 			name:               "v2 Salt cache delete",
@@ -180,6 +188,52 @@ func TestSensitiveRoutesEnforceSaltAuthorization(t *testing.T) {
 	}
 }
 
+func TestRolesGrantCapabilitiesWithoutSaltPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, err := logger.InitLogger(gin.TestMode)
+	require.NoError(t, err)
+
+	tests := []authorizationRouteCase{
+		{
+			name:          "refresh-views",
+			method:        http.MethodGet,
+			path:          "/api/v1/conformity/refresh",
+			allowedStatus: http.StatusOK,
+			checksRoles:   true,
+			capabilities:  []string{"refresh-views"},
+		},
+		{
+			name:          "manage-keys",
+			method:        http.MethodPost,
+			path:          "/api/v1/salt_keys/minion_keys/accept",
+			allowedStatus: http.StatusBadRequest,
+			checksRoles:   true,
+			capabilities:  []string{"manage-keys"},
+		},
+		{
+			name:          "view-pillar",
+			method:        http.MethodGet,
+			path:          "/api/v2/salt_cache/cache-key/",
+			allowedStatus: http.StatusBadRequest,
+			checksRoles:   true,
+			capabilities:  []string{"view-pillar"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := serveAuthorizedRoute(t, "", test, "", false)
+			require.Equal(t, test.allowedStatus, response.Code, response.Body.String())
+		})
+	}
+
+	t.Run("read capabilities are not consulted for changes", func(t *testing.T) {
+		test := authorizationRouteCase{method: http.MethodDelete, path: "/api/v2/salt_cache/cache-key/"}
+		response := serveAuthorizedRoute(t, "", test, `["@jobs"]`, false)
+		require.Equal(t, http.StatusForbidden, response.Code, response.Body.String())
+	})
+}
+
 func serveAuthorizedRoute(
 	t *testing.T,
 	upstreamURL string,
@@ -215,8 +269,14 @@ func serveAuthorizedRoute(
 		require.NoError(t, mock.ExpectationsWereMet())
 	})
 
+	expectUnrevokedRouteToken(mock)
 	expectActiveRouteUser(mock)
-	expectRouteSaltPermissions(mock, permissions)
+	if test.checksRoles {
+		expectRouteCapabilities(mock, test.capabilities...)
+	}
+	if permissions != "" {
+		expectRouteSaltPermissions(mock, permissions)
+	}
 	if expectSaltKeys {
 		expectMissingSaltKeysTable(mock)
 	}
@@ -236,6 +296,12 @@ func serveAuthorizedRoute(
 	return response.ResponseRecorder
 }
 
+func expectUnrevokedRouteToken(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "revoked_token" WHERE id IN ($1,$2)`)).
+		WithArgs("route-test-access", "route-test-family").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+}
+
 func expectActiveRouteUser(mock sqlmock.Sqlmock) {
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "auth_user" WHERE id = $1 AND username = $2 AND is_active = $3 ORDER BY "auth_user"."id" LIMIT $4`)).
 		WithArgs(uint(7), routeAuthorizationUsername, true, 1).
@@ -249,6 +315,16 @@ func expectRouteSaltPermissions(mock sqlmock.Sqlmock, permissions string) {
 		WillReturnRows(sqlmock.NewRows([]string{"salt_permissions"}).AddRow(permissions))
 }
 
+func expectRouteCapabilities(mock sqlmock.Sqlmock, capabilities ...string) {
+	rows := sqlmock.NewRows([]string{"capability"})
+	for _, capability := range capabilities {
+		rows.AddRow(capability)
+	}
+	mock.ExpectQuery(`SELECT DISTINCT rc.capability FROM rbac_role_capability rc`).
+		WithArgs(uint(7)).
+		WillReturnRows(rows)
+}
+
 func expectMissingSaltKeysTable(mock sqlmock.Sqlmock) {
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM information_schema.tables WHERE table_schema = CURRENT_SCHEMA() AND table_name = $1 AND table_type = $2`)).
 		WithArgs("salt_keys", "BASE TABLE").
@@ -261,6 +337,8 @@ func signedRouteAuthorizationToken(t *testing.T) string {
 		"username": routeAuthorizationUsername,
 		"user_id":  7,
 		"exp":      4102444800,
+		"jti":      "route-test-access",
+		"sid":      "route-test-family",
 	})
 	signed, err := token.SignedString([]byte(routeAuthorizationSecret))
 	require.NoError(t, err)
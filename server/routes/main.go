@@ -15,7 +15,6 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
-	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -30,46 +29,78 @@ import (
 	"github.com/PaulChristophel/agartha/server/api/v1/saltKeys"
 	"github.com/PaulChristophel/agartha/server/api/v1/saltMinion"
 	"github.com/PaulChristophel/agartha/server/api/v1/saltReturn"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/apiToken"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/auditLog"
 	"github.com/PaulChristophel/agartha/server/api/v1/secure/authUser"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/group"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/loginFailure"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/permission"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/role"
+	"github.com/PaulChristophel/agartha/server/api/v1/secure/session"
 	"github.com/PaulChristophel/agartha/server/api/v1/secure/userSettings"
 	"github.com/PaulChristophel/agartha/server/api/v1/validate"
 
 	v2SaltCache "github.com/PaulChristophel/agartha/server/api/v2/saltCache"
 
 	// saltCachev2 "github.com/PaulChristophel/agartha/server/api/v2/saltCache"
+	"github.com/PaulChristophel/agartha/server/audit"
 	"github.com/PaulChristophel/agartha/server/auth"
 	"github.com/PaulChristophel/agartha/server/config"
 	"github.com/PaulChristophel/agartha/server/db"
 	docsV1 "github.com/PaulChristophel/agartha/server/docs/v1"
+	"github.com/PaulChristophel/agartha/server/jobstate"
 	"github.com/PaulChristophel/agartha/server/logger"
 	"github.com/PaulChristophel/agartha/server/middleware"
+	model "github.com/PaulChristophel/agartha/server/model/agartha"
+	"github.com/PaulChristophel/agartha/server/notify"
+	"github.com/PaulChristophel/agartha/server/redact"
 	gormsessions "github.com/gin-contrib/sessions/gorm"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
 var (
-	router       *gin.Engine
-	saltDBTables config.SaltDBTables
-	options      config.HTTPOptions
-	ldapOptions  config.LDAPOptions
-	casOptions   config.CASOptions
-	saltOptions  config.SaltOptions
-	authMethods  []string
-	log          *zap.Logger
+	router          *gin.Engine
+	saltDBTables    config.SaltDBTables
+	options         config.HTTPOptions
+	ldapOptions     config.LDAPOptions
+	casOptions      config.CASOptions
+	samlOptions     config.SAMLOptions
+	oidcOptions     config.OIDCOptions
+	certOptions     config.CertOptions
+	proxyOptions    config.ProxyOptions
+	saltOptions     config.SaltOptions
+	passwordOptions config.PasswordOptions
+	notifier        notify.Notifier
+	mfaOptions      config.MFAOptions
+	tokenOptions    config.TokenOptions
+	lockoutOptions  config.LockoutOptions
+	authMethods     []string
+	authProviders   []config.AuthProviderOptions
+	log             *zap.Logger
 )
 
 func Router(frontend embed.FS, agarthaOptions config.Config) error {
 	options = agarthaOptions.HTTP
 	ldapOptions = agarthaOptions.LDAP
 	casOptions = agarthaOptions.CAS
+	samlOptions = agarthaOptions.SAML
+	oidcOptions = agarthaOptions.OIDC
+	certOptions = agarthaOptions.Cert
+	proxyOptions = agarthaOptions.Proxy
 	saltOptions = agarthaOptions.Salt
+	passwordOptions = agarthaOptions.Password
+	notifier = notify.New(agarthaOptions.SMTP)
+	mfaOptions = agarthaOptions.MFA
+	tokenOptions = agarthaOptions.Token
+	lockoutOptions = agarthaOptions.Lockout
 	saltDBTables = agarthaOptions.DB.Tables
 	var err error
 	authMethods, err = agarthaOptions.EffectiveAuthMethods()
 	if err != nil {
 		return err
 	}
+	authProviders = agarthaOptions.Auth.Providers
 
 	docsV1.SwaggerInfo.BasePath = "/"
 
@@ -117,11 +148,24 @@ func Router(frontend embed.FS, agarthaOptions config.Config) error {
 	router.UseRawPath = true
 	router.UnescapePathValues = false
 
+	if err := audit.Configure(db.DB, agarthaOptions.Audit); err != nil {
+		return fmt.Errorf("configure audit log: %w", err)
+	}
+	defer func() {
+		if err := audit.Close(); err != nil {
+			log.Error("Failed to close audit sinks", zap.Error(err))
+		}
+	}()
+
+	if err := redact.Configure(agarthaOptions.Redaction); err != nil {
+		return fmt.Errorf("configure redaction: %w", err)
+	}
+
 	// This is the server backend API
 	store := gormsessions.NewStore(db.DB, true, []byte(options.Secret))
 	store.Options(sessions.Options{
 		Path:     "/",
-		MaxAge:   int((8 * time.Hour).Seconds()),
+		MaxAge:   int(tokenOptions.RefreshTokenTTL.Seconds()),
 		HttpOnly: true,
 		Secure:   options.CookieSecure,
 		SameSite: http.SameSiteLaxMode,
@@ -159,6 +203,7 @@ func Router(frontend embed.FS, agarthaOptions config.Config) error {
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
+	go jobstate.Run(ctx, db.DB, saltDBTables, agarthaOptions.JobState)
 	return serveHTTP(ctx, srv, options)
 }
 
@@ -220,47 +265,66 @@ func addServerRoutes(router *gin.Engine) {
 	AddVersionRoutes(rootRoute)
 
 	authRoute := router.Group("/auth")
-	auth.SetOptions([]byte(options.Secret), authMethods, ldapOptions, casOptions, options.CookieSecure)
+	auth.SetOptions([]byte(options.Secret), authMethods, authProviders, ldapOptions, casOptions, samlOptions, oidcOptions, options.CookieSecure)
+	auth.SetPasswordOptions(passwordOptions, notifier)
+	auth.SetMFAOptions(mfaOptions)
+	auth.SetCertOptions(certOptions)
+	auth.SetProxyOptions(proxyOptions, options.TrustedProxies)
+	auth.SetTokenOptions(tokenOptions)
+	auth.SetLockoutOptions(lockoutOptions)
 	auth.AddRoutes(authRoute)
+	auth.AddCASServiceRoute(router)
 	auth.AddSessionRoutes(authRoute.Group(
 		"",
-		middleware.AuthRequired([]byte(options.Secret)),
+		middleware.AuthRequired([]byte(options.Secret), db.DB),
 		middleware.ActiveUserRequired(db.DB),
 	))
 
 	grpV1 := router.Group(
 		"/api/v1",
-		middleware.AuthRequired([]byte(options.Secret)),
+		middleware.AuthRequired([]byte(options.Secret), db.DB),
 		middleware.ActiveUserRequired(db.DB),
 	)
 	netapi.Handler(grpV1, saltOptions.URL, db.DB)
-	saltOperational := grpV1.Group("", middleware.SaltPermissionForMethodRequired(db.DB))
-	conformity.AddRoutes(saltOperational)
+	saltOperational := middleware.SaltPermissionForMethodRequired(db.DB)
+	viewReturns := grpV1.Group("", middleware.CapabilityRequired(db.DB, model.CapabilityViewReturns, saltOperational))
+	conformity.AddRoutes(grpV1, db.DB)
 	jid.SetOptions(saltDBTables)
-	jid.AddRoutes(saltOperational)
+	jid.AddRoutes(viewReturns)
 	saltCache.SetOptions(saltDBTables)
-	saltCache.AddRoutes(saltOperational)
+	saltCache.AddRoutes(grpV1, db.DB)
 	saltKeys.SetOptions(saltDBTables)
 	saltKeys.AddRoutes(grpV1, db.DB)
-	saltMinion.AddRoutes(saltOperational)
+	saltMinion.AddRoutes(grpV1, db.DB)
 	saltEvent.SetOptions(saltDBTables)
-	saltEvent.AddRoutes(saltOperational)
-	highState.AddRoutes(saltOperational)
+	saltEvent.AddRoutes(viewReturns)
+	highState.SetOptions(saltDBTables)
+	highState.AddRoutes(viewReturns)
 	saltReturn.SetOptions(saltDBTables)
-	saltReturn.AddRoutes(saltOperational)
+	saltReturn.AddRoutes(viewReturns)
 	validate.AddRoutes(grpV1)
 
 	grpV1secure := grpV1.Group("/secure", middleware.UniqueAuthRequired())
 	authUser.AddRoutes(grpV1secure)
 	userSettings.AddRoutes(grpV1secure)
+	userAdministration := grpV1.Group("/secure", middleware.CapabilityRequired(db.DB, model.CapabilityAdminUsers, middleware.AdministrationRequired()))
+	authUser.AddAdminRoutes(userAdministration.Group("", middleware.SuperuserAccountProtected(db.DB)))
+	loginFailure.AddAdminRoutes(userAdministration)
+	auditLog.AddAdminRoutes(grpV1.Group("/secure", middleware.AdministrationRequired()))
+	role.AddAdminRoutes(grpV1.Group("/secure", middleware.AdministrationRequired()))
+	group.AddAdminRoutes(grpV1.Group("/secure", middleware.AdministrationRequired()))
+	permission.AddAdminRoutes(grpV1.Group("/secure", middleware.AdministrationRequired()))
+	permission.AddRoutes(grpV1.Group("/secure"))
+	apiToken.AddRoutes(grpV1.Group("/secure"))
+	session.AddRoutes(grpV1.Group("/secure"))
 
 	grpV2 := router.Group(
 		"/api/v2",
-		middleware.AuthRequired([]byte(options.Secret)),
+		middleware.AuthRequired([]byte(options.Secret), db.DB),
 		middleware.ActiveUserRequired(db.DB),
 	)
 	v2SaltCache.SetOptions(saltDBTables)
-	v2SaltCache.AddRoutes(grpV2.Group("", middleware.SaltPermissionForMethodRequired(db.DB)))
+	v2SaltCache.AddRoutes(grpV2.Group("", middleware.CapabilityRequired(db.DB, model.CapabilityViewPillar, middleware.SaltPermissionForMethodRequired(db.DB))))
 
 }
 
@@ -0,0 +1,316 @@
+package saltacl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Salt clients understood by Policy.Evaluate.
+const (
+	ClientLocal  = "local"
+	ClientRunner = "runner"
+	ClientWheel  = "wheel"
+)
+
+// Target types understood by ParseTargetType.
+const (
+	TargetGlob     = "glob"
+	TargetList     = "list"
+	TargetPCRE     = "pcre"
+	TargetCompound = "compound"
+)
+
+// Request is a Salt command to evaluate.
+type Request struct {
+	// Client is local, runner or wheel. Variants such as local_async are
+	// treated as their base client.
+	Client     string
+	Fun        string
+	Target     string
+	TargetType string
+	Args       []any
+	Kwargs     map[string]any
+}
+
+// Decision is the outcome of evaluating a Request.
+type Decision struct {
+	Allowed bool   `json:"allowed" example:"true"`
+	Reason  string `json:"reason" example:"web* grants test.ping"`
+	// Minions lists the known minions a local command targets.
+	Minions []string `json:"minions,omitempty"`
+}
+
+// Policy is a Salt eauth ACL as returned by Salt login.
+type Policy struct {
+	entries []any
+}
+
+// NewPolicy wraps decoded Salt permissions. A top-level object is treated as
+// a list of single-key objects.
+func NewPolicy(permissions any) Policy {
+	var entries []any
+	switch permission := permissions.(type) {
+	case []any:
+		entries = permission
+	case map[string]any:
+		keys := make([]string, 0, len(permission))
+		for key := range permission {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entries = append(entries, map[string]any{key: permission[key]})
+		}
+	case string:
+		entries = []any{permission}
+	}
+	return Policy{entries: entries}
+}
+
+// Evaluate reports whether the policy lets req run, the way Salt's eauth
+// checks would. Function and argument expressions are regular expressions
+// anchored at the start, as with Python's re.match. A local command is
+// allowed by a targeted grant when every known minion it targets is also
+// targeted by the grant; minions lists the known minion IDs. Targets that
+// cannot be resolved offline never grant access.
+func (p Policy) Evaluate(req Request, minions []string) (Decision, error) {
+	client, _, _ := strings.Cut(req.Client, "_")
+	switch client {
+	case "", ClientLocal:
+		return p.evaluateLocal(req, minions)
+	case ClientRunner, ClientWheel:
+		return p.evaluateSpec(client, req)
+	}
+	return Decision{}, fmt.Errorf("unsupported client %q", req.Client)
+}
+
+func (p Policy) evaluateLocal(req Request, minions []string) (Decision, error) {
+	target, err := ParseTargetType(req.Target, req.TargetType)
+	if err != nil {
+		return Decision{}, err
+	}
+	decision := Decision{Minions: []string{}}
+	for _, id := range minions {
+		if target.Match(id) {
+			decision.Minions = append(decision.Minions, id)
+		}
+	}
+
+	for _, entry := range p.entries {
+		switch grant := entry.(type) {
+		case string:
+			if !strings.HasPrefix(grant, "@") && matchExpression(grant, req.Fun) {
+				decision.Allowed = true
+				decision.Reason = fmt.Sprintf("%s grants %s on every minion", grant, req.Fun)
+				return decision, nil
+			}
+		case map[string]any:
+			key, functions, ok := singleKey(grant)
+			if !ok || strings.HasPrefix(key, "@") {
+				continue
+			}
+			allowedTarget, err := ParseTarget(key)
+			if err != nil || !coversMinions(allowedTarget, decision.Minions) {
+				continue
+			}
+			if checkFunction(functions, req.Fun, req.Args, req.Kwargs) {
+				decision.Allowed = true
+				decision.Reason = fmt.Sprintf("%s grants %s", key, req.Fun)
+				return decision, nil
+			}
+		}
+	}
+	decision.Reason = fmt.Sprintf("no grant allows %s on the targeted minions", req.Fun)
+	return decision, nil
+}
+
+// evaluateSpec mirrors Salt's check for runner and wheel functions, where
+// @runner and @wheel name the client and any other @ entry names a module,
+// such as @jobs for the jobs runner.
+func (p Policy) evaluateSpec(client string, req Request) (Decision, error) {
+	module, function, ok := strings.Cut(req.Fun, ".")
+	if !ok || module == "" || function == "" || strings.Contains(function, ".") {
+		return Decision{}, fmt.Errorf("%s function %q must be in the form module.function", client, req.Fun)
+	}
+
+	for _, entry := range p.entries {
+		switch grant := entry.(type) {
+		case string:
+			if grant == "@"+module || grant == "@"+client || grant == "@"+client+"s" {
+				return Decision{Allowed: true, Reason: fmt.Sprintf("%s grants %s", grant, req.Fun)}, nil
+			}
+		case map[string]any:
+			key, functions, ok := singleKey(grant)
+			if !ok {
+				continue
+			}
+			if key == "@"+module && checkFunction(functions, function, req.Args, req.Kwargs) {
+				return Decision{Allowed: true, Reason: fmt.Sprintf("%s grants %s", key, req.Fun)}, nil
+			}
+			if (key == "@"+client || key == "@"+client+"s") && checkFunction(functions, req.Fun, req.Args, req.Kwargs) {
+				return Decision{Allowed: true, Reason: fmt.Sprintf("%s grants %s", key, req.Fun)}, nil
+			}
+		}
+	}
+	return Decision{Reason: fmt.Sprintf("no @%s or @%s grant allows %s", client, module, req.Fun)}, nil
+}
+
+// ParseTargetType parses a target of the given Salt target type. An empty
+// type is a glob.
+func ParseTargetType(expression, targetType string) (Target, error) {
+	if strings.TrimSpace(expression) == "" {
+		return Target{}, fmt.Errorf("empty target")
+	}
+	var root targetNode
+	var err error
+	switch targetType {
+	case "", TargetGlob:
+		root, err = globNode(expression)
+	case TargetList:
+		root, err = parseMatcher("L@" + expression)
+	case TargetPCRE:
+		root, err = parseMatcher("E@" + expression)
+	case TargetCompound:
+		return ParseTarget(expression)
+	default:
+		return Target{}, fmt.Errorf("%w: target type %s", ErrUnresolvableTarget, targetType)
+	}
+	if err != nil {
+		return Target{}, err
+	}
+	return Target{expression: expression, root: root}, nil
+}
+
+func coversMinions(target Target, minions []string) bool {
+	for _, id := range minions {
+		if !target.Match(id) {
+			return false
+		}
+	}
+	return true
+}
+
+func singleKey(grant map[string]any) (string, any, bool) {
+	if len(grant) != 1 {
+		return "", nil, false
+	}
+	for key, value := range grant {
+		return key, value, true
+	}
+	return "", nil, false
+}
+
+// checkFunction matches a function against a grant's list of function
+// expressions, each either a string or a single-key object whose value
+// constrains the arguments.
+func checkFunction(functions any, fun string, args []any, kwargs map[string]any) bool {
+	conditions, ok := functions.([]any)
+	if !ok {
+		conditions = []any{functions}
+	}
+	for _, condition := range conditions {
+		switch grant := condition.(type) {
+		case string:
+			if matchExpression(grant, fun) {
+				return true
+			}
+		case map[string]any:
+			expression, constraints, ok := singleKey(grant)
+			if ok && matchExpression(expression, fun) && checkArguments(constraints, args, kwargs) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkArguments matches arguments against {"args": [...], "kwargs": {...}}
+// constraints, or a list of them. A null expression allows any value, but
+// the argument must be present.
+func checkArguments(constraints any, args []any, kwargs map[string]any) bool {
+	alternatives, ok := constraints.([]any)
+	if !ok {
+		alternatives = []any{constraints}
+	}
+	for _, alternative := range alternatives {
+		condition, ok := alternative.(map[string]any)
+		if !ok {
+			continue
+		}
+		if matchArguments(condition, args, kwargs) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchArguments(condition map[string]any, args []any, kwargs map[string]any) bool {
+	if positional, ok := condition["args"].([]any); ok {
+		for i, expression := range positional {
+			if i >= len(args) {
+				return false
+			}
+			if expression == nil {
+				continue
+			}
+			pattern, ok := expression.(string)
+			if !ok || !matchExpression(pattern, pythonString(args[i])) {
+				return false
+			}
+		}
+	}
+	if named, ok := condition["kwargs"].(map[string]any); ok {
+		for name, expression := range named {
+			value, present := kwargs[name]
+			if !present {
+				return false
+			}
+			if expression == nil {
+				continue
+			}
+			pattern, ok := expression.(string)
+			if !ok || !matchExpression(pattern, pythonString(value)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchExpression applies a Salt ACL expression the way Python's re.match
+// does. Expressions Go cannot compile never match.
+func matchExpression(expression, value string) bool {
+	compiled, err := regexp.Compile("^(?:" + expression + ")")
+	return err == nil && compiled.MatchString(value)
+}
+
+// pythonString renders a decoded JSON scalar as Python's str would, since
+// Salt compares arguments after converting them to strings. Lists and
+// objects are rendered as JSON.
+func pythonString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return "None"
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case float64:
+		if v == float64(int64(v)) {
+			return strconv.FormatInt(int64(v), 10)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
@@ -0,0 +1,97 @@
+package saltacl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func decodePolicy(t *testing.T, document string) Policy {
+	t.Helper()
+	var permissions any
+	require.NoError(t, json.Unmarshal([]byte(document), &permissions))
+	return NewPolicy(permissions)
+}
+
+func TestPolicyEvaluateLocal(t *testing.T) {
+	minions := []string{"web01", "web02", "db01"}
+	policy := decodePolicy(t, `[
+		"test.ping",
+		{"web*": ["state.*", {"cmd.run": {"args": ["systemctl status .*"]}}]},
+		{"L@db01": [{"pkg.install": {"kwargs": {"name": "^(vim|htop)$", "refresh": null}}}]},
+		{"G@role:web": ["cmd.run"]}
+	]`)
+
+	tests := []struct {
+		name        string
+		request     Request
+		wantAllowed bool
+		wantMinions []string
+	}{
+		{name: "untargeted function grant", request: Request{Fun: "test.ping", Target: "*"}, wantAllowed: true, wantMinions: minions},
+		{name: "function is matched from the start", request: Request{Fun: "test.ping_all", Target: "*"}, wantAllowed: true, wantMinions: minions},
+		{name: "targeted grant", request: Request{Client: "local_async", Fun: "state.apply", Target: "web0?"}, wantAllowed: true, wantMinions: []string{"web01", "web02"}},
+		{name: "target outside grant", request: Request{Fun: "state.apply", Target: "*"}, wantMinions: minions},
+		{name: "list target inside grant", request: Request{Fun: "state.apply", Target: "web01,web02", TargetType: TargetList}, wantAllowed: true, wantMinions: []string{"web01", "web02"}},
+		{name: "compound target inside grant", request: Request{Fun: "state.apply", Target: "* and not db01", TargetType: TargetCompound}, wantAllowed: true, wantMinions: []string{"web01", "web02"}},
+		{name: "matching positional argument", request: Request{Fun: "cmd.run", Target: "web01", Args: []any{"systemctl status nginx"}}, wantAllowed: true, wantMinions: []string{"web01"}},
+		{name: "other positional argument", request: Request{Fun: "cmd.run", Target: "web01", Args: []any{"rm -rf /"}}, wantMinions: []string{"web01"}},
+		{name: "missing positional argument", request: Request{Fun: "cmd.run", Target: "web01"}, wantMinions: []string{"web01"}},
+		{name: "matching keyword arguments", request: Request{Fun: "pkg.install", Target: "db01", Kwargs: map[string]any{"name": "vim", "refresh": true}}, wantAllowed: true, wantMinions: []string{"db01"}},
+		{name: "keyword argument outside constraint", request: Request{Fun: "pkg.install", Target: "db01", Kwargs: map[string]any{"name": "nmap", "refresh": true}}, wantMinions: []string{"db01"}},
+		{name: "missing unconstrained keyword argument", request: Request{Fun: "pkg.install", Target: "db01", Kwargs: map[string]any{"name": "vim"}}, wantMinions: []string{"db01"}},
+		{name: "unresolvable grant target", request: Request{Fun: "cmd.run", Target: "web01", Args: []any{"uptime"}}, wantMinions: []string{"web01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := policy.Evaluate(tt.request, minions)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAllowed, decision.Allowed, decision.Reason)
+			require.Equal(t, tt.wantMinions, decision.Minions)
+		})
+	}
+}
+
+func TestPolicyEvaluateRunnerAndWheel(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		request     Request
+		wantAllowed bool
+	}{
+		{name: "bare wheel", policy: `["@wheel"]`, request: Request{Client: ClientWheel, Fun: "key.accept"}, wantAllowed: true},
+		{name: "bare wheel does not grant runners", policy: `["@wheel"]`, request: Request{Client: ClientRunner, Fun: "jobs.list_jobs"}},
+		{name: "bare jobs module", policy: `["@jobs"]`, request: Request{Client: ClientRunner, Fun: "jobs.lookup_jid"}, wantAllowed: true},
+		{name: "scoped module", policy: `[{"@jobs": ["list_.*"]}]`, request: Request{Client: ClientRunner, Fun: "jobs.list_jobs"}, wantAllowed: true},
+		{name: "scoped module other function", policy: `[{"@jobs": ["list_.*"]}]`, request: Request{Client: ClientRunner, Fun: "jobs.lookup_jid"}},
+		{name: "scoped client", policy: `{"@wheel": ["key.list_all"]}`, request: Request{Client: ClientWheel, Fun: "key.list_all"}, wantAllowed: true},
+		{name: "scoped client other function", policy: `{"@wheel": ["key.list_all"]}`, request: Request{Client: ClientWheel, Fun: "key.delete"}},
+		{name: "argument constraint", policy: `[{"@runners": [{"state.orch": {"args": ["orch\\.deploy"]}}]}]`, request: Request{Client: "runner_async", Fun: "state.orch", Args: []any{"orch.deploy"}}, wantAllowed: true},
+		{name: "argument outside constraint", policy: `[{"@runners": [{"state.orch": {"args": ["orch\\.deploy"]}}]}]`, request: Request{Client: ClientRunner, Fun: "state.orch", Args: []any{"orch.destroy"}}},
+		{name: "minion function grant", policy: `[".*"]`, request: Request{Client: ClientWheel, Fun: "key.accept"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := decodePolicy(t, tt.policy).Evaluate(tt.request, nil)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAllowed, decision.Allowed, decision.Reason)
+		})
+	}
+}
+
+func TestPolicyEvaluateRejectsMalformedRequests(t *testing.T) {
+	policy := decodePolicy(t, `[".*"]`)
+	for _, request := range []Request{
+		{Client: "ssh", Fun: "test.ping", Target: "*"},
+		{Fun: "test.ping"},
+		{Fun: "test.ping", Target: "G@os:Debian", TargetType: TargetCompound},
+		{Fun: "test.ping", Target: "os:Debian", TargetType: "grain"},
+		{Client: ClientRunner, Fun: "jobs"},
+	} {
+		_, err := policy.Evaluate(request, nil)
+		require.Error(t, err, request)
+	}
+}
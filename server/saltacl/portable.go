@@ -0,0 +1,204 @@
+package saltacl
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxPortableRepeat is the largest bound PostgreSQL accepts in {m,n}.
+const maxPortableRepeat = 255
+
+// portablePattern rewrites a Go regular expression into an equivalent one
+// that PostgreSQL's ~ operator reads the same way, so that Target.Match and
+// Target.SQL agree on every minion ID. Escapes such as \d, case folding and
+// named groups are spelled out as plain classes and groups. Constructs whose
+// meaning differs between the two engines, like word boundaries, multi-line
+// anchors and control characters without a shared escape, are rejected.
+func portablePattern(pattern string) (string, error) {
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	if err := writePortable(&out, parsed); err != nil {
+		return "", fmt.Errorf("%s: %w", pattern, err)
+	}
+	return out.String(), nil
+}
+
+func writePortable(out *strings.Builder, re *syntax.Regexp) error {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		out.WriteString("(?:)")
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if err := writePortableLiteral(out, r, re.Flags&syntax.FoldCase != 0); err != nil {
+				return err
+			}
+		}
+	case syntax.OpCharClass:
+		return writePortableClass(out, re.Rune)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		// Minion IDs never contain newlines, so both spellings of . agree.
+		out.WriteString(".")
+	case syntax.OpBeginText:
+		out.WriteString("^")
+	case syntax.OpEndText:
+		out.WriteString("$")
+	case syntax.OpCapture:
+		out.WriteString("(")
+		if err := writePortable(out, re.Sub[0]); err != nil {
+			return err
+		}
+		out.WriteString(")")
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest:
+		if err := writePortableAtom(out, re.Sub[0]); err != nil {
+			return err
+		}
+		out.WriteString(map[syntax.Op]string{syntax.OpStar: "*", syntax.OpPlus: "+", syntax.OpQuest: "?"}[re.Op])
+	case syntax.OpRepeat:
+		if re.Min > maxPortableRepeat || re.Max > maxPortableRepeat {
+			return fmt.Errorf("repetition bounds above %d are not supported", maxPortableRepeat)
+		}
+		if err := writePortableAtom(out, re.Sub[0]); err != nil {
+			return err
+		}
+		switch {
+		case re.Max == -1:
+			fmt.Fprintf(out, "{%d,}", re.Min)
+		case re.Min == re.Max:
+			fmt.Fprintf(out, "{%d}", re.Min)
+		default:
+			fmt.Fprintf(out, "{%d,%d}", re.Min, re.Max)
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpAlternate {
+				if err := writePortableGroup(out, sub); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := writePortable(out, sub); err != nil {
+				return err
+			}
+		}
+	case syntax.OpAlternate:
+		for i, sub := range re.Sub {
+			if i > 0 {
+				out.WriteString("|")
+			}
+			if err := writePortable(out, sub); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s is not supported in minion targets", re.Op)
+	}
+	return nil
+}
+
+// writePortableAtom writes the operand of a repetition, grouping anything
+// longer than a single character.
+func writePortableAtom(out *strings.Builder, re *syntax.Regexp) error {
+	switch {
+	case re.Op == syntax.OpCharClass, re.Op == syntax.OpAnyChar, re.Op == syntax.OpAnyCharNotNL, re.Op == syntax.OpCapture:
+		return writePortable(out, re)
+	case re.Op == syntax.OpLiteral && len(re.Rune) == 1:
+		return writePortable(out, re)
+	}
+	return writePortableGroup(out, re)
+}
+
+func writePortableGroup(out *strings.Builder, re *syntax.Regexp) error {
+	out.WriteString("(?:")
+	if err := writePortable(out, re); err != nil {
+		return err
+	}
+	out.WriteString(")")
+	return nil
+}
+
+func writePortableLiteral(out *strings.Builder, r rune, foldCase bool) error {
+	if foldCase {
+		folds := []rune{r}
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			folds = append(folds, f)
+		}
+		if len(folds) > 1 {
+			out.WriteString("[")
+			for _, f := range folds {
+				if err := writePortableRune(out, f); err != nil {
+					return err
+				}
+			}
+			out.WriteString("]")
+			return nil
+		}
+	}
+	return writePortableRune(out, r)
+}
+
+// writePortableClass writes a character class given as sorted ranges. Classes
+// reaching both ends of the code space are written negated so that NUL and
+// the highest code point never have to be spelled out.
+func writePortableClass(out *strings.Builder, ranges []rune) error {
+	if len(ranges) == 0 {
+		return fmt.Errorf("empty character classes are not supported")
+	}
+	if ranges[0] == 0 && ranges[len(ranges)-1] == unicode.MaxRune {
+		if len(ranges) == 2 {
+			// A class such as [\s\S] matches any character.
+			out.WriteString(".")
+			return nil
+		}
+		var negated []rune
+		for i := 1; i < len(ranges)-1; i += 2 {
+			negated = append(negated, ranges[i]+1, ranges[i+1]-1)
+		}
+		out.WriteString("[^")
+		ranges = negated
+	} else {
+		out.WriteString("[")
+	}
+	for i := 0; i < len(ranges); i += 2 {
+		if err := writePortableRune(out, ranges[i]); err != nil {
+			return err
+		}
+		if ranges[i+1] != ranges[i] {
+			out.WriteString("-")
+			if err := writePortableRune(out, ranges[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	out.WriteString("]")
+	return nil
+}
+
+// portableEscapes are the control characters both engines accept as escapes,
+// inside and outside character classes.
+var portableEscapes = map[rune]string{'\a': `\a`, '\t': `\t`, '\n': `\n`, '\v': `\v`, '\f': `\f`, '\r': `\r`}
+
+// writePortableRune writes one character. ASCII punctuation is escaped, which
+// both engines read as the literal character; letters, digits and non-ASCII
+// characters are written as they are.
+func writePortableRune(out *strings.Builder, r rune) error {
+	switch {
+	case r < 0x20 || r == 0x7f:
+		escape, ok := portableEscapes[r]
+		if !ok {
+			return fmt.Errorf("control character %s is not supported", strconv.QuoteRune(r))
+		}
+		out.WriteString(escape)
+	case r < 0x80 && !unicode.IsLetter(r) && !unicode.IsDigit(r):
+		out.WriteByte('\\')
+		out.WriteRune(r)
+	default:
+		out.WriteRune(r)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package saltacl
+
+import (
+	"sort"
+	"strings"
+)
+
+// Scope is the set of minions whose data a user may read.
+type Scope struct {
+	all     bool
+	targets []Target
+}
+
+// Unrestricted returns a scope covering every minion.
+func Unrestricted() Scope {
+	return Scope{all: true}
+}
+
+// MinionScope derives the readable minions from decoded Salt eauth
+// permissions. Function grants without a target and bare @wheel, @runner or
+// @jobs grants cover every minion; target-scoped grants cover the minions
+// their targets select. Targets that cannot be resolved from minion IDs alone
+// select nothing, so an unsupported matcher never widens access.
+func MinionScope(permissions any) Scope {
+	var scope Scope
+	scope.add(permissions)
+	if scope.all {
+		return Unrestricted()
+	}
+	return scope
+}
+
+func (s *Scope) add(value any) {
+	switch permission := value.(type) {
+	case string:
+		if strings.TrimSpace(permission) != "" {
+			s.all = true
+		}
+	case []any:
+		for _, item := range permission {
+			s.add(item)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(permission))
+		for key := range permission {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !grantsSomething(permission[key]) {
+				continue
+			}
+			if key == "@jobs" {
+				s.all = true
+				continue
+			}
+			if strings.HasPrefix(key, "@") {
+				continue
+			}
+			if target, err := ParseTarget(key); err == nil {
+				s.targets = append(s.targets, target)
+			}
+		}
+	}
+}
+
+func grantsSomething(value any) bool {
+	switch permission := value.(type) {
+	case string:
+		return strings.TrimSpace(permission) != ""
+	case []any:
+		for _, item := range permission {
+			if grantsSomething(item) {
+				return true
+			}
+		}
+	case map[string]any:
+		return len(permission) > 0
+	}
+	return false
+}
+
+// All reports whether the scope covers every minion.
+func (s Scope) All() bool {
+	return s.all
+}
+
+// Targets returns the targets of a restricted scope.
+func (s Scope) Targets() []Target {
+	return s.targets
+}
+
+// Match reports whether the scope covers the minion ID.
+func (s Scope) Match(id string) bool {
+	if s.all {
+		return true
+	}
+	for _, target := range s.targets {
+		if target.Match(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQL returns a condition selecting rows whose column holds a minion ID in
+// the scope, with its bind arguments.
+func (s Scope) SQL(column string) (string, []any) {
+	if s.all {
+		return "TRUE", nil
+	}
+	if len(s.targets) == 0 {
+		return "FALSE", nil
+	}
+	var conditions []string
+	var args []any
+	for _, target := range s.targets {
+		condition, targetArgs := target.SQL(column)
+		conditions = append(conditions, condition)
+		args = append(args, targetArgs...)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
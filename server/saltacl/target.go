@@ -0,0 +1,293 @@
+// Package saltacl evaluates Salt eauth ACLs without contacting Salt.
+package saltacl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnresolvableTarget is returned for targets that depend on minion data
+// Agartha cannot evaluate offline, such as grain, pillar or nodegroup matches.
+var ErrUnresolvableTarget = errors.New("target cannot be resolved from minion IDs")
+
+// Target is a parsed Salt target that matches on minion ID alone. Globs, L@
+// lists and E@ regular expressions are supported, combined with and, or, not
+// and whitespace-separated parentheses as in Salt compound targeting. Regular
+// expressions outside the dialect portablePattern accepts are unresolvable.
+type Target struct {
+	expression string
+	root       targetNode
+}
+
+type targetNode interface {
+	match(id string) bool
+	sql(column string) (string, []any)
+}
+
+// ParseTarget parses a Salt target expression.
+func ParseTarget(expression string) (Target, error) {
+	tokens := strings.Fields(expression)
+	if len(tokens) == 0 {
+		return Target{}, fmt.Errorf("empty target")
+	}
+	parser := targetParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err != nil {
+		return Target{}, err
+	}
+	if parser.position != len(tokens) {
+		return Target{}, fmt.Errorf("unexpected %q in target", tokens[parser.position])
+	}
+	return Target{expression: expression, root: root}, nil
+}
+
+// String returns the expression the target was parsed from.
+func (t Target) String() string {
+	return t.expression
+}
+
+// Match reports whether the minion ID is selected by the target.
+func (t Target) Match(id string) bool {
+	return t.root != nil && t.root.match(id)
+}
+
+// SQL returns a condition selecting rows whose column holds a matching minion
+// ID, with its bind arguments. Rows where the column is NULL never match.
+func (t Target) SQL(column string) (string, []any) {
+	if t.root == nil {
+		return "FALSE", nil
+	}
+	return t.root.sql(column)
+}
+
+type targetParser struct {
+	tokens   []string
+	position int
+}
+
+func (p *targetParser) next() (string, bool) {
+	if p.position >= len(p.tokens) {
+		return "", false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *targetParser) parseOr() (targetNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.next()
+		if !ok || token != "or" {
+			return left, nil
+		}
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+}
+
+func (p *targetParser) parseAnd() (targetNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, ok := p.next()
+		if !ok || token != "and" {
+			return left, nil
+		}
+		p.position++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *targetParser) parseNot() (targetNode, error) {
+	token, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("target ends unexpectedly")
+	}
+	if token != "not" {
+		return p.parseTerm()
+	}
+	p.position++
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return notNode{operand}, nil
+}
+
+func (p *targetParser) parseTerm() (targetNode, error) {
+	token, _ := p.next()
+	p.position++
+	switch token {
+	case "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.next(); !ok || closing != ")" {
+			return nil, fmt.Errorf("unbalanced parentheses in target")
+		}
+		p.position++
+		return node, nil
+	case ")", "and", "or":
+		return nil, fmt.Errorf("unexpected %q in target", token)
+	}
+	return parseMatcher(token)
+}
+
+func parseMatcher(term string) (targetNode, error) {
+	if len(term) < 2 || term[1] != '@' || term[0] < 'A' || term[0] > 'Z' {
+		return globNode(term)
+	}
+	pattern := term[2:]
+	switch term[0] {
+	case 'L':
+		var ids []string
+		for _, id := range strings.Split(pattern, ",") {
+			if id != "" {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("empty list target")
+		}
+		return listNode(ids), nil
+	case 'E':
+		portable, err := portablePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnresolvableTarget, err)
+		}
+		return newRegexNode("^(?:" + portable + ")")
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnresolvableTarget, term)
+}
+
+// globNode translates a shell-style glob into an anchored regular expression
+// understood by both Go and PostgreSQL.
+func globNode(glob string) (targetNode, error) {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			pattern.WriteString(".*")
+		case '?':
+			pattern.WriteString(".")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 1 {
+				pattern.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			pattern.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern.WriteString("$")
+
+	portable, err := portablePattern(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	return newRegexNode(portable)
+}
+
+// regexNode matches minion IDs against a pattern written by portablePattern,
+// so that Go and PostgreSQL evaluate it alike.
+type regexNode struct {
+	expression *regexp.Regexp
+	pattern    string
+}
+
+func newRegexNode(pattern string) (targetNode, error) {
+	expression, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return regexNode{expression: expression, pattern: pattern}, nil
+}
+
+func (n regexNode) match(id string) bool {
+	return n.expression.MatchString(id)
+}
+
+func (n regexNode) sql(column string) (string, []any) {
+	return column + " ~ ?", []any{n.pattern}
+}
+
+type listNode []string
+
+func (n listNode) match(id string) bool {
+	for _, item := range n {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (n listNode) sql(column string) (string, []any) {
+	return column + " IN ?", []any{[]string(n)}
+}
+
+type notNode struct {
+	operand targetNode
+}
+
+func (n notNode) match(id string) bool {
+	return !n.operand.match(id)
+}
+
+func (n notNode) sql(column string) (string, []any) {
+	condition, args := n.operand.sql(column)
+	return "NOT (" + condition + ")", args
+}
+
+type andNode struct {
+	left, right targetNode
+}
+
+func (n andNode) match(id string) bool {
+	return n.left.match(id) && n.right.match(id)
+}
+
+func (n andNode) sql(column string) (string, []any) {
+	return joinSQL(column, "AND", n.left, n.right)
+}
+
+type orNode struct {
+	left, right targetNode
+}
+
+func (n orNode) match(id string) bool {
+	return n.left.match(id) || n.right.match(id)
+}
+
+func (n orNode) sql(column string) (string, []any) {
+	return joinSQL(column, "OR", n.left, n.right)
+}
+
+func joinSQL(column, operator string, left, right targetNode) (string, []any) {
+	leftCondition, leftArgs := left.sql(column)
+	rightCondition, rightArgs := right.sql(column)
+	return "(" + leftCondition + " " + operator + " " + rightCondition + ")", append(leftArgs, rightArgs...)
+}
@@ -0,0 +1,124 @@
+package saltacl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTargetMatch(t *testing.T) {
+	tests := []struct {
+		target  string
+		match   []string
+		noMatch []string
+	}{
+		{target: "*", match: []string{"web01", "db01"}},
+		{target: "web*", match: []string{"web01", "web.example.com"}, noMatch: []string{"db01", "aweb01"}},
+		{target: "web0?", match: []string{"web01"}, noMatch: []string{"web010"}},
+		{target: "web[12]", match: []string{"web1", "web2"}, noMatch: []string{"web3"}},
+		{target: "web[!12]", match: []string{"web3"}, noMatch: []string{"web1"}},
+		{target: "db.example.com", match: []string{"db.example.com"}, noMatch: []string{"dbxexample.com"}},
+		{target: "L@db1,db2", match: []string{"db1", "db2"}, noMatch: []string{"db3", "db"}},
+		{target: "E@web\\d+", match: []string{"web12", "web1.example.com"}, noMatch: []string{"aweb1"}},
+		{target: "web* and not web02", match: []string{"web01"}, noMatch: []string{"web02", "db01"}},
+		{target: "db* or web* and not web02", match: []string{"db01", "web01"}, noMatch: []string{"web02"}},
+		{target: "( db* or web* ) and not *02", match: []string{"db01", "web01"}, noMatch: []string{"db02", "web02"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target, err := ParseTarget(tt.target)
+			require.NoError(t, err)
+			for _, id := range tt.match {
+				require.True(t, target.Match(id), id)
+			}
+			for _, id := range tt.noMatch {
+				require.False(t, target.Match(id), id)
+			}
+		})
+	}
+}
+
+func TestParseTargetRejectsUnresolvableAndMalformedTargets(t *testing.T) {
+	for _, target := range []string{"G@os:Debian", "web* and I@role:web", "N@webservers", "E@(?<=web)1"} {
+		_, err := ParseTarget(target)
+		require.ErrorIs(t, err, ErrUnresolvableTarget, target)
+	}
+	for _, target := range []string{"", "web* and", "( web*", "web* db*", "L@,"} {
+		_, err := ParseTarget(target)
+		require.Error(t, err, target)
+	}
+}
+
+func TestTargetSQL(t *testing.T) {
+	target, err := ParseTarget("not ( L@db1,db2 or web* )")
+	require.NoError(t, err)
+
+	condition, args := target.SQL("minion_id")
+	require.Equal(t, "NOT ((minion_id IN ? OR minion_id ~ ?))", condition)
+	require.Equal(t, []any{[]string{"db1", "db2"}, "^web.*$"}, args)
+}
+
+func TestRegularExpressionTargetsUseACommonDialect(t *testing.T) {
+	tests := []struct {
+		target string
+		sql    string
+	}{
+		{target: `E@web\d+`, sql: `^(?:web[0-9]+)`},
+		{target: `E@(?i)db\.prod`, sql: `^(?:[Dd][Bb]\.[Pp][Rr][Oo][Dd])`},
+		{target: `E@(?P<role>web|db)-\w{2,3}$`, sql: `^(?:(web|db)\-[0-9A-Z\_a-z]{2,3}$)`},
+		{target: `E@[^\s.]+`, sql: `^(?:[^\t-\n\f-\r\ \.]+)`},
+		{target: `web[!12]?`, sql: `^web[^1-2].$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			target, err := ParseTarget(tt.target)
+			require.NoError(t, err)
+			_, args := target.SQL("id")
+			require.Equal(t, []any{tt.sql}, args)
+		})
+	}
+
+	// Word boundaries, line anchors and Go-only escapes mean something else,
+	// or nothing, to PostgreSQL and would let the two checks disagree.
+	for _, target := range []string{`E@web\b`, `E@(?m)^web$`, `E@web\x00`, `E@web\B`, `E@web{1,300}`} {
+		_, err := ParseTarget(target)
+		require.ErrorIs(t, err, ErrUnresolvableTarget, target)
+	}
+}
+
+func TestMinionScope(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions any
+		all         bool
+		visible     []string
+		hidden      []string
+	}{
+		{name: "function grant", permissions: []any{"test.ping"}, all: true},
+		{name: "jobs runner", permissions: []any{"@jobs"}, all: true},
+		{name: "scoped jobs runner", permissions: map[string]any{"@jobs": []any{"jobs.lookup_jid"}}, all: true},
+		{name: "target grant", permissions: []any{map[string]any{"web*": []any{".*"}}}, visible: []string{"web01"}, hidden: []string{"db01"}},
+		{
+			name:        "several targets",
+			permissions: []any{map[string]any{"web*": []any{".*"}, "L@cache1": []any{"test.ping"}, "db*": []any{}}},
+			visible:     []string{"web01", "cache1"},
+			hidden:      []string{"db01", "cache2"},
+		},
+		{name: "scoped wheel grant", permissions: map[string]any{"@wheel": []any{"key.list_all"}}, hidden: []string{"web01"}},
+		{name: "grain target", permissions: map[string]any{"G@role:web": []any{".*"}}, hidden: []string{"web01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := MinionScope(tt.permissions)
+			require.Equal(t, tt.all, scope.All())
+			for _, id := range tt.visible {
+				require.True(t, scope.Match(id), id)
+			}
+			for _, id := range tt.hidden {
+				require.False(t, scope.Match(id), id)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+	// The RFC lists eight digit values; six digit codes are their last six digits.
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		code, err := Code(rfc6238Secret, Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidateAllowsConfiguredSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, err := Code(rfc6238Secret, Step(now)-1)
+	require.NoError(t, err)
+
+	step, ok := Validate(rfc6238Secret, previous, now, 1)
+	require.True(t, ok)
+	require.Equal(t, Step(now)-1, step)
+
+	_, ok = Validate(rfc6238Secret, previous, now, 0)
+	require.False(t, ok)
+	_, ok = Validate(rfc6238Secret, "12345", now, 1)
+	require.False(t, ok)
+	_, ok = Validate("not base32!", "123456", now, 1)
+	require.False(t, ok)
+}
+
+func TestGenerateSecretAndURL(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	parsed, err := url.Parse(URL("Agartha", "alice", secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/Agartha:alice", parsed.Path)
+	require.Equal(t, secret, parsed.Query().Get("secret"))
+	require.Equal(t, "Agartha", parsed.Query().Get("issuer"))
+}